@@ -1,8 +1,12 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"connectrpc.com/connect"
 	"github.com/database-playground/backend/gen/dbrunner/v1/dbrunnerv1connect"
+	"github.com/database-playground/backend/internal/dbrunner"
 	httpservermodule "github.com/database-playground/backend/internal/modules/httpserver"
 	redismodule "github.com/database-playground/backend/internal/modules/redis"
 	slogmodule "github.com/database-playground/backend/internal/modules/slog"
@@ -11,7 +15,18 @@ import (
 )
 
 func main() {
-	fx.New(slogmodule.FxOptions, redismodule.FxModule, dbrunnerservice.FxModule, fx.Provide(func(s *dbrunnerservice.Service) httpservermodule.HTTPHandler {
+	// The worker pool starts this binary with the worker command.
+	if len(os.Args) > 1 && os.Args[1] == dbrunner.WorkerCommand {
+		if err := dbrunner.ServeWorker(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fx.New(slogmodule.FxOptions, redismodule.FxModule, dbrunnerservice.FxModule, fx.Provide(httpservermodule.AsHTTPHandler(func(s *dbrunnerservice.Service) httpservermodule.HTTPHandler {
 		return httpservermodule.WrapHTTPHandler[dbrunnerv1connect.DbRunnerServiceHandler](dbrunnerv1connect.NewDbRunnerServiceHandler, s, connect.WithRequireConnectProtocolHeader())
-	}), httpservermodule.FxModule).Run()
+	}), httpservermodule.AsAdminHTTPHandler(func(s *dbrunnerservice.AdminService) httpservermodule.HTTPHandler {
+		return httpservermodule.WrapHTTPHandler[dbrunnerv1connect.DbRunnerAdminServiceHandler](dbrunnerv1connect.NewDbRunnerAdminServiceHandler, s, connect.WithRequireConnectProtocolHeader())
+	}), httpservermodule.AsAdminHTTPHandler(httpservermodule.ExpvarHandler)), httpservermodule.FxModule).Run()
 }
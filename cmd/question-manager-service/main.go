@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/database-playground/backend/gen/questionmanager/v1/questionmanagerv1connect"
+	"github.com/database-playground/backend/internal/clients"
 	"github.com/database-playground/backend/internal/database"
 	httpservermodule "github.com/database-playground/backend/internal/modules/httpserver"
 	slogmodule "github.com/database-playground/backend/internal/modules/slog"
@@ -10,7 +11,7 @@ import (
 )
 
 func main() {
-	fx.New(slogmodule.FxOptions, database.FxModule, questionmanagerservice.FxModule, fx.Provide(func(s *questionmanagerservice.Service) httpservermodule.HTTPHandler {
+	fx.New(slogmodule.FxOptions, database.FxModule, clients.DBRunnerClientFxModule, questionmanagerservice.FxModule, fx.Provide(httpservermodule.AsHTTPHandler(func(s *questionmanagerservice.Service) httpservermodule.HTTPHandler {
 		return httpservermodule.WrapHTTPHandler[questionmanagerv1connect.QuestionManagerServiceHandler](questionmanagerv1connect.NewQuestionManagerServiceHandler, s)
-	}), httpservermodule.FxModule).Run()
+	})), httpservermodule.FxModule).Run()
 }
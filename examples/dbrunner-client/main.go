@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"connectrpc.com/connect"
+	commonv1 "github.com/database-playground/backend/gen/common/v1"
 	dbrunnerv1 "github.com/database-playground/backend/gen/dbrunner/v1"
 	"github.com/database-playground/backend/gen/dbrunner/v1/dbrunnerv1connect"
 )
@@ -19,6 +20,7 @@ func main() {
 	baseURL := flag.String("base-url", "http://localhost:3000", "base URL of the service")
 	schema := flag.String("schema", "", "schema SQL")
 	query := flag.String("query", "", "query SQL")
+	maxRows := flag.Int64("max-rows", 0, "maximum number of rows in each result (0 for no limit)")
 	flag.Parse()
 
 	if *schema == "" || *query == "" {
@@ -60,6 +62,9 @@ func main() {
 	mainQueryResponse, err := client.RunQuery(context.Background(), connect.NewRequest(&dbrunnerv1.RunQueryRequest{
 		Schema: *schema,
 		Query:  *query,
+		Limits: &commonv1.ExecutionLimits{
+			MaxRows: *maxRows,
+		},
 	}))
 	if err != nil {
 		panic(err)
@@ -68,6 +73,9 @@ func main() {
 	if errMessage := mainQueryResponse.Msg.GetError(); errMessage != "" {
 		panic(errMessage)
 	}
+	if limitExceeded := mainQueryResponse.Msg.GetLimitExceeded(); limitExceeded != nil {
+		panic(limitExceeded.GetMessage())
+	}
 
 	fmt.Printf("\x1b[90mINPUT_HASH: %v\x1b[0m\n", mainQueryResponse.Msg.GetId())
 
@@ -81,32 +89,53 @@ func main() {
 
 	fmt.Printf("\x1b[90mOUTPUT_HASH: %v\x1b[0m\n", stream.ResponseHeader().Get("output-hash"))
 
+	truncated := false
 	for stream.Receive() {
 		switch msg := stream.Msg().GetKind().(type) {
 		case *dbrunnerv1.RetrieveQueryResponse_Header:
 			header := msg.Header
 
 			fmt.Println("\x1b[1m" + strings.Join(header.Header, "\t") + "\x1b[0m")
+			truncated = header.GetTruncated()
 		case *dbrunnerv1.RetrieveQueryResponse_Row:
-			row := msg.Row
-
-			for i, cell := range row.Cells {
-				if i > 0 {
-					fmt.Print("\t")
-				}
-				if cell.Value == nil {
-					fmt.Print("\x1b[3mNULL\x1b[0m")
-				} else {
-					fmt.Print(*cell.Value)
-				}
-
-				if i == len(row.Cells)-1 {
-					fmt.Println()
-				}
+			printRow(msg.Row)
+		case *dbrunnerv1.RetrieveQueryResponse_Statement:
+			statement := msg.Statement
+
+			fmt.Printf("\n\x1b[90m-- statement %d", statement.GetIndex()+1)
+			if statement.RowsAffected != nil {
+				fmt.Printf(" (%d rows affected)", statement.GetRowsAffected())
+			}
+			fmt.Println("\x1b[0m")
+			if len(statement.GetHeader().GetHeader()) > 0 {
+				fmt.Println("\x1b[1m" + strings.Join(statement.GetHeader().GetHeader(), "\t") + "\x1b[0m")
 			}
+		case *dbrunnerv1.RetrieveQueryResponse_StatementRow:
+			printRow(msg.StatementRow)
 		}
 	}
 	if stream.Err() != nil {
 		panic(stream.Err())
 	}
+
+	if truncated {
+		fmt.Println("\x1b[90m(truncated)\x1b[0m")
+	}
+}
+
+func printRow(row *dbrunnerv1.DataRow) {
+	for i, cell := range row.Cells {
+		if i > 0 {
+			fmt.Print("\t")
+		}
+		if cell.Value == nil {
+			fmt.Print("\x1b[3mNULL\x1b[0m")
+		} else {
+			fmt.Print(*cell.Value)
+		}
+
+		if i == len(row.Cells)-1 {
+			fmt.Println()
+		}
+	}
 }
@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"connectrpc.com/connect"
 	dbrunnerv1 "github.com/database-playground/backend/gen/dbrunner/v1"
@@ -57,4 +58,52 @@ func main() {
 	}
 
 	fmt.Printf("\x1b[90mOUTPUT_SAME? %v\x1b[0m\n", sameQueryResponse.Msg.GetSame())
+	if sameQueryResponse.Msg.GetSame() {
+		return
+	}
+
+	diffStream, err := client.DiffQuery(context.Background(), connect.NewRequest(&dbrunnerv1.DiffQueryRequest{
+		LeftId:  mainQueryResponse.Msg.GetId(),
+		RightId: secondaryQueryResponse.Msg.GetId(),
+	}))
+	if err != nil {
+		panic(err)
+	}
+
+	for diffStream.Receive() {
+		switch kind := diffStream.Msg().Kind.(type) {
+		case *dbrunnerv1.DiffQueryResponse_Summary:
+			fmt.Printf("ROWS: %d (query) / %d (query2)", kind.Summary.GetLeftRowCount(), kind.Summary.GetRightRowCount())
+			if kind.Summary.GetOrderMismatch() {
+				fmt.Print(", order mismatched")
+			}
+			fmt.Println()
+		case *dbrunnerv1.DiffQueryResponse_Header:
+			fmt.Printf("HEADER: %v -> %v\n", kind.Header.GetLeft(), kind.Header.GetRight())
+		case *dbrunnerv1.DiffQueryResponse_MissingRow:
+			fmt.Printf("\x1b[31m- #%d %s\x1b[0m\n", kind.MissingRow.GetIndex(), formatCells(kind.MissingRow.GetCells()))
+		case *dbrunnerv1.DiffQueryResponse_ExtraRow:
+			fmt.Printf("\x1b[32m+ #%d %s\x1b[0m\n", kind.ExtraRow.GetIndex(), formatCells(kind.ExtraRow.GetCells()))
+		case *dbrunnerv1.DiffQueryResponse_Cell:
+			fmt.Printf("\x1b[33m~ #%d/#%d [%d] %s -> %s\x1b[0m\n",
+				kind.Cell.GetLeftRow(), kind.Cell.GetRightRow(), kind.Cell.GetColumn(),
+				formatCells([]*dbrunnerv1.Cell{kind.Cell.GetLeft()}), formatCells([]*dbrunnerv1.Cell{kind.Cell.GetRight()}))
+		}
+	}
+	if err := diffStream.Err(); err != nil {
+		panic(err)
+	}
+}
+
+func formatCells(cells []*dbrunnerv1.Cell) string {
+	formatted := make([]string, len(cells))
+	for i, cell := range cells {
+		if cell.Value == nil {
+			formatted[i] = "NULL"
+		} else {
+			formatted[i] = fmt.Sprintf("%q", cell.GetValue())
+		}
+	}
+
+	return strings.Join(formatted, ", ")
 }
@@ -14,6 +14,7 @@ require (
 	github.com/redis/go-redis/v9 v9.6.0
 	github.com/samber/lo v1.46.0
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/sync v0.7.0
 	google.golang.org/protobuf v1.34.2
 	modernc.org/sqlite v1.31.1
 )
@@ -64,12 +65,11 @@ require (
 	go.uber.org/multierr v1.10.0 // indirect
 	go.uber.org/zap v1.26.0 // indirect
 	golang.org/x/crypto v0.25.0 // indirect
-	golang.org/x/sync v0.7.0 // indirect
 	golang.org/x/sys v0.22.0 // indirect
 	golang.org/x/text v0.16.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	modernc.org/gc/v3 v3.0.0-20240107210532-573471604cb6 // indirect
-	modernc.org/libc v1.55.3 // indirect
+	modernc.org/libc v1.55.3
 	modernc.org/mathutil v1.6.0 // indirect
 	modernc.org/memory v1.8.0 // indirect
 	modernc.org/strutil v1.2.0 // indirect
@@ -2,20 +2,31 @@ package database
 
 import (
 	commonv1 "github.com/database-playground/backend/gen/common/v1"
-	"github.com/samber/lo"
 )
 
 type Cursor struct {
 	Offset int64
 	Limit  int64
+	// After is the opaque keyset cursor returned by the previous page,
+	// which the page starts after. The offset is ignored if it is set.
+	After string
 }
 
+// GetOffset returns the offset, which is never negative.
 func (c Cursor) GetOffset() int64 {
-	return c.Offset
+	if c.After != "" {
+		return 0
+	}
+	return max(c.Offset, 0)
 }
 
+// GetLimit returns the limit between 1 and 100. The limit is 10 if
+// it is not positive.
 func (c Cursor) GetLimit() int64 {
-	return min(lo.CoalesceOrEmpty(c.Limit, 10), 100)
+	if c.Limit <= 0 {
+		return 10
+	}
+	return min(c.Limit, 100)
 }
 
 func CursorFromProto(cursor *commonv1.Cursor) Cursor {
@@ -26,5 +37,6 @@ func CursorFromProto(cursor *commonv1.Cursor) Cursor {
 	return Cursor{
 		Limit:  cursor.GetLimit(),
 		Offset: cursor.GetOffset(),
+		After:  cursor.GetAfter(),
 	}
 }
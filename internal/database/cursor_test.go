@@ -29,4 +29,17 @@ func TestCursor(t *testing.T) {
 
 		assert.EqualValues(t, 100, cursor.GetLimit())
 	})
+
+	t.Run("negative limit and offset are clamped", func(t *testing.T) {
+		cursor := database.Cursor{Limit: -1, Offset: -1}
+
+		assert.EqualValues(t, 10, cursor.GetLimit())
+		assert.EqualValues(t, 0, cursor.GetOffset())
+	})
+
+	t.Run("offset is ignored after a keyset cursor", func(t *testing.T) {
+		cursor := database.Cursor{Offset: 10, After: "cursor"}
+
+		assert.EqualValues(t, 0, cursor.GetOffset())
+	})
 }
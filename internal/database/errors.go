@@ -1,5 +1,31 @@
 package database
 
-import "github.com/jackc/pgx/v5"
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
 
 var ErrNotFound = pgx.ErrNoRows
+
+var (
+	// ErrAlreadyExists is returned if the ID of the created resource is used.
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrSchemaNotFound is returned if the schema of the question does not exist or is deleted.
+	ErrSchemaNotFound = errors.New("schema not found")
+	// ErrSchemaInUse is returned if the deleted schema is used by questions.
+	ErrSchemaInUse = errors.New("schema is used by questions")
+	// ErrInvalidCursor is returned if the keyset cursor is malformed or
+	// does not match the sort of the list.
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// uniqueViolation is the SQLSTATE code of the unique constraint violations.
+const uniqueViolation = "23505"
+
+// isPgError returns whether the error is a PostgreSQL error of the SQLSTATE code.
+func isPgError(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == code
+}
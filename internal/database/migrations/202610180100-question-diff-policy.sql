@@ -0,0 +1,10 @@
+-- diff_policy decides how much of the answer is revealed
+-- when a user requests the difference against the answer.
+--
+-- none: reveals nothing but whether the result is correct.
+-- summary: reveals the row counts and the columns that differ.
+-- sample: reveals the summary and a few missing and extra rows.
+CREATE TYPE dp_diff_policy AS ENUM ('none', 'summary', 'sample');
+
+ALTER TABLE dp_questions
+ADD COLUMN diff_policy DP_DIFF_POLICY NOT NULL DEFAULT 'summary';
@@ -0,0 +1,4 @@
+-- compare_options configures how the result of a challenge
+-- is compared with the answer. See models.CompareOptions.
+ALTER TABLE dp_questions
+ADD COLUMN compare_options JSONB NOT NULL DEFAULT '{}'::JSONB;
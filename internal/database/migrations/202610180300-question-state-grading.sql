@@ -0,0 +1,8 @@
+-- grade_by_state grades the challenges of the questions modifying
+-- data by the resulting database instead of the query result.
+--
+-- state_tables are the tables compared; all the tables are
+-- compared if empty.
+ALTER TABLE dp_questions
+ADD COLUMN grade_by_state BOOLEAN NOT NULL DEFAULT FALSE,
+ADD COLUMN state_tables TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,28 @@
+-- Hidden datasets of the questions
+--
+-- The challenges are graded against every dataset of the question in
+-- addition to the initial SQL of the schema, so the hardcoded queries
+-- cannot pass.
+
+-- fixture: the SQL is run after the initial SQL of the schema,
+--          for example, to insert more data.
+-- init:    the SQL replaces the initial SQL of the schema.
+CREATE TYPE dp_dataset_kind AS ENUM ('fixture', 'init');
+
+CREATE TABLE dp_question_datasets (
+    dataset_id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    question_id BIGINT NOT NULL REFERENCES dp_questions ON DELETE CASCADE,
+
+    kind DP_DATASET_KIND NOT NULL DEFAULT 'fixture',
+    initial_sql TEXT NOT NULL,
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX dp_question_datasets_question_id_idx ON dp_question_datasets (question_id);
+
+CREATE TRIGGER dp_question_datasets_moddatetime
+BEFORE UPDATE ON dp_question_datasets
+FOR EACH ROW
+EXECUTE PROCEDURE MODDATETIME(updated_at);
@@ -0,0 +1,10 @@
+-- execution_limits limits the resources the queries can use.
+-- See models.ExecutionLimits.
+--
+-- The limits of a question override the limits of its schema
+-- key by key.
+ALTER TABLE dp_schemas
+ADD COLUMN execution_limits JSONB NOT NULL DEFAULT '{}'::JSONB;
+
+ALTER TABLE dp_questions
+ADD COLUMN execution_limits JSONB NOT NULL DEFAULT '{}'::JSONB;
@@ -0,0 +1,5 @@
+-- sandbox_allow lists the sandboxed operations the answer and the
+-- challenges of a question are allowed to perform, for example,
+-- 'pragma' for the questions teaching PRAGMA statements.
+ALTER TABLE dp_questions
+ADD COLUMN sandbox_allow TEXT[] NOT NULL DEFAULT '{}';
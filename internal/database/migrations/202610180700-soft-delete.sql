@@ -0,0 +1,7 @@
+-- Soft deletes
+--
+-- The deleted schemas and questions are kept until they are purged,
+-- which looks for the rows deleted before the retention period.
+
+CREATE INDEX dp_schemas_deleted_at_idx ON dp_schemas (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX dp_questions_deleted_at_idx ON dp_questions (deleted_at) WHERE deleted_at IS NOT NULL;
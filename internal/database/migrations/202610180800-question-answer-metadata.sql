@@ -0,0 +1,9 @@
+-- The metadata of the result of the answer on the schema, recorded
+-- when the question is written and the answer is validated.
+--
+-- answer_output_hash: the hash of the output in the dbrunner cache,
+--                     which is NULL if the output is not cached.
+-- answer_row_count:   the number of the rows of the result.
+ALTER TABLE dp_questions
+ADD COLUMN answer_output_hash TEXT,
+ADD COLUMN answer_row_count BIGINT;
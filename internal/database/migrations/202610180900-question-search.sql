@@ -0,0 +1,19 @@
+-- tags are the free-form labels of the questions,
+-- which the questions can be filtered by.
+ALTER TABLE dp_questions
+ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX dp_questions_tags_idx ON dp_questions USING GIN (tags);
+
+-- search_vector is the full-text search document of the title and the
+-- description. The title ranks higher than the description.
+--
+-- The simple configuration is used since the questions are not
+-- necessarily written in English, so the words are not stemmed.
+ALTER TABLE dp_questions
+ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', title), 'A') ||
+    setweight(to_tsvector('simple', description), 'B')
+) STORED;
+
+CREATE INDEX dp_questions_search_vector_idx ON dp_questions USING GIN (search_vector);
@@ -0,0 +1,11 @@
+-- The questions are listed by keyset pagination, which seeks to the
+-- sort key of the last question of the previous page. The sort keys
+-- must not be NULL, and are indexed with question_id breaking the ties.
+UPDATE dp_questions SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+
+ALTER TABLE dp_questions
+ALTER COLUMN created_at SET NOT NULL;
+
+CREATE INDEX dp_questions_created_at_idx ON dp_questions (created_at, question_id);
+CREATE INDEX dp_questions_difficulty_idx ON dp_questions (difficulty, question_id);
+CREATE INDEX dp_questions_title_idx ON dp_questions (title, question_id);
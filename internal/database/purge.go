@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PurgeResult is the number of the rows purged by [Database.PurgeDeleted].
+type PurgeResult struct {
+	Questions int64
+	Schemas   int64
+}
+
+// PurgeDeleted permanently deletes the questions and the schemas
+// soft-deleted longer than the retention period ago, with the datasets
+// of the questions. The schemas still used by any question, even a
+// deleted one, are kept until the question is purged.
+func (db *Database) PurgeDeleted(ctx context.Context, retention time.Duration) (PurgeResult, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return PurgeResult{}, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	var result PurgeResult
+
+	tag, err := tx.Exec(ctx, `
+		--sql
+		DELETE FROM dp_questions
+		WHERE deleted_at < CURRENT_TIMESTAMP - make_interval(secs => $1);
+	`, retention.Seconds())
+	if err != nil {
+		return PurgeResult{}, fmt.Errorf("purge questions: %w", err)
+	}
+	result.Questions = tag.RowsAffected()
+
+	tag, err = tx.Exec(ctx, `
+		--sql
+		DELETE FROM dp_schemas
+		WHERE deleted_at < CURRENT_TIMESTAMP - make_interval(secs => $1)
+			AND NOT EXISTS (SELECT 1 FROM dp_questions WHERE dp_questions.schema_id = dp_schemas.schema_id);
+	`, retention.Seconds())
+	if err != nil {
+		return PurgeResult{}, fmt.Errorf("purge schemas: %w", err)
+	}
+	result.Schemas = tag.RowsAffected()
+
+	if err := tx.Commit(ctx); err != nil {
+		return PurgeResult{}, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,61 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/database-playground/backend/internal/database"
+	"github.com/database-playground/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurgeDeleted(t *testing.T) {
+	t.Parallel()
+
+	db, cleanup := createOnetimeDatabase(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(ctx))
+	require.NoError(t, db.SeedTestOnly(ctx))
+
+	_, err := db.CreateSchema(ctx, database.SchemaParams{ID: "bookstore", InitialSQL: "CREATE TABLE books (id INTEGER);"})
+	require.NoError(t, err)
+
+	question, err := db.CreateQuestion(ctx, database.QuestionParams{
+		SchemaID:   "bookstore",
+		Type:       "select",
+		Difficulty: models.DifficultyEasy,
+		Title:      "List the books",
+		Answer:     "SELECT * FROM books;",
+		DiffPolicy: models.DiffPolicySummary,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, db.DeleteQuestion(ctx, question.ID))
+	require.NoError(t, db.DeleteSchema(ctx, "bookstore"))
+
+	// The rows deleted within the retention period are kept.
+	result, err := db.PurgeDeleted(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, database.PurgeResult{}, result)
+
+	_, err = db.GetQuestion(ctx, question.ID, true)
+	require.NoError(t, err)
+
+	result, err = db.PurgeDeleted(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, database.PurgeResult{Questions: 1, Schemas: 1}, result)
+
+	_, err = db.GetQuestion(ctx, question.ID, true)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	_, err = db.GetSchema(ctx, "bookstore", true)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	// The undeleted rows are never purged.
+	_, err = db.GetSchema(ctx, "shop", false)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/database-playground/backend/internal/models"
+)
+
+// questionCursor is the keyset cursor of the question list, which is
+// the sort key of the last question of the page.
+type questionCursor struct {
+	Sort       QuestionSort `json:"s,omitempty"`
+	Descending bool         `json:"d,omitempty"`
+	// Value is the value of the sorted column in the text format of
+	// PostgreSQL. It is empty if the questions are listed by their IDs.
+	Value string `json:"v,omitempty"`
+	ID    int64  `json:"i"`
+}
+
+// cursorTimeLayout is the layout of the timestamps in the cursors,
+// in which PostgreSQL parses the TIMESTAMP values.
+const cursorTimeLayout = "2006-01-02T15:04:05.999999"
+
+// encodeQuestionCursor returns the cursor of the page after the question.
+func encodeQuestionCursor(param ListQuestionsParams, last *models.Question) string {
+	cursor := questionCursor{
+		Sort:       param.Sort,
+		Descending: param.Descending,
+		ID:         last.ID,
+	}
+
+	switch param.Sort {
+	case QuestionSortCreatedAt:
+		cursor.Value = last.CreatedAt.Format(cursorTimeLayout)
+	case QuestionSortDifficulty:
+		cursor.Value = string(last.Difficulty)
+	case QuestionSortTitle:
+		cursor.Value = last.Title
+	}
+
+	// questionCursor is always serializable.
+	b, _ := json.Marshal(cursor)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeQuestionCursor decodes the cursor. It returns [ErrInvalidCursor]
+// if the cursor is malformed.
+func decodeQuestionCursor(s string) (questionCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return questionCursor{}, ErrInvalidCursor
+	}
+
+	var cursor questionCursor
+	if err := json.Unmarshal(b, &cursor); err != nil {
+		return questionCursor{}, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
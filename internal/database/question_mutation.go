@@ -0,0 +1,210 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/database-playground/backend/internal/models"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/samber/lo"
+)
+
+// QuestionParams is the writable fields of a question.
+type QuestionParams struct {
+	SchemaID string
+
+	Type       string
+	Difficulty models.Difficulty
+
+	Title       string
+	Description string
+	Tags        []string
+
+	Answer        string
+	SolutionVideo *string
+
+	DiffPolicy     models.DiffPolicy
+	CompareOptions models.CompareOptions
+	GradeByState   bool
+	StateTables    []string
+	Limits         models.ExecutionLimits
+	SandboxAllow   []models.SandboxPermission
+
+	// The metadata of the result of the answer on the schema.
+	AnswerOutputHash *string
+	AnswerRowCount   *int64
+}
+
+// args returns the arguments $1 to $16 of the statements writing questions.
+func (p QuestionParams) args() []any {
+	// state_tables and tags are not nullable.
+	stateTables := p.StateTables
+	if stateTables == nil {
+		stateTables = []string{}
+	}
+	tags := p.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return []any{
+		p.SchemaID,
+		p.Type, string(p.Difficulty),
+		p.Title, p.Description,
+		p.Answer, p.SolutionVideo,
+		string(p.DiffPolicy), p.CompareOptions, p.GradeByState,
+		stateTables,
+		p.Limits,
+		lo.Map(p.SandboxAllow, func(permission models.SandboxPermission, _ int) string {
+			return string(permission)
+		}),
+		p.AnswerOutputHash, p.AnswerRowCount,
+		tags,
+	}
+}
+
+// CreateQuestion creates a question. It returns [ErrSchemaNotFound]
+// if the schema does not exist or is deleted.
+func (db *Database) CreateQuestion(ctx context.Context, params QuestionParams) (*models.Question, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if err := lockSchema(ctx, tx, params.SchemaID); err != nil {
+		return nil, err
+	}
+
+	var question models.Question
+	err = pgxscan.Get(ctx, tx, &question, `
+		--sql
+		INSERT INTO dp_questions (
+			schema_id, type, difficulty, title, description, answer, solution_video,
+			diff_policy, compare_options, grade_by_state, state_tables, execution_limits, sandbox_allow,
+			answer_output_hash, answer_row_count, tags
+		)
+		VALUES ($1, $2, $3::DP_DIFFICULTY, $4, $5, $6, $7, $8::DP_DIFF_POLICY, $9, $10, $11, $12, $13, $14, $15, $16)
+		RETURNING question_id, schema_id, type, difficulty, title, description, tags, created_at, updated_at, deleted_at;
+	`, params.args()...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &question, tx.Commit(ctx)
+}
+
+// UpdateQuestion replaces the fields of the question, which must not be
+// deleted. It returns [ErrSchemaNotFound] if the schema does not exist
+// or is deleted.
+func (db *Database) UpdateQuestion(ctx context.Context, questionID int64, params QuestionParams) (*models.Question, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if err := lockSchema(ctx, tx, params.SchemaID); err != nil {
+		return nil, err
+	}
+
+	var question models.Question
+	err = pgxscan.Get(ctx, tx, &question, `
+		--sql
+		UPDATE dp_questions
+		SET schema_id = $1, type = $2, difficulty = $3::DP_DIFFICULTY, title = $4, description = $5,
+			answer = $6, solution_video = $7, diff_policy = $8::DP_DIFF_POLICY, compare_options = $9,
+			grade_by_state = $10, state_tables = $11, execution_limits = $12, sandbox_allow = $13,
+			answer_output_hash = $14, answer_row_count = $15, tags = $16
+		WHERE question_id = $17 AND deleted_at IS NULL
+		RETURNING question_id, schema_id, type, difficulty, title, description, tags, created_at, updated_at, deleted_at;
+	`, append(params.args(), questionID)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &question, tx.Commit(ctx)
+}
+
+// DeleteQuestion soft-deletes the question. Its datasets are deleted
+// when it is purged.
+func (db *Database) DeleteQuestion(ctx context.Context, questionID int64) error {
+	tag, err := db.pool.Exec(ctx, `
+		--sql
+		UPDATE dp_questions SET deleted_at = CURRENT_TIMESTAMP
+		WHERE question_id = $1 AND deleted_at IS NULL;
+	`, questionID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RestoreQuestion restores the soft-deleted question. It returns
+// [ErrSchemaNotFound] if the schema of the question is deleted, which
+// should be restored first.
+func (db *Database) RestoreQuestion(ctx context.Context, questionID int64) (*models.Question, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	var schemaID string
+	err = tx.QueryRow(ctx, `
+		--sql
+		SELECT schema_id FROM dp_questions
+		WHERE question_id = $1 AND deleted_at IS NOT NULL
+		FOR UPDATE;
+	`, questionID).Scan(&schemaID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockSchema(ctx, tx, schemaID); err != nil {
+		return nil, err
+	}
+
+	var question models.Question
+	err = pgxscan.Get(ctx, tx, &question, `
+		--sql
+		UPDATE dp_questions SET deleted_at = NULL
+		WHERE question_id = $1
+		RETURNING question_id, schema_id, type, difficulty, title, description, tags, created_at, updated_at, deleted_at;
+	`, questionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &question, tx.Commit(ctx)
+}
+
+// lockSchema locks the schema of the question being written, so it
+// cannot be deleted until the transaction ends. It returns
+// [ErrSchemaNotFound] if the schema does not exist or is deleted.
+func lockSchema(ctx context.Context, tx pgx.Tx, schemaID string) error {
+	var id string
+	err := tx.QueryRow(ctx, `
+		--sql
+		SELECT schema_id FROM dp_schemas
+		WHERE schema_id = $1 AND deleted_at IS NULL
+		FOR SHARE;
+	`, schemaID).Scan(&id)
+	if errors.Is(err, ErrNotFound) {
+		return ErrSchemaNotFound
+	}
+
+	return err
+}
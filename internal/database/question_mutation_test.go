@@ -0,0 +1,169 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/database-playground/backend/internal/database"
+	"github.com/database-playground/backend/internal/models"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuestionMutation(t *testing.T) {
+	t.Parallel()
+
+	db, cleanup := createOnetimeDatabase(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(ctx))
+	require.NoError(t, db.SeedTestOnly(ctx))
+
+	params := database.QuestionParams{
+		SchemaID:       "shop",
+		Type:           "select",
+		Difficulty:     models.DifficultyEasy,
+		Title:          "List the products",
+		Description:    "List all the products.",
+		Tags:           []string{"select"},
+		Answer:         "SELECT * FROM products;",
+		DiffPolicy:     models.DiffPolicySample,
+		CompareOptions: models.CompareOptions{IgnoreRowOrder: true},
+		SandboxAllow:   []models.SandboxPermission{models.SandboxPermissionPragma},
+
+		AnswerOutputHash: lo.ToPtr("output-hash"),
+		AnswerRowCount:   lo.ToPtr[int64](3),
+	}
+
+	question, err := db.CreateQuestion(ctx, params)
+	require.NoError(t, err)
+	assert.Equal(t, "shop", question.SchemaID)
+	assert.Equal(t, models.DifficultyEasy, question.Difficulty)
+	assert.Equal(t, "List the products", question.Title)
+	assert.Equal(t, []string{"select"}, question.Tags)
+
+	answer, err := db.GetQuestionAnswer(ctx, question.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM products;", answer.Answer)
+	assert.Equal(t, models.DiffPolicySample, answer.DiffPolicy)
+	assert.True(t, answer.CompareOptions.IgnoreRowOrder)
+	assert.Equal(t, []models.SandboxPermission{models.SandboxPermissionPragma}, answer.SandboxAllow)
+	assert.Equal(t, lo.ToPtr("output-hash"), answer.AnswerOutputHash)
+	assert.Equal(t, lo.ToPtr[int64](3), answer.AnswerRowCount)
+
+	params.Difficulty = models.DifficultyHard
+	params.SolutionVideo = lo.ToPtr("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	updated, err := db.UpdateQuestion(ctx, question.ID, params)
+	require.NoError(t, err)
+	assert.Equal(t, question.ID, updated.ID)
+	assert.Equal(t, models.DifficultyHard, updated.Difficulty)
+
+	solution, err := db.GetQuestionSolution(ctx, question.ID)
+	require.NoError(t, err)
+	assert.Equal(t, params.SolutionVideo, solution.SolutionVideo)
+
+	require.NoError(t, db.DeleteQuestion(ctx, question.ID))
+
+	_, err = db.GetQuestion(ctx, question.ID, false)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	_, err = db.GetQuestionAnswer(ctx, question.ID)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	_, err = db.GetQuestionSolution(ctx, question.ID)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	deleted, err := db.GetQuestion(ctx, question.ID, true)
+	require.NoError(t, err)
+	assert.NotNil(t, deleted.DeletedAt)
+
+	err = db.DeleteQuestion(ctx, question.ID)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	restored, err := db.RestoreQuestion(ctx, question.ID)
+	require.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+
+	_, err = db.RestoreQuestion(ctx, question.ID)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+}
+
+func TestListQuestions_IncludeDeleted(t *testing.T) {
+	t.Parallel()
+
+	db, cleanup := createOnetimeDatabase(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(ctx))
+	require.NoError(t, db.SeedTestOnly(ctx))
+
+	questions, _, err := db.ListQuestions(ctx, database.ListQuestionsParams{})
+	require.NoError(t, err)
+	require.NotEmpty(t, questions)
+
+	require.NoError(t, db.DeleteQuestion(ctx, questions[0].ID))
+
+	listed, _, err := db.ListQuestions(ctx, database.ListQuestionsParams{})
+	require.NoError(t, err)
+	assert.Len(t, listed, len(questions)-1)
+
+	listed, _, err = db.ListQuestions(ctx, database.ListQuestionsParams{IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Len(t, listed, len(questions))
+}
+
+func TestRestoreQuestion_SchemaDeleted(t *testing.T) {
+	t.Parallel()
+
+	db, cleanup := createOnetimeDatabase(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(ctx))
+	require.NoError(t, db.SeedTestOnly(ctx))
+
+	_, err := db.CreateSchema(ctx, database.SchemaParams{ID: "bookstore", InitialSQL: "CREATE TABLE books (id INTEGER);"})
+	require.NoError(t, err)
+
+	question, err := db.CreateQuestion(ctx, database.QuestionParams{
+		SchemaID:   "bookstore",
+		Type:       "select",
+		Difficulty: models.DifficultyEasy,
+		Title:      "List the books",
+		Answer:     "SELECT * FROM books;",
+		DiffPolicy: models.DiffPolicySummary,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, db.DeleteQuestion(ctx, question.ID))
+	require.NoError(t, db.DeleteSchema(ctx, "bookstore"))
+
+	_, err = db.RestoreQuestion(ctx, question.ID)
+	assert.ErrorIs(t, err, database.ErrSchemaNotFound)
+}
+
+func TestQuestionMutation_SchemaNotFound(t *testing.T) {
+	t.Parallel()
+
+	db, cleanup := createOnetimeDatabase(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(ctx))
+	require.NoError(t, db.SeedTestOnly(ctx))
+
+	params := database.QuestionParams{
+		SchemaID:   "not-found",
+		Type:       "select",
+		Difficulty: models.DifficultyEasy,
+		Title:      "Select one",
+		Answer:     "SELECT 1;",
+		DiffPolicy: models.DiffPolicySummary,
+	}
+
+	_, err := db.CreateQuestion(ctx, params)
+	assert.ErrorIs(t, err, database.ErrSchemaNotFound)
+}
@@ -2,6 +2,8 @@ package database
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"github.com/database-playground/backend/internal/models"
 	"github.com/georgysavva/scany/v2/pgxscan"
@@ -9,34 +11,194 @@ import (
 
 type ListQuestionsParams struct {
 	Cursor
+
+	// IncludeDeleted lists the soft-deleted questions as well.
+	IncludeDeleted bool
+
+	// The filters. The empty filters match all the questions.
+	Difficulty models.Difficulty
+	Type       string
+	SchemaID   string
+	// Tags matches the questions with all the tags.
+	Tags []string
+	// Search is the full-text search query of the title and the
+	// description, in the syntax of websearch_to_tsquery.
+	Search string
+
+	Sort       QuestionSort
+	Descending bool
+}
+
+// QuestionSort is the column the questions are listed by.
+// The questions with the same value are listed by their IDs.
+type QuestionSort string
+
+const (
+	QuestionSortID         QuestionSort = ""
+	QuestionSortCreatedAt  QuestionSort = "created_at"
+	QuestionSortDifficulty QuestionSort = "difficulty"
+	QuestionSortTitle      QuestionSort = "title"
+)
+
+// column returns the column of the sort and the type of the column,
+// which are empty if the questions are listed by their IDs.
+func (s QuestionSort) column() (column string, columnType string, err error) {
+	switch s {
+	case QuestionSortID:
+		return "", "", nil
+	case QuestionSortCreatedAt:
+		return "created_at", "TIMESTAMP", nil
+	case QuestionSortDifficulty:
+		return "difficulty", "DP_DIFFICULTY", nil
+	case QuestionSortTitle:
+		return "title", "TEXT", nil
+	default:
+		return "", "", fmt.Errorf("unknown sort %q", s)
+	}
+}
+
+// statementArgs is the arguments of a statement built at runtime.
+type statementArgs []any
+
+// add adds the argument and returns its placeholder.
+func (a *statementArgs) add(arg any) string {
+	*a = append(*a, arg)
+	return fmt.Sprintf("$%d", len(*a))
+}
+
+// filters returns the conditions of the filters, adding their
+// arguments to args.
+func (p ListQuestionsParams) filters(args *statementArgs) []string {
+	var conditions []string
+
+	if !p.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if p.Difficulty != models.DifficultyUnspecified {
+		conditions = append(conditions, "difficulty = "+args.add(string(p.Difficulty))+"::DP_DIFFICULTY")
+	}
+	if p.Type != "" {
+		conditions = append(conditions, "type = "+args.add(p.Type))
+	}
+	if p.SchemaID != "" {
+		conditions = append(conditions, "schema_id = "+args.add(p.SchemaID))
+	}
+	if len(p.Tags) > 0 {
+		conditions = append(conditions, "tags @> "+args.add(p.Tags))
+	}
+	if p.Search != "" {
+		conditions = append(conditions, "search_vector @@ websearch_to_tsquery('simple', "+args.add(p.Search)+")")
+	}
+
+	return conditions
 }
 
-func (db *Database) ListQuestions(ctx context.Context, param ListQuestionsParams) ([]*models.Question, error) {
+// where joins the conditions to a WHERE clause.
+func where(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+// ListQuestions lists a page of the questions, and returns the cursor
+// of the next page, which is empty on the last page.
+//
+// The page starts after the keyset cursor if there is one; otherwise,
+// it starts at the offset. It returns [ErrInvalidCursor] if the cursor
+// is not returned by the list of the same sort.
+func (db *Database) ListQuestions(ctx context.Context, param ListQuestionsParams) ([]*models.Question, string, error) {
+	column, columnType, err := param.Sort.column()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var args statementArgs
+	conditions := param.filters(&args)
+
+	operator, direction := ">", "ASC"
+	if param.Descending {
+		operator, direction = "<", "DESC"
+	}
+
+	if param.After != "" {
+		after, err := decodeQuestionCursor(param.After)
+		if err != nil {
+			return nil, "", err
+		}
+		if after.Sort != param.Sort || after.Descending != param.Descending {
+			return nil, "", ErrInvalidCursor
+		}
+
+		if column == "" {
+			conditions = append(conditions, fmt.Sprintf("question_id %s %s", operator, args.add(after.ID)))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("(%s, question_id) %s (%s::%s, %s)",
+				column, operator, args.add(after.Value), columnType, args.add(after.ID)))
+		}
+	}
+
+	orderBy := "question_id " + direction
+	if column != "" {
+		orderBy = fmt.Sprintf("%s %s, question_id %s", column, direction, direction)
+	}
+
+	// One more question is fetched to know whether there is the next page.
+	limit := param.GetLimit()
+
 	var questions []*models.Question
 
-	err := pgxscan.Select(ctx, db.pool, &questions, `
+	err = pgxscan.Select(ctx, db.pool, &questions, `
 		--sql
-		SELECT question_id, schema_id, type, difficulty, title, description, created_at, updated_at
+		SELECT question_id, schema_id, type, difficulty, title, description, tags, created_at, updated_at, deleted_at
 		FROM dp_questions
-		ORDER BY question_id
-		LIMIT $1 OFFSET $2;
-	`, param.GetLimit(), param.GetOffset())
+		`+where(conditions)+`
+		ORDER BY `+orderBy+`
+		LIMIT `+args.add(limit+1)+` OFFSET `+args.add(param.GetOffset())+`;
+	`, args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+
+	if int64(len(questions)) <= limit {
+		return questions, "", nil
+	}
+
+	questions = questions[:limit]
+	return questions, encodeQuestionCursor(param, questions[limit-1]), nil
+}
+
+// CountQuestions returns the number of the questions matching the
+// filters of the parameters. The cursor is ignored.
+func (db *Database) CountQuestions(ctx context.Context, param ListQuestionsParams) (int64, error) {
+	var args statementArgs
+	conditions := param.filters(&args)
+
+	var count int64
+	err := db.pool.QueryRow(ctx, `
+		--sql
+		SELECT COUNT(*) FROM dp_questions
+		`+where(conditions)+`;
+	`, args...).Scan(&count)
+	if err != nil {
+		return 0, err
 	}
 
-	return questions, nil
+	return count, nil
 }
 
-func (db *Database) GetQuestion(ctx context.Context, questionID int64) (*models.Question, error) {
+// GetQuestion returns the question. The soft-deleted question is
+// not found unless includeDeleted is true.
+func (db *Database) GetQuestion(ctx context.Context, questionID int64, includeDeleted bool) (*models.Question, error) {
 	var question models.Question
 
 	err := pgxscan.Get(ctx, db.pool, &question, `
 		--sql
-		SELECT question_id, schema_id, type, difficulty, title, description, created_at, updated_at
+		SELECT question_id, schema_id, type, difficulty, title, description, tags, created_at, updated_at, deleted_at
 		FROM dp_questions
-		WHERE question_id = $1;
-	`, questionID)
+		WHERE question_id = $1 AND ($2 OR deleted_at IS NULL);
+	`, questionID, includeDeleted)
 	if err != nil {
 		return nil, err
 	}
@@ -49,10 +211,30 @@ func (db *Database) GetQuestionAnswer(ctx context.Context, questionID int64) (*m
 
 	err := pgxscan.Get(ctx, db.pool, &questionAnswer, `
 		--sql
-		SELECT question_id, answer, initial_sql AS schema
+		SELECT question_id, answer, initial_sql AS schema, diff_policy, compare_options, grade_by_state, state_tables,
+			dp_schemas.execution_limits || dp_questions.execution_limits AS execution_limits,
+			sandbox_allow, answer_output_hash, answer_row_count
 		FROM dp_questions
 		JOIN dp_schemas USING (schema_id)
-		WHERE question_id = $1;
+		WHERE question_id = $1 AND dp_questions.deleted_at IS NULL;
+	`, questionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The fixtures are run after the initial SQL of the schema.
+	err = pgxscan.Select(ctx, db.pool, &questionAnswer.Datasets, `
+		--sql
+		SELECT dataset_id,
+			CASE kind
+				WHEN 'init' THEN dp_question_datasets.initial_sql
+				ELSE dp_schemas.initial_sql || E'\n' || dp_question_datasets.initial_sql
+			END AS schema
+		FROM dp_question_datasets
+		JOIN dp_questions USING (question_id)
+		JOIN dp_schemas USING (schema_id)
+		WHERE question_id = $1
+		ORDER BY dataset_id;
 	`, questionID)
 	if err != nil {
 		return nil, err
@@ -68,7 +250,7 @@ func (db *Database) GetQuestionSolution(ctx context.Context, questionID int64) (
 		--sql
 		SELECT question_id, solution_video
 		FROM dp_questions
-		WHERE question_id = $1;
+		WHERE question_id = $1 AND deleted_at IS NULL;
 	`, questionID)
 	if err != nil {
 		return nil, err
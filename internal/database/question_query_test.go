@@ -2,6 +2,7 @@ package database_test
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/database-playground/backend/internal/database"
@@ -21,7 +22,7 @@ func TestListQuestions(t *testing.T) {
 	require.NoError(t, db.Migrate(ctx))
 	require.NoError(t, db.SeedTestOnly(ctx))
 
-	rootQuestions, err := db.ListQuestions(ctx, database.ListQuestionsParams{})
+	rootQuestions, _, err := db.ListQuestions(ctx, database.ListQuestionsParams{})
 	if err != nil {
 		t.Fatalf("failed to get schema: %v", err)
 	}
@@ -31,7 +32,7 @@ func TestListQuestions(t *testing.T) {
 	t.Logf("%#v", rootQuestions)
 
 	t.Run("offset=1; limit=5", func(t *testing.T) {
-		questions, err := db.ListQuestions(ctx, database.ListQuestionsParams{Cursor: database.Cursor{Offset: 1, Limit: 5}})
+		questions, _, err := db.ListQuestions(ctx, database.ListQuestionsParams{Cursor: database.Cursor{Offset: 1, Limit: 5}})
 		if err != nil {
 			t.Fatalf("failed to get schema: %v", err)
 		}
@@ -48,7 +49,7 @@ func TestListQuestions(t *testing.T) {
 	})
 
 	t.Run("offset=5; limit=5", func(t *testing.T) {
-		questions, err := db.ListQuestions(ctx, database.ListQuestionsParams{Cursor: database.Cursor{Offset: 5, Limit: 5}})
+		questions, _, err := db.ListQuestions(ctx, database.ListQuestionsParams{Cursor: database.Cursor{Offset: 5, Limit: 5}})
 		if err != nil {
 			t.Fatalf("failed to get schema: %v", err)
 		}
@@ -61,7 +62,7 @@ func TestListQuestions(t *testing.T) {
 	})
 
 	t.Run("offset=0; limit=5", func(t *testing.T) {
-		questions, err := db.ListQuestions(ctx, database.ListQuestionsParams{Cursor: database.Cursor{Offset: 0, Limit: 5}})
+		questions, _, err := db.ListQuestions(ctx, database.ListQuestionsParams{Cursor: database.Cursor{Offset: 0, Limit: 5}})
 		if err != nil {
 			t.Fatalf("failed to get schema: %v", err)
 		}
@@ -74,7 +75,7 @@ func TestListQuestions(t *testing.T) {
 	})
 
 	t.Run("limit=0 should be represented as limit=10", func(t *testing.T) {
-		questions, err := db.ListQuestions(ctx, database.ListQuestionsParams{Cursor: database.Cursor{Limit: 0}})
+		questions, _, err := db.ListQuestions(ctx, database.ListQuestionsParams{Cursor: database.Cursor{Limit: 0}})
 		if err != nil {
 			t.Fatalf("failed to get schema: %v", err)
 		}
@@ -87,6 +88,188 @@ func TestListQuestions(t *testing.T) {
 	})
 }
 
+func TestListQuestions_Filters(t *testing.T) {
+	t.Parallel()
+
+	db, cleanup := createOnetimeDatabase(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(ctx))
+	require.NoError(t, db.SeedTestOnly(ctx))
+
+	listIDs := func(t *testing.T, params database.ListQuestionsParams) []int64 {
+		t.Helper()
+
+		params.Limit = 100
+		questions, _, err := db.ListQuestions(ctx, params)
+		require.NoError(t, err)
+
+		return lo.Map(questions, func(q *models.Question, _ int) int64 {
+			return q.ID
+		})
+	}
+
+	t.Run("difficulty", func(t *testing.T) {
+		questions, _, err := db.ListQuestions(ctx, database.ListQuestionsParams{Difficulty: models.DifficultyHard})
+		require.NoError(t, err)
+		require.NotEmpty(t, questions)
+
+		for _, q := range questions {
+			assert.Equal(t, models.DifficultyHard, q.Difficulty)
+		}
+	})
+
+	t.Run("type and schema", func(t *testing.T) {
+		questions, _, err := db.ListQuestions(ctx, database.ListQuestionsParams{Type: "條件查詢", SchemaID: "shop"})
+		require.NoError(t, err)
+		assert.Len(t, questions, 3)
+
+		for _, q := range questions {
+			assert.Equal(t, "條件查詢", q.Type)
+			assert.Equal(t, "shop", q.SchemaID)
+		}
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		assert.Equal(t, []int64{1, 2, 3}, listIDs(t, database.ListQuestionsParams{Tags: []string{"select"}}))
+		assert.Equal(t, []int64{1, 3}, listIDs(t, database.ListQuestionsParams{Tags: []string{"select", "where"}}))
+		assert.Empty(t, listIDs(t, database.ListQuestionsParams{Tags: []string{"join"}}))
+	})
+
+	t.Run("search", func(t *testing.T) {
+		assert.Equal(t, []int64{1}, listIDs(t, database.ListQuestionsParams{Search: "laptop"}))
+		// the description is searched as well
+		assert.Equal(t, []int64{3, 8}, listIDs(t, database.ListQuestionsParams{Search: `"with ID 1"`}))
+		assert.Equal(t, []int64{3}, listIDs(t, database.ListQuestionsParams{Search: `"with ID 1" -borrowings`}))
+	})
+
+	t.Run("sort by title", func(t *testing.T) {
+		questions, _, err := db.ListQuestions(ctx, database.ListQuestionsParams{Sort: database.QuestionSortTitle})
+		require.NoError(t, err)
+
+		assert.IsNonDecreasing(t, lo.Map(questions, func(q *models.Question, _ int) string {
+			return q.Title
+		}))
+	})
+
+	t.Run("sort by difficulty descending", func(t *testing.T) {
+		questions, _, err := db.ListQuestions(ctx, database.ListQuestionsParams{
+			Cursor:     database.Cursor{Limit: 100},
+			Sort:       database.QuestionSortDifficulty,
+			Descending: true,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, models.DifficultyHard, questions[0].Difficulty)
+		assert.Equal(t, models.DifficultyEasy, questions[len(questions)-1].Difficulty)
+	})
+
+	t.Run("unknown sort", func(t *testing.T) {
+		_, _, err := db.ListQuestions(ctx, database.ListQuestionsParams{Sort: "answer"})
+		assert.Error(t, err)
+	})
+}
+
+func TestListQuestions_Keyset(t *testing.T) {
+	t.Parallel()
+
+	db, cleanup := createOnetimeDatabase(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(ctx))
+	require.NoError(t, db.SeedTestOnly(ctx))
+
+	for _, sort := range []database.QuestionSort{
+		database.QuestionSortID,
+		database.QuestionSortCreatedAt,
+		database.QuestionSortDifficulty,
+		database.QuestionSortTitle,
+	} {
+		t.Run(string(sort), func(t *testing.T) {
+			params := database.ListQuestionsParams{Sort: sort, Descending: true}
+
+			all, next, err := db.ListQuestions(ctx, database.ListQuestionsParams{
+				Cursor:     database.Cursor{Limit: 100},
+				Sort:       sort,
+				Descending: true,
+			})
+			require.NoError(t, err)
+			assert.Empty(t, next, "no next page")
+
+			var paged []*models.Question
+			params.Limit = 4
+			for {
+				questions, next, err := db.ListQuestions(ctx, params)
+				require.NoError(t, err)
+				require.LessOrEqual(t, len(questions), 4)
+
+				paged = append(paged, questions...)
+				if next == "" {
+					break
+				}
+				params.After = next
+			}
+
+			assert.Equal(t, all, paged)
+		})
+	}
+
+	t.Run("the offset is ignored after the cursor", func(t *testing.T) {
+		first, next, err := db.ListQuestions(ctx, database.ListQuestionsParams{Cursor: database.Cursor{Limit: 2}})
+		require.NoError(t, err)
+		require.NotEmpty(t, next)
+
+		second, _, err := db.ListQuestions(ctx, database.ListQuestionsParams{Cursor: database.Cursor{Limit: 2, Offset: 5, After: next}})
+		require.NoError(t, err)
+		require.Len(t, second, 2)
+		assert.Equal(t, first[1].ID+1, second[0].ID)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		_, _, err := db.ListQuestions(ctx, database.ListQuestionsParams{Cursor: database.Cursor{After: "invalid"}})
+		assert.ErrorIs(t, err, database.ErrInvalidCursor)
+	})
+
+	t.Run("the sort is changed", func(t *testing.T) {
+		_, next, err := db.ListQuestions(ctx, database.ListQuestionsParams{Cursor: database.Cursor{Limit: 2}})
+		require.NoError(t, err)
+
+		_, _, err = db.ListQuestions(ctx, database.ListQuestionsParams{
+			Cursor: database.Cursor{After: next},
+			Sort:   database.QuestionSortTitle,
+		})
+		assert.ErrorIs(t, err, database.ErrInvalidCursor)
+	})
+}
+
+func TestCountQuestions(t *testing.T) {
+	t.Parallel()
+
+	db, cleanup := createOnetimeDatabase(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(ctx))
+	require.NoError(t, db.SeedTestOnly(ctx))
+
+	all, _, err := db.ListQuestions(ctx, database.ListQuestionsParams{Cursor: database.Cursor{Limit: 100}})
+	require.NoError(t, err)
+
+	count, err := db.CountQuestions(ctx, database.ListQuestionsParams{})
+	require.NoError(t, err)
+	assert.EqualValues(t, len(all), count)
+
+	// the cursor is ignored
+	count, err = db.CountQuestions(ctx, database.ListQuestionsParams{
+		Cursor: database.Cursor{Limit: 1, Offset: 2},
+		Tags:   []string{"select"},
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, count)
+}
+
 func TestGetQuestion(t *testing.T) {
 	t.Parallel()
 
@@ -98,14 +281,14 @@ func TestGetQuestion(t *testing.T) {
 	require.NoError(t, db.SeedTestOnly(ctx))
 
 	// List questions
-	questions, err := db.ListQuestions(ctx, database.ListQuestionsParams{})
+	questions, _, err := db.ListQuestions(ctx, database.ListQuestionsParams{})
 	if err != nil {
 		t.Fatalf("failed to get schema: %v", err)
 	}
 
 	// Check if GetQuestion returns the same question
 	for _, listQuestion := range questions {
-		getQuestion, err := db.GetQuestion(ctx, listQuestion.ID)
+		getQuestion, err := db.GetQuestion(ctx, listQuestion.ID, false)
 		if err != nil {
 			t.Fatalf("failed to get schema: %v", err)
 		}
@@ -129,6 +312,25 @@ func TestGetQuestionAnswer(t *testing.T) {
 	assert.EqualValues(t, 1, questionAnswer.ID)
 	assert.EqualValues(t, "SELECT * FROM products WHERE product_name = 'Laptop';", questionAnswer.Answer)
 	assert.Contains(t, questionAnswer.Schema, "CREATE TABLE products (")
+	assert.Equal(t, models.DiffPolicySummary, questionAnswer.DiffPolicy)
+	assert.Equal(t, models.CompareOptions{}, questionAnswer.CompareOptions)
+	assert.False(t, questionAnswer.GradeByState)
+	assert.Empty(t, questionAnswer.StateTables)
+
+	// the limits of the question override the limits of the schema
+	assert.Equal(t, models.ExecutionLimits{TimeoutMS: 2000, MaxRows: 100}, questionAnswer.Limits)
+	assert.Empty(t, questionAnswer.SandboxAllow)
+
+	// the fixture runs after the initial SQL of the schema
+	require.Len(t, questionAnswer.Datasets, 1)
+	assert.True(t, strings.HasPrefix(questionAnswer.Datasets[0].Schema, questionAnswer.Schema))
+	assert.Contains(t, questionAnswer.Datasets[0].Schema, "(3, 'Laptop', 1299.99, 5)")
+
+	t.Run("no datasets", func(t *testing.T) {
+		questionAnswer, err := db.GetQuestionAnswer(ctx, 2)
+		require.NoError(t, err)
+		assert.Empty(t, questionAnswer.Datasets)
+	})
 }
 
 func TestGetQuestionSolution(t *testing.T) {
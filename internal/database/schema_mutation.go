@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/database-playground/backend/internal/models"
+	"github.com/georgysavva/scany/v2/pgxscan"
+)
+
+// SchemaParams is the writable fields of a schema.
+type SchemaParams struct {
+	ID          string
+	Picture     *string
+	Description string
+	InitialSQL  string
+	Limits      models.ExecutionLimits
+}
+
+// CreateSchema creates a schema. It returns [ErrAlreadyExists] if the ID
+// is used, including by a deleted schema, which can be restored instead.
+func (db *Database) CreateSchema(ctx context.Context, params SchemaParams) (*models.Schema, error) {
+	var schema models.Schema
+
+	err := pgxscan.Get(ctx, db.pool, &schema, `
+		--sql
+		INSERT INTO dp_schemas (schema_id, picture, description, initial_sql, execution_limits)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING schema_id, picture, description, created_at, updated_at, deleted_at;
+	`, params.ID, params.Picture, params.Description, params.InitialSQL, params.Limits)
+	if isPgError(err, uniqueViolation) {
+		return nil, ErrAlreadyExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &schema, nil
+}
+
+// UpdateSchema replaces the fields of the schema of params.ID, which must
+// not be deleted.
+//
+// If the initial SQL or the limits change, the recorded results of the
+// answers of its questions no longer apply, and are cleared in the same
+// transaction until the questions are written again.
+func (db *Database) UpdateSchema(ctx context.Context, params SchemaParams) (*models.Schema, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	// Lock the schema, so no questions can record the results on the
+	// old schema until it is updated.
+	var runtime SchemaRuntime
+	err = pgxscan.Get(ctx, tx, &runtime, `
+		--sql
+		SELECT initial_sql, execution_limits FROM dp_schemas
+		WHERE schema_id = $1 AND deleted_at IS NULL
+		FOR UPDATE;
+	`, params.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var schema models.Schema
+	err = pgxscan.Get(ctx, tx, &schema, `
+		--sql
+		UPDATE dp_schemas
+		SET picture = $2, description = $3, initial_sql = $4, execution_limits = $5
+		WHERE schema_id = $1
+		RETURNING schema_id, picture, description, created_at, updated_at, deleted_at;
+	`, params.ID, params.Picture, params.Description, params.InitialSQL, params.Limits)
+	if err != nil {
+		return nil, err
+	}
+
+	if runtime.InitialSQL != params.InitialSQL || runtime.Limits != params.Limits {
+		_, err = tx.Exec(ctx, `
+			--sql
+			UPDATE dp_questions SET answer_output_hash = NULL, answer_row_count = NULL
+			WHERE schema_id = $1;
+		`, params.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &schema, tx.Commit(ctx)
+}
+
+// DeleteSchema soft-deletes the schema. It returns [ErrSchemaInUse] if
+// there are questions using it, instead of leaving them without a schema.
+// The deleted questions do not count, as the schema is not purged until
+// they are.
+func (db *Database) DeleteSchema(ctx context.Context, schemaID string) error {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	// Lock the schema, so no questions can be created with it until it
+	// is deleted.
+	var id string
+	err = tx.QueryRow(ctx, `
+		--sql
+		SELECT schema_id FROM dp_schemas WHERE schema_id = $1 AND deleted_at IS NULL FOR UPDATE;
+	`, schemaID).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	var inUse bool
+	err = tx.QueryRow(ctx, `
+		--sql
+		SELECT EXISTS (SELECT 1 FROM dp_questions WHERE schema_id = $1 AND deleted_at IS NULL);
+	`, schemaID).Scan(&inUse)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrSchemaInUse
+	}
+
+	_, err = tx.Exec(ctx, `
+		--sql
+		UPDATE dp_schemas SET deleted_at = CURRENT_TIMESTAMP WHERE schema_id = $1;
+	`, schemaID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RestoreSchema restores the soft-deleted schema.
+func (db *Database) RestoreSchema(ctx context.Context, schemaID string) (*models.Schema, error) {
+	var schema models.Schema
+
+	err := pgxscan.Get(ctx, db.pool, &schema, `
+		--sql
+		UPDATE dp_schemas SET deleted_at = NULL
+		WHERE schema_id = $1 AND deleted_at IS NOT NULL
+		RETURNING schema_id, picture, description, created_at, updated_at, deleted_at;
+	`, schemaID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &schema, nil
+}
@@ -0,0 +1,159 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/database-playground/backend/internal/database"
+	"github.com/database-playground/backend/internal/models"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaMutation(t *testing.T) {
+	t.Parallel()
+
+	db, cleanup := createOnetimeDatabase(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(ctx))
+	require.NoError(t, db.SeedTestOnly(ctx))
+
+	params := database.SchemaParams{
+		ID:          "bookstore",
+		Description: "The schema of a bookstore",
+		InitialSQL:  "CREATE TABLE books (id INTEGER PRIMARY KEY, title TEXT);",
+		Limits:      models.ExecutionLimits{MaxRows: 100},
+	}
+
+	schema, err := db.CreateSchema(ctx, params)
+	require.NoError(t, err)
+	assert.Equal(t, "bookstore", schema.ID)
+	assert.Equal(t, "The schema of a bookstore", schema.Description)
+
+	_, err = db.CreateSchema(ctx, params)
+	assert.ErrorIs(t, err, database.ErrAlreadyExists)
+
+	params.Description = "The schema of a used bookstore"
+	params.InitialSQL = "CREATE TABLE books (id INTEGER PRIMARY KEY, title TEXT, author TEXT);"
+	schema, err = db.UpdateSchema(ctx, params)
+	require.NoError(t, err)
+	assert.Equal(t, "The schema of a used bookstore", schema.Description)
+
+	initialSQL, err := db.GetSchemaInitialSQL(ctx, "bookstore")
+	require.NoError(t, err)
+	assert.Equal(t, params.InitialSQL, initialSQL.InitialSQL)
+
+	require.NoError(t, db.DeleteSchema(ctx, "bookstore"))
+
+	_, err = db.GetSchema(ctx, "bookstore", false)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	_, err = db.GetSchemaInitialSQL(ctx, "bookstore")
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	deleted, err := db.GetSchema(ctx, "bookstore", true)
+	require.NoError(t, err)
+	assert.NotNil(t, deleted.DeletedAt)
+
+	_, err = db.UpdateSchema(ctx, params)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	_, err = db.CreateSchema(ctx, params)
+	assert.ErrorIs(t, err, database.ErrAlreadyExists)
+
+	restored, err := db.RestoreSchema(ctx, "bookstore")
+	require.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+
+	_, err = db.RestoreSchema(ctx, "bookstore")
+	assert.ErrorIs(t, err, database.ErrNotFound)
+}
+
+func TestSchemaMutation_NotFound(t *testing.T) {
+	t.Parallel()
+
+	db, cleanup := createOnetimeDatabase(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(ctx))
+	require.NoError(t, db.SeedTestOnly(ctx))
+
+	_, err := db.UpdateSchema(ctx, database.SchemaParams{ID: "not-found", InitialSQL: "SELECT 1;"})
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	err = db.DeleteSchema(ctx, "not-found")
+	assert.ErrorIs(t, err, database.ErrNotFound)
+}
+
+func TestDeleteSchema_InUse(t *testing.T) {
+	t.Parallel()
+
+	db, cleanup := createOnetimeDatabase(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(ctx))
+	require.NoError(t, db.SeedTestOnly(ctx))
+
+	err := db.DeleteSchema(ctx, "shop")
+	assert.ErrorIs(t, err, database.ErrSchemaInUse)
+
+	_, err = db.GetSchema(ctx, "shop", false)
+	assert.NoError(t, err)
+}
+
+func TestUpdateSchema_ClearsAnswerResults(t *testing.T) {
+	t.Parallel()
+
+	db, cleanup := createOnetimeDatabase(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(ctx))
+	require.NoError(t, db.SeedTestOnly(ctx))
+
+	question, err := db.CreateQuestion(ctx, database.QuestionParams{
+		SchemaID:   "shop",
+		Type:       "select",
+		Difficulty: models.DifficultyEasy,
+		Title:      "List the products",
+		Answer:     "SELECT * FROM products;",
+		DiffPolicy: models.DiffPolicySummary,
+
+		AnswerOutputHash: lo.ToPtr("output-hash"),
+		AnswerRowCount:   lo.ToPtr[int64](2),
+	})
+	require.NoError(t, err)
+
+	runtime, err := db.GetSchemaRuntime(ctx, "shop")
+	require.NoError(t, err)
+
+	params := database.SchemaParams{
+		ID:          "shop",
+		Description: "The schema of a shop",
+		InitialSQL:  runtime.InitialSQL,
+		Limits:      runtime.Limits,
+	}
+
+	// The description does not change the results of the answers.
+	_, err = db.UpdateSchema(ctx, params)
+	require.NoError(t, err)
+
+	answer, err := db.GetQuestionAnswer(ctx, question.ID)
+	require.NoError(t, err)
+	assert.Equal(t, lo.ToPtr("output-hash"), answer.AnswerOutputHash)
+	assert.Equal(t, lo.ToPtr[int64](2), answer.AnswerRowCount)
+
+	params.InitialSQL = "CREATE TABLE products (product_id INT PRIMARY KEY);"
+	_, err = db.UpdateSchema(ctx, params)
+	require.NoError(t, err)
+
+	answer, err = db.GetQuestionAnswer(ctx, question.ID)
+	require.NoError(t, err)
+	assert.Nil(t, answer.AnswerOutputHash)
+	assert.Nil(t, answer.AnswerRowCount)
+}
@@ -7,15 +7,17 @@ import (
 	"github.com/georgysavva/scany/v2/pgxscan"
 )
 
-func (db *Database) GetSchema(ctx context.Context, schemaID string) (*models.Schema, error) {
+// GetSchema returns the schema. The soft-deleted schema is not found
+// unless includeDeleted is true.
+func (db *Database) GetSchema(ctx context.Context, schemaID string, includeDeleted bool) (*models.Schema, error) {
 	var schema models.Schema
 
 	err := pgxscan.Get(ctx, db.pool, &schema, `
 		--sql
-		SELECT schema_id, picture, description, created_at, updated_at
+		SELECT schema_id, picture, description, created_at, updated_at, deleted_at
 		FROM dp_schemas
-		WHERE schema_id = $1;
-	`, schemaID)
+		WHERE schema_id = $1 AND ($2 OR deleted_at IS NULL);
+	`, schemaID, includeDeleted)
 	if err != nil {
 		return nil, err
 	}
@@ -30,7 +32,7 @@ func (db *Database) GetSchemaInitialSQL(ctx context.Context, schemaID string) (*
 		--sql
 		SELECT schema_id, initial_sql
 		FROM dp_schemas
-		WHERE schema_id = $1;
+		WHERE schema_id = $1 AND deleted_at IS NULL;
 	`, schemaID)
 	if err != nil {
 		return nil, err
@@ -38,3 +40,27 @@ func (db *Database) GetSchemaInitialSQL(ctx context.Context, schemaID string) (*
 
 	return &model, nil
 }
+
+// SchemaRuntime is what the queries on a schema run with.
+type SchemaRuntime struct {
+	InitialSQL string                 `db:"initial_sql"`
+	Limits     models.ExecutionLimits `db:"execution_limits"`
+}
+
+// GetSchemaRuntime returns the initial SQL and the execution limits of
+// the schema, for example, to run the answer of a question on it.
+func (db *Database) GetSchemaRuntime(ctx context.Context, schemaID string) (*SchemaRuntime, error) {
+	var runtime SchemaRuntime
+
+	err := pgxscan.Get(ctx, db.pool, &runtime, `
+		--sql
+		SELECT initial_sql, execution_limits
+		FROM dp_schemas
+		WHERE schema_id = $1 AND deleted_at IS NULL;
+	`, schemaID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &runtime, nil
+}
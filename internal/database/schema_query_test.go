@@ -19,7 +19,7 @@ func TestGetSchema(t *testing.T) {
 	require.NoError(t, db.Migrate(ctx))
 	require.NoError(t, db.SeedTestOnly(ctx))
 
-	schema, err := db.GetSchema(ctx, "shop")
+	schema, err := db.GetSchema(ctx, "shop", false)
 	if err != nil {
 		t.Fatalf("failed to get schema: %v", err)
 	}
@@ -59,6 +59,6 @@ func TestGetSchema_NotFound(t *testing.T) {
 	require.NoError(t, db.Migrate(ctx))
 	require.NoError(t, db.SeedTestOnly(ctx))
 
-	_, err := db.GetSchema(ctx, "not-found")
+	_, err := db.GetSchema(ctx, "not-found", false)
 	assert.ErrorIs(t, err, database.ErrNotFound)
 }
@@ -0,0 +1,7 @@
+INSERT INTO dp_question_datasets (question_id, kind, initial_sql)
+VALUES (
+    1,
+    'fixture',
+    'INSERT INTO products (product_id, product_name, price, stock) VALUES
+    (3, ''Laptop'', 1299.99, 5);'
+);
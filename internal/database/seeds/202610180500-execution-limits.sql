@@ -0,0 +1,7 @@
+UPDATE dp_schemas
+SET execution_limits = '{"timeout_ms": 2000, "max_rows": 1000}'::JSONB
+WHERE schema_id = 'shop';
+
+UPDATE dp_questions
+SET execution_limits = '{"max_rows": 100}'::JSONB
+WHERE question_id = 1;
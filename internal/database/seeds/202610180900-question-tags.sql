@@ -0,0 +1,7 @@
+UPDATE dp_questions
+SET tags = '{select, where}'
+WHERE question_id IN (1, 3);
+
+UPDATE dp_questions
+SET tags = '{select}'
+WHERE question_id = 2;
@@ -0,0 +1,121 @@
+package dbrunner
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// CellType is the SQLite storage class of a cell.
+type CellType string
+
+const (
+	// CellTypeUnspecified is the type of the cells cached before the
+	// cell types are recorded.
+	CellTypeUnspecified CellType = ""
+	CellTypeNull        CellType = "null"
+	CellTypeInteger     CellType = "integer"
+	CellTypeReal        CellType = "real"
+	CellTypeText        CellType = "text"
+	CellTypeBlob        CellType = "blob"
+)
+
+// IsNumeric returns whether the cell type is integer or real.
+func (t CellType) IsNumeric() bool {
+	return t == CellTypeInteger || t == CellTypeReal
+}
+
+// Cell is a cell of the query result.
+type Cell struct {
+	// Value is the string representation of the cell. It is nil if the cell is NULL.
+	//
+	// Integers and reals are formatted in the shortest representation, and
+	// blobs are formatted in lowercase hexadecimal.
+	Value *string `json:"value"`
+	// Type is the storage class of the cell.
+	Type CellType `json:"type,omitempty"`
+}
+
+// NewCell creates a cell from the value returned by the SQLite driver.
+func NewCell(value any) Cell {
+	var formatted string
+	var cellType CellType
+
+	switch value := value.(type) {
+	case nil:
+		return Cell{Type: CellTypeNull}
+	case int64:
+		formatted, cellType = strconv.FormatInt(value, 10), CellTypeInteger
+	case float64:
+		formatted, cellType = strconv.FormatFloat(value, 'g', -1, 64), CellTypeReal
+	case string:
+		formatted, cellType = value, CellTypeText
+	case []byte:
+		formatted, cellType = hex.EncodeToString(value), CellTypeBlob
+	default:
+		// for example, time.Time parsed from the DATE columns.
+		formatted, cellType = fmt.Sprintf("%v", value), CellTypeText
+	}
+
+	return Cell{Value: &formatted, Type: cellType}
+}
+
+// IsNull returns whether the cell is NULL.
+func (c Cell) IsNull() bool {
+	return c.Value == nil
+}
+
+// Equal returns whether the values of the two cells are the same.
+//
+// The types are not compared, so the integer 1 equals the text '1'.
+func (c Cell) Equal(other Cell) bool {
+	if c.Value == nil || other.Value == nil {
+		return c.Value == other.Value
+	}
+
+	return *c.Value == *other.Value
+}
+
+// Float returns the numeric value of the cell.
+//
+// ok is false if the cell is not numeric.
+func (c Cell) Float() (value float64, ok bool) {
+	if c.Value == nil || !c.Type.IsNumeric() {
+		return 0, false
+	}
+
+	value, err := strconv.ParseFloat(*c.Value, 64)
+	return value, err == nil
+}
+
+// UnmarshalJSON unmarshals the cell. For compatibility, it accepts
+// the nullable string which was the cell format before the cell types
+// are recorded.
+func (c *Cell) UnmarshalJSON(data []byte) error {
+	var value *string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*c = Cell{Value: value}
+		return nil
+	}
+
+	type cell Cell
+	return json.Unmarshal(data, (*cell)(c))
+}
+
+// CellScanner scans a value into a [Cell].
+type CellScanner struct {
+	cell Cell
+}
+
+func (s *CellScanner) Scan(value any) error {
+	s.cell = NewCell(value)
+	return nil
+}
+
+func (s *CellScanner) Cell() Cell {
+	return s.cell
+}
+
+var _ sql.Scanner = &CellScanner{}
@@ -0,0 +1,55 @@
+package dbrunner_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/database-playground/backend/internal/dbrunner"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCell(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		value    any
+		expected dbrunner.Cell
+	}{
+		{"null", nil, dbrunner.Cell{Type: dbrunner.CellTypeNull}},
+		{"integer", int64(42), dbrunner.Cell{Value: lo.ToPtr("42"), Type: dbrunner.CellTypeInteger}},
+		{"real", 19.99, dbrunner.Cell{Value: lo.ToPtr("19.99"), Type: dbrunner.CellTypeReal}},
+		{"text", "Alice", dbrunner.Cell{Value: lo.ToPtr("Alice"), Type: dbrunner.CellTypeText}},
+		{"blob", []byte{0xde, 0xad}, dbrunner.Cell{Value: lo.ToPtr("dead"), Type: dbrunner.CellTypeBlob}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, c.expected, dbrunner.NewCell(c.value))
+		})
+	}
+}
+
+func TestCell_UnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	t.Run("typed cell", func(t *testing.T) {
+		t.Parallel()
+
+		var cells []dbrunner.Cell
+		require.NoError(t, json.Unmarshal([]byte(`[{"value":"1","type":"integer"},{"value":null,"type":"null"}]`), &cells))
+		assert.Equal(t, []dbrunner.Cell{dbrunner.NewCell(int64(1)), dbrunner.NewCell(nil)}, cells)
+	})
+
+	t.Run("legacy nullable string", func(t *testing.T) {
+		t.Parallel()
+
+		var cells []dbrunner.Cell
+		require.NoError(t, json.Unmarshal([]byte(`["1",null]`), &cells))
+		assert.Equal(t, []dbrunner.Cell{{Value: lo.ToPtr("1")}, {}}, cells)
+	})
+}
@@ -0,0 +1,300 @@
+package dbrunner
+
+import (
+	"cmp"
+	"encoding/json"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CompareOptions configures how two outputs are compared.
+//
+// The zero value compares the outputs exactly.
+type CompareOptions struct {
+	// IgnoreRowOrder compares the rows regardless of their order,
+	// for example, when the question does not require ORDER BY.
+	IgnoreRowOrder bool `json:"ignore_row_order,omitempty"`
+	// IgnoreColumnOrder compares the columns regardless of their order,
+	// for example, SELECT a, b and SELECT b, a.
+	IgnoreColumnOrder bool `json:"ignore_column_order,omitempty"`
+	// IgnoreColumnNames compares the columns regardless of their names
+	// and aliases.
+	IgnoreColumnNames bool `json:"ignore_column_names,omitempty"`
+	// IgnoreDuplicateRows compares the rows as a set instead of a multiset.
+	IgnoreDuplicateRows bool `json:"ignore_duplicate_rows,omitempty"`
+	// FloatEpsilon is the maximum absolute difference between two
+	// numeric cells to be considered the same.
+	FloatEpsilon float64 `json:"float_epsilon,omitempty"`
+	// DecimalPlaces rounds the numeric cells to the decimal places
+	// before comparing. nil means no rounding.
+	DecimalPlaces *int `json:"decimal_places,omitempty"`
+	// CompareState compares the captured database states instead of
+	// the query results. See [Input.CaptureState].
+	CompareState bool `json:"compare_state,omitempty"`
+}
+
+// IsZero returns whether the options compare the outputs exactly.
+func (o CompareOptions) IsZero() bool {
+	return !o.IgnoreRowOrder && !o.IgnoreColumnOrder && !o.IgnoreColumnNames &&
+		!o.IgnoreDuplicateRows && o.FloatEpsilon == 0 && o.DecimalPlaces == nil &&
+		!o.CompareState
+}
+
+// Canonicalize returns the canonical form of the output with the options,
+// so the outputs considered the same by the options have the same [Output.Hash].
+//
+// The output itself is not modified.
+func (o Output) Canonicalize(opts CompareOptions) Output {
+	header := slices.Clone(o.Header)
+	data := make([][]Cell, len(o.Data))
+	for i, row := range o.Data {
+		data[i] = slices.Clone(row)
+	}
+
+	if opts.DecimalPlaces != nil {
+		for _, row := range data {
+			for i, cell := range row {
+				row[i] = roundCell(cell, *opts.DecimalPlaces)
+			}
+		}
+	}
+
+	if opts.IgnoreColumnNames {
+		for i := range header {
+			header[i] = ""
+		}
+	}
+
+	if opts.IgnoreColumnOrder {
+		header, data = sortColumns(header, data, opts.IgnoreRowOrder)
+	}
+
+	if opts.IgnoreDuplicateRows {
+		seen := make(map[string]struct{}, len(data))
+		data = slices.DeleteFunc(data, func(row []Cell) bool {
+			key := rowKey(row)
+			if _, ok := seen[key]; ok {
+				return true
+			}
+			seen[key] = struct{}{}
+			return false
+		})
+	}
+
+	if opts.IgnoreRowOrder {
+		slices.SortStableFunc(data, func(a, b []Cell) int {
+			return cmp.Compare(rowKey(a), rowKey(b))
+		})
+	}
+
+	return Output{
+		Header: header,
+		Data:   data,
+	}
+}
+
+// sortColumns sorts the columns by their names and then their values.
+//
+// If the row order is ignored, the values of a column are sorted
+// before comparing, so the column order does not depend on the row order.
+func sortColumns(header []string, data [][]Cell, ignoreRowOrder bool) ([]string, [][]Cell) {
+	type column struct {
+		index int
+		name  string
+		key   string
+	}
+
+	columns := make([]column, len(header))
+	for i, name := range header {
+		values := make([]*string, len(data))
+		for j, row := range data {
+			if i < len(row) {
+				values[j] = row[i].Value
+			}
+		}
+
+		if ignoreRowOrder {
+			slices.SortFunc(values, func(a, b *string) int {
+				return compareNullableString(a, b)
+			})
+		}
+
+		// json.Marshal never fails on []*string.
+		key, _ := json.Marshal(values)
+		columns[i] = column{index: i, name: name, key: string(key)}
+	}
+
+	slices.SortStableFunc(columns, func(a, b column) int {
+		return cmp.Or(cmp.Compare(a.name, b.name), cmp.Compare(a.key, b.key))
+	})
+
+	sortedHeader := make([]string, len(columns))
+	for i, column := range columns {
+		sortedHeader[i] = column.name
+	}
+
+	sortedData := make([][]Cell, len(data))
+	for j, row := range data {
+		sortedRow := make([]Cell, len(columns))
+		for i, column := range columns {
+			if column.index < len(row) {
+				sortedRow[i] = row[column.index]
+			}
+		}
+		sortedData[j] = sortedRow
+	}
+
+	return sortedHeader, sortedData
+}
+
+// compareNullableString compares two nullable strings. NULL is the smallest.
+func compareNullableString(a, b *string) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	default:
+		return cmp.Compare(*a, *b)
+	}
+}
+
+// roundCell rounds the numeric cell to the decimal places.
+// The other cells are returned as is.
+func roundCell(cell Cell, places int) Cell {
+	value, ok := cell.Float()
+	if !ok {
+		return cell
+	}
+
+	formatted := strconv.FormatFloat(value, 'f', max(places, 0), 64)
+	return Cell{Value: &formatted, Type: CellTypeReal}
+}
+
+// Equal returns whether the two outputs are the same with the options.
+//
+// The outputs are compared by the same parts as [Output.Hash]: the results,
+// the statement results, the captured states and whether they are truncated.
+// The options relax how the results are compared, so the outputs with the
+// same hash are always the same, and with the zero options, the outputs with
+// different hashes are never the same.
+//
+// The cells are compared by their values as [Cell.Equal] does, except that
+// the numeric cells within [CompareOptions.FloatEpsilon] are considered the
+// same. Note that the rows are sorted by their string forms when the row
+// order is ignored, so the rows only different within the epsilon may be
+// sorted differently. Use [CompareOptions.DecimalPlaces] in that case.
+//
+// If [CompareOptions.CompareState] is set, only the captured states are
+// compared table by table with the other options.
+//
+// A truncated output never equals an output that is not truncated,
+// since the dropped rows are unknown.
+func Equal(left, right Output, opts CompareOptions) bool {
+	if left.Truncated != right.Truncated {
+		return false
+	}
+
+	if opts.CompareState {
+		_, different := firstDifferentTable(left, right, opts)
+		return !different
+	}
+
+	if !resultsEqual(left, right, opts) {
+		return false
+	}
+
+	if !slices.EqualFunc(left.Statements, right.Statements, func(leftStatement, rightStatement StatementResult) bool {
+		return equalRowsAffected(leftStatement.RowsAffected, rightStatement.RowsAffected) &&
+			resultsEqual(leftStatement.Output(), rightStatement.Output(), opts)
+	}) {
+		return false
+	}
+
+	_, different := firstDifferentTable(left, right, opts)
+	return !different
+}
+
+// resultsEqual returns whether the headers and the rows of the two outputs
+// are the same with the options.
+func resultsEqual(left, right Output, opts CompareOptions) bool {
+	left, right = left.Canonicalize(opts), right.Canonicalize(opts)
+
+	if !slices.Equal(left.Header, right.Header) {
+		return false
+	}
+
+	return slices.EqualFunc(left.Data, right.Data, func(leftRow, rightRow []Cell) bool {
+		return slices.EqualFunc(leftRow, rightRow, func(leftCell, rightCell Cell) bool {
+			return cellsEqualWithin(leftCell, rightCell, opts.FloatEpsilon)
+		})
+	})
+}
+
+// equalRowsAffected compares the numbers of rows affected by two statements.
+func equalRowsAffected(left, right *int64) bool {
+	if left == nil || right == nil {
+		return left == right
+	}
+
+	return *left == *right
+}
+
+// cellsEqualWithin returns whether the two cells are the same.
+//
+// The numeric cells are compared by their values if the epsilon is
+// positive, so 1000000 equals 1e+06 within any epsilon.
+func cellsEqualWithin(left, right Cell, epsilon float64) bool {
+	if epsilon <= 0 {
+		return left.Equal(right)
+	}
+
+	leftValue, leftOk := left.Float()
+	rightValue, rightOk := right.Float()
+	if leftOk && rightOk {
+		return math.Abs(leftValue-rightValue) <= epsilon
+	}
+
+	return left.Equal(right)
+}
+
+// firstDifferentTable returns the name of the first table whose
+// captured state is different in the two outputs.
+func firstDifferentTable(left, right Output, opts CompareOptions) (name string, different bool) {
+	opts.CompareState = false
+
+	names := make([]string, 0, len(left.State)+len(right.State))
+	for _, table := range slices.Concat(left.State, right.State) {
+		if !slices.ContainsFunc(names, func(name string) bool { return strings.EqualFold(name, table.Name) }) {
+			names = append(names, table.Name)
+		}
+	}
+
+	for _, name := range names {
+		leftTable, leftOk := findTable(left.State, name)
+		rightTable, rightOk := findTable(right.State, name)
+
+		if leftOk != rightOk || leftTable.Missing != rightTable.Missing ||
+			!Equal(leftTable.Output(), rightTable.Output(), opts) {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// findTable finds the state of the table by its case-insensitive name.
+func findTable(state []TableState, name string) (TableState, bool) {
+	index := slices.IndexFunc(state, func(table TableState) bool {
+		return strings.EqualFold(table.Name, name)
+	})
+	if index == -1 {
+		return TableState{}, false
+	}
+
+	return state[index], true
+}
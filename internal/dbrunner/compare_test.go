@@ -0,0 +1,274 @@
+package dbrunner_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/database-playground/backend/internal/dbrunner"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutput_Canonicalize(t *testing.T) {
+	t.Parallel()
+
+	base := dbrunner.Output{
+		Header: []string{"id", "name"},
+		Data: [][]dbrunner.Cell{
+			{dbrunner.NewCell("1"), dbrunner.NewCell("Alice")},
+			{dbrunner.NewCell("2"), dbrunner.NewCell(nil)},
+		},
+	}
+
+	sameHash := func(t *testing.T, a, b dbrunner.Output, opts dbrunner.CompareOptions) bool {
+		t.Helper()
+		return lo.Must(a.Canonicalize(opts).Hash()) == lo.Must(b.Canonicalize(opts).Hash())
+	}
+
+	t.Run("zero options keep the output as is", func(t *testing.T) {
+		t.Parallel()
+
+		assert.True(t, dbrunner.CompareOptions{}.IsZero())
+		assert.Equal(t, lo.Must(base.Hash()), lo.Must(base.Canonicalize(dbrunner.CompareOptions{}).Hash()))
+	})
+
+	t.Run("row order", func(t *testing.T) {
+		t.Parallel()
+
+		reordered := dbrunner.Output{
+			Header: base.Header,
+			Data:   [][]dbrunner.Cell{base.Data[1], base.Data[0]},
+		}
+
+		assert.False(t, sameHash(t, base, reordered, dbrunner.CompareOptions{}))
+		assert.True(t, sameHash(t, base, reordered, dbrunner.CompareOptions{IgnoreRowOrder: true}))
+	})
+
+	t.Run("column order", func(t *testing.T) {
+		t.Parallel()
+
+		reordered := dbrunner.Output{
+			Header: []string{"name", "id"},
+			Data: [][]dbrunner.Cell{
+				{dbrunner.NewCell("Alice"), dbrunner.NewCell("1")},
+				{dbrunner.NewCell(nil), dbrunner.NewCell("2")},
+			},
+		}
+
+		assert.False(t, sameHash(t, base, reordered, dbrunner.CompareOptions{}))
+		assert.True(t, sameHash(t, base, reordered, dbrunner.CompareOptions{IgnoreColumnOrder: true}))
+	})
+
+	t.Run("column names", func(t *testing.T) {
+		t.Parallel()
+
+		aliased := dbrunner.Output{
+			Header: []string{"student_id", "student_name"},
+			Data:   base.Data,
+		}
+
+		assert.False(t, sameHash(t, base, aliased, dbrunner.CompareOptions{}))
+		assert.True(t, sameHash(t, base, aliased, dbrunner.CompareOptions{IgnoreColumnNames: true}))
+	})
+
+	t.Run("column names and order", func(t *testing.T) {
+		t.Parallel()
+
+		reordered := dbrunner.Output{
+			Header: []string{"b", "a"},
+			Data: [][]dbrunner.Cell{
+				{dbrunner.NewCell(nil), dbrunner.NewCell("2")},
+				{dbrunner.NewCell("Alice"), dbrunner.NewCell("1")},
+			},
+		}
+
+		opts := dbrunner.CompareOptions{IgnoreColumnNames: true, IgnoreColumnOrder: true, IgnoreRowOrder: true}
+		assert.False(t, sameHash(t, base, reordered, dbrunner.CompareOptions{IgnoreColumnNames: true, IgnoreColumnOrder: true}))
+		assert.True(t, sameHash(t, base, reordered, opts))
+	})
+
+	t.Run("duplicate rows", func(t *testing.T) {
+		t.Parallel()
+
+		duplicated := dbrunner.Output{
+			Header: base.Header,
+			Data:   [][]dbrunner.Cell{base.Data[0], base.Data[1], base.Data[0]},
+		}
+
+		assert.False(t, sameHash(t, base, duplicated, dbrunner.CompareOptions{IgnoreRowOrder: true}))
+		assert.True(t, sameHash(t, base, duplicated, dbrunner.CompareOptions{IgnoreDuplicateRows: true}))
+	})
+
+	t.Run("the output itself is not modified", func(t *testing.T) {
+		t.Parallel()
+
+		output := dbrunner.Output{
+			Header: []string{"b", "a"},
+			Data:   [][]dbrunner.Cell{{dbrunner.NewCell("2"), dbrunner.NewCell("1")}},
+		}
+
+		_ = output.Canonicalize(dbrunner.CompareOptions{IgnoreColumnNames: true, IgnoreColumnOrder: true})
+		assert.Equal(t, []string{"b", "a"}, output.Header)
+		assert.Equal(t, "2", *output.Data[0][0].Value)
+	})
+}
+
+func TestEqual(t *testing.T) {
+	t.Parallel()
+
+	output := func(cells ...dbrunner.Cell) dbrunner.Output {
+		return dbrunner.Output{
+			Header: []string{"price"},
+			Data:   [][]dbrunner.Cell{cells},
+		}
+	}
+
+	t.Run("integer and real with the same value", func(t *testing.T) {
+		t.Parallel()
+
+		assert.True(t, dbrunner.Equal(output(dbrunner.NewCell(int64(1))), output(dbrunner.NewCell(1.0)), dbrunner.CompareOptions{}))
+	})
+
+	t.Run("integer and real in different forms", func(t *testing.T) {
+		t.Parallel()
+
+		left := output(dbrunner.NewCell(int64(1000000)))
+		right := output(dbrunner.NewCell(1e6))
+
+		assert.False(t, dbrunner.Equal(left, right, dbrunner.CompareOptions{}))
+		assert.True(t, dbrunner.Equal(left, right, dbrunner.CompareOptions{FloatEpsilon: 1e-9}))
+	})
+
+	t.Run("numeric text is not numeric", func(t *testing.T) {
+		t.Parallel()
+
+		assert.False(t, dbrunner.Equal(output(dbrunner.NewCell("1.0")), output(dbrunner.NewCell(int64(1))), dbrunner.CompareOptions{}))
+	})
+
+	t.Run("float epsilon", func(t *testing.T) {
+		t.Parallel()
+
+		left := output(dbrunner.NewCell(19.99))
+		right := output(dbrunner.NewCell(19.990000000000002))
+
+		assert.False(t, dbrunner.Equal(left, right, dbrunner.CompareOptions{}))
+		assert.True(t, dbrunner.Equal(left, right, dbrunner.CompareOptions{FloatEpsilon: 1e-9}))
+		assert.False(t, dbrunner.Equal(left, output(dbrunner.NewCell(20.0)), dbrunner.CompareOptions{FloatEpsilon: 1e-9}))
+	})
+
+	t.Run("decimal places", func(t *testing.T) {
+		t.Parallel()
+
+		left := output(dbrunner.NewCell(3.14159))
+		right := output(dbrunner.NewCell(3.1416))
+
+		assert.False(t, dbrunner.Equal(left, right, dbrunner.CompareOptions{}))
+		assert.True(t, dbrunner.Equal(left, right, dbrunner.CompareOptions{DecimalPlaces: lo.ToPtr(2)}))
+
+		// rounding makes the hashes of canonical forms the same as well
+		opts := dbrunner.CompareOptions{DecimalPlaces: lo.ToPtr(2)}
+		assert.Equal(t, lo.Must(left.Canonicalize(opts).Hash()), lo.Must(right.Canonicalize(opts).Hash()))
+	})
+
+	t.Run("NULL", func(t *testing.T) {
+		t.Parallel()
+
+		assert.True(t, dbrunner.Equal(output(dbrunner.NewCell(nil)), output(dbrunner.NewCell(nil)), dbrunner.CompareOptions{FloatEpsilon: 1}))
+		assert.False(t, dbrunner.Equal(output(dbrunner.NewCell(nil)), output(dbrunner.NewCell(int64(0))), dbrunner.CompareOptions{FloatEpsilon: 1}))
+	})
+
+	t.Run("different header", func(t *testing.T) {
+		t.Parallel()
+
+		right := output(dbrunner.NewCell(int64(1)))
+		right.Header = []string{"cost"}
+
+		assert.False(t, dbrunner.Equal(output(dbrunner.NewCell(int64(1))), right, dbrunner.CompareOptions{}))
+	})
+
+	t.Run("statement results", func(t *testing.T) {
+		t.Parallel()
+
+		withStatements := func(rowsAffected int64, cells ...dbrunner.Cell) dbrunner.Output {
+			result := output(dbrunner.NewCell(int64(1)))
+			result.Statements = []dbrunner.StatementResult{
+				{Header: []string{}, Data: [][]dbrunner.Cell{}, RowsAffected: &rowsAffected},
+				{Header: []string{"price"}, Data: [][]dbrunner.Cell{cells}},
+			}
+			return result
+		}
+
+		left := withStatements(1, dbrunner.NewCell(19.99))
+
+		assert.True(t, dbrunner.Equal(left, withStatements(1, dbrunner.NewCell(19.99)), dbrunner.CompareOptions{}))
+		assert.False(t, dbrunner.Equal(left, withStatements(2, dbrunner.NewCell(19.99)), dbrunner.CompareOptions{}))
+		assert.False(t, dbrunner.Equal(left, output(dbrunner.NewCell(int64(1))), dbrunner.CompareOptions{}))
+
+		// the statement results are compared with the options as well
+		right := withStatements(1, dbrunner.NewCell(19.990000000000002))
+		assert.False(t, dbrunner.Equal(left, right, dbrunner.CompareOptions{}))
+		assert.True(t, dbrunner.Equal(left, right, dbrunner.CompareOptions{FloatEpsilon: 1e-9}))
+	})
+
+	t.Run("the same as comparing the hashes without options", func(t *testing.T) {
+		t.Parallel()
+
+		rowsAffected := int64(1)
+		base := output(dbrunner.NewCell(int64(1)))
+
+		withStatement := base
+		withStatement.Statements = []dbrunner.StatementResult{{Header: []string{}, Data: [][]dbrunner.Cell{}, RowsAffected: &rowsAffected}}
+
+		withState := base
+		withState.State = []dbrunner.TableState{{Name: "customers", Header: []string{"id"}, Data: [][]dbrunner.Cell{}}}
+
+		truncated := base
+		truncated.Truncated = true
+
+		outputs := []dbrunner.Output{
+			base, withStatement, withState, truncated,
+			output(dbrunner.NewCell(1.0)),
+			output(dbrunner.NewCell(1e6)),
+			output(dbrunner.NewCell(int64(1000000))),
+			output(dbrunner.NewCell("1")),
+			output(dbrunner.NewCell(nil)),
+		}
+
+		for _, left := range outputs {
+			for _, right := range outputs {
+				sameHash := lo.Must(left.Hash()) == lo.Must(right.Hash())
+				assert.Equal(t, sameHash, dbrunner.Equal(left, right, dbrunner.CompareOptions{}), "%+v and %+v", left, right)
+			}
+		}
+	})
+
+	t.Run("compare state", func(t *testing.T) {
+		t.Parallel()
+
+		withState := func(header []string, rows ...[]dbrunner.Cell) dbrunner.Output {
+			return dbrunner.Output{
+				Header: header,
+				Data:   [][]dbrunner.Cell{},
+				State: []dbrunner.TableState{
+					{Name: "customers", Header: []string{"id"}, Data: rows},
+				},
+			}
+		}
+
+		left := withState([]string{}, []dbrunner.Cell{dbrunner.NewCell(int64(2))})
+		right := withState([]string{"id"}, []dbrunner.Cell{dbrunner.NewCell(int64(2))})
+		different := withState([]string{}, []dbrunner.Cell{dbrunner.NewCell(int64(3))})
+
+		assert.False(t, dbrunner.Equal(left, right, dbrunner.CompareOptions{}))
+		assert.True(t, dbrunner.Equal(left, right, dbrunner.CompareOptions{CompareState: true}))
+		assert.False(t, dbrunner.Equal(left, different, dbrunner.CompareOptions{CompareState: true}))
+
+		missing := left
+		missing.State = []dbrunner.TableState{{Name: "customers", Missing: true}}
+		assert.False(t, dbrunner.Equal(left, missing, dbrunner.CompareOptions{CompareState: true}))
+
+		extra := left
+		extra.State = append(slices.Clone(left.State), dbrunner.TableState{Name: "orders", Header: []string{"id"}})
+		assert.False(t, dbrunner.Equal(left, extra, dbrunner.CompareOptions{CompareState: true}))
+	})
+}
@@ -0,0 +1,273 @@
+package dbrunner
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+)
+
+// OutputDiff is the difference between two outputs.
+//
+// The left output is treated as the expected output, and the
+// right output is treated as the actual output.
+type OutputDiff struct {
+	LeftHeader  []string
+	RightHeader []string
+
+	LeftRowCount  int
+	RightRowCount int
+
+	// HeaderMismatch is true if the headers are different.
+	HeaderMismatch bool
+	// OrderMismatch is true if the rows are the same but in the different order.
+	OrderMismatch bool
+
+	// MissingRows are the rows that are in the left output but not in the right output.
+	MissingRows []RowDiff
+	// ExtraRows are the rows that are in the right output but not in the left output.
+	ExtraRows []RowDiff
+	// CellChanges are the cells that are different in the paired rows.
+	CellChanges []CellDiff
+
+	// Truncated is true if there are more differences than reported.
+	// See [Diff].
+	Truncated bool
+}
+
+// Same returns whether the two outputs are the same.
+func (d OutputDiff) Same() bool {
+	return !d.HeaderMismatch && !d.OrderMismatch && !d.Truncated &&
+		len(d.MissingRows) == 0 && len(d.ExtraRows) == 0 && len(d.CellChanges) == 0
+}
+
+type RowDiff struct {
+	// Index is the zero-based index of the row in its output.
+	Index int
+	Cells []Cell
+}
+
+type CellDiff struct {
+	LeftRow  int
+	RightRow int
+	Column   int
+	Left     Cell
+	Right    Cell
+}
+
+// Diff compares the left output with the right output.
+//
+// The rows are matched regardless of their position first. The unmatched
+// rows that share at least one cell at the same column are then paired up
+// and reported as cell changes; the rest are reported as missing or extra rows.
+//
+// At most maxRows missing, extra and changed rows are reported in total,
+// and [OutputDiff.Truncated] is set if there are more. The rows are not
+// paired after the limit, so the extra rows are not reported then.
+// maxRows is not limited if it is negative.
+func Diff(left, right Output, maxRows int) OutputDiff {
+	diff := OutputDiff{
+		LeftHeader:     left.Header,
+		RightHeader:    right.Header,
+		LeftRowCount:   len(left.Data),
+		RightRowCount:  len(right.Data),
+		HeaderMismatch: !slices.Equal(left.Header, right.Header),
+	}
+
+	// rightIndices maps the key of a row to the indices of the
+	// right rows with this key which have not been matched yet.
+	rightIndices := make(map[string][]int, len(right.Data))
+	for i, row := range right.Data {
+		key := rowKey(row)
+		rightIndices[key] = append(rightIndices[key], i)
+	}
+
+	var unmatchedLeft []int
+	lastMatched := -1
+	for i, row := range left.Data {
+		key := rowKey(row)
+
+		indices := rightIndices[key]
+		if len(indices) == 0 {
+			unmatchedLeft = append(unmatchedLeft, i)
+			continue
+		}
+
+		if indices[0] < lastMatched {
+			diff.OrderMismatch = true
+		}
+		lastMatched = indices[0]
+		rightIndices[key] = indices[1:]
+	}
+
+	var unmatchedRight []int
+	for _, indices := range rightIndices {
+		unmatchedRight = append(unmatchedRight, indices...)
+	}
+	slices.Sort(unmatchedRight)
+
+	// reported is the number of the rows reported so far.
+	reported := 0
+	full := func() bool {
+		return maxRows >= 0 && reported >= maxRows
+	}
+
+	// Pair the unmatched rows in order.
+	candidates := newPairCandidates(right.Data, unmatchedRight)
+	for n, li := range unmatchedLeft {
+		if full() {
+			diff.Truncated = true
+			unmatchedLeft = unmatchedLeft[:n]
+			break
+		}
+
+		leftRow := left.Data[li]
+		reported++
+
+		pairedRi, ok := candidates.pair(leftRow)
+		if !ok {
+			diff.MissingRows = append(diff.MissingRows, RowDiff{
+				Index: li,
+				Cells: leftRow,
+			})
+			continue
+		}
+
+		rightRow := right.Data[pairedRi]
+		for column := range leftRow {
+			if !leftRow[column].Equal(rightRow[column]) {
+				diff.CellChanges = append(diff.CellChanges, CellDiff{
+					LeftRow:  li,
+					RightRow: pairedRi,
+					Column:   column,
+					Left:     leftRow[column],
+					Right:    rightRow[column],
+				})
+			}
+		}
+	}
+
+	// The unpaired right rows may be paired with the left rows
+	// not paired yet, so they are not reported as extra rows.
+	if !diff.Truncated {
+		for _, ri := range unmatchedRight {
+			if candidates.paired[ri] {
+				continue
+			}
+			if full() {
+				diff.Truncated = true
+				break
+			}
+
+			reported++
+			diff.ExtraRows = append(diff.ExtraRows, RowDiff{
+				Index: ri,
+				Cells: right.Data[ri],
+			})
+		}
+	}
+
+	// The order does not matter if the rows are different.
+	if len(diff.MissingRows) > 0 || len(diff.ExtraRows) > 0 || len(diff.CellChanges) > 0 || diff.Truncated {
+		diff.OrderMismatch = false
+	}
+
+	return diff
+}
+
+// pairCandidates finds the first unpaired right row sharing a cell at
+// the same column with a left row, without comparing it with every
+// unpaired right row.
+type pairCandidates struct {
+	rows [][]Cell
+	// buckets maps the width, the column and the value of a cell to the
+	// indices of the unpaired right rows with this cell, in order. The
+	// paired rows are removed from the front of the buckets lazily.
+	buckets map[string][]int
+	paired  map[int]bool
+}
+
+func newPairCandidates(rows [][]Cell, indices []int) *pairCandidates {
+	candidates := &pairCandidates{
+		rows:    rows,
+		buckets: make(map[string][]int),
+		paired:  make(map[int]bool, len(indices)),
+	}
+
+	for _, i := range indices {
+		for column, cell := range rows[i] {
+			key := cellBucketKey(len(rows[i]), column, cell)
+			candidates.buckets[key] = append(candidates.buckets[key], i)
+		}
+	}
+
+	return candidates
+}
+
+// pair pairs the left row with the first unpaired right row which has
+// the same width and shares at least one cell at the same column.
+func (c *pairCandidates) pair(row []Cell) (index int, ok bool) {
+	index = -1
+	for column, cell := range row {
+		key := cellBucketKey(len(row), column, cell)
+
+		bucket := c.buckets[key]
+		for len(bucket) > 0 && c.paired[bucket[0]] {
+			bucket = bucket[1:]
+		}
+		c.buckets[key] = bucket
+
+		if len(bucket) > 0 && (index == -1 || bucket[0] < index) {
+			index = bucket[0]
+		}
+	}
+
+	if index == -1 {
+		return 0, false
+	}
+
+	c.paired[index] = true
+	return index, true
+}
+
+// cellBucketKey returns the key of the cell at the column of a row
+// of the width. The cells with the same key are [Cell.Equal].
+func cellBucketKey(width, column int, cell Cell) string {
+	prefix := strconv.Itoa(width) + ":" + strconv.Itoa(column)
+	if cell.Value == nil {
+		return prefix + "!"
+	}
+
+	return prefix + "=" + *cell.Value
+}
+
+// DiffState compares the captured states of the left output with the
+// right output, and returns the difference of the first different table.
+// See [Diff] for maxRows.
+//
+// The returned table is empty if the states are the same.
+func DiffState(left, right Output, opts CompareOptions, maxRows int) (table string, diff OutputDiff) {
+	table, different := firstDifferentTable(left, right, opts)
+	if !different {
+		return "", Diff(Output{}, Output{}, maxRows)
+	}
+
+	opts.CompareState = false
+	leftTable, _ := findTable(left.State, table)
+	rightTable, _ := findTable(right.State, table)
+
+	return table, Diff(leftTable.Output().Canonicalize(opts), rightTable.Output().Canonicalize(opts), maxRows)
+}
+
+// rowKey returns the comparable key of a row.
+//
+// Only the values are used, so the key does not depend on the cell types.
+func rowKey(row []Cell) string {
+	values := make([]*string, len(row))
+	for i, cell := range row {
+		values[i] = cell.Value
+	}
+
+	// json.Marshal never fails on []*string.
+	key, _ := json.Marshal(values)
+	return string(key)
+}
@@ -0,0 +1,231 @@
+package dbrunner_test
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/database-playground/backend/internal/dbrunner"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	base := dbrunner.Output{
+		Header: []string{"id", "name"},
+		Data: [][]dbrunner.Cell{
+			{dbrunner.NewCell("1"), dbrunner.NewCell("Alice")},
+			{dbrunner.NewCell("2"), dbrunner.NewCell("Bob")},
+			{dbrunner.NewCell("3"), dbrunner.NewCell(nil)},
+		},
+	}
+
+	t.Run("same output", func(t *testing.T) {
+		t.Parallel()
+
+		diff := dbrunner.Diff(base, base, -1)
+		assert.True(t, diff.Same())
+		assert.Equal(t, 3, diff.LeftRowCount)
+		assert.Equal(t, 3, diff.RightRowCount)
+	})
+
+	t.Run("different header", func(t *testing.T) {
+		t.Parallel()
+
+		right := dbrunner.Output{
+			Header: []string{"id", "username"},
+			Data:   base.Data,
+		}
+
+		diff := dbrunner.Diff(base, right, -1)
+		assert.False(t, diff.Same())
+		assert.True(t, diff.HeaderMismatch)
+		assert.Empty(t, diff.MissingRows)
+		assert.Empty(t, diff.ExtraRows)
+		assert.Empty(t, diff.CellChanges)
+	})
+
+	t.Run("different order", func(t *testing.T) {
+		t.Parallel()
+
+		right := dbrunner.Output{
+			Header: base.Header,
+			Data:   [][]dbrunner.Cell{base.Data[2], base.Data[0], base.Data[1]},
+		}
+
+		diff := dbrunner.Diff(base, right, -1)
+		assert.False(t, diff.Same())
+		assert.True(t, diff.OrderMismatch)
+		assert.Empty(t, diff.MissingRows)
+		assert.Empty(t, diff.ExtraRows)
+	})
+
+	t.Run("missing and extra rows", func(t *testing.T) {
+		t.Parallel()
+
+		right := dbrunner.Output{
+			Header: base.Header,
+			Data: [][]dbrunner.Cell{
+				base.Data[0],
+				{dbrunner.NewCell("4"), dbrunner.NewCell("Dave")},
+			},
+		}
+
+		diff := dbrunner.Diff(base, right, -1)
+		assert.False(t, diff.Same())
+		assert.False(t, diff.OrderMismatch)
+		assert.Equal(t, []dbrunner.RowDiff{
+			{Index: 1, Cells: base.Data[1]},
+			{Index: 2, Cells: base.Data[2]},
+		}, diff.MissingRows)
+		assert.Equal(t, []dbrunner.RowDiff{
+			{Index: 1, Cells: right.Data[1]},
+		}, diff.ExtraRows)
+		assert.Empty(t, diff.CellChanges)
+	})
+
+	t.Run("changed cells", func(t *testing.T) {
+		t.Parallel()
+
+		right := dbrunner.Output{
+			Header: base.Header,
+			Data: [][]dbrunner.Cell{
+				base.Data[0],
+				{dbrunner.NewCell("2"), dbrunner.NewCell("Bobby")},
+				{dbrunner.NewCell("3"), dbrunner.NewCell("Charlie")},
+			},
+		}
+
+		diff := dbrunner.Diff(base, right, -1)
+		assert.False(t, diff.Same())
+		assert.Empty(t, diff.MissingRows)
+		assert.Empty(t, diff.ExtraRows)
+		assert.Equal(t, []dbrunner.CellDiff{
+			{LeftRow: 1, RightRow: 1, Column: 1, Left: dbrunner.NewCell("Bob"), Right: dbrunner.NewCell("Bobby")},
+			{LeftRow: 2, RightRow: 2, Column: 1, Left: dbrunner.NewCell(nil), Right: dbrunner.NewCell("Charlie")},
+		}, diff.CellChanges)
+	})
+
+	t.Run("duplicated rows", func(t *testing.T) {
+		t.Parallel()
+
+		right := dbrunner.Output{
+			Header: base.Header,
+			Data:   append(append([][]dbrunner.Cell{}, base.Data...), base.Data[0]),
+		}
+
+		diff := dbrunner.Diff(base, right, -1)
+		assert.False(t, diff.Same())
+		assert.Empty(t, diff.MissingRows)
+		assert.Equal(t, []dbrunner.RowDiff{
+			{Index: 3, Cells: base.Data[0]},
+		}, diff.ExtraRows)
+	})
+}
+
+func TestDiff_Bounded(t *testing.T) {
+	t.Parallel()
+
+	const rows = 20000
+
+	// No rows match, and the rows of the same width share no cell,
+	// which is the worst case of pairing the rows one by one.
+	output := func(prefix string) dbrunner.Output {
+		output := dbrunner.Output{Header: []string{"a", "b", "c"}}
+		for i := range rows {
+			value := prefix + strconv.Itoa(i)
+			output.Data = append(output.Data, []dbrunner.Cell{
+				dbrunner.NewCell(value), dbrunner.NewCell(value), dbrunner.NewCell(value),
+			})
+		}
+		return output
+	}
+	left, right := output("left"), output("right")
+
+	t.Run("the unmatched rows are paired without comparing every pair", func(t *testing.T) {
+		t.Parallel()
+
+		start := time.Now()
+		diff := dbrunner.Diff(left, right, -1)
+		elapsed := time.Since(start)
+
+		assert.Len(t, diff.MissingRows, rows)
+		assert.Len(t, diff.ExtraRows, rows)
+		assert.Empty(t, diff.CellChanges)
+		assert.False(t, diff.Truncated)
+		// comparing every pair takes minutes
+		assert.Less(t, elapsed, 5*time.Second)
+	})
+
+	t.Run("the reported rows are limited", func(t *testing.T) {
+		t.Parallel()
+
+		diff := dbrunner.Diff(left, right, 10)
+		assert.Len(t, diff.MissingRows, 10)
+		assert.Empty(t, diff.ExtraRows)
+		assert.True(t, diff.Truncated)
+		assert.False(t, diff.Same())
+
+		diff = dbrunner.Diff(left, right, 0)
+		assert.Empty(t, diff.MissingRows)
+		assert.True(t, diff.Truncated)
+	})
+
+	t.Run("the rows sharing a cell are paired in order", func(t *testing.T) {
+		t.Parallel()
+
+		right := dbrunner.Output{Header: left.Header, Data: slices.Clone(left.Data)}
+		right.Data[0] = []dbrunner.Cell{left.Data[0][0], dbrunner.NewCell("x"), dbrunner.NewCell("x")}
+		right.Data[rows-1] = []dbrunner.Cell{dbrunner.NewCell("y"), left.Data[rows-1][1], dbrunner.NewCell(nil)}
+
+		diff := dbrunner.Diff(left, right, 3)
+		assert.Empty(t, diff.MissingRows)
+		assert.Empty(t, diff.ExtraRows)
+		assert.False(t, diff.Truncated)
+		assert.Equal(t, []dbrunner.CellDiff{
+			{LeftRow: 0, RightRow: 0, Column: 1, Left: left.Data[0][1], Right: dbrunner.NewCell("x")},
+			{LeftRow: 0, RightRow: 0, Column: 2, Left: left.Data[0][2], Right: dbrunner.NewCell("x")},
+			{LeftRow: rows - 1, RightRow: rows - 1, Column: 0, Left: left.Data[rows-1][0], Right: dbrunner.NewCell("y")},
+			{LeftRow: rows - 1, RightRow: rows - 1, Column: 2, Left: left.Data[rows-1][2], Right: dbrunner.NewCell(nil)},
+		}, diff.CellChanges)
+	})
+}
+
+func TestDiffState(t *testing.T) {
+	t.Parallel()
+
+	left := dbrunner.Output{
+		State: []dbrunner.TableState{
+			{Name: "customers", Header: []string{"id"}, Data: [][]dbrunner.Cell{{dbrunner.NewCell(int64(2))}}},
+			{Name: "orders", Header: []string{"id"}, Data: [][]dbrunner.Cell{}},
+		},
+	}
+
+	t.Run("same state", func(t *testing.T) {
+		t.Parallel()
+
+		table, diff := dbrunner.DiffState(left, left, dbrunner.CompareOptions{CompareState: true}, -1)
+		assert.Empty(t, table)
+		assert.True(t, diff.Same())
+	})
+
+	t.Run("different table", func(t *testing.T) {
+		t.Parallel()
+
+		right := dbrunner.Output{
+			State: []dbrunner.TableState{
+				left.State[0],
+				{Name: "orders", Header: []string{"id"}, Data: [][]dbrunner.Cell{{dbrunner.NewCell(int64(1))}}},
+			},
+		}
+
+		table, diff := dbrunner.DiffState(left, right, dbrunner.CompareOptions{CompareState: true}, -1)
+		assert.Equal(t, "orders", table)
+		assert.False(t, diff.Same())
+		assert.Equal(t, []dbrunner.RowDiff{
+			{Index: 0, Cells: []dbrunner.Cell{dbrunner.NewCell(int64(1))}},
+		}, diff.ExtraRows)
+	})
+}
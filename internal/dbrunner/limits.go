@@ -0,0 +1,321 @@
+package dbrunner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"modernc.org/libc"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// DefaultTimeout is the timeout of a query if [Limits.Timeout] is not set.
+const DefaultTimeout = 1 * time.Second
+
+// Limits limits the resources a query can use.
+//
+// The zero value of each field means no limit, except Timeout,
+// which defaults to [DefaultTimeout].
+type Limits struct {
+	// Timeout is the maximum duration of running the initial SQL and the query.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// MaxRows is the maximum number of rows kept in each result.
+	// The rest of the rows are dropped, and the output is marked as truncated.
+	MaxRows int `json:"max_rows,omitempty"`
+	// MaxCellBytes is the maximum bytes of a cell. The longer cells are
+	// cut, and the output is marked as truncated.
+	MaxCellBytes int `json:"max_cell_bytes,omitempty"`
+
+	// MaxOutputBytes is the maximum total bytes of the headers and the
+	// cells in the output. The query fails if the output is larger.
+	MaxOutputBytes int64 `json:"max_output_bytes,omitempty"`
+	// MaxDatabaseBytes is the maximum size of the in-memory database,
+	// which bounds the memory the query can use to store data. The query
+	// fails if it grows the database larger.
+	//
+	// The database created by the initial SQL is allowed to be larger,
+	// but the query cannot grow it anymore.
+	MaxDatabaseBytes int64 `json:"max_database_bytes,omitempty"`
+
+	// MaxVMSteps is the maximum number of the virtual machine instructions
+	// SQLite runs for the query, which bounds the work the query can do
+	// regardless of the load of the server. The query fails if it runs more.
+	MaxVMSteps int64 `json:"max_vm_steps,omitempty"`
+	// MaxHeapBytes is the maximum bytes SQLite can allocate while the
+	// query runs, in addition to what it has allocated before. The query
+	// fails if SQLite needs more.
+	//
+	// The heap limit of SQLite applies to the whole process, so it is only
+	// enforced when the query runs alone in a worker of [WorkerPool].
+	MaxHeapBytes int64 `json:"max_heap_bytes,omitempty"`
+}
+
+// IsZero returns whether the limits are the default limits.
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// timeout returns the timeout of the query.
+func (l Limits) timeout() time.Duration {
+	if l.Timeout <= 0 {
+		return DefaultTimeout
+	}
+
+	return l.Timeout
+}
+
+// Limit is a kind of limit the query can exceed.
+type Limit string
+
+const (
+	LimitTimeout       Limit = "timeout"
+	LimitOutputBytes   Limit = "output_bytes"
+	LimitDatabaseBytes Limit = "database_bytes"
+	LimitVMSteps       Limit = "vm_steps"
+	LimitHeapBytes     Limit = "heap_bytes"
+)
+
+// LimitError is returned when a query exceeds one of its [Limits].
+type LimitError struct {
+	Limit Limit
+	// Max is the value of the exceeded limit, in nanoseconds for the
+	// timeout, in instructions for the VM steps, and in bytes for the others.
+	Max int64
+	Err error
+}
+
+func (e *LimitError) Error() string {
+	switch e.Limit {
+	case LimitTimeout:
+		return fmt.Sprintf("query timeout (takes more than %s)", time.Duration(e.Max))
+	case LimitOutputBytes:
+		return fmt.Sprintf("output too large (more than %d bytes)", e.Max)
+	case LimitDatabaseBytes:
+		return fmt.Sprintf("database too large (more than %d bytes)", e.Max)
+	case LimitVMSteps:
+		return fmt.Sprintf("query too complex (runs more than %d steps)", e.Max)
+	case LimitHeapBytes:
+		return fmt.Sprintf("query uses too much memory (more than %d bytes)", e.Max)
+	default:
+		return fmt.Sprintf("limit %s exceeded", e.Limit)
+	}
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// limitError converts the error caused by exceeding the limits
+// to a [LimitError]. Other errors are returned as is.
+func (l Limits) limitError(err error) error {
+	if err == nil || errors.As(err, new(*LimitError)) {
+		return err
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &LimitError{Limit: LimitTimeout, Max: int64(l.timeout()), Err: err}
+	}
+
+	code := sqliteErrorCode(err)
+	if l.MaxDatabaseBytes > 0 && code == sqlite3.SQLITE_FULL {
+		return &LimitError{Limit: LimitDatabaseBytes, Max: l.MaxDatabaseBytes, Err: err}
+	}
+	if l.MaxHeapBytes > 0 && code == sqlite3.SQLITE_NOMEM {
+		return &LimitError{Limit: LimitHeapBytes, Max: l.MaxHeapBytes, Err: err}
+	}
+
+	return err
+}
+
+// sqliteErrorCode returns the primary result code of the SQLite error,
+// or zero if the error is not from SQLite.
+func sqliteErrorCode(err error) int {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() & 0xff
+	}
+
+	var queryErr *QueryError
+	if errors.As(err, &queryErr) {
+		return queryErr.code & 0xff
+	}
+
+	return 0
+}
+
+// stepLimiters are the installed step limiters.
+var stepLimiters callbacks[*stepLimiter]
+
+// stepInterval is the number of the VM instructions between the checks
+// of [Limits.MaxVMSteps].
+const stepInterval = 1000
+
+// stepLimiter interrupts the statements running more than
+// [Limits.MaxVMSteps] VM instructions in total, as the progress
+// handler of the connection.
+type stepLimiter struct {
+	id       uintptr
+	max      int64
+	interval int64
+	steps    atomic.Int64
+	exceeded atomic.Bool
+}
+
+// installStepLimiter installs the step limiter on the connection.
+// It returns nil if the steps are not limited.
+func installStepLimiter(h handle, maxSteps int64) *stepLimiter {
+	if maxSteps <= 0 {
+		return nil
+	}
+
+	l := &stepLimiter{max: maxSteps, interval: min(stepInterval, maxSteps)}
+	l.id = stepLimiters.add(l)
+
+	sqlite3.Xsqlite3_progress_handler(h.tls, h.db, int32(l.interval), cFuncPointer(progressCallback), l.id)
+
+	return l
+}
+
+// uninstall uninstalls the step limiter from the connection.
+func (l *stepLimiter) uninstall(h handle) {
+	if l == nil {
+		return
+	}
+
+	sqlite3.Xsqlite3_progress_handler(h.tls, h.db, 0, 0, 0)
+	stepLimiters.remove(l.id)
+}
+
+// limitError returns a [LimitError] if the error is caused by
+// exceeding the steps. Other errors are returned as is.
+func (l *stepLimiter) limitError(err error) error {
+	if l == nil || err == nil || !l.exceeded.Load() {
+		return err
+	}
+
+	return &LimitError{Limit: LimitVMSteps, Max: l.max, Err: err}
+}
+
+// progressCallback is the progress handler of SQLite, which interrupts
+// the statement by returning non-zero.
+func progressCallback(tls *libc.TLS, id uintptr) int32 {
+	l, ok := stepLimiters.get(id)
+	if !ok {
+		return 0
+	}
+
+	if l.steps.Add(l.interval) > l.max {
+		l.exceeded.Store(true)
+		return 1
+	}
+
+	return 0
+}
+
+// enableHeapLimit enables the memory statistics of SQLite, which the heap
+// limit needs but the driver disables at compile time. It must be called
+// before SQLite is initialized by opening the first database.
+func enableHeapLimit() error {
+	tls := libc.NewTLS()
+	defer tls.Close()
+
+	args := libc.NewVaList(int32(1))
+	defer libc.Xfree(tls, args)
+
+	if rc := sqlite3.Xsqlite3_config(tls, sqlite3.SQLITE_CONFIG_MEMSTATUS, args); rc != sqlite3.SQLITE_OK {
+		return fmt.Errorf("enable memory statistics: %s", libc.GoString(sqlite3.Xsqlite3_errstr(tls, rc)))
+	}
+
+	return nil
+}
+
+// limitHeap limits the bytes SQLite allocates from now on to maxBytes,
+// and returns the function removing the limit. The limit applies to
+// the whole process, and only takes effect after [enableHeapLimit].
+func limitHeap(maxBytes int64) (restore func()) {
+	if maxBytes <= 0 {
+		return func() {}
+	}
+
+	tls := libc.NewTLS()
+	used := sqlite3.Xsqlite3_memory_used(tls)
+	previous := sqlite3.Xsqlite3_hard_heap_limit64(tls, used+maxBytes)
+
+	return func() {
+		sqlite3.Xsqlite3_hard_heap_limit64(tls, previous)
+		tls.Close()
+	}
+}
+
+// resultLimiter applies the limits to the results of a query.
+type resultLimiter struct {
+	limits Limits
+	// bytes is the total bytes of the results so far.
+	bytes int64
+	// truncated is true if any row is dropped or any cell is cut.
+	truncated bool
+}
+
+// header accounts the header of a result.
+func (l *resultLimiter) header(header []string) error {
+	for _, column := range header {
+		if err := l.account(len(column)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// row accounts the row to be appended to a result with n rows.
+// It returns false if the row should be dropped. The long cells
+// are cut in place.
+func (l *resultLimiter) row(n int, cells []Cell) (bool, error) {
+	if l.limits.MaxRows > 0 && n >= l.limits.MaxRows {
+		l.truncated = true
+		return false, nil
+	}
+
+	for i, cell := range cells {
+		if cell.Value == nil {
+			continue
+		}
+
+		if l.limits.MaxCellBytes > 0 && len(*cell.Value) > l.limits.MaxCellBytes {
+			value := cutString(*cell.Value, l.limits.MaxCellBytes)
+			cells[i].Value = &value
+			l.truncated = true
+		}
+
+		if err := l.account(len(*cells[i].Value)); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// cutString cuts the string to at most n bytes without splitting
+// the UTF-8 encoded characters.
+func cutString(s string, n int) string {
+	end := n
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+
+	return s[:end]
+}
+
+func (l *resultLimiter) account(n int) error {
+	l.bytes += int64(n)
+	if l.limits.MaxOutputBytes > 0 && l.bytes > l.limits.MaxOutputBytes {
+		return &LimitError{Limit: LimitOutputBytes, Max: l.limits.MaxOutputBytes}
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package dbrunner_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/database-playground/backend/internal/dbrunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunQuery_Limits(t *testing.T) {
+	t.Parallel()
+
+	initSQL := `
+		CREATE TABLE test (id INTEGER PRIMARY KEY, name TEXT);
+		INSERT INTO test (name) VALUES ('Alice'), ('Bob'), ('Charlie');
+	`
+
+	t.Run("the rows exceeding max rows are dropped", func(t *testing.T) {
+		t.Parallel()
+
+		output, err := dbrunner.RunQuery(context.Background(), dbrunner.Input{
+			Init:   initSQL,
+			Query:  "SELECT name FROM test ORDER BY id;",
+			Limits: dbrunner.Limits{MaxRows: 2},
+		})
+		require.NoError(t, err)
+
+		assert.True(t, output.Truncated)
+		assert.Equal(t, [][]dbrunner.Cell{
+			{dbrunner.NewCell("Alice")},
+			{dbrunner.NewCell("Bob")},
+		}, output.Data)
+	})
+
+	t.Run("the output fitting max rows is not truncated", func(t *testing.T) {
+		t.Parallel()
+
+		output, err := dbrunner.RunQuery(context.Background(), dbrunner.Input{
+			Init:   initSQL,
+			Query:  "SELECT name FROM test ORDER BY id;",
+			Limits: dbrunner.Limits{MaxRows: 3},
+		})
+		require.NoError(t, err)
+
+		assert.False(t, output.Truncated)
+		assert.Len(t, output.Data, 3)
+	})
+
+	t.Run("the long cells are cut", func(t *testing.T) {
+		t.Parallel()
+
+		output, err := dbrunner.RunQuery(context.Background(), dbrunner.Input{
+			Init:   initSQL,
+			Query:  "SELECT name FROM test WHERE id = 3;",
+			Limits: dbrunner.Limits{MaxCellBytes: 4},
+		})
+		require.NoError(t, err)
+
+		assert.True(t, output.Truncated)
+		assert.Equal(t, "Char", *output.Data[0][0].Value)
+	})
+
+	t.Run("the long cells are cut at the character boundaries", func(t *testing.T) {
+		t.Parallel()
+
+		output, err := dbrunner.RunQuery(context.Background(), dbrunner.Input{
+			Query:  "SELECT 'éé', 'aé';",
+			Limits: dbrunner.Limits{MaxCellBytes: 1},
+		})
+		require.NoError(t, err)
+
+		assert.True(t, output.Truncated)
+		assert.Equal(t, "", *output.Data[0][0].Value)
+		assert.Equal(t, "a", *output.Data[0][1].Value)
+	})
+
+	t.Run("the query running too many steps exceeds max VM steps", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := dbrunner.RunQuery(context.Background(), dbrunner.Input{
+			Init: initSQL,
+			Query: `
+				WITH RECURSIVE cte (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM cte LIMIT 1000000)
+				SELECT count(*) FROM cte;
+			`,
+			Limits: dbrunner.Limits{MaxVMSteps: 10000},
+		})
+
+		var limitErr *dbrunner.LimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, dbrunner.LimitVMSteps, limitErr.Limit)
+		assert.Equal(t, "query too complex (runs more than 10000 steps)", err.Error())
+	})
+
+	t.Run("the query fitting max VM steps is run", func(t *testing.T) {
+		t.Parallel()
+
+		output, err := dbrunner.RunQuery(context.Background(), dbrunner.Input{
+			Init:   initSQL,
+			Query:  "SELECT count(*) FROM test;",
+			Limits: dbrunner.Limits{MaxVMSteps: 10000},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, [][]dbrunner.Cell{{dbrunner.NewCell(int64(3))}}, output.Data)
+	})
+
+	t.Run("the large output exceeds max output bytes", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := dbrunner.RunQuery(context.Background(), dbrunner.Input{
+			Init:   initSQL,
+			Query:  "SELECT a.name, b.name FROM test a CROSS JOIN test b;",
+			Limits: dbrunner.Limits{MaxOutputBytes: 32},
+		})
+
+		var limitErr *dbrunner.LimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, dbrunner.LimitOutputBytes, limitErr.Limit)
+	})
+
+	t.Run("the growing database exceeds max database bytes", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := dbrunner.RunQuery(context.Background(), dbrunner.Input{
+			Init: initSQL,
+			Query: `
+				CREATE TABLE big AS
+				WITH RECURSIVE cte (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM cte LIMIT 10000)
+				SELECT n, randomblob(1024) AS payload FROM cte;
+			`,
+			Limits: dbrunner.Limits{MaxDatabaseBytes: 1 << 20},
+		})
+
+		var limitErr *dbrunner.LimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, dbrunner.LimitDatabaseBytes, limitErr.Limit)
+	})
+
+	t.Run("the long query exceeds the timeout", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := dbrunner.RunQuery(context.Background(), dbrunner.Input{
+			Init: initSQL,
+			Query: `
+				WITH RECURSIVE cte (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM cte)
+				SELECT count(*) FROM cte;
+			`,
+			Limits: dbrunner.Limits{Timeout: 100 * time.Millisecond},
+		})
+
+		var limitErr *dbrunner.LimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, dbrunner.LimitTimeout, limitErr.Limit)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.Equal(t, "query timeout (takes more than 100ms)", err.Error())
+	})
+}
+
+func TestInputHash_Limits(t *testing.T) {
+	input := dbrunner.Input{Init: "CREATE TABLE a (id INTEGER);", Query: "SELECT * FROM a;"}
+
+	limited := input
+	limited.Limits = dbrunner.Limits{MaxRows: 10}
+
+	assert.NotEqual(t, input.Hash(), limited.Hash())
+}
@@ -1,56 +1,277 @@
 package dbrunner
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"time"
+	"slices"
+	"strings"
 
-	"github.com/samber/lo"
 	_ "modernc.org/sqlite"
 )
 
-const timeoutSecond = 1
+// RunQuery runs the query on the database initialized with the initial SQL.
+//
+// The query is run within [Input.Limits]. If the query exceeds one of
+// the limits, a [LimitError] is returned; if the results are cut to
+// fit the limits, [Output.Truncated] is set. If a statement performs
+// an operation [Input.Sandbox] denies, a [SandboxError] is returned
+// and the statement and the rest of the query are not run.
+func (r *Runner) RunQuery(ctx context.Context, input Input) (Output, error) {
+	output, err := r.runQuery(ctx, input)
+	if err != nil {
+		return Output{}, input.Limits.limitError(err)
+	}
 
-func RunQuery(ctx context.Context, input Input) (Output, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeoutSecond*time.Second)
+	return output, nil
+}
+
+func (r *Runner) runQuery(ctx context.Context, input Input) (Output, error) {
+	ctx, cancel := context.WithTimeout(ctx, input.Limits.timeout())
 	defer cancel()
 
 	db, err := sql.Open("sqlite", ":memory:")
 	if err != nil {
 		return Output{}, fmt.Errorf("open database: %w", err)
 	}
+	defer db.Close()
 
-	_, err = db.ExecContext(ctx, input.Init)
+	// Each connection of an in-memory database has its own database,
+	// so we run everything in the same connection.
+	conn, err := db.Conn(ctx)
 	if err != nil {
-		return Output{}, fmt.Errorf("exec init: %w", err)
+		return Output{}, fmt.Errorf("open connection: %w", err)
+	}
+	defer conn.Close()
+
+	if err := r.initialize(ctx, conn, input.Init, input.Limits.timeout()); err != nil {
+		return Output{}, err
+	}
+
+	if input.Limits.MaxDatabaseBytes > 0 {
+		if err := limitDatabaseSize(ctx, conn, input.Limits.MaxDatabaseBytes); err != nil {
+			return Output{}, err
+		}
+	}
+
+	limiter := &resultLimiter{limits: input.Limits}
+
+	var totalChanges int64
+	if err := conn.QueryRowContext(ctx, "SELECT total_changes()").Scan(&totalChanges); err != nil {
+		return Output{}, fmt.Errorf("get total changes: %w", err)
 	}
 
-	rows, err := db.QueryContext(ctx, input.Query)
+	// The sandbox and the steps only apply to the statements of the query.
+	var authorizer *authorizer
+	var stepLimiter *stepLimiter
+	if err := withHandle(conn, func(h handle) error {
+		authorizer = installAuthorizer(h, input.Sandbox)
+		stepLimiter = installStepLimiter(h, input.Limits.MaxVMSteps)
+		return nil
+	}); err != nil {
+		return Output{}, fmt.Errorf("install authorizer: %w", err)
+	}
+
+	results, err := runStatements(ctx, conn, input, authorizer, &totalChanges, limiter)
+	err = stepLimiter.limitError(err)
+
+	if uninstallErr := withHandle(conn, func(h handle) error {
+		stepLimiter.uninstall(h)
+		authorizer.uninstall(h)
+		return nil
+	}); uninstallErr != nil && err == nil {
+		err = fmt.Errorf("uninstall authorizer: %w", uninstallErr)
+	}
 	if err != nil {
-		return Output{}, fmt.Errorf("query: %w", err)
+		return Output{}, err
+	}
+
+	if len(results) == 0 {
+		return Output{
+			Header:      []string{},
+			ColumnTypes: []string{},
+			Data:        [][]Cell{},
+		}, nil
+	}
+
+	// The output is the result of the last statement returning rows.
+	output := Output{
+		Header:      []string{},
+		ColumnTypes: []string{},
+		Data:        [][]Cell{},
+	}
+	for _, result := range results {
+		if len(result.Header) > 0 {
+			output.Header, output.ColumnTypes, output.Data = result.Header, result.ColumnTypes, result.Data
+		}
+	}
+
+	// A single statement returning rows is fully described by the output itself.
+	if len(results) > 1 || results[0].RowsAffected != nil {
+		output.Statements = results
+	}
+
+	if input.CaptureState {
+		output.State, err = captureState(ctx, conn, input.StateTables, limiter)
+		if err != nil {
+			return Output{}, fmt.Errorf("capture state: %w", err)
+		}
+	}
+
+	output.Truncated = limiter.truncated
+
+	return output, nil
+}
+
+// runStatements runs the statements of the query in order and returns
+// their results.
+//
+// Each statement is prepared by SQLite to find where it ends, and exactly
+// the text of the statement is run, so the statements run are the ones
+// the authorizer checks. The rest of the query is not run if a statement
+// fails.
+func runStatements(ctx context.Context, conn *sql.Conn, input Input, authorizer *authorizer, totalChanges *int64, limiter *resultLimiter) ([]StatementResult, error) {
+	var results []StatementResult
+
+	query := input.Query
+	for index := 1; ; index++ {
+		var statement preparedStatement
+		var rest string
+		var ok bool
+
+		authorizer.begin()
+		err := withHandle(conn, func(h handle) error {
+			var err error
+			statement, rest, ok, err = h.nextStatement(query)
+			if err == nil && statement.VacuumInto && !input.Sandbox.allows(PermissionVacuumInto) {
+				return &SandboxError{Permission: PermissionVacuumInto}
+			}
+			return err
+		})
+		if err == nil && !ok {
+			return results, nil
+		}
+
+		authorizer.prepared(statement)
+		var result StatementResult
+		if err == nil {
+			result, err = runStatement(ctx, conn, statement.SQL, totalChanges, limiter)
+		}
+		if err != nil {
+			return nil, statementError(conn, query, index, authorizer, err)
+		}
+
+		results = append(results, result)
+		query = rest
+	}
+}
+
+// statementError returns the error of the statement at the index,
+// whose text starts the query.
+//
+// The statement is prefixed to the error if the query has more than
+// one statement, and the operation the authorizer denies is reported
+// as a [SandboxError].
+func statementError(conn *sql.Conn, query string, index int, authorizer *authorizer, err error) error {
+	if permission := authorizer.deniedPermission(); permission != "" {
+		err = &SandboxError{Permission: permission}
+	}
+
+	multiple := index > 1
+	if !multiple {
+		_ = withHandle(conn, func(h handle) error {
+			multiple = h.hasMultipleStatements(query)
+			return nil
+		})
+	}
+	if !multiple {
+		return err
+	}
+
+	var sandboxErr *SandboxError
+	if errors.As(err, &sandboxErr) {
+		sandboxErr.Statement = index
+		return sandboxErr
+	}
+
+	return fmt.Errorf("statement %d: %w", index, err)
+}
+
+// runStatement runs a statement and returns its result.
+//
+// totalChanges is the total changes of the connection before the
+// statement, which is updated to the value after the statement.
+func runStatement(ctx context.Context, conn *sql.Conn, statement string, totalChanges *int64, limiter *resultLimiter) (StatementResult, error) {
+	result, err := queryResult(ctx, conn, statement, limiter)
+	if err != nil {
+		return StatementResult{}, err
+	}
+
+	// changes() is not reset by the statements other than INSERT, UPDATE
+	// and DELETE, so we only trust it when total_changes() is changed.
+	var changes, newTotalChanges int64
+	if err := conn.QueryRowContext(ctx, "SELECT changes(), total_changes()").Scan(&changes, &newTotalChanges); err != nil {
+		return StatementResult{}, fmt.Errorf("get changes: %w", err)
+	}
+
+	switch {
+	case newTotalChanges != *totalChanges:
+		result.RowsAffected = &changes
+	case len(result.Header) == 0:
+		result.RowsAffected = new(int64)
+	}
+	*totalChanges = newTotalChanges
+
+	return result, nil
+}
+
+// queryResult runs the query and collects its rows.
+//
+// The limiter applies the limits to the result. The result is not
+// limited if the limiter is nil.
+func queryResult(ctx context.Context, conn *sql.Conn, query string, limiter *resultLimiter) (StatementResult, error) {
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return StatementResult{}, fmt.Errorf("query: %w", err)
 	}
 	defer rows.Close()
 
 	cols, err := rows.Columns()
 	if err != nil {
-		return Output{}, fmt.Errorf("get columns: %w", err)
+		return StatementResult{}, fmt.Errorf("get columns: %w", err)
 	}
 
-	output := Output{
-		Header: cols,
-		Data:   [][]*string{},
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return StatementResult{}, fmt.Errorf("get column types: %w", err)
+	}
+
+	result := StatementResult{
+		Header:      cols,
+		ColumnTypes: make([]string, len(colTypes)),
+		Data:        [][]Cell{},
+	}
+	for i, colType := range colTypes {
+		result.ColumnTypes[i] = colType.DatabaseTypeName()
+	}
+
+	if limiter != nil {
+		if err := limiter.header(cols); err != nil {
+			return StatementResult{}, err
+		}
 	}
+
 	for rows.Next() {
 		// Create the dynamic slice of pointers to interface{}
 		// so we can pass them to rows.Scan
 		rawCells := make([]any, len(cols))
 
 		// Fill the slice with pointer to the scanner.
-		// The scanner converts all the values to string, while
-		// leaves the nil values as nil.
+		// The scanner converts all the values to typed cells.
 		for i := range rawCells {
-			rawCells[i] = new(NullableStringScanner)
+			rawCells[i] = new(CellScanner)
 		}
 
 		err := rows.Scan(rawCells...)
@@ -58,36 +279,103 @@ func RunQuery(ctx context.Context, input Input) (Output, error) {
 			break
 		}
 
-		cells := make([]*string, len(rawCells))
+		cells := make([]Cell, len(rawCells))
 		for i, cell := range rawCells {
-			cells[i] = cell.(*NullableStringScanner).Value()
+			cells[i] = cell.(*CellScanner).Cell()
+		}
+
+		if limiter != nil {
+			keep, err := limiter.row(len(result.Data), cells)
+			if err != nil {
+				return StatementResult{}, err
+			}
+			// The rest of the rows are dropped as well.
+			if !keep {
+				break
+			}
 		}
 
-		output.Data = append(output.Data, cells)
+		result.Data = append(result.Data, cells)
 	}
 	if err := rows.Err(); err != nil {
-		return Output{}, fmt.Errorf("rows error: %w", err)
+		return StatementResult{}, fmt.Errorf("rows error: %w", err)
+	}
+	if err := rows.Close(); err != nil {
+		return StatementResult{}, fmt.Errorf("close rows: %w", err)
 	}
 
-	return output, nil
+	return result, nil
 }
 
-type NullableStringScanner struct {
-	value *string
+// captureState captures the contents of the tables.
+//
+// All the tables except the internal tables of SQLite are captured
+// if tables is empty.
+func captureState(ctx context.Context, conn *sql.Conn, tables []string, limiter *resultLimiter) ([]TableState, error) {
+	existing, err := queryResult(ctx, conn, "SELECT name FROM sqlite_schema WHERE type = 'table' AND name NOT LIKE 'sqlite\\_%' ESCAPE '\\'", nil)
+	if err != nil {
+		return nil, fmt.Errorf("list tables: %w", err)
+	}
+
+	// The table names are case-insensitive in SQLite.
+	existingTables := make(map[string]string, len(existing.Data))
+	for _, row := range existing.Data {
+		name := *row[0].Value
+		existingTables[strings.ToLower(name)] = name
+	}
+
+	if len(tables) == 0 {
+		for _, name := range existingTables {
+			tables = append(tables, name)
+		}
+		slices.Sort(tables)
+	}
+
+	states := make([]TableState, 0, len(tables))
+	for _, table := range tables {
+		name, ok := existingTables[strings.ToLower(table)]
+		if !ok {
+			states = append(states, TableState{Name: table, Missing: true})
+			continue
+		}
+
+		result, err := queryResult(ctx, conn, "SELECT * FROM "+quoteIdentifier(name), limiter)
+		if err != nil {
+			return nil, fmt.Errorf("table %s: %w", table, err)
+		}
+
+		slices.SortStableFunc(result.Data, func(a, b []Cell) int {
+			return cmp.Compare(rowKey(a), rowKey(b))
+		})
+
+		states = append(states, TableState{
+			Name:   table,
+			Header: result.Header,
+			Data:   result.Data,
+		})
+	}
+
+	return states, nil
 }
 
-func (n *NullableStringScanner) Scan(value any) error {
-	if value == nil {
-		n.value = nil
-		return nil
+// limitDatabaseSize limits the size of the database to maxBytes.
+//
+// SQLite keeps the current size if the database is already larger.
+func limitDatabaseSize(ctx context.Context, conn *sql.Conn, maxBytes int64) error {
+	var pageSize int64
+	if err := conn.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return fmt.Errorf("get page size: %w", err)
+	}
+
+	maxPageCount := max(maxBytes/pageSize, 1)
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("PRAGMA max_page_count = %d", maxPageCount)); err != nil {
+		return fmt.Errorf("limit database size: %w", err)
 	}
 
-	n.value = lo.ToPtr(fmt.Sprintf("%v", value))
 	return nil
 }
 
-func (n *NullableStringScanner) Value() *string {
-	return n.value
+// quoteIdentifier quotes the identifier for SQLite.
+func quoteIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
 }
-
-var _ sql.Scanner = &NullableStringScanner{}
@@ -31,10 +31,11 @@ func TestRunQuery(t *testing.T) {
 		output, err := dbrunner.RunQuery(context.Background(), input)
 		require.NoError(t, err)
 		assert.Equal(t, dbrunner.Output{
-			Header: []string{"id", "name"},
-			Data: [][]*string{
-				{lo.ToPtr("1"), lo.ToPtr("Alice")},
-				{lo.ToPtr("2"), lo.ToPtr("Bob")},
+			Header:      []string{"id", "name"},
+			ColumnTypes: []string{"INTEGER", "TEXT"},
+			Data: [][]dbrunner.Cell{
+				{dbrunner.NewCell(int64(1)), dbrunner.NewCell("Alice")},
+				{dbrunner.NewCell(int64(2)), dbrunner.NewCell("Bob")},
 			},
 		}, output)
 	})
@@ -57,8 +58,9 @@ func TestRunQuery(t *testing.T) {
 		output, err := dbrunner.RunQuery(context.Background(), input)
 		require.NoError(t, err)
 		assert.Equal(t, dbrunner.Output{
-			Header: []string{},
-			Data:   [][]*string{},
+			Header:      []string{},
+			ColumnTypes: []string{},
+			Data:        [][]dbrunner.Cell{},
 		}, output)
 	})
 
@@ -80,8 +82,17 @@ func TestRunQuery(t *testing.T) {
 		output, err := dbrunner.RunQuery(context.Background(), input)
 		require.NoError(t, err)
 		assert.Equal(t, dbrunner.Output{
-			Header: []string{},
-			Data:   [][]*string{},
+			Header:      []string{},
+			ColumnTypes: []string{},
+			Data:        [][]dbrunner.Cell{},
+			Statements: []dbrunner.StatementResult{
+				{
+					Header:       []string{},
+					ColumnTypes:  []string{},
+					Data:         [][]dbrunner.Cell{},
+					RowsAffected: lo.ToPtr(int64(1)),
+				},
+			},
 		}, output)
 	})
 
@@ -103,9 +114,20 @@ func TestRunQuery(t *testing.T) {
 		output, err := dbrunner.RunQuery(context.Background(), input)
 		require.NoError(t, err)
 		assert.Equal(t, dbrunner.Output{
-			Header: []string{"id", "name"},
-			Data: [][]*string{
-				{lo.ToPtr("1"), lo.ToPtr("Charlie")},
+			Header:      []string{"id", "name"},
+			ColumnTypes: []string{"INTEGER", "TEXT"},
+			Data: [][]dbrunner.Cell{
+				{dbrunner.NewCell(int64(1)), dbrunner.NewCell("Charlie")},
+			},
+			Statements: []dbrunner.StatementResult{
+				{
+					Header:      []string{"id", "name"},
+					ColumnTypes: []string{"INTEGER", "TEXT"},
+					Data: [][]dbrunner.Cell{
+						{dbrunner.NewCell(int64(1)), dbrunner.NewCell("Charlie")},
+					},
+					RowsAffected: lo.ToPtr(int64(1)),
+				},
 			},
 		}, output)
 	})
@@ -135,10 +157,11 @@ func TestRunQuery(t *testing.T) {
 		})
 		require.NoError(t, err)
 		assert.Equal(t, dbrunner.Output{
-			Header: []string{"id", "name"},
-			Data: [][]*string{
-				{lo.ToPtr("1"), lo.ToPtr("Alice")},
-				{lo.ToPtr("2"), lo.ToPtr("Bob")},
+			Header:      []string{"id", "name"},
+			ColumnTypes: []string{"INTEGER", "TEXT"},
+			Data: [][]dbrunner.Cell{
+				{dbrunner.NewCell(int64(1)), dbrunner.NewCell("Alice")},
+				{dbrunner.NewCell(int64(2)), dbrunner.NewCell("Bob")},
 			},
 		}, output)
 	})
@@ -268,10 +291,193 @@ func TestRunQuery(t *testing.T) {
 		require.NoError(t, err)
 
 		assert.Equal(t, dbrunner.Output{
-			Header: []string{"id", "name"},
-			Data: [][]*string{
-				{lo.ToPtr("1"), nil},
+			Header:      []string{"id", "name"},
+			ColumnTypes: []string{"INTEGER", "TEXT"},
+			Data: [][]dbrunner.Cell{
+				{dbrunner.NewCell(int64(1)), dbrunner.NewCell(nil)},
 			},
 		}, output)
 	})
+
+	t.Run("with typed values, the cells should carry the types", func(t *testing.T) {
+		input := dbrunner.Input{
+			Init:  "CREATE TABLE test (id INTEGER PRIMARY KEY);",
+			Query: "SELECT 1, 1.0, 19.99, 'text', x'dead', NULL;",
+		}
+
+		output, err := dbrunner.RunQuery(context.Background(), input)
+		require.NoError(t, err)
+
+		require.Len(t, output.Data, 1)
+		assert.Equal(t, []dbrunner.Cell{
+			dbrunner.NewCell(int64(1)),
+			dbrunner.NewCell(1.0),
+			dbrunner.NewCell(19.99),
+			dbrunner.NewCell("text"),
+			dbrunner.NewCell([]byte{0xde, 0xad}),
+			dbrunner.NewCell(nil),
+		}, output.Data[0])
+		assert.Equal(t, dbrunner.CellTypeReal, output.Data[0][1].Type)
+		// expressions have no declared types
+		assert.Equal(t, []string{"", "", "", "", "", ""}, output.ColumnTypes)
+	})
+
+	t.Run("with declared types, the column types should be recorded", func(t *testing.T) {
+		input := dbrunner.Input{
+			Init: `
+				CREATE TABLE test (
+					id INTEGER PRIMARY KEY,
+					price DECIMAL(10, 2),
+					avatar BLOB
+				);
+				`,
+			Query: "SELECT id, price, avatar, id + 1 AS next_id FROM test;",
+		}
+
+		output, err := dbrunner.RunQuery(context.Background(), input)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"INTEGER", "DECIMAL(10, 2)", "BLOB", ""}, output.ColumnTypes)
+	})
+
+	t.Run("with multiple statements, each statement should have its result", func(t *testing.T) {
+		t.Parallel()
+
+		input := dbrunner.Input{
+			Init: `
+				CREATE TABLE test (
+					id INTEGER PRIMARY KEY,
+					name TEXT
+				);
+
+				INSERT INTO test (name) VALUES ('Alice');
+				INSERT INTO test (name) VALUES ('Bob');
+			`,
+			Query: "UPDATE test SET name = 'Charlie'; SELECT name FROM test WHERE id = 1; CREATE TABLE empty (id INTEGER);",
+		}
+
+		output, err := dbrunner.RunQuery(context.Background(), input)
+		require.NoError(t, err)
+
+		// the output is the result of the last statement returning rows
+		assert.Equal(t, []string{"name"}, output.Header)
+		assert.Equal(t, [][]dbrunner.Cell{{dbrunner.NewCell("Charlie")}}, output.Data)
+
+		require.Len(t, output.Statements, 3)
+		assert.Equal(t, lo.ToPtr(int64(2)), output.Statements[0].RowsAffected)
+		assert.Empty(t, output.Statements[0].Header)
+		assert.Nil(t, output.Statements[1].RowsAffected)
+		assert.Equal(t, output.Data, output.Statements[1].Data)
+		assert.Equal(t, lo.ToPtr(int64(0)), output.Statements[2].RowsAffected)
+	})
+
+	t.Run("with multiple statements, the failed statement should be reported", func(t *testing.T) {
+		t.Parallel()
+
+		input := dbrunner.Input{
+			Init:  "CREATE TABLE test (id INTEGER PRIMARY KEY);",
+			Query: "SELECT * FROM test; SELECT * FROM unknown_table;",
+		}
+
+		_, err := dbrunner.RunQuery(context.Background(), input)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "statement 2")
+	})
+
+	t.Run("with state capturing, the tables should be captured after the query", func(t *testing.T) {
+		t.Parallel()
+
+		initSQL := `
+			CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT);
+			CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER);
+
+			INSERT INTO customers VALUES (1, 'Alice'), (2, 'Bob'), (3, 'Charlie');
+			INSERT INTO orders VALUES (1, 2);
+		`
+
+		output, err := dbrunner.RunQuery(context.Background(), dbrunner.Input{
+			Init:         initSQL,
+			Query:        "DELETE FROM customers WHERE id NOT IN (SELECT customer_id FROM orders); DROP TABLE orders;",
+			CaptureState: true,
+			StateTables:  []string{"customers", "orders"},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []dbrunner.TableState{
+			{
+				Name:   "customers",
+				Header: []string{"id", "name"},
+				Data: [][]dbrunner.Cell{
+					{dbrunner.NewCell(int64(2)), dbrunner.NewCell("Bob")},
+				},
+			},
+			{
+				Name:    "orders",
+				Missing: true,
+			},
+		}, output.State)
+
+		t.Run("all tables", func(t *testing.T) {
+			t.Parallel()
+
+			output, err := dbrunner.RunQuery(context.Background(), dbrunner.Input{
+				Init:         initSQL,
+				Query:        "UPDATE customers SET name = 'Dave' WHERE id = 3;",
+				CaptureState: true,
+			})
+			require.NoError(t, err)
+
+			require.Len(t, output.State, 2)
+			assert.Equal(t, "customers", output.State[0].Name)
+			assert.Equal(t, "orders", output.State[1].Name)
+			assert.Equal(t, dbrunner.NewCell("Dave"), output.State[0].Data[2][1])
+		})
+
+		t.Run("not captured if not requested", func(t *testing.T) {
+			t.Parallel()
+
+			output, err := dbrunner.RunQuery(context.Background(), dbrunner.Input{
+				Init:  initSQL,
+				Query: "SELECT 1;",
+			})
+			require.NoError(t, err)
+			assert.Nil(t, output.State)
+		})
+	})
+}
+
+func TestRunQuery_StatementBoundaries(t *testing.T) {
+	t.Parallel()
+
+	// The statements are split where SQLite ends them.
+	testmap := map[string]int{
+		"SELECT 1":                              1,
+		"SELECT 1;":                             1,
+		"SELECT 1; SELECT 2;":                   2,
+		"SELECT 1;\n-- comment\nSELECT 2":       2,
+		"SELECT 1; -- comment":                  1,
+		";;SELECT 1;;":                          1,
+		"SELECT 'a;b'; SELECT \"c;d\"":          2,
+		"SELECT 'it''s;'; SELECT 2":             2,
+		"SELECT /* ; */ 1; SELECT 2":            2,
+		"SELECT [a;b] FROM (SELECT 1 AS [a;b])": 1,
+		"SELECT 'a\\'; SELECT '\\'":             2,
+		"BEGIN; SELECT 1; END;":                 3,
+		"CREATE TABLE t (a); CREATE TABLE u (a); CREATE TRIGGER tr AFTER INSERT ON t BEGIN UPDATE t SET a = CASE WHEN a > 0 THEN 1 ELSE 0 END; DELETE FROM u; END; SELECT 1": 4,
+	}
+
+	for query, expected := range testmap {
+		t.Run(query, func(t *testing.T) {
+			t.Parallel()
+
+			output, err := dbrunner.RunQuery(context.Background(), dbrunner.Input{Query: query})
+			require.NoError(t, err)
+
+			statements := len(output.Statements)
+			if statements == 0 && len(output.Header) > 0 {
+				statements = 1
+			}
+			assert.Equal(t, expected, statements)
+		})
+	}
 }
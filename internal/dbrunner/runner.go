@@ -0,0 +1,367 @@
+package dbrunner
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Runner runs the queries.
+//
+// It builds each distinct schema once and keeps the initialized database
+// in memory, so the queries on the same schema copy the database from
+// the snapshot instead of running the initial SQL again.
+type Runner struct {
+	snapshots *snapshotCache
+	group     singleflight.Group
+}
+
+// NewRunner creates a runner which keeps the snapshots up to
+// snapshotCacheBytes bytes in total. The least recently used
+// snapshots are evicted first.
+//
+// The snapshots are disabled if snapshotCacheBytes is not positive.
+func NewRunner(snapshotCacheBytes int64) *Runner {
+	return &Runner{
+		snapshots: newSnapshotCache(snapshotCacheBytes),
+	}
+}
+
+// defaultRunner is the runner of [RunQuery], which keeps no snapshots.
+var defaultRunner = NewRunner(0)
+
+// RunQuery runs the query with the default runner, which always
+// runs the initial SQL.
+func RunQuery(ctx context.Context, input Input) (Output, error) {
+	return defaultRunner.RunQuery(ctx, input)
+}
+
+// initialize initializes the database of the connection with the initial SQL.
+//
+// The database is restored from the snapshot if there is one; otherwise,
+// the initial SQL is run and the snapshot is taken for the next time.
+// Building the snapshot is limited by the timeout.
+func (r *Runner) initialize(ctx context.Context, conn *sql.Conn, init string, timeout time.Duration) error {
+	if r.snapshots.maxBytes <= 0 {
+		return execInitDirectly(ctx, conn, init)
+	}
+
+	key := snapshotKey(init)
+
+	snapshot, ok := r.snapshots.Get(key)
+	if !ok {
+		// Only one goroutine builds the snapshot of a schema at a time,
+		// and the others wait for it. The snapshot is built regardless of
+		// the cancellation of the goroutine building it, which may not be
+		// the one waiting for it.
+		result, err, _ := r.group.Do(key, func() (any, error) {
+			if snapshot, ok := r.snapshots.Get(key); ok {
+				return snapshot, nil
+			}
+
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+			defer cancel()
+
+			snapshot, err := buildSnapshot(ctx, init)
+			if err != nil {
+				return nil, err
+			}
+
+			r.snapshots.Add(key, snapshot)
+			return snapshot, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		snapshot = result.(*schemaSnapshot)
+	}
+
+	err := snapshot.restore(conn)
+	if errors.Is(err, errSnapshotUnavailable) {
+		return execInitDirectly(ctx, conn, init)
+	}
+	if err != nil {
+		return fmt.Errorf("restore snapshot: %w", err)
+	}
+
+	// The connection settings are not a part of the database.
+	for _, pragma := range snapshot.pragmas {
+		if _, err := conn.ExecContext(ctx, pragma); err != nil {
+			return fmt.Errorf("exec init pragma: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// execInitDirectly runs the initial SQL on the connection without the snapshot.
+func execInitDirectly(ctx context.Context, conn *sql.Conn, init string) error {
+	if _, err := conn.ExecContext(ctx, init); err != nil {
+		return fmt.Errorf("exec init: %w", err)
+	}
+
+	return nil
+}
+
+// errSnapshotUnavailable is returned when the snapshot cannot be restored,
+// since the initial SQL is not snapshotable or the snapshot is evicted.
+var errSnapshotUnavailable = errors.New("snapshot is unavailable")
+
+// schemaSnapshot is the initialized database of a schema.
+type schemaSnapshot struct {
+	// mu guards the source connection, which is closed when
+	// the snapshot is evicted.
+	mu     sync.RWMutex
+	closed bool
+
+	// db and conn are the in-memory database the snapshot is restored
+	// from. They are nil if the initial SQL is not snapshotable, so it
+	// must be run on each database.
+	db   *sql.DB
+	conn *sql.Conn
+	// bytes is the size of the database.
+	bytes int64
+	// pragmas are the PRAGMA statements of the initial SQL.
+	pragmas []string
+}
+
+// size returns the size of the snapshot in bytes.
+func (s *schemaSnapshot) size() int64 {
+	size := s.bytes
+	for _, pragma := range s.pragmas {
+		size += int64(len(pragma))
+	}
+
+	return size
+}
+
+// restore copies the database of the snapshot to the connection
+// with the backup API.
+//
+// The Deserialize method of the driver is not used, since it passes
+// the buffer it does not allocate with sqlite3_malloc to SQLite, which
+// crashes the process when the connection is closed.
+func (s *schemaSnapshot) restore(conn *sql.Conn) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.conn == nil || s.closed {
+		return errSnapshotUnavailable
+	}
+
+	return withHandle(s.conn, func(source handle) error {
+		return withHandle(conn, func(destination handle) error {
+			return destination.backupFrom(source)
+		})
+	})
+}
+
+// close closes the source database after the restorations in progress.
+func (s *schemaSnapshot) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil || s.closed {
+		return
+	}
+
+	s.closed = true
+	_ = s.conn.Close()
+	_ = s.db.Close()
+}
+
+// buildSnapshot runs the initial SQL in a new in-memory database,
+// which is kept open as the snapshot.
+func buildSnapshot(ctx context.Context, init string) (*schemaSnapshot, error) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("open connection: %w", err)
+	}
+
+	// The database is closed unless it is kept as the snapshot.
+	snapshot := &schemaSnapshot{db: db, conn: conn}
+	keep := false
+	defer func() {
+		if !keep {
+			_ = conn.Close()
+			_ = db.Close()
+		}
+	}()
+
+	pragmas, snapshotable, err := execInit(ctx, conn, init)
+	if err != nil {
+		return nil, fmt.Errorf("exec init: %w", err)
+	}
+	if !snapshotable {
+		return &schemaSnapshot{}, nil
+	}
+	snapshot.pragmas = pragmas
+
+	var pageCount, pageSize int64
+	if err := conn.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pageCount); err != nil {
+		return nil, fmt.Errorf("get page count: %w", err)
+	}
+	if err := conn.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return nil, fmt.Errorf("get page size: %w", err)
+	}
+	snapshot.bytes = pageCount * pageSize
+
+	keep = true
+	return snapshot, nil
+}
+
+// snapshotKey returns the key of the snapshot of the initial SQL.
+func snapshotKey(init string) string {
+	return Input{Init: init}.SchemaHash()
+}
+
+// allPermissions is the sandbox of the initial SQL, which is trusted.
+var allPermissions = Sandbox{Allow: []Permission{
+	PermissionAttach, PermissionPragma, PermissionVacuumInto, PermissionLoadExtension,
+}}
+
+// execInit runs the initial SQL statement by statement, and returns what
+// restoring the snapshot of the database needs to know about it.
+//
+// pragmas are the PRAGMA statements, which must be run again after
+// restoring the snapshot. The snapshot only contains the main database,
+// so snapshotable is false if the initial SQL creates temporary objects
+// or attaches databases.
+func execInit(ctx context.Context, conn *sql.Conn, init string) (pragmas []string, snapshotable bool, err error) {
+	var authorizer *authorizer
+	if err := withHandle(conn, func(h handle) error {
+		authorizer = installAuthorizer(h, allPermissions)
+		return nil
+	}); err != nil {
+		return nil, false, fmt.Errorf("install authorizer: %w", err)
+	}
+	defer func() {
+		_ = withHandle(conn, func(h handle) error {
+			authorizer.uninstall(h)
+			return nil
+		})
+	}()
+
+	snapshotable = true
+	for query := init; ; {
+		var statement preparedStatement
+		var ok bool
+
+		authorizer.begin()
+		err := withHandle(conn, func(h handle) error {
+			var err error
+			statement, query, ok, err = h.nextStatement(query)
+			return err
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			return pragmas, snapshotable, nil
+		}
+
+		// The actions are recorded when the statement is prepared.
+		if authorizer.performed(sqlite3.SQLITE_PRAGMA) {
+			pragmas = append(pragmas, statement.SQL)
+		}
+		if authorizer.performed(
+			sqlite3.SQLITE_ATTACH,
+			sqlite3.SQLITE_CREATE_TEMP_INDEX, sqlite3.SQLITE_CREATE_TEMP_TABLE,
+			sqlite3.SQLITE_CREATE_TEMP_TRIGGER, sqlite3.SQLITE_CREATE_TEMP_VIEW,
+		) {
+			snapshotable = false
+		}
+
+		authorizer.prepared(statement)
+		if _, err := conn.ExecContext(ctx, statement.SQL); err != nil {
+			return nil, false, err
+		}
+	}
+}
+
+// snapshotCache is a LRU cache of the snapshots bounded by their total size.
+type snapshotCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type snapshotEntry struct {
+	key      string
+	snapshot *schemaSnapshot
+}
+
+func newSnapshotCache(maxBytes int64) *snapshotCache {
+	return &snapshotCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Get returns the snapshot of the key and marks it as recently used.
+func (c *snapshotCache) Get(key string) (*schemaSnapshot, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	return element.Value.(*snapshotEntry).snapshot, true
+}
+
+// remove removes the entry and closes its snapshot in the background,
+// after the restorations in progress.
+func (c *snapshotCache) remove(element *list.Element) {
+	entry := element.Value.(*snapshotEntry)
+
+	c.bytes -= entry.snapshot.size()
+	c.order.Remove(element)
+	delete(c.entries, entry.key)
+
+	go entry.snapshot.close()
+}
+
+// Add adds the snapshot and evicts the least recently used snapshots
+// until the total size fits. The snapshot larger than the cache is
+// closed instead of added.
+func (c *snapshotCache) Add(key string, snapshot *schemaSnapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	size := snapshot.size()
+	if size > c.maxBytes {
+		go snapshot.close()
+		return
+	}
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+
+	for c.bytes+size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+
+	c.entries[key] = c.order.PushFront(&snapshotEntry{key: key, snapshot: snapshot})
+	c.bytes += size
+}
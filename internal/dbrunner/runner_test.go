@@ -0,0 +1,91 @@
+package dbrunner_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/database-playground/backend/internal/dbrunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunner_RunQuery(t *testing.T) {
+	t.Parallel()
+
+	initSQL := `
+		PRAGMA foreign_keys = ON;
+
+		CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT);
+		CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER REFERENCES customers);
+
+		INSERT INTO customers VALUES (1, 'Alice'), (2, 'Bob');
+		INSERT INTO orders VALUES (1, 2);
+	`
+
+	t.Run("the snapshot gives the same result as running the initial SQL", func(t *testing.T) {
+		t.Parallel()
+
+		runner := dbrunner.NewRunner(1 << 20)
+		input := dbrunner.Input{Init: initSQL, Query: "SELECT * FROM customers;"}
+
+		expected, err := dbrunner.RunQuery(context.Background(), input)
+		require.NoError(t, err)
+
+		for range 3 {
+			output, err := runner.RunQuery(context.Background(), input)
+			require.NoError(t, err)
+			assert.Equal(t, expected, output)
+		}
+	})
+
+	t.Run("the changes are not written back to the snapshot", func(t *testing.T) {
+		t.Parallel()
+
+		runner := dbrunner.NewRunner(1 << 20)
+
+		_, err := runner.RunQuery(context.Background(), dbrunner.Input{Init: initSQL, Query: "DELETE FROM orders; DELETE FROM customers;"})
+		require.NoError(t, err)
+
+		output, err := runner.RunQuery(context.Background(), dbrunner.Input{Init: initSQL, Query: "SELECT count(*) FROM customers;"})
+		require.NoError(t, err)
+		assert.Equal(t, [][]dbrunner.Cell{{dbrunner.NewCell(int64(2))}}, output.Data)
+	})
+
+	t.Run("the pragmas are applied after restoring the snapshot", func(t *testing.T) {
+		t.Parallel()
+
+		runner := dbrunner.NewRunner(1 << 20)
+
+		for range 2 {
+			_, err := runner.RunQuery(context.Background(), dbrunner.Input{Init: initSQL, Query: "DELETE FROM customers WHERE id = 2;"})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "FOREIGN KEY")
+		}
+	})
+
+	t.Run("the temporary tables are not snapshotted", func(t *testing.T) {
+		t.Parallel()
+
+		runner := dbrunner.NewRunner(1 << 20)
+		input := dbrunner.Input{
+			Init:  "CREATE TEMP TABLE scratch (id INTEGER); INSERT INTO scratch VALUES (1);",
+			Query: "SELECT * FROM scratch;",
+		}
+
+		for range 2 {
+			output, err := runner.RunQuery(context.Background(), input)
+			require.NoError(t, err)
+			assert.Equal(t, [][]dbrunner.Cell{{dbrunner.NewCell(int64(1))}}, output.Data)
+		}
+	})
+
+	t.Run("the invalid initial SQL is reported", func(t *testing.T) {
+		t.Parallel()
+
+		runner := dbrunner.NewRunner(1 << 20)
+
+		_, err := runner.RunQuery(context.Background(), dbrunner.Input{Init: "CREATE TABLE;", Query: "SELECT 1;"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exec init")
+	})
+}
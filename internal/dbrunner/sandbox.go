@@ -0,0 +1,232 @@
+package dbrunner
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"modernc.org/libc"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Permission is an operation the [Sandbox] denies unless allowed.
+type Permission string
+
+const (
+	// PermissionAttach allows ATTACH and DETACH, which can open
+	// the files on the server as databases.
+	PermissionAttach Permission = "attach"
+	// PermissionPragma allows all the PRAGMA statements. Without it,
+	// only the pragmas inspecting the schema are allowed.
+	PermissionPragma Permission = "pragma"
+	// PermissionVacuumInto allows VACUUM INTO, which writes the
+	// database to a file.
+	PermissionVacuumInto Permission = "vacuum_into"
+	// PermissionLoadExtension allows load_extension(), which loads
+	// a shared library into the server.
+	PermissionLoadExtension Permission = "load_extension"
+)
+
+// description returns the user-facing description of the operation.
+func (p Permission) description() string {
+	switch p {
+	case PermissionAttach:
+		return "ATTACH and DETACH are"
+	case PermissionPragma:
+		return "PRAGMA is"
+	case PermissionVacuumInto:
+		return "VACUUM INTO is"
+	case PermissionLoadExtension:
+		return "load_extension() is"
+	default:
+		return string(p) + " is"
+	}
+}
+
+// inspectionPragmas are the pragmas which only inspect the schema,
+// so they are allowed without [PermissionPragma].
+var inspectionPragmas = []string{
+	"collation_list",
+	"foreign_key_check",
+	"foreign_key_list",
+	"function_list",
+	"index_info",
+	"index_list",
+	"index_xinfo",
+	"integrity_check",
+	"pragma_list",
+	"quick_check",
+	"table_info",
+	"table_list",
+	"table_xinfo",
+}
+
+// Sandbox restricts the operations the query can perform.
+//
+// The zero value denies all the operations listed in [Permission].
+// It is enforced by the SQLite authorizer while the query runs, so
+// SQLite decides what each statement does. Only the query is checked;
+// the initial SQL is trusted.
+type Sandbox struct {
+	// Allow lists the operations the query is allowed to perform.
+	Allow []Permission `json:"allow,omitempty"`
+}
+
+func (s Sandbox) allows(permission Permission) bool {
+	return slices.Contains(s.Allow, permission)
+}
+
+// SandboxError is returned when the query performs an operation
+// the [Sandbox] denies.
+type SandboxError struct {
+	Permission Permission
+	// Statement is the one-based index of the denied statement.
+	// It is zero if the query has only one statement.
+	Statement int
+}
+
+func (e *SandboxError) Error() string {
+	message := e.Permission.description() + " not allowed in this question"
+	if e.Statement > 0 {
+		return fmt.Sprintf("statement %d: %s", e.Statement, message)
+	}
+
+	return message
+}
+
+// authorizer enforces the sandbox on a connection as its SQLite
+// authorizer, which SQLite consults when it prepares a statement,
+// including the statements SQLite prepares internally.
+type authorizer struct {
+	id      uintptr
+	sandbox Sandbox
+
+	mu sync.Mutex
+	// vacuum allows the ATTACH of the temporary database VACUUM
+	// runs internally.
+	vacuum bool
+	// denied is the permission of the first denied operation.
+	denied Permission
+	// actions are the actions SQLite has authorized or denied
+	// since the statement began.
+	actions map[int32]bool
+}
+
+// authorizers are the installed authorizers.
+var authorizers callbacks[*authorizer]
+
+// installAuthorizer installs the authorizer of the sandbox on the connection.
+// It must be uninstalled with [authorizer.uninstall].
+func installAuthorizer(h handle, sandbox Sandbox) *authorizer {
+	a := &authorizer{sandbox: sandbox}
+	a.id = authorizers.add(a)
+
+	sqlite3.Xsqlite3_set_authorizer(h.tls, h.db, cFuncPointer(authorizerCallback), a.id)
+
+	return a
+}
+
+// uninstall uninstalls the authorizer from the connection.
+func (a *authorizer) uninstall(h handle) {
+	sqlite3.Xsqlite3_set_authorizer(h.tls, h.db, 0, 0)
+	authorizers.remove(a.id)
+}
+
+// authorizerCallback is the authorizer callback of SQLite.
+func authorizerCallback(tls *libc.TLS, id uintptr, action int32, zArg1, zArg2, zArg3, zArg4 uintptr) int32 {
+	a, ok := authorizers.get(id)
+	if !ok {
+		return sqlite3.SQLITE_OK
+	}
+
+	return a.authorize(action, libc.GoString(zArg1), libc.GoString(zArg2))
+}
+
+// authorize returns SQLITE_DENY if the sandbox denies the action.
+//
+// See https://www.sqlite.org/c3ref/c_alter_table.html for the
+// arguments of the actions.
+func (a *authorizer) authorize(action int32, arg1, arg2 string) int32 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.actions == nil {
+		a.actions = make(map[int32]bool)
+	}
+	a.actions[action] = true
+
+	var permission Permission
+	switch action {
+	case sqlite3.SQLITE_ATTACH, sqlite3.SQLITE_DETACH:
+		if a.vacuum {
+			return sqlite3.SQLITE_OK
+		}
+		permission = PermissionAttach
+	case sqlite3.SQLITE_PRAGMA:
+		// The pragma functions, for example, pragma_table_info(),
+		// run the pragma when they are queried.
+		if slices.Contains(inspectionPragmas, strings.ToLower(arg1)) {
+			return sqlite3.SQLITE_OK
+		}
+		permission = PermissionPragma
+	case sqlite3.SQLITE_FUNCTION:
+		if !strings.EqualFold(arg2, "load_extension") {
+			return sqlite3.SQLITE_OK
+		}
+		permission = PermissionLoadExtension
+	default:
+		return sqlite3.SQLITE_OK
+	}
+
+	if a.sandbox.allows(permission) {
+		return sqlite3.SQLITE_OK
+	}
+
+	if a.denied == "" {
+		a.denied = permission
+	}
+	return sqlite3.SQLITE_DENY
+}
+
+// begin resets the authorizer for the next statement.
+func (a *authorizer) begin() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.vacuum = false
+	a.denied = ""
+	clear(a.actions)
+}
+
+// prepared adjusts the authorizer for running the prepared statement.
+func (a *authorizer) prepared(statement preparedStatement) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.vacuum = statement.Vacuum
+}
+
+// deniedPermission returns the permission denied since the statement
+// began, or an empty string if none.
+func (a *authorizer) deniedPermission() Permission {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.denied
+}
+
+// performed returns whether any of the actions has been authorized or
+// denied since the statement began.
+func (a *authorizer) performed(actions ...int32) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, action := range actions {
+		if a.actions[action] {
+			return true
+		}
+	}
+
+	return false
+}
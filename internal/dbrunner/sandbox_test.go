@@ -0,0 +1,135 @@
+package dbrunner_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/database-playground/backend/internal/dbrunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunQuery_SandboxPermissions(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	testcases := []struct {
+		name       string
+		query      string
+		permission dbrunner.Permission
+	}{
+		{"select", "SELECT * FROM test;", ""},
+		{"attach", "ATTACH DATABASE ':memory:' AS x;", dbrunner.PermissionAttach},
+		{"attach file", "attach '" + filepath.Join(dir, "attach.db") + "' as x", dbrunner.PermissionAttach},
+		{"detach", "DETACH x;", dbrunner.PermissionAttach},
+		{"explain attach", "EXPLAIN ATTACH ':memory:' AS x;", dbrunner.PermissionAttach},
+		{"pragma", "PRAGMA journal_mode = WAL;", dbrunner.PermissionPragma},
+		{"pragma with schema", "PRAGMA main.journal_mode;", dbrunner.PermissionPragma},
+		{"inspection pragma", "PRAGMA table_info(test);", ""},
+		{"inspection pragma with schema", "PRAGMA main.table_info(test);", ""},
+		{"pragma function", "SELECT * FROM pragma_table_info('test');", ""},
+		{"denied pragma function", "SELECT * FROM pragma_database_list;", dbrunner.PermissionPragma},
+		{"denied pragma function without columns", "SELECT 1 FROM pragma_database_list;", dbrunner.PermissionPragma},
+		{"vacuum", "VACUUM;", ""},
+		{"vacuum into", "VACUUM INTO '" + filepath.Join(dir, "vacuum.db") + "';", dbrunner.PermissionVacuumInto},
+		{"vacuum schema into", "VACUUM main INTO '" + filepath.Join(dir, "vacuum-main.db") + "';", dbrunner.PermissionVacuumInto},
+		{"load extension", "SELECT load_extension('/tmp/x.so');", dbrunner.PermissionLoadExtension},
+		{"load extension with spaces", "SELECT LOAD_EXTENSION ('/tmp/x.so');", dbrunner.PermissionLoadExtension},
+		{"string literal", "SELECT 'ATTACH', 'load_extension';", ""},
+		{"comment", "-- ATTACH\nSELECT 1;", ""},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			input := dbrunner.Input{
+				Init:  "CREATE TABLE test (id INTEGER);",
+				Query: tc.query,
+			}
+
+			_, err := dbrunner.RunQuery(context.Background(), input)
+			if tc.permission == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var sandboxErr *dbrunner.SandboxError
+			require.ErrorAs(t, err, &sandboxErr)
+			assert.Equal(t, tc.permission, sandboxErr.Permission)
+
+			// the allowed operation is not denied, though it may fail
+			input.Sandbox = dbrunner.Sandbox{Allow: []dbrunner.Permission{tc.permission}}
+			_, err = dbrunner.RunQuery(context.Background(), input)
+			assert.False(t, errors.As(err, &sandboxErr), "unexpected sandbox error: %v", err)
+		})
+	}
+}
+
+func TestRunQuery_SandboxMultipleStatements(t *testing.T) {
+	t.Parallel()
+
+	_, err := dbrunner.RunQuery(context.Background(), dbrunner.Input{
+		Query: "SELECT 1; PRAGMA journal_mode = WAL;",
+	})
+	require.Error(t, err)
+	assert.Equal(t, "statement 2: PRAGMA is not allowed in this question", err.Error())
+}
+
+func TestRunQuery_SandboxBackslashString(t *testing.T) {
+	t.Parallel()
+
+	// SQLite has no backslash escapes, so the string ends at the backslash
+	// and the ATTACH is a statement of its own.
+	file := filepath.Join(t.TempDir(), "pwn.db")
+	_, err := dbrunner.RunQuery(context.Background(), dbrunner.Input{
+		Query: `SELECT 'a\'; ATTACH '` + file + `' AS p; SELECT * FROM p.sqlite_schema; SELECT '\'`,
+	})
+
+	var sandboxErr *dbrunner.SandboxError
+	require.ErrorAs(t, err, &sandboxErr)
+	assert.Equal(t, "statement 2: ATTACH and DETACH are not allowed in this question", err.Error())
+	assert.NoFileExists(t, file)
+}
+
+func TestRunQuery_Sandbox(t *testing.T) {
+	t.Parallel()
+
+	t.Run("the denied operation is not run", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := dbrunner.RunQuery(context.Background(), dbrunner.Input{
+			Init:  "CREATE TABLE test (id INTEGER);",
+			Query: "ATTACH DATABASE ':memory:' AS other;",
+		})
+
+		var sandboxErr *dbrunner.SandboxError
+		require.ErrorAs(t, err, &sandboxErr)
+		assert.Equal(t, "ATTACH and DETACH are not allowed in this question", err.Error())
+	})
+
+	t.Run("the allowed operation is run", func(t *testing.T) {
+		t.Parallel()
+
+		output, err := dbrunner.RunQuery(context.Background(), dbrunner.Input{
+			Init:    "CREATE TABLE test (id INTEGER);",
+			Query:   "ATTACH DATABASE ':memory:' AS other; CREATE TABLE other.t (id INTEGER); SELECT name FROM other.sqlite_schema;",
+			Sandbox: dbrunner.Sandbox{Allow: []dbrunner.Permission{dbrunner.PermissionAttach}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, [][]dbrunner.Cell{{dbrunner.NewCell("t")}}, output.Data)
+	})
+
+	t.Run("the initial SQL is trusted", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := dbrunner.RunQuery(context.Background(), dbrunner.Input{
+			Init:  "PRAGMA foreign_keys = ON; CREATE TABLE test (id INTEGER);",
+			Query: "SELECT * FROM test;",
+		})
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,152 @@
+package dbrunner
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSnapshotCache(t *testing.T) {
+	cache := newSnapshotCache(10)
+
+	cache.Add("a", &schemaSnapshot{bytes: 4})
+	cache.Add("b", &schemaSnapshot{bytes: 4})
+
+	// a is used recently, so b is evicted first.
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("a should be cached")
+	}
+	cache.Add("c", &schemaSnapshot{bytes: 4})
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("b should be evicted")
+	}
+	if _, ok := cache.Get("a"); !ok {
+		t.Error("a should be cached")
+	}
+	if _, ok := cache.Get("c"); !ok {
+		t.Error("c should be cached")
+	}
+
+	// the snapshot larger than the cache is not added.
+	cache.Add("d", &schemaSnapshot{bytes: 11})
+	if _, ok := cache.Get("d"); ok {
+		t.Error("d should not be cached")
+	}
+
+	if cache.bytes != 8 {
+		t.Errorf("cache.bytes = %d, want 8", cache.bytes)
+	}
+}
+
+func TestExecInit(t *testing.T) {
+	testmap := map[string]bool{
+		"CREATE TABLE a (id INTEGER);":                                       true,
+		"CREATE TEMP TABLE a (id INTEGER);":                                  false,
+		"create temporary view a AS SELECT 1;":                               false,
+		"ATTACH DATABASE ':memory:' AS other;":                               false,
+		"PRAGMA foreign_keys = ON; SELECT 1;":                                true,
+		"CREATE TABLE temp_a (id INTEGER);":                                  true,
+		"CREATE TABLE [a;b] (id INTEGER); CREATE TEMP TABLE c (id INTEGER);": false,
+	}
+
+	for init, expected := range testmap {
+		_, snapshotable := execTestInit(t, init)
+		if snapshotable != expected {
+			t.Errorf("execInit(%q) snapshotable = %v, want %v", init, snapshotable, expected)
+		}
+	}
+
+	pragmas, _ := execTestInit(t, "PRAGMA foreign_keys = ON; CREATE TABLE a (id INTEGER);\n-- comment\npragma main.recursive_triggers = 1")
+	expected := []string{"PRAGMA foreign_keys = ON;", "\n-- comment\npragma main.recursive_triggers = 1"}
+	if !slices.Equal(pragmas, expected) {
+		t.Errorf("pragmas = %q, want %q", pragmas, expected)
+	}
+}
+
+func execTestInit(t *testing.T, init string) (pragmas []string, snapshotable bool) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	pragmas, snapshotable, err = execInit(context.Background(), conn, init)
+	if err != nil {
+		t.Fatalf("execInit(%q): %v", init, err)
+	}
+
+	return pragmas, snapshotable
+}
+
+func TestRunner_InitializeWithCancelledContext(t *testing.T) {
+	runner := NewRunner(1 << 20)
+	init := "CREATE TABLE a (id INTEGER); INSERT INTO a VALUES (1);"
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The snapshot is built for the other callers even if the caller
+	// building it is cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := runner.initialize(ctx, conn, init, time.Second); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if _, ok := runner.snapshots.Get(snapshotKey(init)); !ok {
+		t.Error("the snapshot should be cached")
+	}
+
+	var count int
+	if err := conn.QueryRowContext(context.Background(), "SELECT count(*) FROM a").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+}
+
+func TestSchemaSnapshot_RestoreClosed(t *testing.T) {
+	snapshot, err := buildSnapshot(context.Background(), "CREATE TABLE a (id INTEGER);")
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot.close()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := snapshot.restore(conn); !errors.Is(err, errSnapshotUnavailable) {
+		t.Errorf("restore() = %v, want %v", err, errSnapshotUnavailable)
+	}
+}
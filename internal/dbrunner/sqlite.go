@@ -0,0 +1,280 @@
+package dbrunner
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"unsafe"
+
+	"modernc.org/libc"
+	"modernc.org/libc/sys/types"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// handle is the SQLite connection under a connection of the driver,
+// for the SQLite APIs the driver does not expose.
+type handle struct {
+	tls *libc.TLS
+	db  uintptr
+}
+
+// withHandle calls f with the SQLite connection of the connection.
+//
+// The handle must not be used after f returns, and f must not use
+// the connection, which is locked while f runs.
+func withHandle(conn *sql.Conn, f func(h handle) error) error {
+	return conn.Raw(func(driverConn any) error {
+		h, err := rawHandle(driverConn)
+		if err != nil {
+			return err
+		}
+
+		return f(h)
+	})
+}
+
+// rawHandle returns the SQLite connection of the driver connection.
+//
+// The driver keeps the connection in its unexported fields, which are
+// read with reflection.
+func rawHandle(driverConn any) (handle, error) {
+	value := reflect.ValueOf(driverConn)
+	if value.Kind() != reflect.Pointer || value.Elem().Kind() != reflect.Struct {
+		return handle{}, fmt.Errorf("unsupported driver connection %T", driverConn)
+	}
+	value = value.Elem()
+
+	db := value.FieldByName("db")
+	tls := value.FieldByName("tls")
+	if db.Kind() != reflect.Uintptr || tls.Type() != reflect.TypeOf((*libc.TLS)(nil)) {
+		return handle{}, fmt.Errorf("unsupported driver connection %T", driverConn)
+	}
+	if db.Uint() == 0 || tls.IsNil() {
+		return handle{}, errors.New("connection is closed")
+	}
+
+	return handle{
+		tls: (*libc.TLS)(tls.UnsafePointer()),
+		db:  uintptr(db.Uint()),
+	}, nil
+}
+
+// preparedStatement is a statement of the query as SQLite prepares it.
+type preparedStatement struct {
+	// SQL is the text of the statement, including the leading
+	// whitespaces and comments.
+	SQL string
+	// Vacuum is true if the statement runs VACUUM.
+	Vacuum bool
+	// VacuumInto is true if the statement runs VACUUM INTO,
+	// which writes the database to a file.
+	VacuumInto bool
+}
+
+// nextStatement prepares the first statement of the SQL and returns it
+// with the rest of the SQL. ok is false if the SQL has no more statements,
+// for example, only whitespaces and comments.
+//
+// The statement is prepared on the connection, so the authorizer of the
+// connection checks it, and the errors preparing it are returned.
+func (h handle) nextStatement(query string) (statement preparedStatement, rest string, ok bool, err error) {
+	zSQL, err := libc.CString(query)
+	if err != nil {
+		return preparedStatement{}, "", false, err
+	}
+	defer libc.Xfree(h.tls, zSQL)
+
+	// ppStmt and pzTail
+	out := libc.Xmalloc(h.tls, types.Size_t(2*pointerSize))
+	if out == 0 {
+		return preparedStatement{}, "", false, errors.New("cannot allocate memory")
+	}
+	defer libc.Xfree(h.tls, out)
+
+	offset := 0
+	for offset < len(query) {
+		if rc := sqlite3.Xsqlite3_prepare_v3(h.tls, h.db, zSQL+uintptr(offset), -1, 0, out, out+pointerSize); rc != sqlite3.SQLITE_OK {
+			return preparedStatement{}, "", false, h.error(rc)
+		}
+
+		pStmt := readPointer(out)
+		tail := int(readPointer(out+pointerSize) - zSQL)
+
+		// The SQL only has whitespaces, comments or semicolons before the tail.
+		if pStmt == 0 {
+			if tail <= offset {
+				break
+			}
+			offset = tail
+			continue
+		}
+
+		statement = preparedStatement{SQL: query[offset:tail]}
+		if sqlite3.Xsqlite3_stmt_readonly(h.tls, pStmt) == 0 {
+			statement.Vacuum, statement.VacuumInto = h.explainVacuum(pStmt)
+		}
+		sqlite3.Xsqlite3_finalize(h.tls, pStmt)
+
+		return statement, query[tail:], true, nil
+	}
+
+	return preparedStatement{}, "", false, nil
+}
+
+// hasStatement returns whether the SQL has any statement.
+// The SQL failing to prepare is considered to have one.
+func (h handle) hasStatement(query string) bool {
+	_, _, ok, err := h.nextStatement(query)
+	return ok || err != nil
+}
+
+// hasMultipleStatements returns whether the SQL has more than one statement.
+//
+// The statements end at the semicolons SQLite considers to complete
+// a statement, so the SQL failing to prepare can be split as well.
+func (h handle) hasMultipleStatements(query string) bool {
+	for i := 0; i < len(query); i++ {
+		if query[i] != ';' || !h.complete(query[:i+1]) || !h.hasStatement(query[:i+1]) {
+			continue
+		}
+
+		return h.hasStatement(query[i+1:])
+	}
+
+	return false
+}
+
+// complete returns whether the SQL ends with a complete statement.
+func (h handle) complete(query string) bool {
+	zSQL, err := libc.CString(query)
+	if err != nil {
+		return false
+	}
+	defer libc.Xfree(h.tls, zSQL)
+
+	return sqlite3.Xsqlite3_complete(h.tls, zSQL) != 0
+}
+
+// explainVacuum returns whether the prepared statement runs VACUUM
+// and VACUUM INTO, by looking for the Vacuum opcode in its program.
+//
+// The statement is switched to EXPLAIN, so it must be finalized
+// without running it.
+func (h handle) explainVacuum(pStmt uintptr) (vacuum, vacuumInto bool) {
+	if sqlite3.Xsqlite3_stmt_explain(h.tls, pStmt, 1) != sqlite3.SQLITE_OK {
+		return false, false
+	}
+
+	// addr, opcode, p1, p2, p3, p4, p5, comment
+	for sqlite3.Xsqlite3_step(h.tls, pStmt) == sqlite3.SQLITE_ROW {
+		if libc.GoString(sqlite3.Xsqlite3_column_text(h.tls, pStmt, 1)) != "Vacuum" {
+			continue
+		}
+
+		vacuum = true
+		// P2 is the register of the file name of VACUUM INTO.
+		if sqlite3.Xsqlite3_column_int64(h.tls, pStmt, 3) != 0 {
+			vacuumInto = true
+		}
+	}
+
+	return vacuum, vacuumInto
+}
+
+// backupFrom copies the main database of the source connection to the
+// main database of the connection with the backup API.
+func (h handle) backupFrom(source handle) error {
+	zMain, err := libc.CString("main")
+	if err != nil {
+		return err
+	}
+	defer libc.Xfree(h.tls, zMain)
+
+	backup := sqlite3.Xsqlite3_backup_init(h.tls, h.db, zMain, source.db, zMain)
+	if backup == 0 {
+		return h.error(sqlite3.Xsqlite3_errcode(h.tls, h.db))
+	}
+
+	rc := sqlite3.Xsqlite3_backup_step(h.tls, backup, -1)
+	// The error of the backup is reported on the connection by finishing it.
+	if finishRC := sqlite3.Xsqlite3_backup_finish(h.tls, backup); finishRC != sqlite3.SQLITE_OK {
+		return h.error(finishRC)
+	}
+	if rc != sqlite3.SQLITE_DONE {
+		return h.error(rc)
+	}
+
+	return nil
+}
+
+// error returns the error of the result code in the same format as
+// the driver.
+func (h handle) error(rc int32) error {
+	str := libc.GoString(sqlite3.Xsqlite3_errstr(h.tls, rc))
+	msg := libc.GoString(sqlite3.Xsqlite3_errmsg(h.tls, h.db))
+	if msg == str {
+		return &QueryError{Message: fmt.Sprintf("%s (%d)", str, rc), code: int(rc)}
+	}
+
+	return &QueryError{Message: fmt.Sprintf("%s: %s (%d)", str, msg, rc), code: int(rc)}
+}
+
+// callbacks are the Go values of the SQLite callbacks by their IDs,
+// since SQLite can only pass a pointer-sized integer to the callbacks.
+type callbacks[T any] struct {
+	mu     sync.RWMutex
+	m      map[uintptr]T
+	nextID uintptr
+}
+
+// add adds the value and returns its ID.
+func (c *callbacks[T]) add(value T) uintptr {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.m == nil {
+		c.m = make(map[uintptr]T)
+	}
+
+	c.nextID++
+	c.m[c.nextID] = value
+	return c.nextID
+}
+
+// get returns the value of the ID.
+func (c *callbacks[T]) get(id uintptr) (T, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	value, ok := c.m[id]
+	return value, ok
+}
+
+// remove removes the value of the ID.
+func (c *callbacks[T]) remove(id uintptr) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.m, id)
+}
+
+// cFuncPointer converts a function defined by a function declaration to
+// a C pointer, in the same way as the driver.
+func cFuncPointer[T any](f T) uintptr {
+	return *(*uintptr)(unsafe.Pointer(&struct{ f T }{f}))
+}
+
+const pointerSize = unsafe.Sizeof(uintptr(0))
+
+// readPointer reads the pointer stored in the C memory.
+func readPointer(p uintptr) uintptr {
+	b := libc.GoBytes(p, int(pointerSize))
+	if pointerSize == 4 {
+		return uintptr(binary.NativeEndian.Uint32(b))
+	}
+
+	return uintptr(binary.NativeEndian.Uint64(b))
+}
@@ -3,12 +3,31 @@ package dbrunner
 import (
 	"crypto/sha256"
 	"encoding/ascii85"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"slices"
 )
 
 type Input struct {
 	Init  string `json:"init"`
 	Query string `json:"query"`
+
+	// CaptureState captures the contents of the tables after the query,
+	// so the queries modifying the data can be compared by the resulting
+	// database. See [Output.State].
+	CaptureState bool `json:"capture_state,omitempty"`
+	// StateTables are the tables to capture. All the tables are captured if empty.
+	StateTables []string `json:"state_tables,omitempty"`
+
+	// Limits limits the resources the query can use.
+	Limits Limits `json:"limits,omitempty"`
+	// Sandbox restricts the operations the query can perform.
+	//
+	// It is hashed since the sandbox is enforced while the query runs,
+	// so the output of a query allowed in one sandbox must not be
+	// reused for another sandbox.
+	Sandbox Sandbox `json:"sandbox,omitempty"`
 }
 
 // Normalize returns a normalized input.
@@ -19,12 +38,37 @@ func (i Input) Normalize() (Input, error) {
 		return Input{}, err
 	}
 
+	var stateTables []string
+	if i.CaptureState && len(i.StateTables) > 0 {
+		stateTables = slices.Clone(i.StateTables)
+		slices.Sort(stateTables)
+		stateTables = slices.Compact(stateTables)
+	}
+
+	var allow []Permission
+	if len(i.Sandbox.Allow) > 0 {
+		allow = slices.Clone(i.Sandbox.Allow)
+		slices.Sort(allow)
+		allow = slices.Compact(allow)
+	}
+
 	return Input{
-		Init:  i.Init,
-		Query: normlizedQuery,
+		Init:         i.Init,
+		Query:        normlizedQuery,
+		CaptureState: i.CaptureState,
+		StateTables:  stateTables,
+		Limits:       i.Limits,
+		Sandbox:      Sandbox{Allow: allow},
 	}, nil
 }
 
+// SchemaHash returns the hex-encoded SHA-256 hash of the initial SQL,
+// which identifies the entries derived from the same schema.
+func (i Input) SchemaHash() string {
+	hashed := sha256.Sum256([]byte(i.Init))
+	return hex.EncodeToString(hashed[:])
+}
+
 // Hash returns a hash of the input.
 //
 // It is encouraged to run [Input.Normalize] before hashing.
@@ -34,6 +78,34 @@ func (i Input) Hash() string {
 	hash.Write([]byte(i.Init))
 	hash.Write([]byte(i.Query))
 
+	// The inputs without state capturing keep the same hash as before.
+	if i.CaptureState {
+		hash.Write([]byte("\x00state"))
+		for _, table := range i.StateTables {
+			hash.Write([]byte("\x00" + table))
+		}
+	}
+
+	// The same query may have a different output under different limits.
+	if !i.Limits.IsZero() {
+		fmt.Fprintf(hash, "\x00limits:%d:%d:%d:%d:%d",
+			i.Limits.Timeout, i.Limits.MaxRows, i.Limits.MaxCellBytes,
+			i.Limits.MaxOutputBytes, i.Limits.MaxDatabaseBytes)
+
+		// The limits without the steps and the heap keep the same hash as before.
+		if i.Limits.MaxVMSteps != 0 || i.Limits.MaxHeapBytes != 0 {
+			fmt.Fprintf(hash, ":%d:%d", i.Limits.MaxVMSteps, i.Limits.MaxHeapBytes)
+		}
+	}
+
+	// The same query may be denied in a different sandbox.
+	if len(i.Sandbox.Allow) > 0 {
+		hash.Write([]byte("\x00sandbox"))
+		for _, permission := range i.Sandbox.Allow {
+			hash.Write([]byte("\x00" + permission))
+		}
+	}
+
 	hashed := hash.Sum(nil)
 
 	output := make([]byte, ascii85.MaxEncodedLen(len(hashed)))
@@ -42,15 +114,122 @@ func (i Input) Hash() string {
 }
 
 type Output struct {
-	Header []string    `json:"header"`
-	Data   [][]*string `json:"data"`
+	Header []string `json:"header"`
+	// ColumnTypes are the declared types of the columns, for example, "INTEGER".
+	// The type is empty if the column is an expression.
+	ColumnTypes []string `json:"column_types,omitempty"`
+	Data        [][]Cell `json:"data"`
+
+	// Statements are the results of each statement in the query.
+	//
+	// It is empty if the query is a single statement returning rows,
+	// whose result is the same as the output itself.
+	Statements []StatementResult `json:"statements,omitempty"`
+
+	// State is the contents of the tables after the query, sorted by
+	// the table names. It is only captured if [Input.CaptureState] is set.
+	State []TableState `json:"state,omitempty"`
+
+	// Truncated is true if some rows or cells of the results are cut
+	// to fit [Limits.MaxRows] and [Limits.MaxCellBytes].
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// StatementResult is the result of a statement in the query.
+type StatementResult struct {
+	Header      []string `json:"header"`
+	ColumnTypes []string `json:"column_types,omitempty"`
+	Data        [][]Cell `json:"data"`
+	// RowsAffected is the number of rows inserted, updated or deleted
+	// by the statement. It is nil if the statement only returns rows.
+	RowsAffected *int64 `json:"rows_affected,omitempty"`
 }
 
+// Output returns the result of the statement as an output,
+// so it can be compared as a query result.
+func (s StatementResult) Output() Output {
+	return Output{
+		Header:      s.Header,
+		ColumnTypes: s.ColumnTypes,
+		Data:        s.Data,
+	}
+}
+
+// TableState is the contents of a table after the query.
+type TableState struct {
+	Name string `json:"name"`
+	// Missing is true if the table does not exist, for example,
+	// dropped by the query.
+	Missing bool     `json:"missing,omitempty"`
+	Header  []string `json:"header"`
+	// Data are the rows of the table, sorted by their values
+	// since the tables have no inherent order.
+	Data [][]Cell `json:"data"`
+}
+
+// Output returns the contents of the table as an output,
+// so it can be compared and diffed as a query result.
+func (t TableState) Output() Output {
+	return Output{
+		Header: t.Header,
+		Data:   t.Data,
+	}
+}
+
+// Hash returns a hash of the output.
+//
+// Only the header, the values of the cells, the statement results, the
+// table states and whether the output is truncated are hashed; the
+// column types and the cell types are not.
 func (o Output) Hash() (string, error) {
 	hasher := sha256.New()
 	encoder := json.NewEncoder(hasher)
 
-	err := encoder.Encode(o)
+	type hashedStatement struct {
+		Header       []string    `json:"header"`
+		Data         [][]*string `json:"data"`
+		RowsAffected *int64      `json:"rows_affected,omitempty"`
+	}
+
+	statements := make([]hashedStatement, len(o.Statements))
+	for i, statement := range o.Statements {
+		statements[i] = hashedStatement{
+			Header:       statement.Header,
+			Data:         cellValues(statement.Data),
+			RowsAffected: statement.RowsAffected,
+		}
+	}
+
+	type hashedTable struct {
+		Name    string      `json:"name"`
+		Missing bool        `json:"missing,omitempty"`
+		Header  []string    `json:"header"`
+		Data    [][]*string `json:"data"`
+	}
+
+	tables := make([]hashedTable, len(o.State))
+	for i, table := range o.State {
+		tables[i] = hashedTable{
+			Name:    table.Name,
+			Missing: table.Missing,
+			Header:  table.Header,
+			Data:    cellValues(table.Data),
+		}
+	}
+
+	err := encoder.Encode(struct {
+		Header     []string          `json:"header"`
+		Data       [][]*string       `json:"data"`
+		Statements []hashedStatement `json:"statements,omitempty"`
+		State      []hashedTable     `json:"state,omitempty"`
+		Truncated  bool              `json:"truncated,omitempty"`
+	}{
+		Header:     o.Header,
+		Data:       cellValues(o.Data),
+		Statements: statements,
+		State:      tables,
+		Truncated:  o.Truncated,
+	})
 	if err != nil {
 		return "", err
 	}
@@ -61,3 +240,20 @@ func (o Output) Hash() (string, error) {
 	ascii85.Encode(output, hashed[:])
 	return string(output), nil
 }
+
+// cellValues returns the values of the cells.
+func cellValues(data [][]Cell) [][]*string {
+	if data == nil {
+		return nil
+	}
+
+	values := make([][]*string, len(data))
+	for i, row := range data {
+		values[i] = make([]*string, len(row))
+		for j, cell := range row {
+			values[i][j] = cell.Value
+		}
+	}
+
+	return values
+}
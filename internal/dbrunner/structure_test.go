@@ -39,43 +39,67 @@ func TestInput_Hash(t *testing.T) {
 		t.Errorf("a.Hash() [%s] == d.Hash() [%s]", a.Hash(), d.Hash())
 	}
 
+	e := d
+	e.CaptureState = true
+
+	if d.Hash() == e.Hash() {
+		t.Errorf("d.Hash() [%s] == e.Hash() [%s]", d.Hash(), e.Hash())
+	}
+
+	f := e
+	f.StateTables = []string{"test"}
+
+	if e.Hash() == f.Hash() {
+		t.Errorf("e.Hash() [%s] == f.Hash() [%s]", e.Hash(), f.Hash())
+	}
+
+	g := d
+	g.Sandbox = Sandbox{Allow: []Permission{PermissionAttach}}
+
+	if d.Hash() == g.Hash() {
+		t.Errorf("d.Hash() [%s] == g.Hash() [%s]", d.Hash(), g.Hash())
+	}
+
 	t.Logf("a.Hash() = %s", a.Hash())
 	t.Logf("b.Hash() = %s", b.Hash())
 	t.Logf("c.Hash() = %s", c.Hash())
 	t.Logf("d.Hash() = %s", d.Hash())
+	t.Logf("e.Hash() = %s", e.Hash())
+	t.Logf("f.Hash() = %s", f.Hash())
+	t.Logf("g.Hash() = %s", g.Hash())
 }
 
 func TestOutput_Hash(t *testing.T) {
 	a := Output{
 		Header: []string{"COL1", "COL2"},
-		Data: [][]*string{
+		Data: [][]Cell{
 			{
-				nil,
-				lo.ToPtr("Hello!"),
+				NewCell(nil),
+				NewCell("Hello!"),
 			},
 		},
 	}
 
 	b := Output{
 		Header: []string{"COL1", "COL2"},
-		Data: [][]*string{
+		Data: [][]Cell{
 			{
-				nil,
-				lo.ToPtr("Hello!"),
+				NewCell(nil),
+				NewCell("Hello!"),
 			},
 		},
 	}
 
 	c := Output{
 		Header: []string{"COL1", "COL2"},
-		Data: [][]*string{
+		Data: [][]Cell{
 			{
-				nil,
-				lo.ToPtr("Hello!"),
+				NewCell(nil),
+				NewCell("Hello!"),
 			},
 			{
-				lo.ToPtr("Hello!"),
-				nil,
+				NewCell("Hello!"),
+				NewCell(nil),
 			},
 		},
 	}
@@ -97,8 +121,30 @@ func TestOutput_Hash(t *testing.T) {
 		t.Errorf("a.Hash() [%s] == d.Hash() [%s]", lo.Must(a.Hash()), lo.Must(d.Hash()))
 	}
 
+	e := Output{
+		Header: []string{},
+		Data:   [][]Cell{},
+		Statements: []StatementResult{
+			{Header: []string{}, Data: [][]Cell{}, RowsAffected: lo.ToPtr(int64(1))},
+		},
+	}
+
+	f := Output{
+		Header: []string{},
+		Data:   [][]Cell{},
+		Statements: []StatementResult{
+			{Header: []string{}, Data: [][]Cell{}, RowsAffected: lo.ToPtr(int64(2))},
+		},
+	}
+
+	if lo.Must(e.Hash()) == lo.Must(f.Hash()) {
+		t.Errorf("e.Hash() [%s] == f.Hash() [%s]", lo.Must(e.Hash()), lo.Must(f.Hash()))
+	}
+
 	t.Logf("a.Hash() = %s", lo.Must(a.Hash()))
 	t.Logf("b.Hash() = %s", lo.Must(b.Hash()))
 	t.Logf("c.Hash() = %s", lo.Must(c.Hash()))
 	t.Logf("d.Hash() = %s", lo.Must(d.Hash()))
+	t.Logf("e.Hash() = %s", lo.Must(e.Hash()))
+	t.Logf("f.Hash() = %s", lo.Must(f.Hash()))
 }
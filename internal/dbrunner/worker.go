@@ -0,0 +1,424 @@
+package dbrunner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// WorkerCommand is the hidden subcommand of the service binary
+// which runs [ServeWorker].
+const WorkerCommand = "dbrunner-worker"
+
+// workerGracePeriod is how long the pool waits for a worker after
+// the timeout of the query before killing it.
+const workerGracePeriod = 5 * time.Second
+
+// workerExitCPULimit is the exit code of the worker exceeding its CPU limit.
+const workerExitCPULimit = 3
+
+// ErrWorkersBusy is returned when no worker is idle before the deadline
+// of the query. The query is not run, so it can be retried later.
+var ErrWorkersBusy = errors.New("all the workers are busy")
+
+// WorkerPoolConfig configures a [WorkerPool].
+type WorkerPoolConfig struct {
+	// Path is the path of the binary which runs [ServeWorker]
+	// when it is started with [WorkerCommand].
+	Path string
+	// Size is the number of the workers.
+	Size int
+
+	// MemoryLimit is the maximum bytes of memory a worker can allocate.
+	// The worker fails the queries or dies when it is exceeded.
+	// It is only enforced on Linux. Zero means no limit.
+	MemoryLimit int64
+	// CPULimit is the maximum CPU time a query can use, rounded up to
+	// seconds. The worker dies when it is exceeded. It is only enforced
+	// on Linux. Zero means no limit.
+	CPULimit time.Duration
+	// SnapshotCacheBytes is the size of the snapshot cache of each worker.
+	// See [NewRunner].
+	SnapshotCacheBytes int64
+}
+
+// WorkerPool runs the queries in the pre-forked worker processes, so
+// a query exhausting the memory or crashing the driver only takes down
+// its worker. The dead workers are restarted for the next queries.
+type WorkerPool struct {
+	config WorkerPoolConfig
+
+	// slots are the idle workers. A nil slot is a dead worker
+	// to be restarted by the next query.
+	slots chan *worker
+}
+
+// NewWorkerPool starts the workers of the pool.
+func NewWorkerPool(config WorkerPoolConfig) (*WorkerPool, error) {
+	if config.Size <= 0 {
+		return nil, errors.New("worker pool size must be positive")
+	}
+
+	pool := &WorkerPool{
+		config: config,
+		slots:  make(chan *worker, config.Size),
+	}
+
+	for range config.Size {
+		w, err := pool.startWorker()
+		if err != nil {
+			_ = pool.Close()
+			return nil, fmt.Errorf("start worker: %w", err)
+		}
+
+		pool.slots <- w
+	}
+
+	return pool, nil
+}
+
+// RunQuery runs the query in an idle worker. See [Runner.RunQuery].
+//
+// If the worker dies while running the query, a [WorkerError] is returned.
+// If no worker is idle within the timeout of the query and the grace
+// period, [ErrWorkersBusy] is returned.
+func (p *WorkerPool) RunQuery(ctx context.Context, input Input) (Output, error) {
+	w, err := p.acquire(ctx, input.Limits.timeout()+workerGracePeriod)
+	if err != nil {
+		return Output{}, err
+	}
+
+	// The query has its whole timeout however long it waited for the worker.
+	ctx, cancel := context.WithTimeout(ctx, input.Limits.timeout()+workerGracePeriod)
+	defer cancel()
+
+	// The worker may have died while idle.
+	if w != nil && w.hasExited() {
+		w = nil
+	}
+
+	if w == nil {
+		w, err = p.startWorker()
+		if err != nil {
+			p.slots <- nil
+			return Output{}, fmt.Errorf("start worker: %w", err)
+		}
+	}
+
+	output, err := w.run(ctx, input)
+	if w.dead {
+		p.slots <- nil
+	} else {
+		p.slots <- w
+	}
+
+	if err != nil {
+		return Output{}, input.Limits.limitError(err)
+	}
+
+	return output, nil
+}
+
+// acquire takes an idle worker, which is nil if it is dead. It waits
+// at most the timeout, and returns [ErrWorkersBusy] after that.
+func (p *WorkerPool) acquire(ctx context.Context, timeout time.Duration) (*worker, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	select {
+	case w := <-p.slots:
+		return w, nil
+	case <-ctx.Done():
+		// The query has not started, so it has not timed out.
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, ErrWorkersBusy
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// Close stops all the workers. It waits for the running queries.
+func (p *WorkerPool) Close() error {
+	for range p.config.Size {
+		if w := <-p.slots; w != nil {
+			w.kill()
+		}
+	}
+
+	return nil
+}
+
+func (p *WorkerPool) startWorker() (*worker, error) {
+	cmd := exec.Command(p.config.Path, WorkerCommand,
+		"-memory-limit", strconv.FormatInt(p.config.MemoryLimit, 10),
+		"-cpu-limit", p.config.CPULimit.String(),
+		"-snapshot-cache-size", strconv.FormatInt(p.config.SnapshotCacheBytes, 10),
+	)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	w := &worker{
+		cmd:     cmd,
+		encoder: json.NewEncoder(stdin),
+		decoder: json.NewDecoder(stdout),
+		exited:  make(chan struct{}),
+	}
+
+	go func() {
+		w.exitErr = cmd.Wait()
+		close(w.exited)
+	}()
+
+	return w, nil
+}
+
+// worker is a worker process.
+type worker struct {
+	cmd     *exec.Cmd
+	encoder *json.Encoder
+	decoder *json.Decoder
+
+	// dead is true if the worker has been killed or has died.
+	dead bool
+	// exited is closed when the process exits, with the error in exitErr.
+	exited  chan struct{}
+	exitErr error
+}
+
+// run runs the query in the worker.
+//
+// The worker is killed if the context is done before the result.
+func (w *worker) run(ctx context.Context, input Input) (Output, error) {
+	var response workerResponse
+	var ioErr error
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		if ioErr = w.encoder.Encode(workerRequest{Input: newWorkerInput(input)}); ioErr != nil {
+			return
+		}
+		ioErr = w.decoder.Decode(&response)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		w.kill()
+		<-done
+		return Output{}, ctx.Err()
+	}
+
+	if ioErr != nil {
+		w.kill()
+
+		workerErr := &WorkerError{Err: w.exitErr}
+		var exitErr *exec.ExitError
+		if errors.As(w.exitErr, &exitErr) && exitErr.ExitCode() == workerExitCPULimit {
+			workerErr.CPULimitExceeded = true
+		}
+		return Output{}, workerErr
+	}
+
+	if response.Error != nil {
+		return Output{}, response.Error.err()
+	}
+
+	return response.Output.output(), nil
+}
+
+// hasExited returns whether the worker process has exited.
+func (w *worker) hasExited() bool {
+	select {
+	case <-w.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// kill kills the worker and waits for it to exit.
+func (w *worker) kill() {
+	w.dead = true
+
+	_ = w.cmd.Process.Kill()
+	<-w.exited
+}
+
+// WorkerError is returned when the worker running the query dies,
+// for example, by exceeding the memory or CPU limit of the workers.
+type WorkerError struct {
+	// CPULimitExceeded is true if the worker is stopped for
+	// exceeding [WorkerPoolConfig.CPULimit].
+	CPULimitExceeded bool
+	// Err is the exit error of the worker.
+	Err error
+}
+
+func (e *WorkerError) Error() string {
+	if e.CPULimitExceeded {
+		return "query crashed the worker: CPU time limit exceeded"
+	}
+
+	return fmt.Sprintf("query crashed the worker: %v", e.Err)
+}
+
+func (e *WorkerError) Unwrap() error {
+	return e.Err
+}
+
+// QueryError is the error SQLite reports for a query, when it is
+// prepared by the runner or run in a worker.
+type QueryError struct {
+	Message string
+	// code is the result code of SQLite, which is not kept across
+	// the processes.
+	code int
+}
+
+func (e *QueryError) Error() string {
+	return e.Message
+}
+
+// workerError is the error of a query run in a worker,
+// which keeps the type of the error across the processes.
+type workerError struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+
+	Limit Limit `json:"limit,omitempty"`
+	Max   int64 `json:"max,omitempty"`
+
+	Permission Permission `json:"permission,omitempty"`
+	Statement  int        `json:"statement,omitempty"`
+}
+
+const (
+	workerErrorLimit    = "limit"
+	workerErrorSandbox  = "sandbox"
+	workerErrorQuery    = "query"
+	workerErrorInternal = "internal"
+)
+
+func newWorkerError(err error) *workerError {
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		return &workerError{Kind: workerErrorLimit, Message: err.Error(), Limit: limitErr.Limit, Max: limitErr.Max}
+	}
+
+	var sandboxErr *SandboxError
+	if errors.As(err, &sandboxErr) {
+		return &workerError{Kind: workerErrorSandbox, Message: err.Error(), Permission: sandboxErr.Permission, Statement: sandboxErr.Statement}
+	}
+
+	if errors.As(err, new(*sqlite.Error)) || errors.As(err, new(*QueryError)) {
+		return &workerError{Kind: workerErrorQuery, Message: err.Error()}
+	}
+
+	return &workerError{Kind: workerErrorInternal, Message: err.Error()}
+}
+
+func (r *workerError) err() error {
+	switch r.Kind {
+	case workerErrorLimit:
+		limitErr := &LimitError{Limit: r.Limit, Max: r.Max}
+		if r.Limit == LimitTimeout {
+			limitErr.Err = context.DeadlineExceeded
+		}
+		return limitErr
+	case workerErrorSandbox:
+		return &SandboxError{Permission: r.Permission, Statement: r.Statement}
+	case workerErrorQuery:
+		return &QueryError{Message: r.Message}
+	default:
+		return errors.New(r.Message)
+	}
+}
+
+// ServeWorker runs the queries received from the standard input and
+// writes the results to the standard output until the input is closed.
+//
+// It is run by the binary started with [WorkerCommand], and the
+// arguments are the arguments after the command.
+func ServeWorker(args []string) error {
+	flags := flag.NewFlagSet(WorkerCommand, flag.ContinueOnError)
+	memoryLimit := flags.Int64("memory-limit", 0, "maximum bytes of memory")
+	cpuLimit := flags.Duration("cpu-limit", 0, "maximum CPU time of a query")
+	snapshotCacheSize := flags.Int64("snapshot-cache-size", 0, "size of the snapshot cache")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *memoryLimit > 0 {
+		if err := setMemoryLimit(*memoryLimit); err != nil {
+			return fmt.Errorf("set memory limit: %w", err)
+		}
+	}
+	if *cpuLimit > 0 {
+		handleCPULimit()
+	}
+	if err := enableHeapLimit(); err != nil {
+		return err
+	}
+
+	runner := NewRunner(*snapshotCacheSize)
+
+	decoder := json.NewDecoder(os.Stdin)
+	encoder := json.NewEncoder(os.Stdout)
+
+	for {
+		var request workerRequest
+		if err := decoder.Decode(&request); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("decode request: %w", err)
+		}
+
+		if *cpuLimit > 0 {
+			if err := setCPULimit(*cpuLimit); err != nil {
+				return fmt.Errorf("set CPU limit: %w", err)
+			}
+		}
+
+		var response workerResponse
+		// The worker runs a query at a time, so the heap limit of the
+		// process only applies to the query.
+		input := request.Input.input()
+		restoreHeapLimit := limitHeap(input.Limits.MaxHeapBytes)
+		output, err := runner.RunQuery(context.Background(), input)
+		restoreHeapLimit()
+		if err != nil {
+			response.Error = newWorkerError(err)
+		} else {
+			response.Output = newWorkerOutput(output)
+		}
+
+		if err := encoder.Encode(response); err != nil {
+			return fmt.Errorf("encode response: %w", err)
+		}
+	}
+}
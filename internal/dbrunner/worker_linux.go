@@ -0,0 +1,54 @@
+//go:build linux
+
+package dbrunner
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// setMemoryLimit limits the data segment of the worker, which covers
+// the heap and the other private writable mappings.
+func setMemoryLimit(bytes int64) error {
+	return syscall.Setrlimit(syscall.RLIMIT_DATA, &syscall.Rlimit{
+		Cur: uint64(bytes),
+		Max: uint64(bytes),
+	})
+}
+
+// setCPULimit limits the CPU time of the next query by moving the soft
+// limit of the CPU time to the current usage plus the limit.
+func setCPULimit(limit time.Duration) error {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return err
+	}
+
+	used := time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+	seconds := uint64(math.Ceil((used + limit).Seconds()))
+
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_CPU, &rlimit); err != nil {
+		return err
+	}
+	rlimit.Cur = min(seconds, rlimit.Max)
+
+	return syscall.Setrlimit(syscall.RLIMIT_CPU, &rlimit)
+}
+
+// handleCPULimit exits the worker when the soft limit of the CPU
+// time is exceeded. The Go runtime ignores SIGXCPU by default.
+func handleCPULimit() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGXCPU)
+
+	go func() {
+		<-signals
+		fmt.Fprintln(os.Stderr, "dbrunner worker: CPU time limit exceeded")
+		os.Exit(workerExitCPULimit)
+	}()
+}
@@ -0,0 +1,225 @@
+package dbrunner
+
+// The messages between the pool and the workers are JSON lines.
+//
+// The strings in JSON must be valid UTF-8, and encoding/json replaces
+// the bytes which are not, but SQLite does not validate the text, for
+// example, CAST(x'ff' AS TEXT). The text of the inputs and the outputs
+// is therefore sent as bytes, which are encoded in base64, and the nil
+// and empty slices are kept apart so the outputs have the same hashes
+// as the ones run in process.
+
+type workerRequest struct {
+	Input workerInput `json:"input"`
+}
+
+type workerResponse struct {
+	Output *workerOutput `json:"output,omitempty"`
+	Error  *workerError  `json:"error,omitempty"`
+}
+
+// workerInput is [Input] with its text as bytes.
+type workerInput struct {
+	Init         []byte   `json:"init"`
+	Query        []byte   `json:"query"`
+	CaptureState bool     `json:"capture_state,omitempty"`
+	StateTables  [][]byte `json:"state_tables,omitempty"`
+	Limits       Limits   `json:"limits,omitempty"`
+	Sandbox      Sandbox  `json:"sandbox,omitempty"`
+}
+
+func newWorkerInput(input Input) workerInput {
+	return workerInput{
+		Init:         []byte(input.Init),
+		Query:        []byte(input.Query),
+		CaptureState: input.CaptureState,
+		StateTables:  stringsToBytes(input.StateTables),
+		Limits:       input.Limits,
+		Sandbox:      input.Sandbox,
+	}
+}
+
+func (i workerInput) input() Input {
+	return Input{
+		Init:         string(i.Init),
+		Query:        string(i.Query),
+		CaptureState: i.CaptureState,
+		StateTables:  bytesToStrings(i.StateTables),
+		Limits:       i.Limits,
+		Sandbox:      i.Sandbox,
+	}
+}
+
+// workerOutput is [Output] with its text as bytes.
+type workerOutput struct {
+	Header      [][]byte          `json:"header"`
+	ColumnTypes [][]byte          `json:"column_types"`
+	Data        [][]workerCell    `json:"data"`
+	Statements  []workerStatement `json:"statements"`
+	State       []workerTable     `json:"state"`
+	Truncated   bool              `json:"truncated,omitempty"`
+}
+
+type workerStatement struct {
+	Header       [][]byte       `json:"header"`
+	ColumnTypes  [][]byte       `json:"column_types"`
+	Data         [][]workerCell `json:"data"`
+	RowsAffected *int64         `json:"rows_affected,omitempty"`
+}
+
+type workerTable struct {
+	Name    []byte         `json:"name"`
+	Missing bool           `json:"missing,omitempty"`
+	Header  [][]byte       `json:"header"`
+	Data    [][]workerCell `json:"data"`
+}
+
+// workerCell is [Cell] with its value as bytes. The value is nil if
+// the cell is NULL.
+type workerCell struct {
+	Value []byte   `json:"value"`
+	Type  CellType `json:"type,omitempty"`
+}
+
+func newWorkerOutput(output Output) *workerOutput {
+	wire := &workerOutput{
+		Header:      stringsToBytes(output.Header),
+		ColumnTypes: stringsToBytes(output.ColumnTypes),
+		Data:        rowsToWorker(output.Data),
+		Truncated:   output.Truncated,
+	}
+
+	if output.Statements != nil {
+		wire.Statements = make([]workerStatement, len(output.Statements))
+		for i, statement := range output.Statements {
+			wire.Statements[i] = workerStatement{
+				Header:       stringsToBytes(statement.Header),
+				ColumnTypes:  stringsToBytes(statement.ColumnTypes),
+				Data:         rowsToWorker(statement.Data),
+				RowsAffected: statement.RowsAffected,
+			}
+		}
+	}
+
+	if output.State != nil {
+		wire.State = make([]workerTable, len(output.State))
+		for i, table := range output.State {
+			wire.State[i] = workerTable{
+				Name:    []byte(table.Name),
+				Missing: table.Missing,
+				Header:  stringsToBytes(table.Header),
+				Data:    rowsToWorker(table.Data),
+			}
+		}
+	}
+
+	return wire
+}
+
+func (o *workerOutput) output() Output {
+	output := Output{
+		Header:      bytesToStrings(o.Header),
+		ColumnTypes: bytesToStrings(o.ColumnTypes),
+		Data:        rowsFromWorker(o.Data),
+		Truncated:   o.Truncated,
+	}
+
+	if o.Statements != nil {
+		output.Statements = make([]StatementResult, len(o.Statements))
+		for i, statement := range o.Statements {
+			output.Statements[i] = StatementResult{
+				Header:       bytesToStrings(statement.Header),
+				ColumnTypes:  bytesToStrings(statement.ColumnTypes),
+				Data:         rowsFromWorker(statement.Data),
+				RowsAffected: statement.RowsAffected,
+			}
+		}
+	}
+
+	if o.State != nil {
+		output.State = make([]TableState, len(o.State))
+		for i, table := range o.State {
+			output.State[i] = TableState{
+				Name:    string(table.Name),
+				Missing: table.Missing,
+				Header:  bytesToStrings(table.Header),
+				Data:    rowsFromWorker(table.Data),
+			}
+		}
+	}
+
+	return output
+}
+
+func rowsToWorker(rows [][]Cell) [][]workerCell {
+	if rows == nil {
+		return nil
+	}
+
+	wire := make([][]workerCell, len(rows))
+	for i, row := range rows {
+		if row == nil {
+			continue
+		}
+
+		wire[i] = make([]workerCell, len(row))
+		for j, cell := range row {
+			wire[i][j] = workerCell{Type: cell.Type}
+			if cell.Value != nil {
+				wire[i][j].Value = []byte(*cell.Value)
+			}
+		}
+	}
+
+	return wire
+}
+
+func rowsFromWorker(wire [][]workerCell) [][]Cell {
+	if wire == nil {
+		return nil
+	}
+
+	rows := make([][]Cell, len(wire))
+	for i, row := range wire {
+		if row == nil {
+			continue
+		}
+
+		rows[i] = make([]Cell, len(row))
+		for j, cell := range row {
+			rows[i][j] = Cell{Type: cell.Type}
+			if cell.Value != nil {
+				value := string(cell.Value)
+				rows[i][j].Value = &value
+			}
+		}
+	}
+
+	return rows
+}
+
+func stringsToBytes(strs []string) [][]byte {
+	if strs == nil {
+		return nil
+	}
+
+	b := make([][]byte, len(strs))
+	for i, str := range strs {
+		b[i] = []byte(str)
+	}
+
+	return b
+}
+
+func bytesToStrings(b [][]byte) []string {
+	if b == nil {
+		return nil
+	}
+
+	strs := make([]string, len(b))
+	for i, value := range b {
+		strs[i] = string(value)
+	}
+
+	return strs
+}
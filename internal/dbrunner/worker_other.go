@@ -0,0 +1,18 @@
+//go:build !linux
+
+package dbrunner
+
+import "time"
+
+// setMemoryLimit is not supported on this platform.
+func setMemoryLimit(int64) error {
+	return nil
+}
+
+// setCPULimit is not supported on this platform.
+func setCPULimit(time.Duration) error {
+	return nil
+}
+
+// handleCPULimit is not supported on this platform.
+func handleCPULimit() {}
@@ -0,0 +1,215 @@
+package dbrunner_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/database-playground/backend/internal/dbrunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain runs the test binary as a worker when the worker pool starts it.
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == dbrunner.WorkerCommand {
+		if err := dbrunner.ServeWorker(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+func newTestWorkerPool(t *testing.T, config dbrunner.WorkerPoolConfig) *dbrunner.WorkerPool {
+	t.Helper()
+
+	config.Path = os.Args[0]
+	pool, err := dbrunner.NewWorkerPool(config)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = pool.Close() })
+
+	return pool
+}
+
+func TestWorkerPool_RunQuery(t *testing.T) {
+	t.Parallel()
+
+	initSQL := `
+		CREATE TABLE test (id INTEGER PRIMARY KEY, name TEXT);
+		INSERT INTO test (name) VALUES ('Alice'), ('Bob');
+	`
+
+	t.Run("the worker returns the same output as running in process", func(t *testing.T) {
+		t.Parallel()
+
+		pool := newTestWorkerPool(t, dbrunner.WorkerPoolConfig{Size: 1})
+		input := dbrunner.Input{Init: initSQL, Query: "SELECT * FROM test ORDER BY id;"}
+
+		expected, err := dbrunner.RunQuery(context.Background(), input)
+		require.NoError(t, err)
+		expectedHash, err := expected.Hash()
+		require.NoError(t, err)
+
+		for range 2 {
+			output, err := pool.RunQuery(context.Background(), input)
+			require.NoError(t, err)
+
+			hash, err := output.Hash()
+			require.NoError(t, err)
+			assert.Equal(t, expectedHash, hash)
+			assert.Equal(t, expected.Data, output.Data)
+		}
+	})
+
+	t.Run("the text which is not UTF-8 is kept", func(t *testing.T) {
+		t.Parallel()
+
+		pool := newTestWorkerPool(t, dbrunner.WorkerPoolConfig{Size: 1})
+		input := dbrunner.Input{
+			Init:         initSQL,
+			Query:        `SELECT CAST(x'ff' AS TEXT) AS "a", '' AS "b", NULL AS "c"; DELETE FROM test WHERE name = CAST(x'fe' AS TEXT);`,
+			CaptureState: true,
+		}
+
+		expected, err := dbrunner.RunQuery(context.Background(), input)
+		require.NoError(t, err)
+		require.Equal(t, "\xff", *expected.Data[0][0].Value)
+
+		output, err := pool.RunQuery(context.Background(), input)
+		require.NoError(t, err)
+		assert.Equal(t, expected, output)
+	})
+
+	t.Run("the errors keep their types", func(t *testing.T) {
+		t.Parallel()
+
+		pool := newTestWorkerPool(t, dbrunner.WorkerPoolConfig{Size: 1})
+
+		_, err := pool.RunQuery(context.Background(), dbrunner.Input{Init: initSQL, Query: "SELECT * FROM unknown_table;"})
+		var queryErr *dbrunner.QueryError
+		require.ErrorAs(t, err, &queryErr)
+		assert.Contains(t, err.Error(), "no such table: unknown_table")
+
+		_, err = pool.RunQuery(context.Background(), dbrunner.Input{Init: initSQL, Query: "ATTACH ':memory:' AS other;"})
+		var sandboxErr *dbrunner.SandboxError
+		require.ErrorAs(t, err, &sandboxErr)
+		assert.Equal(t, dbrunner.PermissionAttach, sandboxErr.Permission)
+
+		_, err = pool.RunQuery(context.Background(), dbrunner.Input{
+			Init:   initSQL,
+			Query:  "WITH RECURSIVE cte (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM cte) SELECT count(*) FROM cte;",
+			Limits: dbrunner.Limits{Timeout: 100 * time.Millisecond},
+		})
+		var limitErr *dbrunner.LimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, dbrunner.LimitTimeout, limitErr.Limit)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("the heap limit only applies to the query in the worker", func(t *testing.T) {
+		t.Parallel()
+
+		pool := newTestWorkerPool(t, dbrunner.WorkerPoolConfig{Size: 1})
+
+		_, err := pool.RunQuery(context.Background(), dbrunner.Input{
+			Init: initSQL,
+			Query: `
+				WITH RECURSIVE cte (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM cte LIMIT 10000)
+				SELECT length(group_concat(hex(randomblob(1024)))) FROM cte;
+			`,
+			Limits: dbrunner.Limits{MaxHeapBytes: 1 << 20},
+		})
+		var limitErr *dbrunner.LimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, dbrunner.LimitHeapBytes, limitErr.Limit)
+
+		// the limit is removed after the query
+		output, err := pool.RunQuery(context.Background(), dbrunner.Input{
+			Init:  initSQL,
+			Query: "SELECT length(hex(randomblob(1 << 20)));",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, [][]dbrunner.Cell{{dbrunner.NewCell(int64(2 << 20))}}, output.Data)
+	})
+
+	t.Run("the busy workers are not reported as the timeout", func(t *testing.T) {
+		t.Parallel()
+
+		pool := newTestWorkerPool(t, dbrunner.WorkerPoolConfig{Size: 1})
+
+		busyCtx, cancelBusy := context.WithCancel(context.Background())
+		busyDone := make(chan struct{})
+		go func() {
+			defer close(busyDone)
+			_, _ = pool.RunQuery(busyCtx, dbrunner.Input{
+				Init:   initSQL,
+				Query:  "WITH RECURSIVE cte (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM cte) SELECT count(*) FROM cte;",
+				Limits: dbrunner.Limits{Timeout: 30 * time.Second},
+			})
+		}()
+		t.Cleanup(func() {
+			cancelBusy()
+			<-busyDone
+		})
+
+		// wait for the query above to take the worker
+		time.Sleep(200 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		_, err := pool.RunQuery(ctx, dbrunner.Input{Init: initSQL, Query: "SELECT count(*) FROM test;"})
+		require.ErrorIs(t, err, dbrunner.ErrWorkersBusy)
+		assert.False(t, errors.As(err, new(*dbrunner.LimitError)))
+	})
+
+	t.Run("the dead worker is restarted", func(t *testing.T) {
+		t.Parallel()
+
+		if runtime.GOOS != "linux" {
+			t.Skip("the CPU limit is only enforced on Linux")
+		}
+
+		pool := newTestWorkerPool(t, dbrunner.WorkerPoolConfig{Size: 1, CPULimit: time.Second})
+
+		_, err := pool.RunQuery(context.Background(), dbrunner.Input{
+			Init:   initSQL,
+			Query:  "WITH RECURSIVE cte (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM cte) SELECT count(*) FROM cte;",
+			Limits: dbrunner.Limits{Timeout: 30 * time.Second},
+		})
+		var workerErr *dbrunner.WorkerError
+		require.ErrorAs(t, err, &workerErr)
+		assert.True(t, workerErr.CPULimitExceeded)
+
+		output, err := pool.RunQuery(context.Background(), dbrunner.Input{Init: initSQL, Query: "SELECT count(*) FROM test;"})
+		require.NoError(t, err)
+		assert.Equal(t, [][]dbrunner.Cell{{dbrunner.NewCell(int64(2))}}, output.Data)
+	})
+
+	t.Run("the cancelled query kills the worker", func(t *testing.T) {
+		t.Parallel()
+
+		pool := newTestWorkerPool(t, dbrunner.WorkerPoolConfig{Size: 1})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		_, err := pool.RunQuery(ctx, dbrunner.Input{
+			Init:   initSQL,
+			Query:  "WITH RECURSIVE cte (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM cte) SELECT count(*) FROM cte;",
+			Limits: dbrunner.Limits{Timeout: 30 * time.Second},
+		})
+		require.Error(t, err)
+
+		output, err := pool.RunQuery(context.Background(), dbrunner.Input{Init: initSQL, Query: "SELECT count(*) FROM test;"})
+		require.NoError(t, err)
+		assert.Equal(t, [][]dbrunner.Cell{{dbrunner.NewCell(int64(2))}}, output.Data)
+	})
+}
@@ -5,6 +5,7 @@ package models
 import (
 	"time"
 
+	commonv1 "github.com/database-playground/backend/gen/common/v1"
 	questionmanagerv1 "github.com/database-playground/backend/gen/questionmanager/v1"
 	"github.com/google/uuid"
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -13,6 +14,8 @@ import (
 // goverter:converter
 // goverter:extend TimeToTimestamp
 // goverter:extend TimestampToTime
+// goverter:extend PTimeToTimestamp
+// goverter:extend TimestampToPTime
 // goverter:extend UUIDToString
 // goverter:extend StringToUUID
 type Converter interface {
@@ -59,6 +62,55 @@ type Converter interface {
 	// goverter:enum:map Difficulty_DIFFICULTY_HARD DifficultyHard
 	DifficultyFromProto(in questionmanagerv1.Difficulty) Difficulty
 
+	// goverter:enum:unknown DiffPolicy_DIFF_POLICY_UNSPECIFIED
+	// goverter:enum:map DiffPolicyUnspecified DiffPolicy_DIFF_POLICY_UNSPECIFIED
+	// goverter:enum:map DiffPolicyNone DiffPolicy_DIFF_POLICY_NONE
+	// goverter:enum:map DiffPolicySummary DiffPolicy_DIFF_POLICY_SUMMARY
+	// goverter:enum:map DiffPolicySample DiffPolicy_DIFF_POLICY_SAMPLE
+	DiffPolicyToProto(in DiffPolicy) questionmanagerv1.DiffPolicy
+
+	// goverter:enum:unknown DiffPolicyUnspecified
+	// goverter:enum:map DiffPolicy_DIFF_POLICY_UNSPECIFIED DiffPolicyUnspecified
+	// goverter:enum:map DiffPolicy_DIFF_POLICY_NONE DiffPolicyNone
+	// goverter:enum:map DiffPolicy_DIFF_POLICY_SUMMARY DiffPolicySummary
+	// goverter:enum:map DiffPolicy_DIFF_POLICY_SAMPLE DiffPolicySample
+	DiffPolicyFromProto(in questionmanagerv1.DiffPolicy) DiffPolicy
+
+	// CompareState is decided by QuestionAnswer.GradeByState.
+	//
+	// goverter:ignore state sizeCache unknownFields
+	// goverter:ignore CompareState
+	CompareOptionsToProto(in CompareOptions) *commonv1.CompareOptions
+
+	// goverter:useZeroValueOnPointerInconsistency
+	CompareOptionsFromProto(in *commonv1.CompareOptions) CompareOptions
+
+	// goverter:ignore state sizeCache unknownFields
+	// goverter:map TimeoutMS TimeoutMs
+	// goverter:map MaxVMSteps MaxVmSteps
+	ExecutionLimitsToProto(in ExecutionLimits) *commonv1.ExecutionLimits
+
+	// goverter:useZeroValueOnPointerInconsistency
+	// goverter:map TimeoutMs TimeoutMS
+	// goverter:map MaxVmSteps MaxVMSteps
+	ExecutionLimitsFromProto(in *commonv1.ExecutionLimits) ExecutionLimits
+
+	// goverter:enum:unknown SandboxPermission_SANDBOX_PERMISSION_UNSPECIFIED
+	// goverter:enum:map SandboxPermissionUnspecified SandboxPermission_SANDBOX_PERMISSION_UNSPECIFIED
+	// goverter:enum:map SandboxPermissionAttach SandboxPermission_SANDBOX_PERMISSION_ATTACH
+	// goverter:enum:map SandboxPermissionPragma SandboxPermission_SANDBOX_PERMISSION_PRAGMA
+	// goverter:enum:map SandboxPermissionVacuumInto SandboxPermission_SANDBOX_PERMISSION_VACUUM_INTO
+	// goverter:enum:map SandboxPermissionLoadExtension SandboxPermission_SANDBOX_PERMISSION_LOAD_EXTENSION
+	SandboxPermissionToProto(in SandboxPermission) commonv1.SandboxPermission
+
+	// goverter:enum:unknown SandboxPermissionUnspecified
+	// goverter:enum:map SandboxPermission_SANDBOX_PERMISSION_UNSPECIFIED SandboxPermissionUnspecified
+	// goverter:enum:map SandboxPermission_SANDBOX_PERMISSION_ATTACH SandboxPermissionAttach
+	// goverter:enum:map SandboxPermission_SANDBOX_PERMISSION_PRAGMA SandboxPermissionPragma
+	// goverter:enum:map SandboxPermission_SANDBOX_PERMISSION_VACUUM_INTO SandboxPermissionVacuumInto
+	// goverter:enum:map SandboxPermission_SANDBOX_PERMISSION_LOAD_EXTENSION SandboxPermissionLoadExtension
+	SandboxPermissionFromProto(in commonv1.SandboxPermission) SandboxPermission
+
 	// goverter:ignore state sizeCache unknownFields
 	// goverter:map ID Id
 	QuestionAnswerToProto(in *QuestionAnswer) *questionmanagerv1.QuestionAnswer
@@ -66,6 +118,13 @@ type Converter interface {
 	// goverter:map Id ID
 	QuestionAnswerFromProto(in *questionmanagerv1.QuestionAnswer) *QuestionAnswer
 
+	// goverter:ignore state sizeCache unknownFields
+	// goverter:map ID Id
+	QuestionDatasetToProto(in *QuestionDataset) *questionmanagerv1.QuestionDataset
+
+	// goverter:map Id ID
+	QuestionDatasetFromProto(in *questionmanagerv1.QuestionDataset) *QuestionDataset
+
 	// goverter:ignore state sizeCache unknownFields
 	// goverter:map ID Id
 	QuestionSolutionToProto(in *QuestionSolution) *questionmanagerv1.QuestionSolution
@@ -82,6 +141,23 @@ func TimestampToTime(t *timestamppb.Timestamp) time.Time {
 	return t.AsTime()
 }
 
+// PTimeToTimestamp converts the optional time, which is nil if the time is nil.
+func PTimeToTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+// TimestampToPTime converts the optional timestamp, which is nil if the timestamp is nil.
+func TimestampToPTime(t *timestamppb.Timestamp) *time.Time {
+	if t == nil {
+		return nil
+	}
+	value := t.AsTime()
+	return &value
+}
+
 func UUIDToString(id uuid.UUID) string {
 	return id.String()
 }
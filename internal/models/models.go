@@ -14,6 +14,8 @@ type Schema struct {
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// DeletedAt is when it was soft-deleted, which is nil unless it is deleted.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 type SchemaInitialSQL struct {
@@ -40,11 +42,29 @@ type Question struct {
 
 	Title       string `json:"title"`
 	Description string `json:"description"`
+	// Tags are the free-form labels of the question.
+	Tags []string `json:"tags"`
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// DeletedAt is when it was soft-deleted, which is nil unless it is deleted.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
+// DiffPolicy represents how much of the answer is revealed
+// when comparing the result of a challenge with the answer.
+type DiffPolicy string
+
+const (
+	DiffPolicyUnspecified DiffPolicy = ""
+	// DiffPolicyNone reveals nothing but whether the result is correct.
+	DiffPolicyNone DiffPolicy = "none"
+	// DiffPolicySummary reveals the row counts and the columns that differ.
+	DiffPolicySummary DiffPolicy = "summary"
+	// DiffPolicySample reveals the summary and a few missing and extra rows.
+	DiffPolicySample DiffPolicy = "sample"
+)
+
 type QuestionAnswer struct {
 	ID int64 `json:"id" db:"question_id"`
 
@@ -53,8 +73,133 @@ type QuestionAnswer struct {
 
 	// Schema is the initial SQL schema of the answer.
 	Schema string `json:"schema"`
+
+	// DiffPolicy is how much of the answer can be revealed in the diff.
+	DiffPolicy DiffPolicy `json:"diff_policy"`
+
+	// CompareOptions configures how the result of a challenge is compared with the answer.
+	CompareOptions CompareOptions `json:"compare_options"`
+
+	// GradeByState grades the challenges by the resulting database
+	// instead of the query result, for the questions modifying data.
+	GradeByState bool `json:"grade_by_state"`
+
+	// StateTables are the tables compared when grading by state.
+	// All the tables are compared if empty.
+	StateTables []string `json:"state_tables"`
+
+	// Datasets are the hidden datasets the challenges are graded against
+	// in addition to the schema.
+	Datasets []*QuestionDataset `json:"datasets" db:"-"`
+
+	// Limits limits the resources the answer and the challenges can use.
+	// The limits of the question override the limits of its schema.
+	Limits ExecutionLimits `json:"limits" db:"execution_limits"`
+
+	// SandboxAllow lists the sandboxed operations the answer and
+	// the challenges are allowed to perform.
+	SandboxAllow []SandboxPermission `json:"sandbox_allow"`
+
+	// AnswerOutputHash is the hash of the output of the answer on the
+	// schema when the question was written. It is nil if the output
+	// was not cached, the question was written before it is recorded,
+	// or the initial SQL or the limits of the schema have changed since.
+	AnswerOutputHash *string `json:"answer_output_hash,omitempty"`
+
+	// AnswerRowCount is the number of the rows of the result of the
+	// answer on the schema when the question was written.
+	AnswerRowCount *int64 `json:"answer_row_count,omitempty"`
+}
+
+// QuestionDataset is a hidden dataset of a question.
+type QuestionDataset struct {
+	ID int64 `json:"id" db:"dataset_id"`
+
+	// Schema is the initial SQL of the dataset, which has been
+	// combined with the initial SQL of the question schema if the
+	// dataset is a fixture.
+	Schema string `json:"schema"`
+}
+
+// CompareOptions configures how the result of a challenge is compared with the answer.
+//
+// The zero value compares the results exactly.
+type CompareOptions struct {
+	IgnoreRowOrder      bool `json:"ignore_row_order,omitempty"`
+	IgnoreColumnOrder   bool `json:"ignore_column_order,omitempty"`
+	IgnoreColumnNames   bool `json:"ignore_column_names,omitempty"`
+	IgnoreDuplicateRows bool `json:"ignore_duplicate_rows,omitempty"`
+	// FloatEpsilon is the maximum absolute difference between two
+	// numeric cells to be considered the same.
+	FloatEpsilon float64 `json:"float_epsilon,omitempty"`
+	// DecimalPlaces rounds the numeric cells to the decimal places
+	// before comparing.
+	DecimalPlaces *int32 `json:"decimal_places,omitempty"`
+}
+
+// ExecutionLimits limits the resources a query can use.
+//
+// The zero value of each field means the default limit.
+type ExecutionLimits struct {
+	// TimeoutMS is the maximum duration of running the schema and the query.
+	TimeoutMS int64 `json:"timeout_ms,omitempty"`
+	// MaxRows is the maximum number of rows kept in each result.
+	MaxRows int64 `json:"max_rows,omitempty"`
+	// MaxCellBytes is the maximum bytes of a cell.
+	MaxCellBytes int64 `json:"max_cell_bytes,omitempty"`
+	// MaxOutputBytes is the maximum total bytes of the result.
+	MaxOutputBytes int64 `json:"max_output_bytes,omitempty"`
+	// MaxDatabaseBytes is the maximum size of the database.
+	MaxDatabaseBytes int64 `json:"max_database_bytes,omitempty"`
+	// MaxVMSteps is the maximum number of the VM instructions of the query.
+	MaxVMSteps int64 `json:"max_vm_steps,omitempty"`
+	// MaxHeapBytes is the maximum bytes SQLite can allocate for the query.
+	MaxHeapBytes int64 `json:"max_heap_bytes,omitempty"`
 }
 
+// Override returns the limits with the non-zero limits of other, the
+// same as merging the stored limits of a schema with its question.
+func (l ExecutionLimits) Override(other ExecutionLimits) ExecutionLimits {
+	if other.TimeoutMS != 0 {
+		l.TimeoutMS = other.TimeoutMS
+	}
+	if other.MaxRows != 0 {
+		l.MaxRows = other.MaxRows
+	}
+	if other.MaxCellBytes != 0 {
+		l.MaxCellBytes = other.MaxCellBytes
+	}
+	if other.MaxOutputBytes != 0 {
+		l.MaxOutputBytes = other.MaxOutputBytes
+	}
+	if other.MaxDatabaseBytes != 0 {
+		l.MaxDatabaseBytes = other.MaxDatabaseBytes
+	}
+	if other.MaxVMSteps != 0 {
+		l.MaxVMSteps = other.MaxVMSteps
+	}
+	if other.MaxHeapBytes != 0 {
+		l.MaxHeapBytes = other.MaxHeapBytes
+	}
+
+	return l
+}
+
+// SandboxPermission is an operation the queries cannot perform unless allowed.
+type SandboxPermission string
+
+const (
+	SandboxPermissionUnspecified SandboxPermission = ""
+	// SandboxPermissionAttach allows ATTACH and DETACH.
+	SandboxPermissionAttach SandboxPermission = "attach"
+	// SandboxPermissionPragma allows all the PRAGMA statements.
+	SandboxPermissionPragma SandboxPermission = "pragma"
+	// SandboxPermissionVacuumInto allows VACUUM INTO.
+	SandboxPermissionVacuumInto SandboxPermission = "vacuum_into"
+	// SandboxPermissionLoadExtension allows load_extension().
+	SandboxPermissionLoadExtension SandboxPermission = "load_extension"
+)
+
 type QuestionSolution struct {
 	ID int64 `json:"id" db:"question_id"`
 
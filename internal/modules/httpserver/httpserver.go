@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"expvar"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -33,15 +34,56 @@ func WrapHTTPHandler[S any](f func(S, ...connect.HandlerOption) (string, http.Ha
 	}
 }
 
-var FxModule = fx.Module("generic-http-server", fx.Provide(createTLSCertificate), fx.Provide(createTLSCertPool), fx.Invoke(func(handler HTTPHandler, cert *tls.Certificate, certPool *x509.CertPool, lc fx.Lifecycle) {
+// AsHTTPHandler annotates the constructor of an [HTTPHandler], so the
+// server serves it along with the other handlers provided this way.
+func AsHTTPHandler(f any) any {
+	return fx.Annotate(f, fx.ResultTags(`group:"http_handlers"`))
+}
+
+// AsAdminHTTPHandler annotates the constructor of an [HTTPHandler], so
+// it is served on the admin address instead of PORT.
+//
+// The admin address is ADMIN_ADDR, which defaults to 127.0.0.1:3200
+// so the admin handlers are only reachable from the same host unless
+// it is configured otherwise.
+func AsAdminHTTPHandler(f any) any {
+	return fx.Annotate(f, fx.ResultTags(`group:"admin_http_handlers"`))
+}
+
+// ExpvarHandler returns the [HTTPHandler] serving the variables published
+// in expvar, such as the metrics of the services, at /debug/vars.
+//
+// It should be provided with [AsAdminHTTPHandler] so the metrics are
+// not exposed on PORT.
+func ExpvarHandler() HTTPHandler {
+	return HTTPHandler{
+		RpcPath: "/debug/vars",
+		Handler: expvar.Handler(),
+	}
+}
+
+var FxModule = fx.Module("generic-http-server", fx.Provide(createTLSCertificate), fx.Provide(createTLSCertPool), fx.Invoke(fx.Annotate(func(handlers []HTTPHandler, adminHandlers []HTTPHandler, cert *tls.Certificate, certPool *x509.CertPool, lc fx.Lifecycle) {
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "3000"
 	}
-	listenedOn := fmt.Sprintf("0.0.0.0:%s", port)
+	serve(lc, fmt.Sprintf("0.0.0.0:%s", port), handlers, cert, certPool)
 
+	if len(adminHandlers) > 0 {
+		adminAddr := os.Getenv("ADMIN_ADDR")
+		if adminAddr == "" {
+			adminAddr = "127.0.0.1:3200"
+		}
+		serve(lc, adminAddr, adminHandlers, cert, certPool)
+	}
+}, fx.ParamTags(`group:"http_handlers"`, `group:"admin_http_handlers"`))))
+
+// serve serves the handlers on the address during the lifecycle.
+func serve(lc fx.Lifecycle, listenedOn string, handlers []HTTPHandler, cert *tls.Certificate, certPool *x509.CertPool) {
 	mux := http.NewServeMux()
-	mux.Handle(handler.RpcPath, handler.Handler)
+	for _, handler := range handlers {
+		mux.Handle(handler.RpcPath, handler.Handler)
+	}
 	mux.Handle("/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		_, _ = w.Write([]byte("ok"))
 	}))
@@ -70,7 +112,9 @@ var FxModule = fx.Module("generic-http-server", fx.Provide(createTLSCertificate)
 					_ = srv.ListenAndServeTLS("", "")
 				}
 			}()
-			fmt.Printf("starting server at %s%s\n", listenedOn, handler.RpcPath)
+			for _, handler := range handlers {
+				fmt.Printf("starting server at %s%s\n", listenedOn, handler.RpcPath)
+			}
 			return nil
 		},
 		OnStop: func(ctx context.Context) error {
@@ -81,7 +125,7 @@ var FxModule = fx.Module("generic-http-server", fx.Provide(createTLSCertificate)
 			return nil
 		},
 	})
-}))
+}
 
 func createTLSCertificate(logger *slog.Logger) (*tls.Certificate, error) {
 	certFile := os.Getenv("TLS_CERT_FILE")
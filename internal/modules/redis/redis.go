@@ -1,7 +1,6 @@
 package redismodule
 
 import (
-	"errors"
 	"fmt"
 	"os"
 	"strconv"
@@ -12,10 +11,14 @@ import (
 
 var FxModule = fx.Module("redis", fx.Provide(New))
 
+// New creates the client of the Redis at REDIS_ADDR.
+//
+// It returns nil if REDIS_ADDR is not set, so the services can run
+// without Redis. The services requiring Redis should report it.
 func New() (*redis.Client, error) {
 	addr := os.Getenv("REDIS_ADDR")
 	if addr == "" {
-		return nil, errors.New("missing REDIS_ADDR")
+		return nil, nil
 	}
 	password := os.Getenv("REDIS_PASSWORD")
 	db := 0
@@ -0,0 +1,140 @@
+package dbrunnerservice
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"connectrpc.com/connect"
+	dbrunnerv1 "github.com/database-playground/backend/gen/dbrunner/v1"
+	"github.com/database-playground/backend/gen/dbrunner/v1/dbrunnerv1connect"
+	"github.com/database-playground/backend/internal/dbrunner"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// AdminService inspects and manages the cache of the [Service].
+type AdminService struct {
+	cacheModule *CacheModule
+
+	dbrunnerv1connect.UnimplementedDbRunnerAdminServiceHandler
+}
+
+// NewAdminService creates the admin service of the cache of the service.
+func NewAdminService(service *Service) *AdminService {
+	return &AdminService{
+		cacheModule: service.cacheModule,
+	}
+}
+
+func (s *AdminService) GetCacheStats(ctx context.Context, request *connect.Request[dbrunnerv1.GetCacheStatsRequest]) (*connect.Response[dbrunnerv1.GetCacheStatsResponse], error) {
+	stats, err := s.cacheModule.Stats(ctx)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	hits, misses := cacheMetrics.Hits.Value(), cacheMetrics.Misses.Value()
+	hitRatio := 0.0
+	if hits+misses > 0 {
+		hitRatio = float64(hits) / float64(hits+misses)
+	}
+
+	return &connect.Response[dbrunnerv1.GetCacheStatsResponse]{
+		Msg: &dbrunnerv1.GetCacheStatsResponse{
+			InputKeys:   stats.InputKeys,
+			OutputKeys:  stats.OutputKeys,
+			MemoryBytes: stats.MemoryBytes,
+			Hits:        hits,
+			Misses:      misses,
+			HitRatio:    hitRatio,
+		},
+	}, nil
+}
+
+func (s *AdminService) LookupInput(ctx context.Context, request *connect.Request[dbrunnerv1.LookupInputRequest]) (*connect.Response[dbrunnerv1.LookupInputResponse], error) {
+	if request.Msg.GetInputHash() == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("input_hash is required"))
+	}
+
+	outputHash, ttl, err := s.cacheModule.GetInput(ctx, request.Msg.GetInputHash())
+	if errors.Is(err, ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("input hash not found"))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	return &connect.Response[dbrunnerv1.LookupInputResponse]{
+		Msg: &dbrunnerv1.LookupInputResponse{
+			OutputHash: outputHash,
+			ExpiresAt:  expiresAt(ttl),
+		},
+	}, nil
+}
+
+func (s *AdminService) LookupOutput(ctx context.Context, request *connect.Request[dbrunnerv1.LookupOutputRequest]) (*connect.Response[dbrunnerv1.LookupOutputResponse], error) {
+	if request.Msg.GetOutputHash() == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("output_hash is required"))
+	}
+
+	output, size, ttl, err := s.cacheModule.InspectOutput(ctx, request.Msg.GetOutputHash())
+	if errors.Is(err, ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("output hash not found"))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	stateTables := make([]string, len(output.State))
+	for i, table := range output.State {
+		stateTables[i] = table.Name
+	}
+
+	return &connect.Response[dbrunnerv1.LookupOutputResponse]{
+		Msg: &dbrunnerv1.LookupOutputResponse{
+			Header: &dbrunnerv1.HeaderRow{
+				Header:        output.Header,
+				DeclaredTypes: output.ColumnTypes,
+				Truncated:     output.Truncated,
+			},
+			Rows:        int64(len(output.Data)),
+			Statements:  int64(len(output.Statements)),
+			StateTables: stateTables,
+			SizeBytes:   int64(size),
+			ExpiresAt:   expiresAt(ttl),
+		},
+	}, nil
+}
+
+func (s *AdminService) InvalidateSchema(ctx context.Context, request *connect.Request[dbrunnerv1.InvalidateSchemaRequest]) (*connect.Response[dbrunnerv1.InvalidateSchemaResponse], error) {
+	var schemaHash string
+	switch target := request.Msg.GetTarget().(type) {
+	case *dbrunnerv1.InvalidateSchemaRequest_SchemaHash:
+		schemaHash = target.SchemaHash
+	case *dbrunnerv1.InvalidateSchemaRequest_Schema:
+		schemaHash = dbrunner.Input{Init: target.Schema}.SchemaHash()
+	}
+	if schemaHash == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("schema_hash or schema is required"))
+	}
+
+	invalidated, err := s.cacheModule.InvalidateSchema(ctx, schemaHash)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	return &connect.Response[dbrunnerv1.InvalidateSchemaResponse]{
+		Msg: &dbrunnerv1.InvalidateSchemaResponse{
+			InvalidatedInputs: invalidated,
+		},
+	}, nil
+}
+
+// expiresAt returns the expiration of the entry of the remaining time to
+// live, which is nil if the entry is pinned.
+func expiresAt(ttl time.Duration) *timestamppb.Timestamp {
+	if ttl <= 0 {
+		return nil
+	}
+
+	return timestamppb.New(time.Now().Add(ttl))
+}
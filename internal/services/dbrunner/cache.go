@@ -0,0 +1,181 @@
+package dbrunnerservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultCacheTTL is the default time to live of the cache entries.
+const defaultCacheTTL = 1 * time.Hour
+
+// defaultMemoryCacheSize is the default total size of the in-process cache.
+const defaultMemoryCacheSize = 64 << 20 // 64 MiB
+
+// CacheTTL configures how long the entries are kept in the [Cache].
+type CacheTTL struct {
+	// Input is the time to live of the input-hash-to-output-hash mappings.
+	Input time.Duration
+	// Output is the time to live of the outputs.
+	Output time.Duration
+	// Sliding extends the time to live of an entry whenever it is read.
+	// Otherwise, the entries expire after the TTL since they are written.
+	Sliding bool
+}
+
+// DefaultCacheTTL keeps the entries for 1 hour since their last access.
+var DefaultCacheTTL = CacheTTL{
+	Input:   defaultCacheTTL,
+	Output:  defaultCacheTTL,
+	Sliding: true,
+}
+
+// Cache stores the mappings of the [CacheModule]:
+//
+//	<input-hash> -> <output-hash>
+//	<output-hash> -> <output-marshaled>
+//	<input-hash> -> <query>
+//
+// and indexes the input hashes by the hash of their schemas
+// ([dbrunner.Input.SchemaHash]), so the entries derived from a schema
+// can be invalidated.
+//
+// The entries expire by its [CacheTTL] unless they are pinned.
+// The getters return [ErrNotFound] if there is no such entry, and the
+// remaining time to live of the entry, which is zero if it is pinned.
+type Cache interface {
+	// GetOutputHash returns the output hash of the input hash, and the
+	// schema hash it is mapped with, which is empty if it is unknown.
+	GetOutputHash(ctx context.Context, inputHash string) (outputHash string, schemaHash string, ttl time.Duration, err error)
+	// SetOutputHash maps the input hash of the schema to the output hash.
+	SetOutputHash(ctx context.Context, schemaHash string, inputHash string, outputHash string) error
+
+	// HasOutput returns whether the output of the output hash is existed.
+	HasOutput(ctx context.Context, outputHash string) (ttl time.Duration, ok bool, err error)
+	// GetOutput returns the marshaled output of the output hash.
+	GetOutput(ctx context.Context, outputHash string) (output []byte, err error)
+	// SetOutput writes (overrides) the marshaled output of the output hash.
+	SetOutput(ctx context.Context, outputHash string, output []byte) error
+
+	// GetQuery returns the query kept for the input hash.
+	GetQuery(ctx context.Context, inputHash string) (query string, err error)
+	// SetQuery keeps (overrides) the query of the input hash, which
+	// expires by the TTL of the mappings. The queries are not removed
+	// when their schemas are invalidated.
+	SetQuery(ctx context.Context, inputHash string, query string) error
+
+	// Pin keeps the mapping of the input hash and the output of the
+	// output hash until they are overridden or invalidated.
+	Pin(ctx context.Context, schemaHash string, inputHash string, outputHash string) error
+
+	// Stats returns the number of the entries and the memory used.
+	Stats(ctx context.Context) (CacheStats, error)
+	// InvalidateSchema removes the mappings of the input hashes of the
+	// schema, including the pinned ones. It returns the number of the
+	// mappings removed.
+	//
+	// The outputs are kept, since they may be shared with the inputs of
+	// another schema, and the outputs of the pinned mappings are unpinned
+	// to expire by their TTL.
+	InvalidateSchema(ctx context.Context, schemaHash string) (invalidated int64, err error)
+}
+
+// CacheStats is the statistics of a [Cache].
+type CacheStats struct {
+	// InputKeys is the number of the input-hash-to-output-hash mappings.
+	InputKeys int64
+	// OutputKeys is the number of the outputs.
+	OutputKeys int64
+	// MemoryBytes is the memory used by the cache. For Redis, it is the
+	// memory used by the whole server.
+	MemoryBytes int64
+}
+
+// The backends of the cache, selected by DB_RUNNER_CACHE.
+const (
+	CacheBackendRedis   = "redis"
+	CacheBackendMemory  = "memory"
+	CacheBackendLayered = "layered"
+)
+
+// NewCache creates the cache of the backend in DB_RUNNER_CACHE:
+//
+//   - "redis" (default) stores the cache in Redis.
+//   - "memory" stores the cache in this process, which is not shared with
+//     the other replicas and does not require Redis.
+//   - "layered" stores the cache in Redis, and keeps the recently used
+//     entries in this process as well.
+//
+// The size of the in-process cache is DB_RUNNER_CACHE_MEMORY_SIZE bytes.
+// redis is nil if Redis is not configured.
+//
+// The time to live of the mappings and the outputs are
+// DB_RUNNER_CACHE_INPUT_TTL and DB_RUNNER_CACHE_OUTPUT_TTL, which
+// default to 1 hour. They are extended when the entries are read,
+// unless DB_RUNNER_CACHE_SLIDING_EXPIRY is false.
+func NewCache(redis *redis.Client) (Cache, error) {
+	ttl := DefaultCacheTTL
+	if ttlStr := os.Getenv("DB_RUNNER_CACHE_INPUT_TTL"); ttlStr != "" {
+		var err error
+		ttl.Input, err = time.ParseDuration(ttlStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DB_RUNNER_CACHE_INPUT_TTL: %w", err)
+		}
+		if ttl.Input <= 0 {
+			return nil, errors.New("invalid DB_RUNNER_CACHE_INPUT_TTL: must be positive")
+		}
+	}
+	if ttlStr := os.Getenv("DB_RUNNER_CACHE_OUTPUT_TTL"); ttlStr != "" {
+		var err error
+		ttl.Output, err = time.ParseDuration(ttlStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DB_RUNNER_CACHE_OUTPUT_TTL: %w", err)
+		}
+		if ttl.Output <= 0 {
+			return nil, errors.New("invalid DB_RUNNER_CACHE_OUTPUT_TTL: must be positive")
+		}
+	}
+	if slidingStr := os.Getenv("DB_RUNNER_CACHE_SLIDING_EXPIRY"); slidingStr != "" {
+		var err error
+		ttl.Sliding, err = strconv.ParseBool(slidingStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DB_RUNNER_CACHE_SLIDING_EXPIRY: %w", err)
+		}
+	}
+
+	memorySize := int64(defaultMemoryCacheSize)
+	if sizeStr := os.Getenv("DB_RUNNER_CACHE_MEMORY_SIZE"); sizeStr != "" {
+		var err error
+		memorySize, err = strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DB_RUNNER_CACHE_MEMORY_SIZE: %w", err)
+		}
+	}
+
+	backend := os.Getenv("DB_RUNNER_CACHE")
+	if backend == "" {
+		backend = CacheBackendRedis
+	}
+
+	switch backend {
+	case CacheBackendRedis:
+		if redis == nil {
+			return nil, errors.New("missing REDIS_ADDR, which the redis cache requires")
+		}
+		return NewRedisCache(redis, ttl), nil
+	case CacheBackendMemory:
+		return NewMemoryCache(memorySize, ttl), nil
+	case CacheBackendLayered:
+		if redis == nil {
+			return nil, errors.New("missing REDIS_ADDR, which the layered cache requires")
+		}
+		return NewLayeredCache(NewMemoryCache(memorySize, ttl), NewRedisCache(redis, ttl)), nil
+	default:
+		return nil, fmt.Errorf("invalid DB_RUNNER_CACHE: unknown backend %q", backend)
+	}
+}
@@ -0,0 +1,222 @@
+package dbrunnerservice
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	dbrunnerv1 "github.com/database-playground/backend/gen/dbrunner/v1"
+	"github.com/database-playground/backend/internal/dbrunner"
+	"github.com/samber/lo"
+	"google.golang.org/protobuf/proto"
+)
+
+// The first byte of a cached output marks its format.
+const (
+	// outputFormatJSON is the JSON written before the format marker was
+	// introduced. It is only read, and the marker is the leading '{'
+	// of the JSON itself.
+	outputFormatJSON byte = '{'
+	// outputFormatProto is a [dbrunnerv1.CachedOutput] in protobuf.
+	outputFormatProto byte = 0x01
+	// outputFormatProtoFlate is a [dbrunnerv1.CachedOutput] in protobuf,
+	// compressed with DEFLATE.
+	outputFormatProtoFlate byte = 0x02
+)
+
+// outputCompressThreshold is the size of the protobuf output above
+// which the output is compressed.
+const outputCompressThreshold = 1024
+
+// marshalOutput encodes the output to be cached.
+//
+// The output is encoded in protobuf, and compressed if it is larger
+// than [outputCompressThreshold].
+func marshalOutput(output dbrunner.Output) ([]byte, error) {
+	encoded, err := proto.Marshal(outputToCached(output))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(encoded) <= outputCompressThreshold {
+		return append([]byte{outputFormatProto}, encoded...), nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(outputFormatProtoFlate)
+
+	writer, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(encoded); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// unmarshalOutput decodes the cached output in any format.
+func unmarshalOutput(data []byte) (*dbrunner.Output, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty cached output")
+	}
+
+	var encoded []byte
+
+	switch data[0] {
+	case outputFormatJSON:
+		var output *dbrunner.Output
+		if err := json.Unmarshal(data, &output); err != nil {
+			return nil, err
+		}
+		return output, nil
+	case outputFormatProto:
+		encoded = data[1:]
+	case outputFormatProtoFlate:
+		var err error
+		encoded, err = io.ReadAll(flate.NewReader(bytes.NewReader(data[1:])))
+		if err != nil {
+			return nil, fmt.Errorf("decompress cached output: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown format of cached output: %#x", data[0])
+	}
+
+	var cached dbrunnerv1.CachedOutput
+	if err := proto.Unmarshal(encoded, &cached); err != nil {
+		return nil, err
+	}
+
+	output := outputFromCached(&cached)
+	return &output, nil
+}
+
+func outputToCached(output dbrunner.Output) *dbrunnerv1.CachedOutput {
+	cached := &dbrunnerv1.CachedOutput{
+		Header: &dbrunnerv1.HeaderRow{
+			Header:        output.Header,
+			DeclaredTypes: output.ColumnTypes,
+			Truncated:     output.Truncated,
+		},
+		Rows:       rowsToCached(output.Data),
+		Statements: make([]*dbrunnerv1.CachedStatement, len(output.Statements)),
+		State:      make([]*dbrunnerv1.CachedTableState, len(output.State)),
+	}
+
+	for i, statement := range output.Statements {
+		cached.Statements[i] = &dbrunnerv1.CachedStatement{
+			Header: &dbrunnerv1.HeaderRow{
+				Header:        statement.Header,
+				DeclaredTypes: statement.ColumnTypes,
+			},
+			Rows:         rowsToCached(statement.Data),
+			RowsAffected: statement.RowsAffected,
+		}
+	}
+
+	for i, table := range output.State {
+		cached.State[i] = &dbrunnerv1.CachedTableState{
+			Name:    table.Name,
+			Missing: table.Missing,
+			Header:  table.Header,
+			Rows:    rowsToCached(table.Data),
+		}
+	}
+
+	return cached
+}
+
+func outputFromCached(cached *dbrunnerv1.CachedOutput) dbrunner.Output {
+	output := dbrunner.Output{
+		Header:      stringsFromProto(cached.GetHeader().GetHeader()),
+		ColumnTypes: cached.GetHeader().GetDeclaredTypes(),
+		Data:        rowsFromCached(cached.GetRows()),
+		Truncated:   cached.GetHeader().GetTruncated(),
+	}
+
+	if len(cached.GetStatements()) > 0 {
+		output.Statements = make([]dbrunner.StatementResult, len(cached.GetStatements()))
+		for i, statement := range cached.GetStatements() {
+			output.Statements[i] = dbrunner.StatementResult{
+				Header:       stringsFromProto(statement.GetHeader().GetHeader()),
+				ColumnTypes:  statement.GetHeader().GetDeclaredTypes(),
+				Data:         rowsFromCached(statement.GetRows()),
+				RowsAffected: statement.RowsAffected,
+			}
+		}
+	}
+
+	if len(cached.GetState()) > 0 {
+		output.State = make([]dbrunner.TableState, len(cached.GetState()))
+		for i, table := range cached.GetState() {
+			output.State[i] = dbrunner.TableState{
+				Name:    table.GetName(),
+				Missing: table.GetMissing(),
+				Header:  stringsFromProto(table.GetHeader()),
+				Data:    rowsFromCached(table.GetRows()),
+			}
+		}
+	}
+
+	return output
+}
+
+// rowsToCached converts the rows to the cached rows, keeping the bytes
+// of the values as they are.
+func rowsToCached(rows [][]dbrunner.Cell) []*dbrunnerv1.CachedRow {
+	cachedRows := make([]*dbrunnerv1.CachedRow, len(rows))
+	for i, row := range rows {
+		cells := make([]*dbrunnerv1.CachedCell, len(row))
+		for j, cell := range row {
+			cells[j] = &dbrunnerv1.CachedCell{Type: cellTypeToProto[cell.Type]}
+			if cell.Value != nil {
+				cells[j].Value = []byte(*cell.Value)
+			}
+		}
+		cachedRows[i] = &dbrunnerv1.CachedRow{Cells: cells}
+	}
+
+	return cachedRows
+}
+
+// rowsFromCached converts the cached rows. The result is never nil,
+// as the JSON-decoded outputs.
+func rowsFromCached(cachedRows []*dbrunnerv1.CachedRow) [][]dbrunner.Cell {
+	rows := make([][]dbrunner.Cell, len(cachedRows))
+	for i, cachedRow := range cachedRows {
+		rows[i] = make([]dbrunner.Cell, len(cachedRow.GetCells()))
+		for j, cell := range cachedRow.GetCells() {
+			rows[i][j] = dbrunner.Cell{Type: cellTypeFromProto[cell.GetType()]}
+			if cell.Value != nil {
+				rows[i][j].Value = lo.ToPtr(string(cell.Value))
+			}
+		}
+	}
+
+	return rows
+}
+
+// stringsFromProto returns the strings, or an empty slice if it is nil.
+func stringsFromProto(strings []string) []string {
+	if strings == nil {
+		return []string{}
+	}
+
+	return strings
+}
+
+var cellTypeFromProto = map[dbrunnerv1.CellType]dbrunner.CellType{
+	dbrunnerv1.CellType_CELL_TYPE_UNSPECIFIED: dbrunner.CellTypeUnspecified,
+	dbrunnerv1.CellType_CELL_TYPE_NULL:        dbrunner.CellTypeNull,
+	dbrunnerv1.CellType_CELL_TYPE_INTEGER:     dbrunner.CellTypeInteger,
+	dbrunnerv1.CellType_CELL_TYPE_REAL:        dbrunner.CellTypeReal,
+	dbrunnerv1.CellType_CELL_TYPE_TEXT:        dbrunner.CellTypeText,
+	dbrunnerv1.CellType_CELL_TYPE_BLOB:        dbrunner.CellTypeBlob,
+}
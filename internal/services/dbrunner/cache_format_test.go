@@ -0,0 +1,118 @@
+package dbrunnerservice
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/database-playground/backend/internal/dbrunner"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestOutputFormat(t *testing.T) {
+	output := dbrunner.Output{
+		Header:      []string{"id", "name"},
+		ColumnTypes: []string{"INTEGER", ""},
+		Data: [][]dbrunner.Cell{
+			{dbrunner.NewCell(int64(1)), dbrunner.NewCell("")},
+			{dbrunner.NewCell(int64(2)), dbrunner.NewCell(nil)},
+		},
+		Statements: []dbrunner.StatementResult{
+			{Header: []string{}, Data: [][]dbrunner.Cell{}, RowsAffected: lo.ToPtr(int64(2))},
+			{Header: []string{"id"}, ColumnTypes: []string{"INTEGER"}, Data: [][]dbrunner.Cell{{dbrunner.NewCell(int64(1))}}},
+		},
+		State: []dbrunner.TableState{
+			{Name: "a", Header: []string{"id"}, Data: [][]dbrunner.Cell{{dbrunner.NewCell(1.5)}}},
+			{Name: "b", Missing: true, Header: []string{}, Data: [][]dbrunner.Cell{}},
+		},
+		Truncated: true,
+	}
+
+	// assertSameOutput compares the outputs in JSON, where the nil
+	// and the empty slices are the same.
+	assertSameOutput := func(t *testing.T, expected dbrunner.Output, actual *dbrunner.Output) {
+		t.Helper()
+
+		require.NotNil(t, actual)
+		assert.JSONEq(t, string(lo.Must(json.Marshal(expected))), string(lo.Must(json.Marshal(actual))))
+	}
+
+	t.Run("the small output is not compressed", func(t *testing.T) {
+		marshaled, err := marshalOutput(output)
+		require.NoError(t, err)
+		assert.Equal(t, outputFormatProto, marshaled[0])
+
+		unmarshaled, err := unmarshalOutput(marshaled)
+		require.NoError(t, err)
+		assertSameOutput(t, output, unmarshaled)
+	})
+
+	t.Run("the large output is compressed", func(t *testing.T) {
+		large := dbrunner.Output{
+			Header: []string{"value"},
+			Data:   make([][]dbrunner.Cell, 1000),
+		}
+		for i := range large.Data {
+			large.Data[i] = []dbrunner.Cell{dbrunner.NewCell(strings.Repeat("x", 16))}
+		}
+
+		marshaled, err := marshalOutput(large)
+		require.NoError(t, err)
+		assert.Equal(t, outputFormatProtoFlate, marshaled[0])
+		assert.Less(t, len(marshaled), len(lo.Must(json.Marshal(large)))/10)
+
+		unmarshaled, err := unmarshalOutput(marshaled)
+		require.NoError(t, err)
+		assertSameOutput(t, large, unmarshaled)
+	})
+
+	t.Run("the empty output has the empty header and data", func(t *testing.T) {
+		marshaled, err := marshalOutput(dbrunner.Output{})
+		require.NoError(t, err)
+
+		unmarshaled, err := unmarshalOutput(marshaled)
+		require.NoError(t, err)
+		assert.NotNil(t, unmarshaled.Header)
+		assert.NotNil(t, unmarshaled.Data)
+	})
+
+	t.Run("the legacy JSON output is readable", func(t *testing.T) {
+		unmarshaled, err := unmarshalOutput(lo.Must(json.Marshal(output)))
+		require.NoError(t, err)
+		assertSameOutput(t, output, unmarshaled)
+	})
+
+	t.Run("the text which is not UTF-8 is kept", func(t *testing.T) {
+		output, err := dbrunner.RunQuery(context.Background(), dbrunner.Input{
+			Init:  "CREATE TABLE t (id INTEGER);",
+			Query: "SELECT CAST(x'ff' AS TEXT), CAST(x'fe' AS TEXT);",
+		})
+		require.NoError(t, err)
+		require.Equal(t, "\xff", *output.Data[0][0].Value)
+
+		marshaled, err := marshalOutput(output)
+		require.NoError(t, err)
+
+		unmarshaled, err := unmarshalOutput(marshaled)
+		require.NoError(t, err)
+		assert.Equal(t, output.Data, unmarshaled.Data)
+
+		// the protobuf cells of the responses are valid UTF-8
+		cell := cellToProto(unmarshaled.Data[0][1])
+		assert.Equal(t, "\uFFFD", cell.GetValue())
+		_, err = proto.Marshal(cell)
+		assert.NoError(t, err)
+	})
+
+	t.Run("the unknown format is rejected", func(t *testing.T) {
+		_, err := unmarshalOutput([]byte{0xff, 0x00})
+		assert.Error(t, err)
+
+		_, err = unmarshalOutput(nil)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,161 @@
+package dbrunnerservice
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// LayeredCache is the [Cache] which keeps the recently used entries of
+// the remote cache in the local cache, for example, a [MemoryCache] in
+// front of a [RedisCache].
+//
+// The entries are written to both caches. The outputs are read from the
+// local cache first, which saves transferring them, but the mappings and
+// the existence of the outputs are still checked against the remote
+// cache, which extends their TTL there and reports their remaining TTL.
+// The local copies are only used if the remote cache fails.
+//
+// Invalidating a schema only removes the local copies in this replica,
+// and the copies in the other replicas are not removed. They are not used
+// after the invalidation either, since the mappings are checked against
+// the remote cache, and the outputs, which are never changed for their
+// hashes, are only reached by the mappings.
+type LayeredCache struct {
+	local  Cache
+	remote Cache
+}
+
+func NewLayeredCache(local Cache, remote Cache) *LayeredCache {
+	return &LayeredCache{
+		local:  local,
+		remote: remote,
+	}
+}
+
+// GetOutputHash returns the output hash of the remote cache, and keeps
+// a copy in the local cache. The local copy is returned if the remote
+// cache fails.
+//
+// The copy is indexed by the schema hash of the remote mapping, so it is
+// removed when the schema is invalidated. The mappings whose schema hash
+// is unknown are not copied, since they could not be invalidated.
+func (c *LayeredCache) GetOutputHash(ctx context.Context, inputHash string) (outputHash string, schemaHash string, ttl time.Duration, err error) {
+	outputHash, schemaHash, ttl, err = c.remote.GetOutputHash(ctx, inputHash)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		if localOutputHash, localSchemaHash, localTTL, localErr := c.local.GetOutputHash(ctx, inputHash); localErr == nil {
+			return localOutputHash, localSchemaHash, localTTL, nil
+		}
+	}
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	// The local cache is only a copy, so its errors are ignored.
+	if schemaHash != "" {
+		if localOutputHash, localSchemaHash, _, err := c.local.GetOutputHash(ctx, inputHash); err != nil || localOutputHash != outputHash || localSchemaHash != schemaHash {
+			_ = c.local.SetOutputHash(ctx, schemaHash, inputHash, outputHash)
+		}
+	}
+	return outputHash, schemaHash, ttl, nil
+}
+
+func (c *LayeredCache) SetOutputHash(ctx context.Context, schemaHash string, inputHash string, outputHash string) error {
+	if err := c.remote.SetOutputHash(ctx, schemaHash, inputHash, outputHash); err != nil {
+		return err
+	}
+
+	_ = c.local.SetOutputHash(ctx, schemaHash, inputHash, outputHash)
+	return nil
+}
+
+// HasOutput returns whether the output is in the remote cache, with its
+// remaining TTL there. The output only in the local cache, for example,
+// when the remote cache fails or has evicted it, is still available.
+func (c *LayeredCache) HasOutput(ctx context.Context, outputHash string) (ttl time.Duration, ok bool, err error) {
+	ttl, ok, err = c.remote.HasOutput(ctx, outputHash)
+	if err == nil && ok {
+		return ttl, true, nil
+	}
+
+	if localTTL, localOK, localErr := c.local.HasOutput(ctx, outputHash); localErr == nil && localOK {
+		return localTTL, true, nil
+	}
+
+	return ttl, ok, err
+}
+
+func (c *LayeredCache) GetOutput(ctx context.Context, outputHash string) (output []byte, err error) {
+	output, err = c.local.GetOutput(ctx, outputHash)
+	if err == nil {
+		return output, nil
+	}
+
+	output, err = c.remote.GetOutput(ctx, outputHash)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = c.local.SetOutput(ctx, outputHash, output)
+	return output, nil
+}
+
+func (c *LayeredCache) SetOutput(ctx context.Context, outputHash string, output []byte) error {
+	if err := c.remote.SetOutput(ctx, outputHash, output); err != nil {
+		return err
+	}
+
+	_ = c.local.SetOutput(ctx, outputHash, output)
+	return nil
+}
+
+// GetQuery returns the query of the local cache, or the remote cache
+// if the local cache does not have it. The query is never changed for
+// its input hash except by another query normalized to the same input.
+func (c *LayeredCache) GetQuery(ctx context.Context, inputHash string) (query string, err error) {
+	query, err = c.local.GetQuery(ctx, inputHash)
+	if err == nil {
+		return query, nil
+	}
+
+	query, err = c.remote.GetQuery(ctx, inputHash)
+	if err != nil {
+		return "", err
+	}
+
+	_ = c.local.SetQuery(ctx, inputHash, query)
+	return query, nil
+}
+
+func (c *LayeredCache) SetQuery(ctx context.Context, inputHash string, query string) error {
+	if err := c.remote.SetQuery(ctx, inputHash, query); err != nil {
+		return err
+	}
+
+	_ = c.local.SetQuery(ctx, inputHash, query)
+	return nil
+}
+
+func (c *LayeredCache) Pin(ctx context.Context, schemaHash string, inputHash string, outputHash string) error {
+	if err := c.remote.Pin(ctx, schemaHash, inputHash, outputHash); err != nil {
+		return err
+	}
+
+	_ = c.local.Pin(ctx, schemaHash, inputHash, outputHash)
+	return nil
+}
+
+// Stats returns the stats of the remote cache, which has all the entries.
+func (c *LayeredCache) Stats(ctx context.Context) (CacheStats, error) {
+	return c.remote.Stats(ctx)
+}
+
+// InvalidateSchema invalidates the entries of the schema in both caches,
+// and returns the number of the mappings removed from the remote cache.
+// The copies in the local caches of the other replicas are kept, but
+// are not used since the mappings are gone from the remote cache.
+func (c *LayeredCache) InvalidateSchema(ctx context.Context, schemaHash string) (invalidated int64, err error) {
+	_, _ = c.local.InvalidateSchema(ctx, schemaHash)
+
+	return c.remote.InvalidateSchema(ctx, schemaHash)
+}
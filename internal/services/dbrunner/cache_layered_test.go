@@ -0,0 +1,193 @@
+package dbrunnerservice_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	dbrunnerservice "github.com/database-playground/backend/internal/services/dbrunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingCache is the remote cache which is unavailable.
+type failingCache struct {
+	dbrunnerservice.Cache
+}
+
+func (failingCache) GetOutputHash(ctx context.Context, inputHash string) (string, string, time.Duration, error) {
+	return "", "", 0, errors.New("unavailable")
+}
+
+func (failingCache) HasOutput(ctx context.Context, outputHash string) (time.Duration, bool, error) {
+	return 0, false, errors.New("unavailable")
+}
+
+func TestLayeredCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("the entries are written to both caches", func(t *testing.T) {
+		local := dbrunnerservice.NewMemoryCache(1024, dbrunnerservice.DefaultCacheTTL)
+		remote := dbrunnerservice.NewMemoryCache(1024, dbrunnerservice.DefaultCacheTTL)
+		cache := dbrunnerservice.NewLayeredCache(local, remote)
+
+		require.NoError(t, cache.SetOutputHash(ctx, "schema-hash", "input-hash", "output-hash"))
+		require.NoError(t, cache.SetOutput(ctx, "output-hash", []byte("output")))
+		require.NoError(t, cache.SetQuery(ctx, "input-hash", "SELECT 1;"))
+
+		for _, c := range []dbrunnerservice.Cache{local, remote} {
+			outputHash, _, _, err := c.GetOutputHash(ctx, "input-hash")
+			require.NoError(t, err)
+			assert.Equal(t, "output-hash", outputHash)
+
+			output, err := c.GetOutput(ctx, "output-hash")
+			require.NoError(t, err)
+			assert.Equal(t, []byte("output"), output)
+
+			query, err := c.GetQuery(ctx, "input-hash")
+			require.NoError(t, err)
+			assert.Equal(t, "SELECT 1;", query)
+		}
+	})
+
+	t.Run("the entries only in the remote cache are copied to the local cache", func(t *testing.T) {
+		local := dbrunnerservice.NewMemoryCache(1024, dbrunnerservice.DefaultCacheTTL)
+		remote := dbrunnerservice.NewMemoryCache(1024, dbrunnerservice.DefaultCacheTTL)
+		cache := dbrunnerservice.NewLayeredCache(local, remote)
+
+		require.NoError(t, remote.SetOutputHash(ctx, "schema-hash", "input-hash", "output-hash"))
+		require.NoError(t, remote.SetOutput(ctx, "output-hash", []byte("output")))
+
+		_, ok, err := cache.HasOutput(ctx, "output-hash")
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		outputHash, _, _, err := cache.GetOutputHash(ctx, "input-hash")
+		require.NoError(t, err)
+		assert.Equal(t, "output-hash", outputHash)
+
+		output, err := cache.GetOutput(ctx, "output-hash")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("output"), output)
+
+		outputHash, _, _, err = local.GetOutputHash(ctx, "input-hash")
+		require.NoError(t, err)
+		assert.Equal(t, "output-hash", outputHash)
+
+		output, err = local.GetOutput(ctx, "output-hash")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("output"), output)
+	})
+
+	t.Run("the local hits are checked against the remote cache", func(t *testing.T) {
+		local := dbrunnerservice.NewMemoryCache(1024, dbrunnerservice.DefaultCacheTTL)
+		remote := dbrunnerservice.NewMemoryCache(1024, dbrunnerservice.CacheTTL{Input: 2 * time.Hour, Output: 2 * time.Hour, Sliding: true})
+		cache := dbrunnerservice.NewLayeredCache(local, remote)
+
+		require.NoError(t, cache.SetOutputHash(ctx, "schema-hash", "input-hash", "output-hash"))
+		require.NoError(t, cache.SetOutput(ctx, "output-hash", []byte("output")))
+
+		// the remaining TTL is the one in the remote cache
+		_, _, ttl, err := cache.GetOutputHash(ctx, "input-hash")
+		require.NoError(t, err)
+		assert.Equal(t, 2*time.Hour, ttl)
+
+		ttl, ok, err := cache.HasOutput(ctx, "output-hash")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 2*time.Hour, ttl)
+
+		// invalidated by another replica
+		_, err = remote.InvalidateSchema(ctx, "schema-hash")
+		require.NoError(t, err)
+
+		_, _, _, err = cache.GetOutputHash(ctx, "input-hash")
+		assert.ErrorIs(t, err, dbrunnerservice.ErrNotFound)
+	})
+
+	t.Run("the local copies are used if the remote cache fails", func(t *testing.T) {
+		local := dbrunnerservice.NewMemoryCache(1024, dbrunnerservice.DefaultCacheTTL)
+		require.NoError(t, local.SetOutputHash(ctx, "schema-hash", "input-hash", "output-hash"))
+		require.NoError(t, local.SetOutput(ctx, "output-hash", []byte("output")))
+
+		cache := dbrunnerservice.NewLayeredCache(local, failingCache{})
+
+		outputHash, _, _, err := cache.GetOutputHash(ctx, "input-hash")
+		require.NoError(t, err)
+		assert.Equal(t, "output-hash", outputHash)
+
+		_, ok, err := cache.HasOutput(ctx, "output-hash")
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("the local copies of the remote mappings are invalidated with their schemas", func(t *testing.T) {
+		local := dbrunnerservice.NewMemoryCache(1024, dbrunnerservice.DefaultCacheTTL)
+		remote := dbrunnerservice.NewMemoryCache(1024, dbrunnerservice.DefaultCacheTTL)
+		cache := dbrunnerservice.NewLayeredCache(local, remote)
+
+		require.NoError(t, remote.SetOutputHash(ctx, "schema-hash", "input-hash", "output-hash"))
+
+		_, schemaHash, _, err := cache.GetOutputHash(ctx, "input-hash")
+		require.NoError(t, err)
+		assert.Equal(t, "schema-hash", schemaHash)
+
+		_, localSchemaHash, _, err := local.GetOutputHash(ctx, "input-hash")
+		require.NoError(t, err)
+		assert.Equal(t, "schema-hash", localSchemaHash)
+
+		_, err = cache.InvalidateSchema(ctx, "schema-hash")
+		require.NoError(t, err)
+
+		// the invalidated copy is not used when the remote cache fails
+		_, _, _, err = dbrunnerservice.NewLayeredCache(local, failingCache{}).GetOutputHash(ctx, "input-hash")
+		assert.Error(t, err)
+	})
+
+	t.Run("the remote mappings without the schema hash are not copied", func(t *testing.T) {
+		local := dbrunnerservice.NewMemoryCache(1024, dbrunnerservice.DefaultCacheTTL)
+		remote := dbrunnerservice.NewMemoryCache(1024, dbrunnerservice.DefaultCacheTTL)
+		cache := dbrunnerservice.NewLayeredCache(local, remote)
+
+		require.NoError(t, remote.SetOutputHash(ctx, "", "input-hash", "output-hash"))
+
+		outputHash, _, _, err := cache.GetOutputHash(ctx, "input-hash")
+		require.NoError(t, err)
+		assert.Equal(t, "output-hash", outputHash)
+
+		_, _, _, err = local.GetOutputHash(ctx, "input-hash")
+		assert.ErrorIs(t, err, dbrunnerservice.ErrNotFound)
+	})
+
+	t.Run("the missing entries are not found", func(t *testing.T) {
+		cache := dbrunnerservice.NewLayeredCache(dbrunnerservice.NewMemoryCache(1024, dbrunnerservice.DefaultCacheTTL), dbrunnerservice.NewMemoryCache(1024, dbrunnerservice.DefaultCacheTTL))
+
+		_, _, _, err := cache.GetOutputHash(ctx, "input-hash")
+		assert.ErrorIs(t, err, dbrunnerservice.ErrNotFound)
+
+		_, err = cache.GetOutput(ctx, "output-hash")
+		assert.ErrorIs(t, err, dbrunnerservice.ErrNotFound)
+	})
+
+	t.Run("the entries of the schema are invalidated in both caches", func(t *testing.T) {
+		local := dbrunnerservice.NewMemoryCache(1024, dbrunnerservice.DefaultCacheTTL)
+		remote := dbrunnerservice.NewMemoryCache(1024, dbrunnerservice.DefaultCacheTTL)
+		cache := dbrunnerservice.NewLayeredCache(local, remote)
+
+		require.NoError(t, cache.SetOutputHash(ctx, "schema-hash", "input-hash", "output-hash"))
+		require.NoError(t, cache.SetOutput(ctx, "output-hash", []byte("output")))
+
+		invalidated, err := cache.InvalidateSchema(ctx, "schema-hash")
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, invalidated)
+
+		for _, c := range []dbrunnerservice.Cache{local, remote} {
+			_, _, _, err := c.GetOutputHash(ctx, "input-hash")
+			assert.ErrorIs(t, err, dbrunnerservice.ErrNotFound)
+
+			_, err = c.GetOutput(ctx, "output-hash")
+			assert.NoError(t, err)
+		}
+	})
+}
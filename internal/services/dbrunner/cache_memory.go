@@ -0,0 +1,263 @@
+package dbrunnerservice
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryCache is the [Cache] stored in this process. The least recently
+// used entries are evicted when the total size exceeds its size, even if
+// they are pinned, and the entries expire by its [CacheTTL].
+type MemoryCache struct {
+	ttl CacheTTL
+
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	order    *list.List
+	entries  map[string]*list.Element
+	// schemas indexes the input hashes in entries by their schema hashes.
+	schemas map[string]map[string]struct{}
+
+	// now returns the current time, which is replaced in the tests.
+	now func() time.Time
+}
+
+type memoryCacheEntry struct {
+	key   string
+	value []byte
+	// schemaHash and inputHash are set if it is an input hash mapping.
+	schemaHash string
+	inputHash  string
+	// ttl is the time to live of the entry, which is zero if it is pinned.
+	ttl       time.Duration
+	expiresAt time.Time
+}
+
+func (e *memoryCacheEntry) expired(now time.Time) bool {
+	return e.ttl > 0 && !now.Before(e.expiresAt)
+}
+
+// size is the bytes the entry takes in the cache.
+func (e *memoryCacheEntry) size() int64 {
+	return int64(len(e.key) + len(e.value))
+}
+
+// NewMemoryCache creates a cache which keeps up to maxBytes bytes of
+// the keys and values in total.
+func NewMemoryCache(maxBytes int64, ttl CacheTTL) *MemoryCache {
+	return &MemoryCache{
+		ttl:      ttl,
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		schemas:  make(map[string]map[string]struct{}),
+		now:      time.Now,
+	}
+}
+
+func (c *MemoryCache) GetOutputHash(ctx context.Context, inputHash string) (outputHash string, schemaHash string, ttl time.Duration, err error) {
+	entry, ttl, ok := c.get(inputHashPrefix + inputHash)
+	if !ok {
+		return "", "", 0, ErrNotFound
+	}
+
+	return string(entry.value), entry.schemaHash, ttl, nil
+}
+
+func (c *MemoryCache) SetOutputHash(ctx context.Context, schemaHash string, inputHash string, outputHash string) error {
+	c.set(&memoryCacheEntry{
+		key:        inputHashPrefix + inputHash,
+		value:      []byte(outputHash),
+		ttl:        c.ttl.Input,
+		schemaHash: schemaHash,
+		inputHash:  inputHash,
+	})
+	return nil
+}
+
+func (c *MemoryCache) HasOutput(ctx context.Context, outputHash string) (ttl time.Duration, ok bool, err error) {
+	_, ttl, ok = c.get(outputHashPrefix + outputHash)
+	return ttl, ok, nil
+}
+
+func (c *MemoryCache) GetOutput(ctx context.Context, outputHash string) (output []byte, err error) {
+	entry, _, ok := c.get(outputHashPrefix + outputHash)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return entry.value, nil
+}
+
+func (c *MemoryCache) SetOutput(ctx context.Context, outputHash string, output []byte) error {
+	c.set(&memoryCacheEntry{
+		key:   outputHashPrefix + outputHash,
+		value: output,
+		ttl:   c.ttl.Output,
+	})
+	return nil
+}
+
+func (c *MemoryCache) GetQuery(ctx context.Context, inputHash string) (query string, err error) {
+	entry, _, ok := c.get(queryPrefix + inputHash)
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return string(entry.value), nil
+}
+
+func (c *MemoryCache) SetQuery(ctx context.Context, inputHash string, query string) error {
+	c.set(&memoryCacheEntry{
+		key:   queryPrefix + inputHash,
+		value: []byte(query),
+		ttl:   c.ttl.Input,
+	})
+	return nil
+}
+
+// Pin keeps the entries from expiring. The pinned input hash is kept in
+// the index of its schema, so schemaHash is not used.
+func (c *MemoryCache) Pin(ctx context.Context, schemaHash string, inputHash string, outputHash string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range []string{inputHashPrefix + inputHash, outputHashPrefix + outputHash} {
+		if element, ok := c.entries[key]; ok {
+			entry := element.Value.(*memoryCacheEntry)
+			entry.ttl = 0
+			entry.expiresAt = time.Time{}
+		}
+	}
+
+	return nil
+}
+
+func (c *MemoryCache) Stats(ctx context.Context) (CacheStats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := CacheStats{MemoryBytes: c.bytes}
+	for key := range c.entries {
+		switch {
+		case strings.HasPrefix(key, inputHashPrefix):
+			stats.InputKeys++
+		case strings.HasPrefix(key, outputHashPrefix):
+			stats.OutputKeys++
+		}
+	}
+
+	return stats, nil
+}
+
+func (c *MemoryCache) InvalidateSchema(ctx context.Context, schemaHash string) (invalidated int64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for inputHash := range c.schemas[schemaHash] {
+		element := c.entries[inputHashPrefix+inputHash]
+		entry := element.Value.(*memoryCacheEntry)
+		if !entry.expired(now) {
+			invalidated++
+		}
+
+		if output, ok := c.entries[outputHashPrefix+string(entry.value)]; ok && entry.ttl == 0 {
+			output := output.Value.(*memoryCacheEntry)
+			if output.ttl == 0 {
+				output.ttl = c.ttl.Output
+				output.expiresAt = now.Add(output.ttl)
+			}
+		}
+		c.remove(element)
+	}
+
+	return invalidated, nil
+}
+
+// get returns a copy of the entry and the remaining TTL of the key, and
+// marks it as recently used. The expiration is extended if the expiry is
+// sliding.
+func (c *MemoryCache) get(key string) (entry memoryCacheEntry, ttl time.Duration, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return memoryCacheEntry{}, 0, false
+	}
+
+	stored := element.Value.(*memoryCacheEntry)
+	now := c.now()
+	if stored.expired(now) {
+		c.remove(element)
+		return memoryCacheEntry{}, 0, false
+	}
+
+	c.order.MoveToFront(element)
+
+	if stored.ttl == 0 {
+		return *stored, 0, true
+	}
+	if c.ttl.Sliding {
+		stored.expiresAt = now.Add(stored.ttl)
+	}
+	return *stored, stored.expiresAt.Sub(now), true
+}
+
+// set sets the entry, and evicts the expired entries and then the least
+// recently used entries until the total size fits. The entry larger than
+// the cache is not added.
+func (c *MemoryCache) set(entry *memoryCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[entry.key]; ok {
+		c.remove(element)
+	}
+
+	now := c.now()
+	entry.expiresAt = now.Add(entry.ttl)
+	if entry.size() > c.maxBytes {
+		return
+	}
+
+	// The least recently used entries mostly expire first; the others
+	// are removed when they are read or evicted.
+	for element := c.order.Back(); element != nil && element.Value.(*memoryCacheEntry).expired(now); element = c.order.Back() {
+		c.remove(element)
+	}
+
+	for c.bytes+entry.size() > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+
+	c.entries[entry.key] = c.order.PushFront(entry)
+	c.bytes += entry.size()
+
+	if entry.schemaHash != "" {
+		if c.schemas[entry.schemaHash] == nil {
+			c.schemas[entry.schemaHash] = make(map[string]struct{})
+		}
+		c.schemas[entry.schemaHash][entry.inputHash] = struct{}{}
+	}
+}
+
+func (c *MemoryCache) remove(element *list.Element) {
+	entry := element.Value.(*memoryCacheEntry)
+
+	c.bytes -= entry.size()
+	c.order.Remove(element)
+	delete(c.entries, entry.key)
+
+	if inputHashes, ok := c.schemas[entry.schemaHash]; ok {
+		delete(inputHashes, entry.inputHash)
+		if len(inputHashes) == 0 {
+			delete(c.schemas, entry.schemaHash)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package dbrunnerservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("the missing entries are not found", func(t *testing.T) {
+		cache := NewMemoryCache(1024, DefaultCacheTTL)
+
+		_, _, _, err := cache.GetOutputHash(ctx, "input-hash")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		_, err = cache.GetOutput(ctx, "output-hash")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		_, ok, err := cache.HasOutput(ctx, "output-hash")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("the written entries are retrievable", func(t *testing.T) {
+		cache := NewMemoryCache(1024, DefaultCacheTTL)
+
+		require.NoError(t, cache.SetOutputHash(ctx, "schema-hash", "input-hash", "output-hash"))
+		require.NoError(t, cache.SetOutput(ctx, "output-hash", []byte("output")))
+
+		outputHash, _, ttl, err := cache.GetOutputHash(ctx, "input-hash")
+		require.NoError(t, err)
+		assert.Equal(t, "output-hash", outputHash)
+		assert.Equal(t, DefaultCacheTTL.Input, ttl)
+
+		ttl, ok, err := cache.HasOutput(ctx, "output-hash")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, DefaultCacheTTL.Output, ttl)
+
+		output, err := cache.GetOutput(ctx, "output-hash")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("output"), output)
+	})
+
+	t.Run("the input and output hashes are in different namespaces", func(t *testing.T) {
+		cache := NewMemoryCache(1024, DefaultCacheTTL)
+
+		require.NoError(t, cache.SetOutputHash(ctx, "schema-hash", "hash", "output-hash"))
+
+		_, ok, err := cache.HasOutput(ctx, "hash")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("the kept queries expire with the mappings", func(t *testing.T) {
+		now := time.Now()
+		cache := NewMemoryCache(1024, CacheTTL{Input: time.Minute, Output: time.Hour})
+		cache.now = func() time.Time { return now }
+
+		_, err := cache.GetQuery(ctx, "input-hash")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		require.NoError(t, cache.SetQuery(ctx, "input-hash", "SELECT 1;"))
+
+		query, err := cache.GetQuery(ctx, "input-hash")
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT 1;", query)
+
+		// the queries are not outputs
+		_, ok, err := cache.HasOutput(ctx, "input-hash")
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		now = now.Add(time.Minute)
+		_, err = cache.GetQuery(ctx, "input-hash")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("the least recently used entries are evicted", func(t *testing.T) {
+		entrySize := int64(len(outputHashPrefix+"a") + len("value"))
+		cache := NewMemoryCache(entrySize*2, DefaultCacheTTL)
+
+		require.NoError(t, cache.SetOutput(ctx, "a", []byte("value")))
+		require.NoError(t, cache.SetOutput(ctx, "b", []byte("value")))
+
+		// a is used more recently than b
+		_, err := cache.GetOutput(ctx, "a")
+		require.NoError(t, err)
+
+		require.NoError(t, cache.SetOutput(ctx, "c", []byte("value")))
+
+		_, err = cache.GetOutput(ctx, "b")
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = cache.GetOutput(ctx, "a")
+		assert.NoError(t, err)
+		_, err = cache.GetOutput(ctx, "c")
+		assert.NoError(t, err)
+		assert.Equal(t, entrySize*2, cache.bytes)
+	})
+
+	t.Run("the entries larger than the cache are not added", func(t *testing.T) {
+		cache := NewMemoryCache(8, DefaultCacheTTL)
+
+		require.NoError(t, cache.SetOutput(ctx, "a", []byte("a large value")))
+
+		_, err := cache.GetOutput(ctx, "a")
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Zero(t, cache.bytes)
+	})
+
+	t.Run("the entries expire if they are not accessed", func(t *testing.T) {
+		now := time.Now()
+		cache := NewMemoryCache(1024, DefaultCacheTTL)
+		cache.now = func() time.Time { return now }
+
+		require.NoError(t, cache.SetOutput(ctx, "a", []byte("value")))
+		require.NoError(t, cache.SetOutput(ctx, "b", []byte("value")))
+
+		// accessing a extends its expiration
+		now = now.Add(DefaultCacheTTL.Output / 2)
+		_, err := cache.GetOutput(ctx, "a")
+		require.NoError(t, err)
+
+		now = now.Add(DefaultCacheTTL.Output / 2)
+		_, err = cache.GetOutput(ctx, "b")
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = cache.GetOutput(ctx, "a")
+		assert.NoError(t, err)
+	})
+
+	t.Run("the entries expire at fixed times if the expiry is not sliding", func(t *testing.T) {
+		now := time.Now()
+		cache := NewMemoryCache(1024, CacheTTL{Input: time.Minute, Output: time.Hour})
+		cache.now = func() time.Time { return now }
+
+		require.NoError(t, cache.SetOutputHash(ctx, "schema-hash", "input-hash", "output-hash"))
+		require.NoError(t, cache.SetOutput(ctx, "output-hash", []byte("output")))
+
+		now = now.Add(30 * time.Second)
+		_, _, ttl, err := cache.GetOutputHash(ctx, "input-hash")
+		require.NoError(t, err)
+		assert.Equal(t, 30*time.Second, ttl)
+
+		now = now.Add(30 * time.Second)
+		_, _, _, err = cache.GetOutputHash(ctx, "input-hash")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		ttl, ok, err := cache.HasOutput(ctx, "output-hash")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, time.Hour-time.Minute, ttl)
+	})
+
+	t.Run("the pinned entries do not expire", func(t *testing.T) {
+		now := time.Now()
+		cache := NewMemoryCache(1024, DefaultCacheTTL)
+		cache.now = func() time.Time { return now }
+
+		require.NoError(t, cache.SetOutputHash(ctx, "schema-hash", "input-hash", "output-hash"))
+		require.NoError(t, cache.SetOutput(ctx, "output-hash", []byte("output")))
+		require.NoError(t, cache.Pin(ctx, "schema-hash", "input-hash", "output-hash"))
+
+		now = now.Add(DefaultCacheTTL.Output * 2)
+
+		outputHash, _, ttl, err := cache.GetOutputHash(ctx, "input-hash")
+		require.NoError(t, err)
+		assert.Equal(t, "output-hash", outputHash)
+		assert.Zero(t, ttl)
+
+		ttl, ok, err := cache.HasOutput(ctx, "output-hash")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Zero(t, ttl)
+	})
+
+	t.Run("the stats count the entries", func(t *testing.T) {
+		cache := NewMemoryCache(1024, DefaultCacheTTL)
+
+		require.NoError(t, cache.SetOutputHash(ctx, "schema-hash", "input-hash", "output-hash"))
+		require.NoError(t, cache.SetOutputHash(ctx, "schema-hash", "input-hash-2", "output-hash"))
+		require.NoError(t, cache.SetOutput(ctx, "output-hash", []byte("output")))
+
+		stats, err := cache.Stats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, CacheStats{InputKeys: 2, OutputKeys: 1, MemoryBytes: cache.bytes}, stats)
+	})
+
+	t.Run("the entries of the schema are invalidated", func(t *testing.T) {
+		cache := NewMemoryCache(1024, DefaultCacheTTL)
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+
+		require.NoError(t, cache.SetOutputHash(ctx, "schema-hash", "input-hash", "output-hash"))
+		require.NoError(t, cache.SetOutput(ctx, "output-hash", []byte("output")))
+		require.NoError(t, cache.SetOutputHash(ctx, "schema-hash", "pinned-input-hash", "pinned-output-hash"))
+		require.NoError(t, cache.SetOutput(ctx, "pinned-output-hash", []byte("pinned output")))
+		require.NoError(t, cache.Pin(ctx, "schema-hash", "pinned-input-hash", "pinned-output-hash"))
+		require.NoError(t, cache.SetOutputHash(ctx, "other-schema-hash", "other-input-hash", "other-output-hash"))
+		require.NoError(t, cache.SetOutput(ctx, "other-output-hash", []byte("other output")))
+
+		invalidated, err := cache.InvalidateSchema(ctx, "schema-hash")
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, invalidated)
+
+		for _, inputHash := range []string{"input-hash", "pinned-input-hash"} {
+			_, _, _, err := cache.GetOutputHash(ctx, inputHash)
+			assert.ErrorIs(t, err, ErrNotFound)
+		}
+		// the outputs are kept, and the pinned one expires again
+		_, err = cache.GetOutput(ctx, "output-hash")
+		assert.NoError(t, err)
+		ttl, ok, err := cache.HasOutput(ctx, "pinned-output-hash")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, time.Hour, ttl)
+
+		_, _, _, err = cache.GetOutputHash(ctx, "other-input-hash")
+		assert.NoError(t, err)
+		_, err = cache.GetOutput(ctx, "other-output-hash")
+		assert.NoError(t, err)
+		assert.NotContains(t, cache.schemas, "schema-hash")
+	})
+
+	t.Run("the evicted entries are removed from the index", func(t *testing.T) {
+		entrySize := int64(len(inputHashPrefix+"a") + len("value"))
+		cache := NewMemoryCache(entrySize, DefaultCacheTTL)
+
+		require.NoError(t, cache.SetOutputHash(ctx, "schema-hash", "a", "value"))
+		require.NoError(t, cache.SetOutputHash(ctx, "other-schema-hash", "b", "value"))
+
+		assert.NotContains(t, cache.schemas, "schema-hash")
+		assert.Contains(t, cache.schemas, "other-schema-hash")
+	})
+}
@@ -0,0 +1,327 @@
+package dbrunnerservice
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// dbrunner:sql-input:<input-hash> -> <output-hash> <schema-hash>
+// dbrunner:sql-output:<output-hash> -> <output-marshaled>
+// dbrunner:sql-query:<input-hash> -> <query>
+// dbrunner:sql-schema:<schema-hash> -> {<input-hash>...}
+// dbrunner:sql-schema-pinned:<schema-hash> -> {<input-hash>...}
+
+const (
+	inputHashPrefix         = "dbrunner:sql-input:"
+	outputHashPrefix        = "dbrunner:sql-output:"
+	queryPrefix             = "dbrunner:sql-query:"
+	schemaIndexPrefix       = "dbrunner:sql-schema:"
+	pinnedSchemaIndexPrefix = "dbrunner:sql-schema-pinned:"
+)
+
+// scanCount is the number of the keys to scan at a time for the stats.
+const scanCount = 1000
+
+// getScript returns the value and the remaining TTL in milliseconds of
+// the key, and extends the TTL to ARGV[1] milliseconds unless it is 0.
+// The TTL of the pinned key, which is -1, is not extended.
+const getScript = `local value = redis.call("GET", KEYS[1])
+if not value then
+	return false
+end
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl > 0 and ARGV[1] ~= "0" then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+	ttl = tonumber(ARGV[1])
+end
+return {value, ttl}`
+
+// extendIndexScript adds the input hash ARGV[2] back to the index of
+// the schema KEYS[1], and extends its TTL to ARGV[1] milliseconds unless
+// it is longer, so the mappings kept by reading them can still be
+// invalidated.
+const extendIndexScript = `redis.call("SADD", KEYS[1], ARGV[2])
+if redis.call("PTTL", KEYS[1]) < tonumber(ARGV[1]) then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return 1`
+
+// touchScript is [getScript] without returning the value.
+const touchScript = `local ttl = redis.call("PTTL", KEYS[1])
+if ttl == -2 then
+	return false
+end
+if ttl > 0 and ARGV[1] ~= "0" then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+	ttl = tonumber(ARGV[1])
+end
+return ttl`
+
+// RedisCache is the [Cache] stored in Redis, which is shared
+// by all the replicas.
+type RedisCache struct {
+	redis *redis.Client
+	ttl   CacheTTL
+}
+
+func NewRedisCache(redis *redis.Client, ttl CacheTTL) *RedisCache {
+	return &RedisCache{
+		redis: redis,
+		ttl:   ttl,
+	}
+}
+
+func (c *RedisCache) GetOutputHash(ctx context.Context, inputHash string) (outputHash string, schemaHash string, ttl time.Duration, err error) {
+	value, ttl, err := c.get(ctx, inputHashPrefix+inputHash, c.ttl.Input)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	// The index is extended with another script, since the keys of a
+	// script must be declared and the index key is only known now.
+	outputHash, schemaHash = splitInputValue(value)
+	if extension := c.extension(c.ttl.Input); ttl > 0 && extension > 0 && schemaHash != "" {
+		if err := c.redis.Eval(ctx, extendIndexScript, []string{schemaIndexPrefix + schemaHash}, extension, inputHash).Err(); err != nil {
+			return "", "", 0, fmt.Errorf("extend schema index: %w", err)
+		}
+	}
+
+	return outputHash, schemaHash, ttl, nil
+}
+
+// SetOutputHash maps the input hash to the output hash, and adds it to
+// the index of the schema. The index expires with the mappings written
+// or read last.
+//
+// The schema hash is stored with the output hash, so the index can be
+// extended when the mapping is read.
+func (c *RedisCache) SetOutputHash(ctx context.Context, schemaHash string, inputHash string, outputHash string) error {
+	_, err := c.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SetEx(ctx, inputHashPrefix+inputHash, outputHash+" "+schemaHash, c.ttl.Input)
+		pipe.SAdd(ctx, schemaIndexPrefix+schemaHash, inputHash)
+		pipe.Expire(ctx, schemaIndexPrefix+schemaHash, c.ttl.Input)
+		return nil
+	})
+	return err
+}
+
+func (c *RedisCache) HasOutput(ctx context.Context, outputHash string) (ttl time.Duration, ok bool, err error) {
+	result, err := c.redis.Eval(ctx, touchScript, []string{outputHashPrefix + outputHash}, c.extension(c.ttl.Output)).Int64()
+	if err == redis.Nil {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	return ttlFromMilliseconds(result), true, nil
+}
+
+func (c *RedisCache) GetOutput(ctx context.Context, outputHash string) (output []byte, err error) {
+	value, _, err := c.get(ctx, outputHashPrefix+outputHash, c.ttl.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(value), nil
+}
+
+func (c *RedisCache) SetOutput(ctx context.Context, outputHash string, output []byte) error {
+	return c.redis.SetEx(ctx, outputHashPrefix+outputHash, string(output), c.ttl.Output).Err()
+}
+
+func (c *RedisCache) GetQuery(ctx context.Context, inputHash string) (query string, err error) {
+	query, _, err = c.get(ctx, queryPrefix+inputHash, c.ttl.Input)
+	return query, err
+}
+
+func (c *RedisCache) SetQuery(ctx context.Context, inputHash string, query string) error {
+	return c.redis.SetEx(ctx, queryPrefix+inputHash, query, c.ttl.Input).Err()
+}
+
+// Pin persists the mapping and the output, and adds the input hash to
+// the index of the pinned inputs of the schema, which never expires.
+func (c *RedisCache) Pin(ctx context.Context, schemaHash string, inputHash string, outputHash string) error {
+	_, err := c.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Persist(ctx, inputHashPrefix+inputHash)
+		pipe.Persist(ctx, outputHashPrefix+outputHash)
+		pipe.SAdd(ctx, pinnedSchemaIndexPrefix+schemaHash, inputHash)
+		return nil
+	})
+	return err
+}
+
+// Stats counts the keys with SCAN, which takes a while if there are
+// many keys, and reads the memory used by the server from INFO.
+func (c *RedisCache) Stats(ctx context.Context) (CacheStats, error) {
+	inputKeys, err := c.countKeys(ctx, inputHashPrefix)
+	if err != nil {
+		return CacheStats{}, fmt.Errorf("count input keys: %w", err)
+	}
+
+	outputKeys, err := c.countKeys(ctx, outputHashPrefix)
+	if err != nil {
+		return CacheStats{}, fmt.Errorf("count output keys: %w", err)
+	}
+
+	info, err := c.redis.Info(ctx, "memory").Result()
+	if err != nil {
+		return CacheStats{}, fmt.Errorf("get memory info: %w", err)
+	}
+
+	memoryBytes, err := infoField(info, "used_memory")
+	if err != nil {
+		return CacheStats{}, err
+	}
+
+	return CacheStats{
+		InputKeys:   inputKeys,
+		OutputKeys:  outputKeys,
+		MemoryBytes: memoryBytes,
+	}, nil
+}
+
+// InvalidateSchema removes the mappings in the indexes of the schema.
+// The outputs of the pinned mappings are unpinned and expire by their
+// TTL.
+func (c *RedisCache) InvalidateSchema(ctx context.Context, schemaHash string) (invalidated int64, err error) {
+	indexKeys := []string{schemaIndexPrefix + schemaHash, pinnedSchemaIndexPrefix + schemaHash}
+
+	inputHashes, err := c.redis.SUnion(ctx, indexKeys...).Result()
+	if err != nil {
+		return 0, err
+	}
+	pinnedInputHashes, err := c.redis.SMembers(ctx, pinnedSchemaIndexPrefix+schemaHash).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	keys := indexKeys
+	var pinnedOutputKeys []string
+	if len(inputHashes) > 0 {
+		inputKeys := make([]string, len(inputHashes))
+		for i, inputHash := range inputHashes {
+			inputKeys[i] = inputHashPrefix + inputHash
+		}
+
+		values, err := c.redis.MGet(ctx, inputKeys...).Result()
+		if err != nil {
+			return 0, err
+		}
+
+		// The expired mappings are still in the index.
+		for i, value := range values {
+			value, ok := value.(string)
+			if !ok {
+				continue
+			}
+
+			outputHash, _ := splitInputValue(value)
+			keys = append(keys, inputKeys[i])
+			invalidated++
+			if slices.Contains(pinnedInputHashes, inputHashes[i]) {
+				pinnedOutputKeys = append(pinnedOutputKeys, outputHashPrefix+outputHash)
+			}
+		}
+	}
+
+	_, err = c.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, keys...)
+		for _, key := range pinnedOutputKeys {
+			pipe.Expire(ctx, key, c.ttl.Output)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return invalidated, nil
+}
+
+// countKeys counts the keys with the prefix.
+func (c *RedisCache) countKeys(ctx context.Context, prefix string) (int64, error) {
+	var count int64
+
+	iter := c.redis.Scan(ctx, 0, prefix+"*", scanCount).Iterator()
+	for iter.Next(ctx) {
+		count++
+	}
+
+	return count, iter.Err()
+}
+
+// get returns the value and the remaining TTL of the key with [getScript].
+func (c *RedisCache) get(ctx context.Context, key string, ttl time.Duration) (value string, remaining time.Duration, err error) {
+	return c.eval(ctx, getScript, key, c.extension(ttl))
+}
+
+// eval runs the script returning the value and the remaining TTL of the key.
+func (c *RedisCache) eval(ctx context.Context, script string, key string, args ...any) (value string, remaining time.Duration, err error) {
+	result, err := c.redis.Eval(ctx, script, []string{key}, args...).Slice()
+	if err == redis.Nil {
+		return "", 0, ErrNotFound
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	if len(result) != 2 {
+		return "", 0, fmt.Errorf("unexpected result of script: %v", result)
+	}
+
+	value, _ = result[0].(string)
+	milliseconds, _ := result[1].(int64)
+	return value, ttlFromMilliseconds(milliseconds), nil
+}
+
+// splitInputValue returns the output hash and the schema hash of the
+// value of an input hash mapping. The mappings written before the schema
+// hash is stored with the output hash only have the output hash.
+func splitInputValue(value string) (outputHash string, schemaHash string) {
+	outputHash, schemaHash, _ = strings.Cut(value, " ")
+	return outputHash, schemaHash
+}
+
+// extension returns the milliseconds to extend the TTL of the entries
+// to when they are read, which is 0 if the expiry is not sliding.
+func (c *RedisCache) extension(ttl time.Duration) int64 {
+	if !c.ttl.Sliding {
+		return 0
+	}
+
+	return ttl.Milliseconds()
+}
+
+// ttlFromMilliseconds converts the TTL returned by PTTL,
+// which is negative for the pinned keys.
+func ttlFromMilliseconds(milliseconds int64) time.Duration {
+	if milliseconds < 0 {
+		return 0
+	}
+
+	return time.Duration(milliseconds) * time.Millisecond
+}
+
+// infoField returns the integer field of the result of INFO.
+func infoField(info string, field string) (int64, error) {
+	for _, line := range strings.Split(info, "\n") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(line), field+":")
+		if !ok {
+			continue
+		}
+
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s in INFO: %w", field, err)
+		}
+		return n, nil
+	}
+
+	return 0, fmt.Errorf("missing %s in INFO", field)
+}
@@ -2,29 +2,43 @@ package dbrunnerservice
 
 import (
 	"context"
-	"encoding/json"
+	"expvar"
 	"time"
 
 	"github.com/database-playground/backend/internal/dbrunner"
-	"github.com/redis/go-redis/v9"
 )
 
-// dbrunner:sql-input:<input-hash> -> <output-hash>
-// dbrunner:sql-output:<output-hash> -> <output-marshaled-json>
+// <input-hash> -> <output-hash>
+// <output-hash> -> <output-marshaled> (see [marshalOutput])
 // <output-hash#1> == <output-hash#2> means the output is the same.
+// <input-hash> -> <query> (only if it is kept, see [CacheModule.KeepQuery])
+
+// cacheMetrics are the metrics of the cache lookups of the queries,
+// published as "dbrunner_cache" in expvar.
+var cacheMetrics = struct {
+	// Hits is the number of the queries whose results are cached.
+	Hits *expvar.Int
+	// Misses is the number of the queries run since their results are
+	// not cached.
+	Misses *expvar.Int
+}{
+	Hits:   new(expvar.Int),
+	Misses: new(expvar.Int),
+}
 
-const (
-	inputHashPrefix  = "dbrunner:sql-input:"
-	outputHashPrefix = "dbrunner:sql-output:"
-)
+func init() {
+	metrics := expvar.NewMap("dbrunner_cache")
+	metrics.Set("hits", cacheMetrics.Hits)
+	metrics.Set("misses", cacheMetrics.Misses)
+}
 
 type CacheModule struct {
-	redis *redis.Client
+	cache Cache
 }
 
-func NewCacheModule(redis *redis.Client) *CacheModule {
+func NewCacheModule(cache Cache) *CacheModule {
 	return &CacheModule{
-		redis: redis,
+		cache: cache,
 	}
 }
 
@@ -32,22 +46,81 @@ func NewCacheModule(redis *redis.Client) *CacheModule {
 //
 // The input hash is better to be [dbrunner.Input.Normalize]d.
 func (c *CacheModule) GetOutputHash(ctx context.Context, inputHash string) (outputHash string, err error) {
-	key := inputHashPrefix + inputHash
+	outputHash, _, _, err = c.cache.GetOutputHash(ctx, inputHash)
+	return outputHash, err
+}
 
-	result := c.redis.GetEx(ctx, key, time.Hour*1)
-	if result.Err() == redis.Nil {
-		return "", ErrNotFound
+// Lookup returns whether the output of the input hash is cached, its
+// output hash, and the remaining time to live of the result, which is
+// the earlier expiration of the mapping and the output. It is zero if
+// both are pinned.
+func (c *CacheModule) Lookup(ctx context.Context, inputHash string) (outputHash string, ttl time.Duration, ok bool) {
+	outputHash, _, inputTTL, err := c.cache.GetOutputHash(ctx, inputHash)
+	if err != nil {
+		return "", 0, false
 	}
-	if result.Err() != nil {
-		return "", result.Err()
+
+	outputTTL, ok, err := c.cache.HasOutput(ctx, outputHash)
+	if err != nil || !ok {
+		return "", 0, false
+	}
+
+	switch {
+	case inputTTL == 0:
+		return outputHash, outputTTL, true
+	case outputTTL == 0:
+		return outputHash, inputTTL, true
+	default:
+		return outputHash, min(inputTTL, outputTTL), true
 	}
+}
 
-	outputHash, err = result.Result()
+// GetInput returns the output hash of the input hash and the remaining
+// time to live of the mapping, which is zero if it is pinned.
+func (c *CacheModule) GetInput(ctx context.Context, inputHash string) (outputHash string, ttl time.Duration, err error) {
+	outputHash, _, ttl, err = c.cache.GetOutputHash(ctx, inputHash)
+	return outputHash, ttl, err
+}
+
+// KeepQuery keeps the query of the input hash in the cache, so it can be
+// read back by [CacheModule.GetQuery] until it expires with the mapping.
+func (c *CacheModule) KeepQuery(ctx context.Context, inputHash string, query string) error {
+	return c.cache.SetQuery(ctx, inputHash, query)
+}
+
+// GetQuery returns the query kept for the input hash.
+func (c *CacheModule) GetQuery(ctx context.Context, inputHash string) (query string, err error) {
+	return c.cache.GetQuery(ctx, inputHash)
+}
+
+// Pin keeps the mapping of the input and its output in the cache until
+// they are overridden or invalidated, for example, for the reference
+// answers.
+func (c *CacheModule) Pin(ctx context.Context, input dbrunner.Input) error {
+	normalizedInput, err := input.Normalize()
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	return outputHash, nil
+	inputHash := normalizedInput.Hash()
+	outputHash, _, _, err := c.cache.GetOutputHash(ctx, inputHash)
+	if err != nil {
+		return err
+	}
+
+	return c.cache.Pin(ctx, normalizedInput.SchemaHash(), inputHash, outputHash)
+}
+
+// Stats returns the statistics of the cache.
+func (c *CacheModule) Stats(ctx context.Context) (CacheStats, error) {
+	return c.cache.Stats(ctx)
+}
+
+// InvalidateSchema removes the cached results of the queries on the
+// schema of the hash ([dbrunner.Input.SchemaHash]), including the
+// pinned ones. It returns the number of the inputs invalidated.
+func (c *CacheModule) InvalidateSchema(ctx context.Context, schemaHash string) (invalidated int64, err error) {
+	return c.cache.InvalidateSchema(ctx, schemaHash)
 }
 
 // HasOutput returns whether the output hash is existed.
@@ -55,33 +128,44 @@ func (c *CacheModule) GetOutputHash(ctx context.Context, inputHash string) (outp
 // It does not unmarshal the output, therefore it is much faster than
 // [cacheModule.getOutput] if you don't need the output.
 func (c *CacheModule) HasOutput(ctx context.Context, outputHash string) bool {
-	key := outputHashPrefix + outputHash
-
-	result := c.redis.GetEx(ctx, key, time.Hour*1)
-	return result.Err() == nil
+	_, ok, err := c.cache.HasOutput(ctx, outputHash)
+	return err == nil && ok
 }
 
 // GetOutput returns the output of the output hash.
 //
 // It is better to use [cacheModule.HasOutput] if you don't need the output.
 func (c *CacheModule) GetOutput(ctx context.Context, outputHash string) (output *dbrunner.Output, err error) {
-	key := outputHashPrefix + outputHash
+	outputMarshaled, err := c.cache.GetOutput(ctx, outputHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalOutput(outputMarshaled)
+}
 
-	result := c.redis.GetEx(ctx, key, time.Hour*1)
-	if result.Err() != nil {
-		if result.Err() == redis.Nil {
-			return nil, ErrNotFound
-		}
+// InspectOutput returns the output of the output hash with its size in
+// the cache and its remaining time to live, which is zero if it is pinned.
+func (c *CacheModule) InspectOutput(ctx context.Context, outputHash string) (output *dbrunner.Output, size int, ttl time.Duration, err error) {
+	ttl, ok, err := c.cache.HasOutput(ctx, outputHash)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if !ok {
+		return nil, 0, 0, ErrNotFound
+	}
 
-		return output, result.Err()
+	outputMarshaled, err := c.cache.GetOutput(ctx, outputHash)
+	if err != nil {
+		return nil, 0, 0, err
 	}
 
-	err = json.Unmarshal([]byte(result.Val()), &output)
+	output, err = unmarshalOutput(outputMarshaled)
 	if err != nil {
-		return nil, err
+		return nil, 0, 0, err
 	}
 
-	return output, nil
+	return output, len(outputMarshaled), ttl, nil
 }
 
 // writeOutput writes (overrides) the output to the cache.
@@ -91,13 +175,12 @@ func (c *CacheModule) writeOutput(ctx context.Context, output dbrunner.Output) (
 		return "", err
 	}
 
-	key := outputHashPrefix + hash
-	outputMarshaled, err := json.Marshal(output)
+	outputMarshaled, err := marshalOutput(output)
 	if err != nil {
 		return "", err
 	}
 
-	err = c.redis.SetEx(ctx, key, string(outputMarshaled), time.Hour*1).Err()
+	err = c.cache.SetOutput(ctx, hash, outputMarshaled)
 	if err != nil {
 		return "", err
 	}
@@ -127,9 +210,8 @@ func (c *CacheModule) writeInput(ctx context.Context, input dbrunner.Input, outp
 	}
 
 	hash = normalizedInput.Hash()
-	key := inputHashPrefix + hash
 
-	err = c.redis.SetEx(ctx, key, outputHash, time.Hour*1).Err()
+	err = c.cache.SetOutputHash(ctx, normalizedInput.SchemaHash(), hash, outputHash)
 	if err != nil {
 		return "", err
 	}
@@ -3,6 +3,7 @@ package dbrunnerservice_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 
@@ -15,6 +16,41 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// hourMilliseconds is the default TTL in milliseconds.
+const hourMilliseconds = int64(time.Hour / time.Millisecond)
+
+// expectGet expects reading the key with the default TTL.
+func expectGet(mock redismock.ClientMock, key string) *redismock.ExpectedCmd {
+	return mock.ExpectEval(dbrunnerservice.GetScript, []string{key}, hourMilliseconds)
+}
+
+// expectGetInput expects reading the mapping of the input hash with
+// the default TTL.
+func expectGetInput(mock redismock.ClientMock, inputHash string) *redismock.ExpectedCmd {
+	return expectGet(mock, "dbrunner:sql-input:"+inputHash)
+}
+
+// expectExtendIndex expects extending the index of the schema to the
+// default TTL after reading the mapping of the input hash.
+func expectExtendIndex(mock redismock.ClientMock, schemaHash string, inputHash string) *redismock.ExpectedCmd {
+	return mock.ExpectEval(dbrunnerservice.ExtendIndexScript, []string{"dbrunner:sql-schema:" + schemaHash}, hourMilliseconds, inputHash)
+}
+
+// expectTouch expects checking the key with the default TTL.
+func expectTouch(mock redismock.ClientMock, key string) *redismock.ExpectedCmd {
+	return mock.ExpectEval(dbrunnerservice.TouchScript, []string{key}, hourMilliseconds)
+}
+
+// expectSetOutputHash expects mapping the input hash of the schema
+// with the default TTL.
+func expectSetOutputHash(mock redismock.ClientMock, schemaHash string, inputHash string, outputHash string) {
+	mock.ExpectTxPipeline()
+	mock.ExpectSetEx("dbrunner:sql-input:"+inputHash, outputHash+" "+schemaHash, time.Hour).SetVal("OK")
+	mock.ExpectSAdd("dbrunner:sql-schema:"+schemaHash, inputHash).SetVal(1)
+	mock.ExpectExpire("dbrunner:sql-schema:"+schemaHash, time.Hour).SetVal(true)
+	mock.ExpectTxPipelineExec()
+}
+
 func TestCacheModule_GetOutputHash(t *testing.T) {
 	t.Parallel()
 
@@ -22,8 +58,8 @@ func TestCacheModule_GetOutputHash(t *testing.T) {
 		t.Parallel()
 
 		client, mock := redismock.NewClientMock()
-		cm := dbrunnerservice.NewCacheModule(client)
-		mock.ExpectGetEx("dbrunner:sql-input:input-hash", 1*time.Hour).SetErr(redis.Nil)
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
+		expectGetInput(mock, "input-hash").SetErr(redis.Nil)
 
 		_, err := cm.GetOutputHash(context.TODO(), "input-hash")
 
@@ -34,13 +70,43 @@ func TestCacheModule_GetOutputHash(t *testing.T) {
 		t.Parallel()
 
 		client, mock := redismock.NewClientMock()
-		cm := dbrunnerservice.NewCacheModule(client)
-		mock.ExpectGetEx("dbrunner:sql-input:input-hash", 1*time.Hour).SetVal("output-hash")
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
+		expectGetInput(mock, "input-hash").SetVal([]any{"output-hash schema-hash", hourMilliseconds})
+		expectExtendIndex(mock, "schema-hash", "input-hash").SetVal(int64(1))
 
 		outputHash, err := cm.GetOutputHash(context.TODO(), "input-hash")
 
 		assert.NoError(t, err)
 		assert.Equal(t, "output-hash", outputHash)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("the index of the schema is not extended with the pinned mapping", func(t *testing.T) {
+		t.Parallel()
+
+		client, mock := redismock.NewClientMock()
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
+		expectGetInput(mock, "input-hash").SetVal([]any{"output-hash schema-hash", int64(-1)})
+
+		outputHash, err := cm.GetOutputHash(context.TODO(), "input-hash")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "output-hash", outputHash)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("if the index of the schema cannot be extended, returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		client, mock := redismock.NewClientMock()
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
+		expectGetInput(mock, "input-hash").SetVal([]any{"output-hash schema-hash", hourMilliseconds})
+		expectExtendIndex(mock, "schema-hash", "input-hash").SetErr(errors.New("unavailable"))
+
+		_, err := cm.GetOutputHash(context.TODO(), "input-hash")
+
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 }
 
@@ -51,8 +117,8 @@ func TestCacheModule_HasOutput(t *testing.T) {
 		t.Parallel()
 
 		client, mock := redismock.NewClientMock()
-		cm := dbrunnerservice.NewCacheModule(client)
-		mock.ExpectGetEx("dbrunner:sql-output:output-hash", 1*time.Hour).SetErr(redis.Nil)
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
+		expectTouch(mock, "dbrunner:sql-output:output-hash").SetErr(redis.Nil)
 
 		hasOutput := cm.HasOutput(context.TODO(), "output-hash")
 
@@ -63,8 +129,8 @@ func TestCacheModule_HasOutput(t *testing.T) {
 		t.Parallel()
 
 		client, mock := redismock.NewClientMock()
-		cm := dbrunnerservice.NewCacheModule(client)
-		mock.ExpectGetEx("dbrunner:sql-output:output-hash", 1*time.Hour).SetVal("output")
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
+		expectTouch(mock, "dbrunner:sql-output:output-hash").SetVal(hourMilliseconds)
 
 		hasOutput := cm.HasOutput(context.TODO(), "output-hash")
 
@@ -80,14 +146,14 @@ func TestCacheModule_GetOutput(t *testing.T) {
 
 		expected := dbrunner.Output{
 			Header: []string{"column", "column2"},
-			Data: [][]*string{
-				{nil, lo.ToPtr("Hello!")},
+			Data: [][]dbrunner.Cell{
+				{dbrunner.NewCell(nil), dbrunner.NewCell("Hello!")},
 			},
 		}
 
 		client, mock := redismock.NewClientMock()
-		cm := dbrunnerservice.NewCacheModule(client)
-		mock.ExpectGetEx("dbrunner:sql-output:output-hash", 1*time.Hour).SetVal(string(lo.Must(json.Marshal(expected))))
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
+		expectGet(mock, "dbrunner:sql-output:output-hash").SetVal([]any{string(lo.Must(json.Marshal(expected))), hourMilliseconds})
 
 		actual, err := cm.GetOutput(context.TODO(), "output-hash")
 		require.NoError(t, err)
@@ -102,8 +168,8 @@ func TestCacheModule_GetOutput(t *testing.T) {
 		t.Parallel()
 
 		client, mock := redismock.NewClientMock()
-		cm := dbrunnerservice.NewCacheModule(client)
-		mock.ExpectGetEx("dbrunner:sql-output:output-hash", 1*time.Hour).SetErr(redis.Nil)
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
+		expectGet(mock, "dbrunner:sql-output:output-hash").SetErr(redis.Nil)
 
 		_, err := cm.GetOutput(context.TODO(), "output-hash")
 
@@ -111,6 +177,38 @@ func TestCacheModule_GetOutput(t *testing.T) {
 	})
 }
 
+func TestCacheModule_KeepQuery(t *testing.T) {
+	t.Parallel()
+
+	t.Run("the kept query is retrievable", func(t *testing.T) {
+		t.Parallel()
+
+		client, mock := redismock.NewClientMock()
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
+		mock.ExpectSetEx("dbrunner:sql-query:input-hash", "SELECT 1;", time.Hour).SetVal("OK")
+		expectGet(mock, "dbrunner:sql-query:input-hash").SetVal([]any{"SELECT 1;", hourMilliseconds})
+
+		require.NoError(t, cm.KeepQuery(context.TODO(), "input-hash", "SELECT 1;"))
+
+		query, err := cm.GetQuery(context.TODO(), "input-hash")
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT 1;", query)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("if the query is not kept, returns not found", func(t *testing.T) {
+		t.Parallel()
+
+		client, mock := redismock.NewClientMock()
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
+		expectGet(mock, "dbrunner:sql-query:input-hash").SetErr(redis.Nil)
+
+		_, err := cm.GetQuery(context.TODO(), "input-hash")
+
+		assert.ErrorIs(t, err, dbrunnerservice.ErrNotFound)
+	})
+}
+
 func TestWriteToCache(t *testing.T) {
 	mockInput, _ := dbrunner.Input{
 		Init:  "CREATE TABLE test (id INTEGER PRIMARY KEY, name TEXT); INSERT INTO test (name) VALUES ('Hello!');",
@@ -118,9 +216,10 @@ func TestWriteToCache(t *testing.T) {
 	}.Normalize()
 	mockOutput := dbrunner.Output{
 		Header: []string{"id", "name"},
-		Data:   [][]*string{{lo.ToPtr("1"), lo.ToPtr("Hello!")}},
+		Data:   [][]dbrunner.Cell{{dbrunner.NewCell("1"), dbrunner.NewCell("Hello!")}},
 	}
 
+	mockSchemaHash := mockInput.SchemaHash()
 	mockInputHash := mockInput.Hash()
 	mockOutputHash, err := mockOutput.Hash()
 	require.NoError(t, err)
@@ -129,13 +228,13 @@ func TestWriteToCache(t *testing.T) {
 		t.Parallel()
 
 		client, mock := redismock.NewClientMock()
-		cm := dbrunnerservice.NewCacheModule(client)
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
 
 		mock.MatchExpectationsInOrder(false)
-		mock.ExpectGetEx("dbrunner:sql-input:"+mockInputHash, 1*time.Hour).SetErr(redis.Nil)
-		mock.ExpectGetEx("dbrunner:sql-output:"+mockOutputHash, 1*time.Hour).SetErr(redis.Nil)
-		mock.ExpectSetEx("dbrunner:sql-input:"+mockInputHash, mockOutputHash, 1*time.Hour).SetVal("OK")
-		mock.ExpectSetEx("dbrunner:sql-output:"+mockOutputHash, string(lo.Must(json.Marshal(mockOutput))), 1*time.Hour).SetVal("OK")
+		expectGetInput(mock, mockInputHash).SetErr(redis.Nil)
+		expectTouch(mock, "dbrunner:sql-output:"+mockOutputHash).SetErr(redis.Nil)
+		expectSetOutputHash(mock, mockSchemaHash, mockInputHash, mockOutputHash)
+		mock.ExpectSetEx("dbrunner:sql-output:"+mockOutputHash, string(lo.Must(dbrunnerservice.MarshalOutput(mockOutput))), 1*time.Hour).SetVal("OK")
 
 		hash, err := cm.WriteToCache(context.TODO(), mockInput, mockOutput)
 		require.NoError(t, err)
@@ -146,13 +245,13 @@ func TestWriteToCache(t *testing.T) {
 		t.Parallel()
 
 		client, mock := redismock.NewClientMock()
-		cm := dbrunnerservice.NewCacheModule(client)
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
 
 		mock.MatchExpectationsInOrder(false)
-		mock.ExpectGetEx("dbrunner:sql-input:"+mockInputHash, 1*time.Hour).SetVal(mockOutputHash)
-		mock.ExpectGetEx("dbrunner:sql-output:"+mockOutputHash, 1*time.Hour).SetErr(redis.Nil)
-		mock.ExpectSetEx("dbrunner:sql-input:"+mockInputHash, mockOutputHash, 1*time.Hour).SetVal("OK")
-		mock.ExpectSetEx("dbrunner:sql-output:"+mockOutputHash, string(lo.Must(json.Marshal(mockOutput))), 1*time.Hour).SetVal("OK")
+		expectGetInput(mock, mockInputHash).SetVal([]any{mockOutputHash, hourMilliseconds})
+		expectTouch(mock, "dbrunner:sql-output:"+mockOutputHash).SetErr(redis.Nil)
+		expectSetOutputHash(mock, mockSchemaHash, mockInputHash, mockOutputHash)
+		mock.ExpectSetEx("dbrunner:sql-output:"+mockOutputHash, string(lo.Must(dbrunnerservice.MarshalOutput(mockOutput))), 1*time.Hour).SetVal("OK")
 
 		hash, err := cm.WriteToCache(context.TODO(), mockInput, mockOutput)
 		require.NoError(t, err)
@@ -164,12 +263,12 @@ func TestWriteToCache(t *testing.T) {
 		t.Parallel()
 
 		client, mock := redismock.NewClientMock()
-		cm := dbrunnerservice.NewCacheModule(client)
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
 
 		mock.MatchExpectationsInOrder(false)
-		mock.ExpectGetEx("dbrunner:sql-input:"+mockInputHash, 1*time.Hour).SetVal(mockOutputHash)
-		mock.ExpectGetEx("dbrunner:sql-output:"+mockOutputHash, 1*time.Hour).SetVal(string(lo.Must(json.Marshal(mockOutput))))
-		mock.ExpectSetEx("dbrunner:sql-input:"+mockInputHash, mockOutputHash, 1*time.Hour).SetVal("OK")
+		expectGetInput(mock, mockInputHash).SetVal([]any{mockOutputHash, hourMilliseconds})
+		expectTouch(mock, "dbrunner:sql-output:"+mockOutputHash).SetVal(hourMilliseconds)
+		expectSetOutputHash(mock, mockSchemaHash, mockInputHash, mockOutputHash)
 
 		hash, err := cm.WriteToCache(context.TODO(), mockInput, mockOutput)
 		require.NoError(t, err)
@@ -180,12 +279,12 @@ func TestWriteToCache(t *testing.T) {
 		t.Parallel()
 
 		client, mock := redismock.NewClientMock()
-		cm := dbrunnerservice.NewCacheModule(client)
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
 
 		mock.MatchExpectationsInOrder(false)
-		mock.ExpectGetEx("dbrunner:sql-input:"+mockInputHash, 1*time.Hour).SetErr(redis.Nil)
-		mock.ExpectGetEx("dbrunner:sql-output:"+mockOutputHash, 1*time.Hour).SetVal(string(lo.Must(json.Marshal(mockOutput))))
-		mock.ExpectSetEx("dbrunner:sql-input:"+mockInputHash, mockOutputHash, 1*time.Hour).SetVal("OK")
+		expectGetInput(mock, mockInputHash).SetErr(redis.Nil)
+		expectTouch(mock, "dbrunner:sql-output:"+mockOutputHash).SetVal(hourMilliseconds)
+		expectSetOutputHash(mock, mockSchemaHash, mockInputHash, mockOutputHash)
 
 		hash, err := cm.WriteToCache(context.TODO(), mockInput, mockOutput)
 		require.NoError(t, err)
@@ -196,14 +295,14 @@ func TestWriteToCache(t *testing.T) {
 		t.Parallel()
 
 		client, mock := redismock.NewClientMock()
-		cm := dbrunnerservice.NewCacheModule(client)
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
 
 		mock.MatchExpectationsInOrder(true)
-		mock.ExpectGetEx("dbrunner:sql-output:"+mockOutputHash, 1*time.Hour).SetErr(redis.Nil)
-		mock.ExpectSetEx("dbrunner:sql-output:"+mockOutputHash, string(lo.Must(json.Marshal(mockOutput))), 1*time.Hour).SetVal("OK")
-		mock.ExpectSetEx("dbrunner:sql-input:"+mockInputHash, mockOutputHash, 1*time.Hour).SetVal("OK")
-		mock.ExpectGetEx("dbrunner:sql-input:"+mockInputHash, 1*time.Hour).SetVal(mockOutputHash)
-		mock.ExpectGetEx("dbrunner:sql-output:"+mockOutputHash, 1*time.Hour).SetVal(string(lo.Must(json.Marshal(mockOutput))))
+		expectTouch(mock, "dbrunner:sql-output:"+mockOutputHash).SetErr(redis.Nil)
+		mock.ExpectSetEx("dbrunner:sql-output:"+mockOutputHash, string(lo.Must(dbrunnerservice.MarshalOutput(mockOutput))), 1*time.Hour).SetVal("OK")
+		expectSetOutputHash(mock, mockSchemaHash, mockInputHash, mockOutputHash)
+		expectGetInput(mock, mockInputHash).SetVal([]any{mockOutputHash, hourMilliseconds})
+		expectGet(mock, "dbrunner:sql-output:"+mockOutputHash).SetVal([]any{string(lo.Must(dbrunnerservice.MarshalOutput(mockOutput))), hourMilliseconds})
 
 		hash, err := cm.WriteToCache(context.TODO(), mockInput, mockOutput)
 		require.NoError(t, err)
@@ -222,3 +321,144 @@ func TestWriteToCache(t *testing.T) {
 		assert.JSONEq(t, string(expectedJSON), string(actualJSON))
 	})
 }
+
+func TestCacheModule_Lookup(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns the earlier expiration of the input and the output", func(t *testing.T) {
+		t.Parallel()
+
+		client, mock := redismock.NewClientMock()
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
+		expectGetInput(mock, "input-hash").SetVal([]any{"output-hash", hourMilliseconds})
+		expectTouch(mock, "dbrunner:sql-output:output-hash").SetVal(int64(60000))
+
+		outputHash, ttl, ok := cm.Lookup(context.TODO(), "input-hash")
+
+		assert.True(t, ok)
+		assert.Equal(t, "output-hash", outputHash)
+		assert.Equal(t, time.Minute, ttl)
+	})
+
+	t.Run("the pinned output does not expire", func(t *testing.T) {
+		t.Parallel()
+
+		client, mock := redismock.NewClientMock()
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
+		expectGetInput(mock, "input-hash").SetVal([]any{"output-hash", int64(-1)})
+		expectTouch(mock, "dbrunner:sql-output:output-hash").SetVal(int64(-1))
+
+		outputHash, ttl, ok := cm.Lookup(context.TODO(), "input-hash")
+
+		assert.True(t, ok)
+		assert.Equal(t, "output-hash", outputHash)
+		assert.Zero(t, ttl)
+	})
+
+	t.Run("if the output is missing, returns false", func(t *testing.T) {
+		t.Parallel()
+
+		client, mock := redismock.NewClientMock()
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
+		expectGetInput(mock, "input-hash").SetVal([]any{"output-hash", hourMilliseconds})
+		expectTouch(mock, "dbrunner:sql-output:output-hash").SetErr(redis.Nil)
+
+		_, _, ok := cm.Lookup(context.TODO(), "input-hash")
+
+		assert.False(t, ok)
+	})
+
+	t.Run("the TTL is not extended if the expiry is not sliding", func(t *testing.T) {
+		t.Parallel()
+
+		client, mock := redismock.NewClientMock()
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.CacheTTL{Input: time.Hour, Output: time.Hour}))
+		mock.ExpectEval(dbrunnerservice.GetScript, []string{"dbrunner:sql-input:input-hash"}, int64(0)).SetVal([]any{"output-hash schema-hash", int64(60000)})
+		mock.ExpectEval(dbrunnerservice.TouchScript, []string{"dbrunner:sql-output:output-hash"}, int64(0)).SetVal(int64(120000))
+
+		outputHash, ttl, ok := cm.Lookup(context.TODO(), "input-hash")
+
+		assert.True(t, ok)
+		assert.Equal(t, "output-hash", outputHash)
+		assert.Equal(t, time.Minute, ttl)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCacheModule_Pin(t *testing.T) {
+	t.Parallel()
+
+	input := dbrunner.Input{Init: "CREATE TABLE a (id INT);", Query: "SELECT * FROM a;"}
+	normalizedInput := lo.Must(input.Normalize())
+	inputHash := normalizedInput.Hash()
+
+	client, mock := redismock.NewClientMock()
+	cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
+	expectGetInput(mock, inputHash).SetVal([]any{"output-hash", hourMilliseconds})
+	mock.ExpectTxPipeline()
+	mock.ExpectPersist("dbrunner:sql-input:" + inputHash).SetVal(true)
+	mock.ExpectPersist("dbrunner:sql-output:output-hash").SetVal(true)
+	mock.ExpectSAdd("dbrunner:sql-schema-pinned:"+input.SchemaHash(), inputHash).SetVal(1)
+	mock.ExpectTxPipelineExec()
+
+	require.NoError(t, cm.Pin(context.TODO(), input))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCacheModule_Stats(t *testing.T) {
+	t.Parallel()
+
+	client, mock := redismock.NewClientMock()
+	cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
+	mock.ExpectScan(0, "dbrunner:sql-input:*", 1000).SetVal([]string{"dbrunner:sql-input:a", "dbrunner:sql-input:b"}, 0)
+	mock.ExpectScan(0, "dbrunner:sql-output:*", 1000).SetVal([]string{"dbrunner:sql-output:a"}, 0)
+	mock.ExpectInfo("memory").SetVal("# Memory\r\nused_memory:1048576\r\nused_memory_human:1.00M\r\n")
+
+	stats, err := cm.Stats(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, dbrunnerservice.CacheStats{InputKeys: 2, OutputKeys: 1, MemoryBytes: 1048576}, stats)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCacheModule_InvalidateSchema(t *testing.T) {
+	t.Parallel()
+
+	t.Run("removes the mappings of the schema and unpins their outputs", func(t *testing.T) {
+		t.Parallel()
+
+		client, mock := redismock.NewClientMock()
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
+		mock.ExpectSUnion("dbrunner:sql-schema:schema-hash", "dbrunner:sql-schema-pinned:schema-hash").SetVal([]string{"a", "pinned", "expired"})
+		mock.ExpectSMembers("dbrunner:sql-schema-pinned:schema-hash").SetVal([]string{"pinned"})
+		mock.ExpectMGet("dbrunner:sql-input:a", "dbrunner:sql-input:pinned", "dbrunner:sql-input:expired").SetVal([]any{"output-hash schema-hash", "pinned-output-hash", nil})
+		mock.ExpectTxPipeline()
+		mock.ExpectDel(
+			"dbrunner:sql-schema:schema-hash", "dbrunner:sql-schema-pinned:schema-hash",
+			"dbrunner:sql-input:a", "dbrunner:sql-input:pinned",
+		).SetVal(4)
+		mock.ExpectExpire("dbrunner:sql-output:pinned-output-hash", time.Hour).SetVal(true)
+		mock.ExpectTxPipelineExec()
+
+		invalidated, err := cm.InvalidateSchema(context.TODO(), "schema-hash")
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, invalidated)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("if there are no mappings of the schema, removes nothing", func(t *testing.T) {
+		t.Parallel()
+
+		client, mock := redismock.NewClientMock()
+		cm := dbrunnerservice.NewCacheModule(dbrunnerservice.NewRedisCache(client, dbrunnerservice.DefaultCacheTTL))
+		mock.ExpectSUnion("dbrunner:sql-schema:schema-hash", "dbrunner:sql-schema-pinned:schema-hash").SetVal([]string{})
+		mock.ExpectSMembers("dbrunner:sql-schema-pinned:schema-hash").SetVal([]string{})
+		mock.ExpectTxPipeline()
+		mock.ExpectDel("dbrunner:sql-schema:schema-hash", "dbrunner:sql-schema-pinned:schema-hash").SetVal(0)
+		mock.ExpectTxPipelineExec()
+
+		invalidated, err := cm.InvalidateSchema(context.TODO(), "schema-hash")
+		require.NoError(t, err)
+		assert.Zero(t, invalidated)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 
 	"connectrpc.com/connect"
+	commonv1 "github.com/database-playground/backend/gen/common/v1"
 	dbrunnerv1 "github.com/database-playground/backend/gen/dbrunner/v1"
+	"github.com/database-playground/backend/internal/dbrunner"
 )
 
 func (s *Service) AreQueriesOutputSame(ctx context.Context, request *connect.Request[dbrunnerv1.AreQueriesOutputSameRequest]) (*connect.Response[dbrunnerv1.AreQueriesOutputSameResponse], error) {
@@ -35,9 +37,70 @@ func (s *Service) AreQueriesOutputSame(ctx context.Context, request *connect.Req
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
+	options := compareOptionsFromProto(request.Msg.GetOptions())
+
+	// The outputs with the same hash are always the same, and without the
+	// options, the outputs with different hashes are never the same, so
+	// the outputs are only compared cell by cell if the options need it.
+	if leftOutputHash == rightOutputHash || options.IsZero() {
+		return &connect.Response[dbrunnerv1.AreQueriesOutputSameResponse]{
+			Msg: &dbrunnerv1.AreQueriesOutputSameResponse{
+				Same: leftOutputHash == rightOutputHash,
+			},
+		}, nil
+	}
+
+	leftOutput, err := s.getOutput(ctx, leftOutputHash, "left_id")
+	if err != nil {
+		return nil, err
+	}
+
+	rightOutput, err := s.getOutput(ctx, rightOutputHash, "right_id")
+	if err != nil {
+		return nil, err
+	}
+
 	return &connect.Response[dbrunnerv1.AreQueriesOutputSameResponse]{
 		Msg: &dbrunnerv1.AreQueriesOutputSameResponse{
-			Same: leftOutputHash == rightOutputHash,
+			Same: dbrunner.Equal(*leftOutput, *rightOutput, options),
 		},
 	}, nil
 }
+
+// getOutput retrieves the output of the output hash from the cache.
+//
+// The returned error is a [connect.Error].
+func (s *Service) getOutput(ctx context.Context, outputHash string, field string) (*dbrunner.Output, error) {
+	output, err := s.cacheModule.GetOutput(ctx, outputHash)
+	if errors.Is(err, ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New(field+" output expired – re-query again!"))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	return output, nil
+}
+
+// compareOptionsFromProto converts the protobuf compare options.
+// nil means comparing exactly.
+func compareOptionsFromProto(options *commonv1.CompareOptions) dbrunner.CompareOptions {
+	return dbrunner.CompareOptions{
+		IgnoreRowOrder:      options.GetIgnoreRowOrder(),
+		IgnoreColumnOrder:   options.GetIgnoreColumnOrder(),
+		IgnoreColumnNames:   options.GetIgnoreColumnNames(),
+		IgnoreDuplicateRows: options.GetIgnoreDuplicateRows(),
+		FloatEpsilon:        options.GetFloatEpsilon(),
+		DecimalPlaces:       decimalPlacesFromProto(options),
+		CompareState:        options.GetCompareState(),
+	}
+}
+
+func decimalPlacesFromProto(options *commonv1.CompareOptions) *int {
+	if options == nil || options.DecimalPlaces == nil {
+		return nil
+	}
+
+	places := int(options.GetDecimalPlaces())
+	return &places
+}
@@ -1,21 +1,166 @@
 package dbrunnerservice
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
 	"github.com/database-playground/backend/gen/dbrunner/v1/dbrunnerv1connect"
+	"github.com/database-playground/backend/internal/dbrunner"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/fx"
 )
 
-var FxModule = fx.Module("dbrunner-service", fx.Provide(New))
+var FxModule = fx.Module("dbrunner-service", fx.Provide(New, NewAdminService, NewCache))
+
+// defaultSnapshotCacheSize is the default total size of the schema snapshots.
+const defaultSnapshotCacheSize = 64 << 20 // 64 MiB
+
+// defaultMaxQueue is the default number of the queries waiting to run.
+const defaultMaxQueue = 100
+
+// queryRunner runs the queries, in this process or in the workers.
+type queryRunner interface {
+	RunQuery(ctx context.Context, input dbrunner.Input) (dbrunner.Output, error)
+}
 
 type Service struct {
 	cacheModule *CacheModule
+	executor    *executor
 
 	dbrunnerv1connect.UnimplementedDbRunnerServiceHandler
 }
 
-func New(redis *redis.Client) *Service {
-	return &Service{
-		cacheModule: NewCacheModule(redis),
+// New creates the service.
+//
+// The queries run in a pool of DB_RUNNER_WORKERS worker processes if it
+// is set; otherwise, they run in this process.
+//
+// At most DB_RUNNER_MAX_CONCURRENCY queries run at the same time, which
+// defaults to the number of the workers or the CPUs, and at most
+// DB_RUNNER_MAX_QUEUE queries wait for them.
+//
+// The concurrent executions of the same query are deduplicated. If
+// DB_RUNNER_DISTRIBUTED_LOCK is true, they are deduplicated across the
+// replicas sharing the Redis as well, which is only useful if the
+// cache is shared, that is, DB_RUNNER_CACHE is "redis" or "layered".
+//
+// redis is nil if Redis is not configured.
+func New(lc fx.Lifecycle, cache Cache, redis *redis.Client) (*Service, error) {
+	snapshotCacheSize := int64(defaultSnapshotCacheSize)
+	if sizeStr := os.Getenv("DB_RUNNER_SNAPSHOT_CACHE_SIZE"); sizeStr != "" {
+		var err error
+		snapshotCacheSize, err = strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DB_RUNNER_SNAPSHOT_CACHE_SIZE: %w", err)
+		}
+	}
+
+	workers := 0
+	if workersStr := os.Getenv("DB_RUNNER_WORKERS"); workersStr != "" {
+		var err error
+		workers, err = strconv.Atoi(workersStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DB_RUNNER_WORKERS: %w", err)
+		}
+	}
+
+	concurrency := workers
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	if concurrencyStr := os.Getenv("DB_RUNNER_MAX_CONCURRENCY"); concurrencyStr != "" {
+		var err error
+		concurrency, err = strconv.Atoi(concurrencyStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DB_RUNNER_MAX_CONCURRENCY: %w", err)
+		}
+		if concurrency <= 0 {
+			return nil, errors.New("invalid DB_RUNNER_MAX_CONCURRENCY: must be positive")
+		}
+	}
+
+	maxQueue := defaultMaxQueue
+	if maxQueueStr := os.Getenv("DB_RUNNER_MAX_QUEUE"); maxQueueStr != "" {
+		var err error
+		maxQueue, err = strconv.Atoi(maxQueueStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DB_RUNNER_MAX_QUEUE: %w", err)
+		}
+		if maxQueue < 0 {
+			return nil, errors.New("invalid DB_RUNNER_MAX_QUEUE: must not be negative")
+		}
+	}
+
+	distributedLock := false
+	if distributedLockStr := os.Getenv("DB_RUNNER_DISTRIBUTED_LOCK"); distributedLockStr != "" {
+		var err error
+		distributedLock, err = strconv.ParseBool(distributedLockStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DB_RUNNER_DISTRIBUTED_LOCK: %w", err)
+		}
+	}
+
+	cacheModule := NewCacheModule(cache)
+	executor := &executor{
+		cacheModule: cacheModule,
+		limiter:     newQueryLimiter(concurrency, maxQueue),
 	}
+	if distributedLock {
+		if redis == nil {
+			return nil, errors.New("missing REDIS_ADDR, which DB_RUNNER_DISTRIBUTED_LOCK requires")
+		}
+		executor.lock = redis
+	}
+
+	if workers <= 0 {
+		executor.runner = dbrunner.NewRunner(snapshotCacheSize)
+
+		return &Service{
+			cacheModule: cacheModule,
+			executor:    executor,
+		}, nil
+	}
+
+	config := dbrunner.WorkerPoolConfig{
+		Size:               workers,
+		SnapshotCacheBytes: snapshotCacheSize,
+	}
+
+	var err error
+	config.Path, err = os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("get executable: %w", err)
+	}
+
+	if memoryLimitStr := os.Getenv("DB_RUNNER_WORKER_MEMORY_LIMIT"); memoryLimitStr != "" {
+		config.MemoryLimit, err = strconv.ParseInt(memoryLimitStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DB_RUNNER_WORKER_MEMORY_LIMIT: %w", err)
+		}
+	}
+
+	if cpuLimitStr := os.Getenv("DB_RUNNER_WORKER_CPU_LIMIT"); cpuLimitStr != "" {
+		config.CPULimit, err = time.ParseDuration(cpuLimitStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DB_RUNNER_WORKER_CPU_LIMIT: %w", err)
+		}
+	}
+
+	pool, err := dbrunner.NewWorkerPool(config)
+	if err != nil {
+		return nil, fmt.Errorf("create worker pool: %w", err)
+	}
+
+	lc.Append(fx.StopHook(pool.Close))
+	executor.runner = pool
+
+	return &Service{
+		cacheModule: cacheModule,
+		executor:    executor,
+	}, nil
 }
@@ -0,0 +1,251 @@
+package dbrunnerservice
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/database-playground/backend/internal/dbrunner"
+	"github.com/redis/go-redis/v9"
+)
+
+// executionLockPrefix is the prefix of the lock of an execution:
+// dbrunner:execution-lock:<input-hash> -> <token>
+const executionLockPrefix = "dbrunner:execution-lock:"
+
+// executionLockGracePeriod is how long the lock outlives the timeout
+// of the query, for the time waiting in the queue and writing the cache.
+const executionLockGracePeriod = 10 * time.Second
+
+// executionPollInterval is how often the replica waiting for the lock
+// checks whether the result has been cached.
+const executionPollInterval = 100 * time.Millisecond
+
+// unlockScript deletes the lock only if it is still held by the token,
+// so an expired lock taken by another replica is not deleted.
+const unlockScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`
+
+// dedupMetrics are the metrics of the deduplication, published
+// as "dbrunner_dedup" in expvar.
+var dedupMetrics = struct {
+	// Shared is the number of the requests sharing the execution
+	// of another request in this replica.
+	Shared *expvar.Int
+	// Waited is the number of the executions which wait for and reuse
+	// the result of another replica.
+	Waited *expvar.Int
+}{
+	Shared: new(expvar.Int),
+	Waited: new(expvar.Int),
+}
+
+func init() {
+	metrics := expvar.NewMap("dbrunner_dedup")
+	metrics.Set("shared", dedupMetrics.Shared)
+	metrics.Set("waited", dedupMetrics.Waited)
+}
+
+// executor executes the queries and caches their outputs.
+//
+// The concurrent executions of the same input are deduplicated: only
+// one of them runs, and the others share its result. If lock is set,
+// the executions are coordinated across the replicas with a lock in
+// Redis as well.
+type executor struct {
+	cacheModule *CacheModule
+	runner      queryRunner
+	limiter     *queryLimiter
+
+	mu sync.Mutex
+	// executions are the running executions by their input hashes.
+	executions map[string]*execution
+	// lock is the Redis client for the distributed lock.
+	// nil means the executions are only deduplicated in this replica.
+	lock *redis.Client
+}
+
+// execution is an execution shared by the requests of the same input.
+type execution struct {
+	// done is closed when id and err are set.
+	done chan struct{}
+	id   string
+	err  error
+
+	// waiters is the number of the requests waiting for the execution,
+	// guarded by the mutex of the executor.
+	waiters int
+	// cancel cancels the execution when all the requests stop waiting.
+	cancel context.CancelFunc
+}
+
+// execute runs the normalized input and writes the output to the cache.
+// It returns the input hash, which is the ID of the cached output.
+//
+// The execution is shared with the other requests of the same input,
+// so it is not canceled when ctx is done, but when all the requests
+// sharing it stop waiting. The canceled execution leaves the queue,
+// so the abandoned requests do not keep the slots of the others.
+func (e *executor) execute(ctx context.Context, normalizedInput dbrunner.Input) (id string, err error) {
+	inputHash := normalizedInput.Hash()
+
+	e.mu.Lock()
+	current, ok := e.executions[inputHash]
+	if ok {
+		dedupMetrics.Shared.Add(1)
+	} else {
+		executionCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		current = &execution{done: make(chan struct{}), cancel: cancel}
+
+		if e.executions == nil {
+			e.executions = make(map[string]*execution)
+		}
+		e.executions[inputHash] = current
+
+		go func() {
+			defer cancel()
+
+			id, err := e.executeOnce(executionCtx, normalizedInput, inputHash)
+
+			e.mu.Lock()
+			if e.executions[inputHash] == current {
+				delete(e.executions, inputHash)
+			}
+			e.mu.Unlock()
+
+			current.id, current.err = id, err
+			close(current.done)
+		}()
+	}
+	current.waiters++
+	e.mu.Unlock()
+
+	select {
+	case <-current.done:
+		if current.err != nil {
+			return "", current.err
+		}
+		return current.id, nil
+	case <-ctx.Done():
+		e.leave(inputHash, current)
+		return "", ctx.Err()
+	}
+}
+
+// leave stops waiting for the execution, and cancels it if no request
+// waits for it anymore. The next request of the same input starts
+// a new execution instead of joining the canceled one.
+func (e *executor) leave(inputHash string, current *execution) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	current.waiters--
+	if current.waiters > 0 {
+		return
+	}
+
+	current.cancel()
+	if e.executions[inputHash] == current {
+		delete(e.executions, inputHash)
+	}
+}
+
+// executeOnce runs the input unless another replica is running it.
+func (e *executor) executeOnce(ctx context.Context, normalizedInput dbrunner.Input, inputHash string) (string, error) {
+	if e.lock != nil {
+		// The lock is only an optimization, so the query runs
+		// anyway if Redis fails.
+		unlock, acquired, err := e.acquireLock(ctx, inputHash, normalizedInput.Limits)
+		switch {
+		case err != nil:
+		case acquired:
+			defer unlock()
+		default:
+			if id, ok := e.waitForOutput(ctx, inputHash); ok {
+				dedupMetrics.Waited.Add(1)
+				return id, nil
+			}
+		}
+	}
+
+	release, err := e.limiter.acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	output, err := e.runner.RunQuery(ctx, normalizedInput)
+	release()
+	if err != nil {
+		return "", err
+	}
+
+	return e.cacheModule.WriteToCache(ctx, normalizedInput, output)
+}
+
+// acquireLock tries to acquire the lock of the input hash.
+// acquired is false if another replica holds the lock.
+func (e *executor) acquireLock(ctx context.Context, inputHash string, limits dbrunner.Limits) (unlock func(), acquired bool, err error) {
+	tokenBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return nil, false, err
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	timeout := limits.Timeout
+	if timeout <= 0 {
+		timeout = dbrunner.DefaultTimeout
+	}
+
+	key := executionLockPrefix + inputHash
+	acquired, err = e.lock.SetNX(ctx, key, token, timeout+executionLockGracePeriod).Result()
+	if err != nil || !acquired {
+		return nil, false, err
+	}
+
+	return func() {
+		_ = e.lock.Eval(ctx, unlockScript, []string{key}, token).Err()
+	}, true, nil
+}
+
+// waitForOutput waits for the replica holding the lock to cache the
+// output of the input hash. ok is false if the lock is released or
+// expired without the output, for example, when the query fails.
+func (e *executor) waitForOutput(ctx context.Context, inputHash string) (id string, ok bool) {
+	ticker := time.NewTicker(executionPollInterval)
+	defer ticker.Stop()
+
+	key := executionLockPrefix + inputHash
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return "", false
+		}
+
+		// The lock is checked before the output, since the holder
+		// caches the output before releasing the lock.
+		held, err := e.lock.Exists(ctx, key).Result()
+		if err != nil {
+			return "", false
+		}
+
+		outputHash, err := e.cacheModule.GetOutputHash(ctx, inputHash)
+		if err == nil && e.cacheModule.HasOutput(ctx, outputHash) {
+			return inputHash, true
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return "", false
+		}
+
+		if held == 0 {
+			return "", false
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package dbrunnerservice
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/database-playground/backend/internal/dbrunner"
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingRunner runs the queries until unblock is closed.
+type blockingRunner struct {
+	calls   atomic.Int32
+	unblock chan struct{}
+	output  dbrunner.Output
+}
+
+func (r *blockingRunner) RunQuery(ctx context.Context, input dbrunner.Input) (dbrunner.Output, error) {
+	r.calls.Add(1)
+	<-r.unblock
+	return r.output, nil
+}
+
+func TestExecutor(t *testing.T) {
+	input := lo.Must(dbrunner.Input{
+		Init:  "CREATE TABLE a (id INT);",
+		Query: "SELECT * FROM a;",
+	}.Normalize())
+	output := dbrunner.Output{
+		Header: []string{"id"},
+		Data:   [][]dbrunner.Cell{{dbrunner.NewCell("1")}},
+	}
+
+	schemaHash := input.SchemaHash()
+	inputHash := input.Hash()
+	outputHash, err := output.Hash()
+	require.NoError(t, err)
+
+	t.Run("the concurrent identical executions run once", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		mock.MatchExpectationsInOrder(true)
+		mock.ExpectEval(touchScript, []string{outputHashPrefix + outputHash}, int64(time.Hour/time.Millisecond)).SetErr(redis.Nil)
+		mock.ExpectSetEx(outputHashPrefix+outputHash, string(lo.Must(marshalOutput(output))), 1*time.Hour).SetVal("OK")
+		mock.ExpectTxPipeline()
+		mock.ExpectSetEx(inputHashPrefix+inputHash, outputHash+" "+schemaHash, 1*time.Hour).SetVal("OK")
+		mock.ExpectSAdd(schemaIndexPrefix+schemaHash, inputHash).SetVal(1)
+		mock.ExpectExpire(schemaIndexPrefix+schemaHash, 1*time.Hour).SetVal(true)
+		mock.ExpectTxPipelineExec()
+
+		runner := &blockingRunner{unblock: make(chan struct{}), output: output}
+		e := &executor{
+			cacheModule: NewCacheModule(NewRedisCache(client, DefaultCacheTTL)),
+			runner:      runner,
+			limiter:     newQueryLimiter(4, 0),
+		}
+
+		const n = 8
+
+		var started, finished sync.WaitGroup
+		ids := make([]string, n)
+		errs := make([]error, n)
+
+		started.Add(n)
+		finished.Add(n)
+		for i := range n {
+			go func() {
+				defer finished.Done()
+
+				started.Done()
+				ids[i], errs[i] = e.execute(context.Background(), input)
+			}()
+		}
+
+		started.Wait()
+		require.Eventually(t, func() bool {
+			return runner.calls.Load() == 1
+		}, time.Second, time.Millisecond)
+		// let the rest of the executions join the running one
+		time.Sleep(50 * time.Millisecond)
+
+		close(runner.unblock)
+		finished.Wait()
+
+		assert.EqualValues(t, 1, runner.calls.Load())
+		for i := range n {
+			require.NoError(t, errs[i])
+			assert.Equal(t, inputHash, ids[i])
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("a waiting execution does not stop the others", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		mock.MatchExpectationsInOrder(true)
+		mock.ExpectEval(touchScript, []string{outputHashPrefix + outputHash}, int64(time.Hour/time.Millisecond)).SetErr(redis.Nil)
+		mock.ExpectSetEx(outputHashPrefix+outputHash, string(lo.Must(marshalOutput(output))), 1*time.Hour).SetVal("OK")
+		mock.ExpectTxPipeline()
+		mock.ExpectSetEx(inputHashPrefix+inputHash, outputHash+" "+schemaHash, 1*time.Hour).SetVal("OK")
+		mock.ExpectSAdd(schemaIndexPrefix+schemaHash, inputHash).SetVal(1)
+		mock.ExpectExpire(schemaIndexPrefix+schemaHash, 1*time.Hour).SetVal(true)
+		mock.ExpectTxPipelineExec()
+
+		runner := &blockingRunner{unblock: make(chan struct{}), output: output}
+		e := &executor{
+			cacheModule: NewCacheModule(NewRedisCache(client, DefaultCacheTTL)),
+			runner:      runner,
+			limiter:     newQueryLimiter(1, 0),
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		canceled := make(chan error)
+		go func() {
+			_, err := e.execute(ctx, input)
+			canceled <- err
+		}()
+
+		require.Eventually(t, func() bool {
+			return runner.calls.Load() == 1
+		}, time.Second, time.Millisecond)
+
+		waiting := make(chan string)
+		go func() {
+			id, _ := e.execute(context.Background(), input)
+			waiting <- id
+		}()
+		// let the execution join the running one
+		time.Sleep(50 * time.Millisecond)
+
+		cancel()
+		assert.ErrorIs(t, <-canceled, context.Canceled)
+
+		close(runner.unblock)
+		assert.Equal(t, inputHash, <-waiting)
+		assert.EqualValues(t, 1, runner.calls.Load())
+	})
+
+	t.Run("the abandoned execution leaves the queue", func(t *testing.T) {
+		client, _ := redismock.NewClientMock()
+
+		runner := &blockingRunner{unblock: make(chan struct{}), output: output}
+		defer close(runner.unblock)
+		e := &executor{
+			cacheModule: NewCacheModule(NewRedisCache(client, DefaultCacheTTL)),
+			runner:      runner,
+			limiter:     newQueryLimiter(1, 1),
+		}
+
+		// the running execution takes the only slot
+		go func() { _, _ = e.execute(context.Background(), input) }()
+		require.Eventually(t, func() bool {
+			return runner.calls.Load() == 1
+		}, time.Second, time.Millisecond)
+
+		queued := lo.Must(dbrunner.Input{Init: input.Init, Query: "SELECT 1;"}.Normalize())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		canceled := make(chan error)
+		go func() {
+			_, err := e.execute(ctx, queued)
+			canceled <- err
+		}()
+		require.Eventually(t, func() bool {
+			return len(e.limiter.admitted) == 2
+		}, time.Second, time.Millisecond)
+
+		cancel()
+		assert.ErrorIs(t, <-canceled, context.Canceled)
+		assert.Eventually(t, func() bool {
+			return len(e.limiter.admitted) == 1
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("the execution locked by another replica reuses its output", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		mock.MatchExpectationsInOrder(true)
+		mock.Regexp().ExpectSetNX(regexp.QuoteMeta(executionLockPrefix+inputHash), `^[0-9a-f]{32}$`, dbrunner.DefaultTimeout+executionLockGracePeriod).SetVal(false)
+		// the output is not ready at the first poll
+		mock.ExpectExists(executionLockPrefix + inputHash).SetVal(1)
+		mock.ExpectEval(getScript, []string{inputHashPrefix + inputHash}, int64(time.Hour/time.Millisecond)).SetErr(redis.Nil)
+		mock.ExpectExists(executionLockPrefix + inputHash).SetVal(0)
+		mock.ExpectEval(getScript, []string{inputHashPrefix + inputHash}, int64(time.Hour/time.Millisecond)).SetVal([]any{outputHash + " " + schemaHash, int64(time.Hour / time.Millisecond)})
+		mock.ExpectEval(extendIndexScript, []string{schemaIndexPrefix + schemaHash}, int64(time.Hour/time.Millisecond), inputHash).SetVal(int64(1))
+		mock.ExpectEval(touchScript, []string{outputHashPrefix + outputHash}, int64(time.Hour/time.Millisecond)).SetVal(int64(time.Hour / time.Millisecond))
+
+		runner := &blockingRunner{unblock: make(chan struct{}), output: output}
+		close(runner.unblock)
+		e := &executor{
+			cacheModule: NewCacheModule(NewRedisCache(client, DefaultCacheTTL)),
+			runner:      runner,
+			limiter:     newQueryLimiter(1, 0),
+			lock:        client,
+		}
+
+		id, err := e.execute(context.Background(), input)
+		require.NoError(t, err)
+		assert.Equal(t, inputHash, id)
+		assert.Zero(t, runner.calls.Load())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("the execution runs if the lock is released without the output", func(t *testing.T) {
+		client, mock := redismock.NewClientMock()
+		mock.MatchExpectationsInOrder(true)
+		mock.Regexp().ExpectSetNX(regexp.QuoteMeta(executionLockPrefix+inputHash), `^[0-9a-f]{32}$`, dbrunner.DefaultTimeout+executionLockGracePeriod).SetVal(false)
+		mock.ExpectExists(executionLockPrefix + inputHash).SetVal(0)
+		mock.ExpectEval(getScript, []string{inputHashPrefix + inputHash}, int64(time.Hour/time.Millisecond)).SetErr(redis.Nil)
+		mock.ExpectEval(touchScript, []string{outputHashPrefix + outputHash}, int64(time.Hour/time.Millisecond)).SetErr(redis.Nil)
+		mock.ExpectSetEx(outputHashPrefix+outputHash, string(lo.Must(marshalOutput(output))), 1*time.Hour).SetVal("OK")
+		mock.ExpectTxPipeline()
+		mock.ExpectSetEx(inputHashPrefix+inputHash, outputHash+" "+schemaHash, 1*time.Hour).SetVal("OK")
+		mock.ExpectSAdd(schemaIndexPrefix+schemaHash, inputHash).SetVal(1)
+		mock.ExpectExpire(schemaIndexPrefix+schemaHash, 1*time.Hour).SetVal(true)
+		mock.ExpectTxPipelineExec()
+
+		runner := &blockingRunner{unblock: make(chan struct{}), output: output}
+		close(runner.unblock)
+		e := &executor{
+			cacheModule: NewCacheModule(NewRedisCache(client, DefaultCacheTTL)),
+			runner:      runner,
+			limiter:     newQueryLimiter(1, 0),
+			lock:        client,
+		}
+
+		id, err := e.execute(context.Background(), input)
+		require.NoError(t, err)
+		assert.Equal(t, inputHash, id)
+		assert.EqualValues(t, 1, runner.calls.Load())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
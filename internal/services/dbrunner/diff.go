@@ -0,0 +1,142 @@
+package dbrunnerservice
+
+import (
+	"context"
+	"errors"
+
+	"connectrpc.com/connect"
+	dbrunnerv1 "github.com/database-playground/backend/gen/dbrunner/v1"
+	"github.com/database-playground/backend/internal/dbrunner"
+)
+
+// maxDiffRows is the maximum number of the missing, extra and changed
+// rows reported by DiffQuery. The rest are summarized as truncated.
+const maxDiffRows = 1000
+
+func (s *Service) DiffQuery(ctx context.Context, request *connect.Request[dbrunnerv1.DiffQueryRequest], stream *connect.ServerStream[dbrunnerv1.DiffQueryResponse]) error {
+	if request.Msg.GetLeftId() == "" {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("left_id is required"))
+	}
+	if request.Msg.GetRightId() == "" {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("right_id is required"))
+	}
+
+	left, err := s.getOutputByID(ctx, request.Msg.GetLeftId(), "left_id")
+	if err != nil {
+		return err
+	}
+
+	right, err := s.getOutputByID(ctx, request.Msg.GetRightId(), "right_id")
+	if err != nil {
+		return err
+	}
+
+	// Diff the canonical forms so the differences ignored by the options are not reported.
+	options := compareOptionsFromProto(request.Msg.GetOptions())
+
+	var table string
+	var diff dbrunner.OutputDiff
+	if options.CompareState {
+		table, diff = dbrunner.DiffState(*left, *right, options, maxDiffRows)
+	} else {
+		diff = dbrunner.Diff(left.Canonicalize(options), right.Canonicalize(options), maxDiffRows)
+	}
+
+	// Only the requested number of the missing and extra rows are sent.
+	missingRows, extraRows := diff.MissingRows, diff.ExtraRows
+	if request.Msg.MaxRows != nil {
+		maxRows := int(max(request.Msg.GetMaxRows(), 0))
+		missingRows = missingRows[:min(len(missingRows), maxRows)]
+		extraRows = extraRows[:min(len(extraRows), maxRows)]
+	}
+	truncated := diff.Truncated || len(missingRows) < len(diff.MissingRows) || len(extraRows) < len(diff.ExtraRows)
+
+	// Send summary as the first packet
+	if err := stream.Send(&dbrunnerv1.DiffQueryResponse{
+		Kind: &dbrunnerv1.DiffQueryResponse_Summary{
+			Summary: &dbrunnerv1.DiffSummary{
+				Same:           diff.Same(),
+				LeftRowCount:   int64(diff.LeftRowCount),
+				RightRowCount:  int64(diff.RightRowCount),
+				OrderMismatch:  diff.OrderMismatch,
+				HeaderMismatch: diff.HeaderMismatch,
+				Truncated:      truncated,
+			},
+		},
+	}); err != nil {
+		return err
+	}
+
+	// Send header as the second packet
+	if err := stream.Send(&dbrunnerv1.DiffQueryResponse{
+		Kind: &dbrunnerv1.DiffQueryResponse_Header{
+			Header: &dbrunnerv1.HeaderDiff{
+				Left:  diff.LeftHeader,
+				Right: diff.RightHeader,
+				Table: table,
+			},
+		},
+	}); err != nil {
+		return err
+	}
+
+	for _, row := range missingRows {
+		if err := stream.Send(&dbrunnerv1.DiffQueryResponse{
+			Kind: &dbrunnerv1.DiffQueryResponse_MissingRow{
+				MissingRow: &dbrunnerv1.RowDiff{
+					Index: int64(row.Index),
+					Cells: cellsToProto(row.Cells),
+				},
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range extraRows {
+		if err := stream.Send(&dbrunnerv1.DiffQueryResponse{
+			Kind: &dbrunnerv1.DiffQueryResponse_ExtraRow{
+				ExtraRow: &dbrunnerv1.RowDiff{
+					Index: int64(row.Index),
+					Cells: cellsToProto(row.Cells),
+				},
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
+	for _, cell := range diff.CellChanges {
+		if err := stream.Send(&dbrunnerv1.DiffQueryResponse{
+			Kind: &dbrunnerv1.DiffQueryResponse_Cell{
+				Cell: &dbrunnerv1.CellDiff{
+					LeftRow:  int64(cell.LeftRow),
+					RightRow: int64(cell.RightRow),
+					Column:   int64(cell.Column),
+					Left:     cellToProto(cell.Left),
+					Right:    cellToProto(cell.Right),
+				},
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getOutputByID retrieves the output of the query ID from the cache.
+//
+// The returned error is a [connect.Error]. The field is used in the
+// error message to indicate which ID is expired.
+func (s *Service) getOutputByID(ctx context.Context, id string, field string) (*dbrunner.Output, error) {
+	outputHash, err := s.cacheModule.GetOutputHash(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New(field+" expired – re-query again!"))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	return s.getOutput(ctx, outputHash, field)
+}
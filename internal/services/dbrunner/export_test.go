@@ -0,0 +1,9 @@
+package dbrunnerservice
+
+var MarshalOutput = marshalOutput
+
+const (
+	GetScript         = getScript
+	ExtendIndexScript = extendIndexScript
+	TouchScript       = touchScript
+)
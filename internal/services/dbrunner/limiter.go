@@ -0,0 +1,148 @@
+package dbrunnerservice
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// queueMetrics are the metrics of the query queue, published
+// as "dbrunner_queue" in expvar.
+var queueMetrics = struct {
+	// Running is the number of the queries running.
+	Running *expvar.Int
+	// Waiting is the number of the queries waiting in the queue.
+	Waiting *expvar.Int
+	// Rejected is the number of the queries rejected for the full queue.
+	Rejected *expvar.Int
+	// WaitSeconds is the total seconds the queries waited in the queue.
+	WaitSeconds *expvar.Float
+	// WaitCount is the number of the queries which have left the queue.
+	WaitCount *expvar.Int
+}{
+	Running:     new(expvar.Int),
+	Waiting:     new(expvar.Int),
+	Rejected:    new(expvar.Int),
+	WaitSeconds: new(expvar.Float),
+	WaitCount:   new(expvar.Int),
+}
+
+func init() {
+	metrics := expvar.NewMap("dbrunner_queue")
+	metrics.Set("running", queueMetrics.Running)
+	metrics.Set("waiting", queueMetrics.Waiting)
+	metrics.Set("rejected", queueMetrics.Rejected)
+	metrics.Set("wait_seconds", queueMetrics.WaitSeconds)
+	metrics.Set("wait_count", queueMetrics.WaitCount)
+}
+
+// queryLimiter bounds the number of the queries running at the same time.
+//
+// The queries beyond the concurrency wait in a bounded queue, and the
+// queries beyond the queue are rejected.
+type queryLimiter struct {
+	concurrency int
+
+	// running holds a token for each running query.
+	running chan struct{}
+	// admitted holds a token for each running or waiting query.
+	admitted chan struct{}
+
+	mu sync.Mutex
+	// averageRun is the moving average of the running time of the
+	// queries, for estimating when to retry.
+	averageRun time.Duration
+}
+
+func newQueryLimiter(concurrency, queueSize int) *queryLimiter {
+	return &queryLimiter{
+		concurrency: concurrency,
+		running:     make(chan struct{}, concurrency),
+		admitted:    make(chan struct{}, concurrency+queueSize),
+	}
+}
+
+// acquire waits for a slot to run a query. The release function must
+// be called after the query is finished.
+//
+// The returned error is a [connect.Error]. If the queue is full, the
+// code is [connect.CodeResourceExhausted] with the Retry-After metadata
+// in seconds.
+func (l *queryLimiter) acquire(ctx context.Context) (release func(), err error) {
+	select {
+	case l.admitted <- struct{}{}:
+	default:
+		queueMetrics.Rejected.Add(1)
+
+		return nil, l.exhaustedError(errors.New("too many queries are running; please retry later"))
+	}
+
+	queueMetrics.Waiting.Add(1)
+	waitStart := time.Now()
+
+	select {
+	case l.running <- struct{}{}:
+	case <-ctx.Done():
+		queueMetrics.Waiting.Add(-1)
+		<-l.admitted
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, connect.NewError(connect.CodeDeadlineExceeded, ctx.Err())
+		}
+		return nil, connect.NewError(connect.CodeCanceled, ctx.Err())
+	}
+
+	queueMetrics.Waiting.Add(-1)
+	queueMetrics.WaitSeconds.Add(time.Since(waitStart).Seconds())
+	queueMetrics.WaitCount.Add(1)
+	queueMetrics.Running.Add(1)
+
+	runStart := time.Now()
+	return func() {
+		l.observeRun(time.Since(runStart))
+
+		queueMetrics.Running.Add(-1)
+		<-l.running
+		<-l.admitted
+	}, nil
+}
+
+// exhaustedError returns err as a [connect.CodeResourceExhausted] error
+// with the Retry-After metadata in seconds.
+func (l *queryLimiter) exhaustedError(err error) *connect.Error {
+	connectErr := connect.NewError(connect.CodeResourceExhausted, err)
+	connectErr.Meta().Set("Retry-After", strconv.Itoa(l.retryAfter()))
+	return connectErr
+}
+
+// observeRun updates the moving average of the running time.
+func (l *queryLimiter) observeRun(duration time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.averageRun == 0 {
+		l.averageRun = duration
+		return
+	}
+
+	l.averageRun = (l.averageRun*7 + duration) / 8
+}
+
+// retryAfter estimates the seconds until the queue has room,
+// which is at least 1 second.
+func (l *queryLimiter) retryAfter() int {
+	l.mu.Lock()
+	averageRun := l.averageRun
+	l.mu.Unlock()
+
+	// The queued queries are run in batches of the concurrency.
+	batches := math.Ceil(float64(len(l.admitted)) / float64(l.concurrency))
+	seconds := int(math.Ceil(batches * averageRun.Seconds()))
+
+	return max(seconds, 1)
+}
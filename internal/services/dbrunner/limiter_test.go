@@ -0,0 +1,74 @@
+package dbrunnerservice
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryLimiter(t *testing.T) {
+	t.Run("the queries beyond the queue are rejected", func(t *testing.T) {
+		limiter := newQueryLimiter(1, 1)
+
+		release, err := limiter.acquire(context.Background())
+		require.NoError(t, err)
+
+		// the second query waits in the queue
+		acquired := make(chan func())
+		go func() {
+			release, err := limiter.acquire(context.Background())
+			if err == nil {
+				acquired <- release
+			}
+		}()
+
+		require.Eventually(t, func() bool {
+			return len(limiter.admitted) == 2
+		}, time.Second, time.Millisecond)
+
+		// the third query is rejected
+		_, err = limiter.acquire(context.Background())
+		var connectErr *connect.Error
+		require.True(t, errors.As(err, &connectErr))
+		assert.Equal(t, connect.CodeResourceExhausted, connectErr.Code())
+		assert.Equal(t, "1", connectErr.Meta().Get("Retry-After"))
+
+		release()
+		(<-acquired)()
+
+		assert.Empty(t, limiter.admitted)
+		assert.Empty(t, limiter.running)
+	})
+
+	t.Run("the waiting query leaves the queue when cancelled", func(t *testing.T) {
+		limiter := newQueryLimiter(1, 1)
+
+		release, err := limiter.acquire(context.Background())
+		require.NoError(t, err)
+		defer release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err = limiter.acquire(ctx)
+		assert.Equal(t, connect.CodeDeadlineExceeded, connect.CodeOf(err))
+		assert.Len(t, limiter.admitted, 1)
+	})
+
+	t.Run("the retry after is estimated by the running time", func(t *testing.T) {
+		limiter := newQueryLimiter(2, 4)
+		limiter.observeRun(3 * time.Second)
+
+		for range 6 {
+			limiter.admitted <- struct{}{}
+		}
+
+		// 6 queries in 3 batches of 3 seconds
+		assert.Equal(t, 9, limiter.retryAfter())
+	})
+}
@@ -3,9 +3,12 @@ package dbrunnerservice
 import (
 	"context"
 	"errors"
+	"strings"
 
 	"connectrpc.com/connect"
+	commonv1 "github.com/database-playground/backend/gen/common/v1"
 	dbrunnerv1 "github.com/database-playground/backend/gen/dbrunner/v1"
+	"github.com/database-playground/backend/internal/dbrunner"
 	"github.com/samber/lo"
 )
 
@@ -13,6 +16,12 @@ func (s *Service) RetrieveQuery(ctx context.Context, request *connect.Request[db
 	if request.Msg.GetId() == "" {
 		return connect.NewError(connect.CodeInvalidArgument, errors.New("id is required"))
 	}
+	if request.Msg.GetCursor().GetOffset() < 0 {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("offset must not be negative"))
+	}
+	if request.Msg.GetCursor().GetLimit() < 0 {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("limit must not be negative"))
+	}
 
 	outputHash, err := s.cacheModule.GetOutputHash(ctx, request.Msg.GetId())
 	if errors.Is(err, ErrNotFound) {
@@ -37,23 +46,22 @@ func (s *Service) RetrieveQuery(ctx context.Context, request *connect.Request[db
 	if err := stream.Send(&dbrunnerv1.RetrieveQueryResponse{
 		Kind: &dbrunnerv1.RetrieveQueryResponse_Header{
 			Header: &dbrunnerv1.HeaderRow{
-				Header: output.Header,
+				Header:        output.Header,
+				DeclaredTypes: output.ColumnTypes,
+				Truncated:     output.Truncated,
+				TotalRows:     int64(len(output.Data)),
 			},
 		},
 	}); err != nil {
 		return err
 	}
 
-	// Send data rows one by one
-	for _, row := range output.Data {
+	// Send data rows in the range of the cursor one by one
+	for _, row := range paginate(output.Data, request.Msg.GetCursor()) {
 		if err := stream.Send(&dbrunnerv1.RetrieveQueryResponse{
 			Kind: &dbrunnerv1.RetrieveQueryResponse_Row{
 				Row: &dbrunnerv1.DataRow{
-					Cells: lo.Map(row, func(cell *string, _ int) *dbrunnerv1.Cell {
-						return &dbrunnerv1.Cell{
-							Value: cell,
-						}
-					}),
+					Cells: cellsToProto(row),
 				},
 			},
 		}); err != nil {
@@ -61,5 +69,78 @@ func (s *Service) RetrieveQuery(ctx context.Context, request *connect.Request[db
 		}
 	}
 
+	// Send the result of each statement
+	for i, statement := range output.Statements {
+		if err := stream.Send(&dbrunnerv1.RetrieveQueryResponse{
+			Kind: &dbrunnerv1.RetrieveQueryResponse_Statement{
+				Statement: &dbrunnerv1.StatementHeader{
+					Index: int64(i),
+					Header: &dbrunnerv1.HeaderRow{
+						Header:        statement.Header,
+						DeclaredTypes: statement.ColumnTypes,
+					},
+					RowsAffected: statement.RowsAffected,
+				},
+			},
+		}); err != nil {
+			return err
+		}
+
+		for _, row := range statement.Data {
+			if err := stream.Send(&dbrunnerv1.RetrieveQueryResponse{
+				Kind: &dbrunnerv1.RetrieveQueryResponse_StatementRow{
+					StatementRow: &dbrunnerv1.DataRow{
+						Cells: cellsToProto(row),
+					},
+				},
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
+
+// paginate returns the rows in the range of the cursor.
+// All the rows after the offset are returned if the limit is not set.
+func paginate(rows [][]dbrunner.Cell, cursor *commonv1.Cursor) [][]dbrunner.Cell {
+	offset := min(cursor.GetOffset(), int64(len(rows)))
+	rows = rows[offset:]
+
+	if cursor != nil && cursor.Limit != nil {
+		rows = rows[:min(cursor.GetLimit(), int64(len(rows)))]
+	}
+
+	return rows
+}
+
+// cellsToProto converts the cells of a row to the protobuf cells.
+func cellsToProto(row []dbrunner.Cell) []*dbrunnerv1.Cell {
+	return lo.Map(row, func(cell dbrunner.Cell, _ int) *dbrunnerv1.Cell {
+		return cellToProto(cell)
+	})
+}
+
+// cellToProto converts a cell to the protobuf cell.
+//
+// The invalid UTF-8 of the text is replaced with U+FFFD, as the protobuf
+// strings must be valid UTF-8. It is the same as the JSON the outputs
+// are hashed in.
+func cellToProto(cell dbrunner.Cell) *dbrunnerv1.Cell {
+	protoCell := &dbrunnerv1.Cell{Type: cellTypeToProto[cell.Type]}
+	if cell.Value != nil {
+		protoCell.Value = lo.ToPtr(strings.ToValidUTF8(*cell.Value, "\uFFFD"))
+	}
+
+	return protoCell
+}
+
+var cellTypeToProto = map[dbrunner.CellType]dbrunnerv1.CellType{
+	dbrunner.CellTypeUnspecified: dbrunnerv1.CellType_CELL_TYPE_UNSPECIFIED,
+	dbrunner.CellTypeNull:        dbrunnerv1.CellType_CELL_TYPE_NULL,
+	dbrunner.CellTypeInteger:     dbrunnerv1.CellType_CELL_TYPE_INTEGER,
+	dbrunner.CellTypeReal:        dbrunnerv1.CellType_CELL_TYPE_REAL,
+	dbrunner.CellTypeText:        dbrunnerv1.CellType_CELL_TYPE_TEXT,
+	dbrunner.CellTypeBlob:        dbrunnerv1.CellType_CELL_TYPE_BLOB,
+}
@@ -0,0 +1,61 @@
+package dbrunnerservice
+
+import (
+	"testing"
+
+	commonv1 "github.com/database-playground/backend/gen/common/v1"
+	"github.com/database-playground/backend/internal/dbrunner"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaginate(t *testing.T) {
+	rows := [][]dbrunner.Cell{
+		{dbrunner.NewCell("1")},
+		{dbrunner.NewCell("2")},
+		{dbrunner.NewCell("3")},
+	}
+
+	cases := []struct {
+		name     string
+		cursor   *commonv1.Cursor
+		expected [][]dbrunner.Cell
+	}{
+		{
+			name:     "no cursor returns all the rows",
+			cursor:   nil,
+			expected: rows,
+		},
+		{
+			name:     "no limit returns the rows after the offset",
+			cursor:   &commonv1.Cursor{Offset: lo.ToPtr[int64](1)},
+			expected: rows[1:],
+		},
+		{
+			name:     "the limit caps the rows",
+			cursor:   &commonv1.Cursor{Limit: lo.ToPtr[int64](1), Offset: lo.ToPtr[int64](1)},
+			expected: rows[1:2],
+		},
+		{
+			name:     "the limit larger than the rows returns the rest",
+			cursor:   &commonv1.Cursor{Limit: lo.ToPtr[int64](10), Offset: lo.ToPtr[int64](2)},
+			expected: rows[2:],
+		},
+		{
+			name:     "the offset after the last row returns no rows",
+			cursor:   &commonv1.Cursor{Offset: lo.ToPtr[int64](5)},
+			expected: [][]dbrunner.Cell{},
+		},
+		{
+			name:     "the zero limit returns no rows",
+			cursor:   &commonv1.Cursor{Limit: lo.ToPtr[int64](0)},
+			expected: [][]dbrunner.Cell{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, paginate(rows, c.cursor))
+		})
+	}
+}
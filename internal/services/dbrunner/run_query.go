@@ -3,10 +3,13 @@ package dbrunnerservice
 import (
 	"context"
 	"errors"
+	"time"
 
 	"connectrpc.com/connect"
+	commonv1 "github.com/database-playground/backend/gen/common/v1"
 	dbrunnerv1 "github.com/database-playground/backend/gen/dbrunner/v1"
 	"github.com/database-playground/backend/internal/dbrunner"
+	"github.com/samber/lo"
 	"modernc.org/sqlite"
 )
 
@@ -19,8 +22,12 @@ func (s *Service) RunQuery(ctx context.Context, request *connect.Request[dbrunne
 	}
 
 	input := dbrunner.Input{
-		Init:  request.Msg.GetSchema(),
-		Query: request.Msg.GetQuery(),
+		Init:         request.Msg.GetSchema(),
+		Query:        request.Msg.GetQuery(),
+		CaptureState: request.Msg.GetCaptureState() != nil,
+		StateTables:  request.Msg.GetCaptureState().GetTables(),
+		Limits:       limitsFromProto(request.Msg.GetLimits()),
+		Sandbox:      dbrunner.Sandbox{Allow: permissionsFromProto(request.Msg.GetSandboxAllow())},
 	}
 
 	// normalize input so it is cachable
@@ -31,29 +38,33 @@ func (s *Service) RunQuery(ctx context.Context, request *connect.Request[dbrunne
 
 	// check if the output is existed; if so, return it.
 	inputHash := normalizedInput.Hash()
-	if outputHash, err := s.cacheModule.GetOutputHash(ctx, inputHash); err == nil && s.cacheModule.HasOutput(ctx, outputHash) {
-		return &connect.Response[dbrunnerv1.RunQueryResponse]{
-			Msg: &dbrunnerv1.RunQueryResponse{
-				ResponseType: &dbrunnerv1.RunQueryResponse_Id{
-					Id: inputHash,
-				},
-			},
-		}, nil
+	if outputHash, ttl, ok := s.cacheModule.Lookup(ctx, inputHash); ok {
+		cacheMetrics.Hits.Add(1)
+		return s.cachedResponse(ctx, request.Msg, normalizedInput, inputHash, outputHash, ttl)
 	}
+	cacheMetrics.Misses.Add(1)
 
-	output, err := dbrunner.RunQuery(ctx, normalizedInput)
+	// run the query, or wait for the same query running.
+	id, err := s.executor.execute(ctx, normalizedInput)
 	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) {
+		var limitErr *dbrunner.LimitError
+		if errors.As(err, &limitErr) {
 			return &connect.Response[dbrunnerv1.RunQueryResponse]{
 				Msg: &dbrunnerv1.RunQueryResponse{
-					ResponseType: &dbrunnerv1.RunQueryResponse_Error{
-						Error: "query timeout (takes more than 1 second)",
+					ResponseType: &dbrunnerv1.RunQueryResponse_LimitExceeded{
+						LimitExceeded: &dbrunnerv1.LimitExceeded{
+							Limit:   limitToProto(limitErr.Limit),
+							Message: limitErr.Error(),
+						},
 					},
 				},
 			}, nil
 		}
 
-		if errors.As(err, new(*sqlite.Error)) {
+		// The query errors are user-side errors, and so are the operations
+		// the sandbox denies and the workers crashed by the query.
+		if errors.As(err, new(*sqlite.Error)) || errors.As(err, new(*dbrunner.QueryError)) ||
+			errors.As(err, new(*dbrunner.SandboxError)) || errors.As(err, new(*dbrunner.WorkerError)) {
 			return &connect.Response[dbrunnerv1.RunQueryResponse]{
 				Msg: &dbrunnerv1.RunQueryResponse{
 					ResponseType: &dbrunnerv1.RunQueryResponse_Error{
@@ -63,13 +74,66 @@ func (s *Service) RunQuery(ctx context.Context, request *connect.Request[dbrunne
 			}, nil
 		}
 
+		// The query has not run, so the clients can retry it later
+		// as if the queue were full.
+		if errors.Is(err, dbrunner.ErrWorkersBusy) {
+			return nil, s.executor.limiter.exhaustedError(err)
+		}
+
+		// The errors from the limiter are already connect errors.
+		if errors.As(err, new(*connect.Error)) {
+			return nil, err
+		}
+		if errors.Is(err, context.Canceled) {
+			return nil, connect.NewError(connect.CodeCanceled, err)
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, connect.NewError(connect.CodeDeadlineExceeded, err)
+		}
+
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
-	// cache the output
-	id, err := s.cacheModule.WriteToCache(ctx, normalizedInput, output)
-	if err != nil {
-		return nil, connect.NewError(connect.CodeInternal, err)
+	outputHash, ttl, ok := s.cacheModule.Lookup(ctx, id)
+	if !ok {
+		if err := s.keepQuery(ctx, request.Msg, id); err != nil {
+			return nil, err
+		}
+
+		// The output is too large for the in-process cache, or
+		// has been evicted already.
+		return &connect.Response[dbrunnerv1.RunQueryResponse]{
+			Msg: &dbrunnerv1.RunQueryResponse{
+				ResponseType: &dbrunnerv1.RunQueryResponse_Id{
+					Id: id,
+				},
+			},
+		}, nil
+	}
+
+	return s.cachedResponse(ctx, request.Msg, normalizedInput, id, outputHash, ttl)
+}
+
+// cachedResponse returns the ID of the cached output with its output
+// hash, row count and expiration, and pins the output and keeps the
+// query if requested.
+//
+// The row count is unset if the output is gone before it is read.
+func (s *Service) cachedResponse(ctx context.Context, request *dbrunnerv1.RunQueryRequest, input dbrunner.Input, id string, outputHash string, ttl time.Duration) (*connect.Response[dbrunnerv1.RunQueryResponse], error) {
+	if request.GetPin() && ttl != 0 {
+		if err := s.cacheModule.Pin(ctx, input); err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+		ttl = 0
+	}
+
+	if err := s.keepQuery(ctx, request, id); err != nil {
+		return nil, err
+	}
+
+	var rowCount *int64
+	if output, err := s.cacheModule.GetOutput(ctx, outputHash); err == nil {
+		rowCount = lo.ToPtr(int64(len(output.Data)))
 	}
 
 	return &connect.Response[dbrunnerv1.RunQueryResponse]{
@@ -77,6 +141,93 @@ func (s *Service) RunQuery(ctx context.Context, request *connect.Request[dbrunne
 			ResponseType: &dbrunnerv1.RunQueryResponse_Id{
 				Id: id,
 			},
+			ExpiresAt:  expiresAt(ttl),
+			OutputHash: &outputHash,
+			RowCount:   rowCount,
 		},
 	}, nil
 }
+
+// keepQuery keeps the query of the ID in the cache if requested.
+//
+// The returned error is a [connect.Error].
+func (s *Service) keepQuery(ctx context.Context, request *dbrunnerv1.RunQueryRequest, id string) error {
+	if !request.GetKeepQuery() {
+		return nil
+	}
+
+	if err := s.cacheModule.KeepQuery(ctx, id, request.GetQuery()); err != nil {
+		return connect.NewError(connect.CodeInternal, err)
+	}
+
+	return nil
+}
+
+func (s *Service) GetQuery(ctx context.Context, request *connect.Request[dbrunnerv1.GetQueryRequest]) (*connect.Response[dbrunnerv1.GetQueryResponse], error) {
+	if request.Msg.GetId() == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("id is required"))
+	}
+
+	query, err := s.cacheModule.GetQuery(ctx, request.Msg.GetId())
+	if errors.Is(err, ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("id expired or the query is not kept – re-query again!"))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	return &connect.Response[dbrunnerv1.GetQueryResponse]{
+		Msg: &dbrunnerv1.GetQueryResponse{
+			Query: query,
+		},
+	}, nil
+}
+
+// limitsFromProto converts the protobuf execution limits.
+// nil means the default limits.
+func limitsFromProto(limits *commonv1.ExecutionLimits) dbrunner.Limits {
+	return dbrunner.Limits{
+		Timeout:          time.Duration(limits.GetTimeoutMs()) * time.Millisecond,
+		MaxRows:          int(limits.GetMaxRows()),
+		MaxCellBytes:     int(limits.GetMaxCellBytes()),
+		MaxOutputBytes:   limits.GetMaxOutputBytes(),
+		MaxDatabaseBytes: limits.GetMaxDatabaseBytes(),
+		MaxVMSteps:       limits.GetMaxVmSteps(),
+		MaxHeapBytes:     limits.GetMaxHeapBytes(),
+	}
+}
+
+func limitToProto(limit dbrunner.Limit) dbrunnerv1.Limit {
+	switch limit {
+	case dbrunner.LimitTimeout:
+		return dbrunnerv1.Limit_LIMIT_TIMEOUT
+	case dbrunner.LimitOutputBytes:
+		return dbrunnerv1.Limit_LIMIT_OUTPUT_BYTES
+	case dbrunner.LimitDatabaseBytes:
+		return dbrunnerv1.Limit_LIMIT_DATABASE_BYTES
+	case dbrunner.LimitVMSteps:
+		return dbrunnerv1.Limit_LIMIT_VM_STEPS
+	case dbrunner.LimitHeapBytes:
+		return dbrunnerv1.Limit_LIMIT_HEAP_BYTES
+	default:
+		return dbrunnerv1.Limit_LIMIT_UNSPECIFIED
+	}
+}
+
+func permissionsFromProto(permissions []commonv1.SandboxPermission) []dbrunner.Permission {
+	allow := make([]dbrunner.Permission, 0, len(permissions))
+	for _, permission := range permissions {
+		switch permission {
+		case commonv1.SandboxPermission_SANDBOX_PERMISSION_ATTACH:
+			allow = append(allow, dbrunner.PermissionAttach)
+		case commonv1.SandboxPermission_SANDBOX_PERMISSION_PRAGMA:
+			allow = append(allow, dbrunner.PermissionPragma)
+		case commonv1.SandboxPermission_SANDBOX_PERMISSION_VACUUM_INTO:
+			allow = append(allow, dbrunner.PermissionVacuumInto)
+		case commonv1.SandboxPermission_SANDBOX_PERMISSION_LOAD_EXTENSION:
+			allow = append(allow, dbrunner.PermissionLoadExtension)
+		}
+	}
+
+	return allow
+}
@@ -0,0 +1,48 @@
+package dbrunnerservice
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	dbrunnerv1 "github.com/database-playground/backend/gen/dbrunner/v1"
+	"github.com/database-playground/backend/internal/dbrunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingRunner fails all the queries with err.
+type failingRunner struct {
+	err error
+}
+
+func (r failingRunner) RunQuery(context.Context, dbrunner.Input) (dbrunner.Output, error) {
+	return dbrunner.Output{}, r.err
+}
+
+func TestRunQuery_WorkersBusy(t *testing.T) {
+	t.Parallel()
+
+	cacheModule := NewCacheModule(NewMemoryCache(1<<20, DefaultCacheTTL))
+	s := &Service{
+		cacheModule: cacheModule,
+		executor: &executor{
+			cacheModule: cacheModule,
+			runner:      failingRunner{err: dbrunner.ErrWorkersBusy},
+			limiter:     newQueryLimiter(1, 0),
+		},
+	}
+
+	_, err := s.RunQuery(context.Background(), connect.NewRequest(&dbrunnerv1.RunQueryRequest{
+		Schema: "CREATE TABLE a (id INT);",
+		Query:  "SELECT * FROM a;",
+	}))
+	require.Error(t, err)
+
+	// The busy workers are reported as the full queue.
+	assert.Equal(t, connect.CodeResourceExhausted, connect.CodeOf(err))
+
+	var connectErr *connect.Error
+	require.ErrorAs(t, err, &connectErr)
+	assert.Equal(t, "1", connectErr.Meta().Get("Retry-After"))
+}
@@ -23,10 +23,19 @@ var scopeMap map[string][]string = map[string][]string{
 	"PostChallenges":         {"challenge"},
 	"GetChallengesId":        {"challenge"},
 	"GetChallengesIdCompare": {"read:question", "challenge"},
+	"GetChallengesIdDiff":    {"read:question", "challenge"},
 	"GetQuestions":           {"read:question"},
 	"GetQuestionsId":         {"read:question"},
 	"GetQuestionsIdSolution": {"read:question", "read:solution"},
 	"GetSchemasId":           {"read:schema"},
+	"PostQuestions":          {"write:resource"},
+	"PutQuestionsId":         {"write:resource"},
+	"DeleteQuestionsId":      {"write:resource"},
+	"PostSchemas":            {"write:resource"},
+	"PutSchemasId":           {"write:resource"},
+	"DeleteSchemasId":        {"write:resource"},
+	"PostQuestionsIdRestore": {"write:resource"},
+	"PostSchemasIdRestore":   {"write:resource"},
 
 	"GetHealthz": nil,
 }
@@ -140,6 +149,17 @@ func sendServerError(w http.ResponseWriter, message string) (any, error) {
 	return nil, nil
 }
 
+// hasScope returns whether the token verified by the authorization
+// middleware has the scope.
+func hasScope(ctx context.Context, scope string) bool {
+	tok, ok := ctx.Value(AuthContextJwtToken).(jwt.Token)
+	if !ok {
+		return false
+	}
+
+	return slices.Contains(parseScope(tok.PrivateClaims()["scope"]), scope)
+}
+
 // parseScope parses the "scope" Get result of [jwt.Token]
 // into a slice of strings.
 func parseScope(scope any) []string {
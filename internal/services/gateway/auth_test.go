@@ -0,0 +1,160 @@
+package gatewayservice
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopeMap(t *testing.T) {
+	t.Parallel()
+
+	// The operations writing the questions and the schemas.
+	writeOperations := []string{
+		"PostQuestions",
+		"PutQuestionsId",
+		"DeleteQuestionsId",
+		"PostQuestionsIdRestore",
+		"PostSchemas",
+		"PutSchemasId",
+		"DeleteSchemasId",
+		"PostSchemasIdRestore",
+	}
+
+	for _, operation := range writeOperations {
+		assert.Equal(t, []string{"write:resource"}, scopeMap[operation], operation)
+	}
+
+	for operation, scopes := range scopeMap {
+		if !strings.HasPrefix(operation, "Get") {
+			continue
+		}
+		assert.NotContains(t, scopes, "write:resource", operation)
+	}
+}
+
+func TestAuthorizationMiddleware(t *testing.T) {
+	t.Parallel()
+
+	const resourceIndicator = "https://api.example.com"
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	privateKey, err := jwk.FromRaw(key)
+	require.NoError(t, err)
+	require.NoError(t, privateKey.Set(jwk.KeyIDKey, "test"))
+	require.NoError(t, privateKey.Set(jwk.AlgorithmKey, jwa.RS256))
+
+	publicKey, err := privateKey.PublicKey()
+	require.NoError(t, err)
+
+	keySet := jwk.NewSet()
+	require.NoError(t, keySet.AddKey(publicKey))
+
+	logto := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(keySet)
+	}))
+	t.Cleanup(logto.Close)
+
+	// token returns a bearer token with the scopes.
+	token := func(t *testing.T, scope string) string {
+		t.Helper()
+
+		tok, err := jwt.NewBuilder().
+			Issuer(logto.URL+"/oidc").
+			Audience([]string{resourceIndicator}).
+			Expiration(time.Now().Add(time.Hour)).
+			Claim("scope", scope).
+			Build()
+		require.NoError(t, err)
+
+		signed, err := jwt.Sign(tok, jwt.WithKey(jwa.RS256, privateKey))
+		require.NoError(t, err)
+
+		return "Bearer " + string(signed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	middleware := NewAuthorizationMiddleware(ctx, logto.URL, resourceIndicator, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// serve runs the operation with the authorization header, and
+	// returns the status and whether the handler is called.
+	serve := func(t *testing.T, operationID string, authorization string) (status int, called bool) {
+		t.Helper()
+
+		handler := middleware(func(ctx context.Context, w http.ResponseWriter, r *http.Request, request interface{}) (interface{}, error) {
+			called = true
+			w.WriteHeader(http.StatusOK)
+			return nil, nil
+		}, operationID)
+
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+
+		_, err := handler(r.Context(), w, r, nil)
+		require.NoError(t, err)
+
+		return w.Code, called
+	}
+
+	cases := []struct {
+		name           string
+		operationID    string
+		scope          string
+		expectedStatus int
+	}{
+		{"the write scope is required to create a question", "PostQuestions", "read:question challenge", http.StatusUnauthorized},
+		{"the write scope is required to delete a schema", "DeleteSchemasId", "read:schema", http.StatusUnauthorized},
+		{"the write scope allows creating a question", "PostQuestions", "read:question write:resource", http.StatusOK},
+		{"the write scope allows restoring a schema", "PostSchemasIdRestore", "write:resource", http.StatusOK},
+		{"the read scope allows reading a question", "GetQuestionsId", "read:question", http.StatusOK},
+		{"the write scope does not replace the read scope", "GetQuestionsId", "write:resource", http.StatusUnauthorized},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			status, called := serve(t, c.operationID, token(t, c.scope))
+			assert.Equal(t, c.expectedStatus, status)
+			assert.Equal(t, c.expectedStatus == http.StatusOK, called)
+		})
+	}
+
+	t.Run("the token is required", func(t *testing.T) {
+		t.Parallel()
+
+		status, called := serve(t, "PostQuestions", "")
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.False(t, called)
+	})
+
+	t.Run("the unknown operations are denied", func(t *testing.T) {
+		t.Parallel()
+
+		status, called := serve(t, "PostUnknown", token(t, "write:resource"))
+		assert.Equal(t, http.StatusNotFound, status)
+		assert.False(t, called)
+	})
+}
@@ -27,6 +27,7 @@ type Converter interface {
 	// goverter:enum:map DifficultyMedium Medium
 	// goverter:enum:map DifficultyHard Hard
 	DifficultyFromModel(in models.Difficulty) openapi.QuestionDifficulty
+	// goverter:map Tags | NonNilStrings
 	QuestionFromModel(in *models.Question) openapi.Question
 	QuestionsFromModel(in []*models.Question) openapi.Questions
 	QuestionSolutionFromModel(in *models.QuestionSolution) openapi.QuestionSolution
@@ -48,6 +49,15 @@ func TimeToTime(in time.Time) time.Time {
 	return in
 }
 
+// NonNilStrings returns an empty slice if in is nil, so the required
+// arrays are not encoded as null.
+func NonNilStrings(in []string) []string {
+	if in == nil {
+		return []string{}
+	}
+	return in
+}
+
 func StringToID(in string) (int64, error) {
 	return strconv.ParseInt(in, 10, 64)
 }
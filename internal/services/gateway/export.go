@@ -0,0 +1,177 @@
+package gatewayservice
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"connectrpc.com/connect"
+	dbrunnerv1 "github.com/database-playground/backend/gen/dbrunner/v1"
+	"github.com/database-playground/backend/internal/services/gateway/openapi"
+	"github.com/samber/lo"
+)
+
+// mediaTypeFormats maps the media types in the Accept header to the
+// formats of the challenge results.
+var mediaTypeFormats = map[string]openapi.GetChallengesIdParamsFormat{
+	"application/json":          openapi.Json,
+	"text/csv":                  openapi.Csv,
+	"text/tab-separated-values": openapi.Tsv,
+	"application/x-ndjson":      openapi.Ndjson,
+	"text/markdown":             openapi.Markdown,
+}
+
+// negotiateFormat returns the format in the format parameter, or the
+// first supported media type in the Accept header, regardless of their
+// quality values. It defaults to JSON.
+func negotiateFormat(format *openapi.GetChallengesIdParamsFormat, accept *string) openapi.GetChallengesIdParamsFormat {
+	if format != nil {
+		return *format
+	}
+
+	for _, mediaRange := range strings.Split(lo.FromPtr(accept), ",") {
+		mediaType, _, _ := strings.Cut(mediaRange, ";")
+		if format, ok := mediaTypeFormats[strings.ToLower(strings.TrimSpace(mediaType))]; ok {
+			return format
+		}
+	}
+
+	return openapi.Json
+}
+
+// resultWriter writes the header and the rows of a query result.
+type resultWriter interface {
+	WriteHeader(header []string) error
+	// WriteRow writes a row, whose nil cells are NULL.
+	WriteRow(row []*string) error
+	// Flush writes the buffered data to the underlying writer.
+	Flush() error
+}
+
+// newResultWriter creates the writer of the format other than JSON.
+// null is how NULL is rendered, which defaults to the convention of the
+// format. It returns false if the format is unknown.
+func newResultWriter(w io.Writer, format openapi.GetChallengesIdParamsFormat, null *string) (resultWriter, bool) {
+	switch format {
+	case openapi.Csv:
+		return &csvResultWriter{w: csv.NewWriter(w), null: lo.FromPtr(null)}, true
+	case openapi.Tsv:
+		writer := csv.NewWriter(w)
+		writer.Comma = '\t'
+		return &csvResultWriter{w: writer, null: lo.FromPtr(null)}, true
+	case openapi.Ndjson:
+		buffered := bufio.NewWriter(w)
+		return &ndjsonResultWriter{w: buffered, encoder: json.NewEncoder(buffered)}, true
+	case openapi.Markdown:
+		return &markdownResultWriter{w: bufio.NewWriter(w), null: lo.FromPtrOr(null, "NULL")}, true
+	default:
+		return nil, false
+	}
+}
+
+// exportResult writes the header and the rows of the stream of
+// RetrieveQuery, whose header has been received, to the writer. The
+// results of the statements after the rows are not exported.
+func exportResult(stream *connect.ServerStreamForClient[dbrunnerv1.RetrieveQueryResponse], header *dbrunnerv1.HeaderRow, w resultWriter) error {
+	if err := w.WriteHeader(header.GetHeader()); err != nil {
+		return err
+	}
+
+	for stream.Receive() {
+		row, ok := stream.Msg().Kind.(*dbrunnerv1.RetrieveQueryResponse_Row)
+		if !ok {
+			break
+		}
+
+		if err := w.WriteRow(cellsFromProto(row.Row.GetCells())); err != nil {
+			return err
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+// csvResultWriter writes the result as CSV, or TSV with a tab as the
+// separator. The fields with the separators, quotes or newlines are quoted.
+type csvResultWriter struct {
+	w    *csv.Writer
+	null string
+}
+
+func (w *csvResultWriter) WriteHeader(header []string) error {
+	return w.w.Write(header)
+}
+
+func (w *csvResultWriter) WriteRow(row []*string) error {
+	return w.w.Write(lo.Map(row, func(cell *string, _ int) string {
+		return lo.FromPtrOr(cell, w.null)
+	}))
+}
+
+func (w *csvResultWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// ndjsonResultWriter writes the header and each row as a JSON array per
+// line, where NULL is null.
+type ndjsonResultWriter struct {
+	w       *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (w *ndjsonResultWriter) WriteHeader(header []string) error {
+	return w.encoder.Encode(header)
+}
+
+func (w *ndjsonResultWriter) WriteRow(row []*string) error {
+	return w.encoder.Encode(row)
+}
+
+func (w *ndjsonResultWriter) Flush() error {
+	return w.w.Flush()
+}
+
+// markdownResultWriter writes the result as a Markdown table. The pipes
+// in the cells are escaped, and the newlines are replaced with <br>.
+type markdownResultWriter struct {
+	w    *bufio.Writer
+	null string
+}
+
+var markdownCellReplacer = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+func (w *markdownResultWriter) WriteHeader(header []string) error {
+	if err := w.writeRow(lo.Map(header, func(column string, _ int) string {
+		return markdownCellReplacer.Replace(column)
+	})); err != nil {
+		return err
+	}
+
+	return w.writeRow(lo.Map(header, func(string, int) string {
+		return "---"
+	}))
+}
+
+func (w *markdownResultWriter) WriteRow(row []*string) error {
+	return w.writeRow(lo.Map(row, func(cell *string, _ int) string {
+		if cell == nil {
+			return w.null
+		}
+		return markdownCellReplacer.Replace(*cell)
+	}))
+}
+
+func (w *markdownResultWriter) writeRow(cells []string) error {
+	_, err := w.w.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	return err
+}
+
+func (w *markdownResultWriter) Flush() error {
+	return w.w.Flush()
+}
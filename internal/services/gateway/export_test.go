@@ -0,0 +1,146 @@
+package gatewayservice
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/database-playground/backend/internal/services/gateway/openapi"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		format   *openapi.GetChallengesIdParamsFormat
+		accept   *string
+		expected openapi.GetChallengesIdParamsFormat
+	}{
+		{"no format and no accept defaults to JSON", nil, nil, openapi.Json},
+		{"the format parameter wins over accept", lo.ToPtr(openapi.Tsv), lo.ToPtr("text/csv"), openapi.Tsv},
+		{"the unknown format is kept to be rejected", lo.ToPtr(openapi.GetChallengesIdParamsFormat("xml")), nil, "xml"},
+		{"a single media type", nil, lo.ToPtr("text/csv"), openapi.Csv},
+		{"the media types are case-insensitive", nil, lo.ToPtr("Text/Markdown"), openapi.Markdown},
+		{"the parameters and spaces are ignored", nil, lo.ToPtr(" application/x-ndjson ; charset=utf-8"), openapi.Ndjson},
+		{"the first supported media type is used", nil, lo.ToPtr("text/html, text/tab-separated-values, text/csv"), openapi.Tsv},
+		{"the quality values are ignored", nil, lo.ToPtr("text/csv;q=0.1, application/json;q=0.9"), openapi.Csv},
+		{"the unsupported media types default to JSON", nil, lo.ToPtr("text/html, */*"), openapi.Json},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, c.expected, negotiateFormat(c.format, c.accept))
+		})
+	}
+}
+
+func TestResultWriter(t *testing.T) {
+	t.Parallel()
+
+	header := []string{"id", "name|alias", "note"}
+	rows := [][]*string{
+		{lo.ToPtr("1"), lo.ToPtr("Alice"), nil},
+		{lo.ToPtr("2"), lo.ToPtr(`Bob, "the builder"`), lo.ToPtr("line 1\nline 2")},
+		{lo.ToPtr("3"), lo.ToPtr("tab\there"), lo.ToPtr("a|b\r\nc")},
+		{lo.ToPtr("4"), lo.ToPtr(""), lo.ToPtr("NULL")},
+	}
+
+	cases := []struct {
+		name     string
+		format   openapi.GetChallengesIdParamsFormat
+		null     *string
+		expected string
+	}{
+		{
+			name:   "CSV quotes the separators, quotes and newlines",
+			format: openapi.Csv,
+			expected: "id,name|alias,note\n" +
+				"1,Alice,\n" +
+				"2,\"Bob, \"\"the builder\"\"\",\"line 1\nline 2\"\n" +
+				"3,tab\there,\"a|b\r\nc\"\n" +
+				"4,,NULL\n",
+		},
+		{
+			name:   "CSV renders NULL as the null parameter",
+			format: openapi.Csv,
+			null:   lo.ToPtr(`\N`),
+			expected: "id,name|alias,note\n" +
+				"1,Alice,\\N\n" +
+				"2,\"Bob, \"\"the builder\"\"\",\"line 1\nline 2\"\n" +
+				"3,tab\there,\"a|b\r\nc\"\n" +
+				"4,,NULL\n",
+		},
+		{
+			name:   "TSV quotes the tabs instead of the commas",
+			format: openapi.Tsv,
+			expected: "id\tname|alias\tnote\n" +
+				"1\tAlice\t\n" +
+				"2\t\"Bob, \"\"the builder\"\"\"\t\"line 1\nline 2\"\n" +
+				"3\t\"tab\there\"\t\"a|b\r\nc\"\n" +
+				"4\t\tNULL\n",
+		},
+		{
+			name:   "Markdown escapes the pipes and replaces the newlines",
+			format: openapi.Markdown,
+			expected: "| id | name\\|alias | note |\n" +
+				"| --- | --- | --- |\n" +
+				"| 1 | Alice | NULL |\n" +
+				"| 2 | Bob, \"the builder\" | line 1<br>line 2 |\n" +
+				"| 3 | tab\there | a\\|b<br>c |\n" +
+				"| 4 |  | NULL |\n",
+		},
+		{
+			name:   "Markdown renders NULL as the null parameter",
+			format: openapi.Markdown,
+			null:   lo.ToPtr("(null)"),
+			expected: "| id | name\\|alias | note |\n" +
+				"| --- | --- | --- |\n" +
+				"| 1 | Alice | (null) |\n" +
+				"| 2 | Bob, \"the builder\" | line 1<br>line 2 |\n" +
+				"| 3 | tab\there | a\\|b<br>c |\n" +
+				"| 4 |  | NULL |\n",
+		},
+		{
+			name:   "NDJSON renders NULL as null regardless of the null parameter",
+			format: openapi.Ndjson,
+			null:   lo.ToPtr("(null)"),
+			expected: `["id","name|alias","note"]` + "\n" +
+				`["1","Alice",null]` + "\n" +
+				`["2","Bob, \"the builder\"","line 1\nline 2"]` + "\n" +
+				`["3","tab\there","a|b\r\nc"]` + "\n" +
+				`["4","","NULL"]` + "\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			w, ok := newResultWriter(&buf, c.format, c.null)
+			require.True(t, ok)
+
+			require.NoError(t, w.WriteHeader(header))
+			for _, row := range rows {
+				require.NoError(t, w.WriteRow(row))
+			}
+			require.NoError(t, w.Flush())
+
+			assert.Equal(t, c.expected, buf.String())
+		})
+	}
+
+	t.Run("JSON and the unknown formats have no writer", func(t *testing.T) {
+		t.Parallel()
+
+		for _, format := range []openapi.GetChallengesIdParamsFormat{openapi.Json, "xml"} {
+			_, ok := newResultWriter(&bytes.Buffer{}, format, nil)
+			assert.False(t, ok, format)
+		}
+	})
+}
@@ -2,7 +2,11 @@ package gatewayservice
 
 import (
 	"context"
+	"errors"
+	"io"
 	"log/slog"
+	"slices"
+	"strconv"
 
 	"connectrpc.com/connect"
 	commonv1 "github.com/database-playground/backend/gen/common/v1"
@@ -10,6 +14,8 @@ import (
 	questionmanagerv1 "github.com/database-playground/backend/gen/questionmanager/v1"
 	"github.com/database-playground/backend/internal/services/gateway/converter"
 	"github.com/database-playground/backend/internal/services/gateway/openapi"
+	"github.com/samber/lo"
+	"google.golang.org/protobuf/proto"
 )
 
 var _ openapi.StrictServerInterface = (*Server)(nil)
@@ -23,16 +29,55 @@ func (s *Server) GetHealthz(context.Context, openapi.GetHealthzRequestObject) (o
 
 // #region Questions
 
+// includeDeletedScopeMessage is the error of the requests including the
+// deleted resources without the write:resource scope.
+const includeDeletedScopeMessage = "Insufficient scope (write:resource is required to include the deleted resources)"
+
 // GetQuestions implements StrictServerInterface.
 func (s *Server) GetQuestions(ctx context.Context, request openapi.GetQuestionsRequestObject) (openapi.GetQuestionsResponseObject, error) {
-	response, err := s.questionManagerService.ListQuestions(ctx, &connect.Request[questionmanagerv1.ListQuestionsRequest]{
-		Msg: &questionmanagerv1.ListQuestionsRequest{
-			Cursor: &commonv1.Cursor{
-				Limit:  request.Params.Limit,
-				Offset: request.Params.Offset,
+	includeDeleted := lo.FromPtr(request.Params.IncludeDeleted)
+	if includeDeleted && !hasScope(ctx, "write:resource") {
+		return openapi.GetQuestions401JSONResponse{
+			UnauthorizedErrorJSONResponse: openapi.UnauthorizedErrorJSONResponse{
+				Message: includeDeletedScopeMessage,
 			},
-		},
+		}, nil
+	}
+
+	if lo.FromPtr(request.Params.Limit) < 0 || lo.FromPtr(request.Params.Offset) < 0 {
+		return openapi.GetQuestions400JSONResponse{
+			BadRequestErrorJSONResponse: openapi.BadRequestErrorJSONResponse{
+				Message: "Limit and offset must not be negative.",
+			},
+		}, nil
+	}
+
+	listRequest, err := listQuestionsParamsToProto(&request.Params)
+	if err != nil {
+		return openapi.GetQuestions400JSONResponse{
+			BadRequestErrorJSONResponse: openapi.BadRequestErrorJSONResponse{
+				Message: "Invalid parameters: " + err.Error(),
+			},
+		}, nil
+	}
+	listRequest.Cursor = &commonv1.Cursor{
+		Limit:  request.Params.Limit,
+		Offset: request.Params.Offset,
+		After:  request.Params.Cursor,
+	}
+	listRequest.IncludeDeleted = includeDeleted
+	listRequest.IncludeTotalCount = lo.FromPtr(request.Params.IncludeTotalCount)
+
+	response, err := s.questionManagerService.ListQuestions(ctx, &connect.Request[questionmanagerv1.ListQuestionsRequest]{
+		Msg: listRequest,
 	})
+	if connect.CodeOf(err) == connect.CodeInvalidArgument {
+		return openapi.GetQuestions400JSONResponse{
+			BadRequestErrorJSONResponse: openapi.BadRequestErrorJSONResponse{
+				Message: "Invalid parameters: " + connectErrorMessage(err),
+			},
+		}, nil
+	}
 	if err != nil {
 		s.logger.ErrorContext(ctx, "Failed to fetch questions", slog.Any("error", err), slog.Any("request", request))
 		return openapi.GetQuestions500JSONResponse{
@@ -45,7 +90,16 @@ func (s *Server) GetQuestions(ctx context.Context, request openapi.GetQuestionsR
 	questionsModel := s.pbConverter.QuestionsFromProto(response.Msg.GetQuestions())
 	questionsResponse := s.modelConverter.QuestionsFromModel(questionsModel)
 
-	return openapi.GetQuestions200JSONResponse(questionsResponse), nil
+	var nextLink string
+	if response.Msg.NextCursor != nil {
+		nextLink = nextQuestionsLink(&request.Params, response.Msg.GetNextCursor())
+	}
+
+	return questionsPageResponse{
+		Body:       questionsResponse,
+		NextLink:   nextLink,
+		TotalCount: response.Msg.TotalCount,
+	}, nil
 }
 
 // GetQuestionsId implements StrictServerInterface.
@@ -59,9 +113,19 @@ func (s *Server) GetQuestionsId(ctx context.Context, request openapi.GetQuestion
 		}, nil
 	}
 
+	includeDeleted := lo.FromPtr(request.Params.IncludeDeleted)
+	if includeDeleted && !hasScope(ctx, "write:resource") {
+		return openapi.GetQuestionsId401JSONResponse{
+			UnauthorizedErrorJSONResponse: openapi.UnauthorizedErrorJSONResponse{
+				Message: includeDeletedScopeMessage,
+			},
+		}, nil
+	}
+
 	response, err := s.questionManagerService.GetQuestion(ctx, &connect.Request[questionmanagerv1.GetQuestionRequest]{
 		Msg: &questionmanagerv1.GetQuestionRequest{
-			Id: id,
+			Id:             id,
+			IncludeDeleted: includeDeleted,
 		},
 	})
 	if connect.CodeOf(err) == connect.CodeNotFound {
@@ -124,6 +188,192 @@ func (s *Server) GetQuestionsIdSolution(ctx context.Context, request openapi.Get
 	return openapi.GetQuestionsIdSolution200JSONResponse(solutionResponse), nil
 }
 
+// PostQuestions implements openapi.StrictServerInterface.
+func (s *Server) PostQuestions(ctx context.Context, request openapi.PostQuestionsRequestObject) (openapi.PostQuestionsResponseObject, error) {
+	input, err := questionInputToProto(request.Body)
+	if err != nil {
+		return openapi.PostQuestions400JSONResponse{
+			BadRequestErrorJSONResponse: openapi.BadRequestErrorJSONResponse{
+				Message: "Invalid question: " + err.Error(),
+			},
+		}, nil
+	}
+
+	response, err := s.questionManagerService.CreateQuestion(ctx, &connect.Request[questionmanagerv1.CreateQuestionRequest]{
+		Msg: &questionmanagerv1.CreateQuestionRequest{
+			Question: input,
+		},
+	})
+	switch connect.CodeOf(err) {
+	case connect.CodeInvalidArgument:
+		return openapi.PostQuestions400JSONResponse{
+			BadRequestErrorJSONResponse: openapi.BadRequestErrorJSONResponse{
+				Message: "Invalid question: " + connectErrorMessage(err),
+			},
+		}, nil
+	case connect.CodeFailedPrecondition:
+		return openapi.PostQuestions422JSONResponse{
+			UnprocessableEntityErrorJSONResponse: openapi.UnprocessableEntityErrorJSONResponse{
+				Message: "Schema not found.",
+			},
+		}, nil
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to create question", slog.Any("error", err), slog.Any("request", request))
+		return openapi.PostQuestions500JSONResponse{
+			ErrorJSONResponse: openapi.ErrorJSONResponse{
+				Message: "Failed to create question.",
+			},
+		}, nil
+	}
+
+	questionModel := s.pbConverter.QuestionFromProto(response.Msg.GetQuestion())
+	questionResponse := s.modelConverter.QuestionFromModel(questionModel)
+
+	return openapi.PostQuestions201JSONResponse(questionResponse), nil
+}
+
+// PutQuestionsId implements openapi.StrictServerInterface.
+func (s *Server) PutQuestionsId(ctx context.Context, request openapi.PutQuestionsIdRequestObject) (openapi.PutQuestionsIdResponseObject, error) {
+	id, err := converter.StringToID(request.Id)
+	if err != nil {
+		return openapi.PutQuestionsId400JSONResponse{
+			BadRequestErrorJSONResponse: openapi.BadRequestErrorJSONResponse{
+				Message: "Invalid ID.",
+			},
+		}, nil
+	}
+
+	input, err := questionInputToProto(request.Body)
+	if err != nil {
+		return openapi.PutQuestionsId400JSONResponse{
+			BadRequestErrorJSONResponse: openapi.BadRequestErrorJSONResponse{
+				Message: "Invalid question: " + err.Error(),
+			},
+		}, nil
+	}
+
+	response, err := s.questionManagerService.UpdateQuestion(ctx, &connect.Request[questionmanagerv1.UpdateQuestionRequest]{
+		Msg: &questionmanagerv1.UpdateQuestionRequest{
+			Id:       id,
+			Question: input,
+		},
+	})
+	switch connect.CodeOf(err) {
+	case connect.CodeInvalidArgument:
+		return openapi.PutQuestionsId400JSONResponse{
+			BadRequestErrorJSONResponse: openapi.BadRequestErrorJSONResponse{
+				Message: "Invalid question: " + connectErrorMessage(err),
+			},
+		}, nil
+	case connect.CodeNotFound:
+		return openapi.PutQuestionsId404JSONResponse{
+			NoSuchResourceErrorJSONResponse: openapi.NoSuchResourceErrorJSONResponse{
+				Message: "Question not found.",
+			},
+		}, nil
+	case connect.CodeFailedPrecondition:
+		return openapi.PutQuestionsId422JSONResponse{
+			UnprocessableEntityErrorJSONResponse: openapi.UnprocessableEntityErrorJSONResponse{
+				Message: "Schema not found.",
+			},
+		}, nil
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to update question", slog.Any("error", err), slog.Any("request", request))
+		return openapi.PutQuestionsId500JSONResponse{
+			ErrorJSONResponse: openapi.ErrorJSONResponse{
+				Message: "Failed to update question.",
+			},
+		}, nil
+	}
+
+	questionModel := s.pbConverter.QuestionFromProto(response.Msg.GetQuestion())
+	questionResponse := s.modelConverter.QuestionFromModel(questionModel)
+
+	return openapi.PutQuestionsId200JSONResponse(questionResponse), nil
+}
+
+// DeleteQuestionsId implements openapi.StrictServerInterface.
+func (s *Server) DeleteQuestionsId(ctx context.Context, request openapi.DeleteQuestionsIdRequestObject) (openapi.DeleteQuestionsIdResponseObject, error) {
+	id, err := converter.StringToID(request.Id)
+	if err != nil {
+		return openapi.DeleteQuestionsId400JSONResponse{
+			BadRequestErrorJSONResponse: openapi.BadRequestErrorJSONResponse{
+				Message: "Invalid ID.",
+			},
+		}, nil
+	}
+
+	_, err = s.questionManagerService.DeleteQuestion(ctx, &connect.Request[questionmanagerv1.DeleteQuestionRequest]{
+		Msg: &questionmanagerv1.DeleteQuestionRequest{
+			Id: id,
+		},
+	})
+	if connect.CodeOf(err) == connect.CodeNotFound {
+		return openapi.DeleteQuestionsId404JSONResponse{
+			NoSuchResourceErrorJSONResponse: openapi.NoSuchResourceErrorJSONResponse{
+				Message: "Question not found.",
+			},
+		}, nil
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to delete question", slog.Any("error", err), slog.Any("request", request))
+		return openapi.DeleteQuestionsId500JSONResponse{
+			ErrorJSONResponse: openapi.ErrorJSONResponse{
+				Message: "Failed to delete question.",
+			},
+		}, nil
+	}
+
+	return openapi.DeleteQuestionsId204Response{}, nil
+}
+
+// PostQuestionsIdRestore implements openapi.StrictServerInterface.
+func (s *Server) PostQuestionsIdRestore(ctx context.Context, request openapi.PostQuestionsIdRestoreRequestObject) (openapi.PostQuestionsIdRestoreResponseObject, error) {
+	id, err := converter.StringToID(request.Id)
+	if err != nil {
+		return openapi.PostQuestionsIdRestore400JSONResponse{
+			BadRequestErrorJSONResponse: openapi.BadRequestErrorJSONResponse{
+				Message: "Invalid ID.",
+			},
+		}, nil
+	}
+
+	response, err := s.questionManagerService.RestoreQuestion(ctx, &connect.Request[questionmanagerv1.RestoreQuestionRequest]{
+		Msg: &questionmanagerv1.RestoreQuestionRequest{
+			Id: id,
+		},
+	})
+	switch connect.CodeOf(err) {
+	case connect.CodeNotFound:
+		return openapi.PostQuestionsIdRestore404JSONResponse{
+			NoSuchResourceErrorJSONResponse: openapi.NoSuchResourceErrorJSONResponse{
+				Message: "Deleted question not found.",
+			},
+		}, nil
+	case connect.CodeFailedPrecondition:
+		return openapi.PostQuestionsIdRestore422JSONResponse{
+			UnprocessableEntityErrorJSONResponse: openapi.UnprocessableEntityErrorJSONResponse{
+				Message: "The schema of the question is deleted. Restore the schema first.",
+			},
+		}, nil
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to restore question", slog.Any("error", err), slog.Any("request", request))
+		return openapi.PostQuestionsIdRestore500JSONResponse{
+			ErrorJSONResponse: openapi.ErrorJSONResponse{
+				Message: "Failed to restore question.",
+			},
+		}, nil
+	}
+
+	questionModel := s.pbConverter.QuestionFromProto(response.Msg.GetQuestion())
+	questionResponse := s.modelConverter.QuestionFromModel(questionModel)
+
+	return openapi.PostQuestionsIdRestore200JSONResponse(questionResponse), nil
+}
+
 // #region Question Challenge
 
 // GetChallenge implements openapi.StrictServerInterface.
@@ -137,66 +387,134 @@ func (s *Server) GetChallengesId(ctx context.Context, request openapi.GetChallen
 		}, nil
 	}
 
+	if lo.FromPtr(request.Params.Limit) < 0 || lo.FromPtr(request.Params.Offset) < 0 {
+		return openapi.GetChallengesId400JSONResponse{
+			BadRequestErrorJSONResponse: openapi.BadRequestErrorJSONResponse{
+				Message: "Limit and offset must not be negative.",
+			},
+		}, nil
+	}
+
+	format := negotiateFormat(request.Params.Format, request.Params.Accept)
+	if !lo.Contains(lo.Values(mediaTypeFormats), format) {
+		return openapi.GetChallengesId400JSONResponse{
+			BadRequestErrorJSONResponse: openapi.BadRequestErrorJSONResponse{
+				Message: "Unknown format.",
+			},
+		}, nil
+	}
+
 	response, err := s.dbrunnerService.RetrieveQuery(ctx, &connect.Request[dbrunnerv1.RetrieveQueryRequest]{
 		Msg: &dbrunnerv1.RetrieveQueryRequest{
 			Id: tc.ChallengeID,
+			Cursor: &commonv1.Cursor{
+				Limit:  request.Params.Limit,
+				Offset: request.Params.Offset,
+			},
 		},
 	})
-	if connect.CodeOf(err) == connect.CodeNotFound {
-		return openapi.GetChallengesId404JSONResponse{
-			NoSuchResourceErrorJSONResponse: openapi.NoSuchResourceErrorJSONResponse{
-				Message: "Challenge not found or is expired.",
-			},
-		}, nil
-	}
 	if err != nil {
-		s.logger.ErrorContext(ctx, "Failed to fetch challenge", slog.Any("error", err), slog.Any("request", request))
-		return openapi.GetChallengesId500JSONResponse{
-			ErrorJSONResponse: openapi.ErrorJSONResponse{
-				Message: "Failed to fetch challenge.",
-			},
-		}, nil
+		return s.challengeRetrieveError(ctx, request, err), nil
 	}
 
-	var header []string
-	var rows [][]*string
+	if format != openapi.Json {
+		return s.exportChallenge(ctx, request, response, format), nil
+	}
+
+	var header, columnTypes []string
+	// The page after the last row has no rows, which are still an array.
+	rows := [][]*string{}
+	cellTypes := [][]openapi.CellType{}
+	var truncated bool
+	var totalRows int64
 
 	for response.Receive() {
 		switch messageKind := response.Msg().Kind.(type) {
 		case *dbrunnerv1.RetrieveQueryResponse_Header:
 			header = messageKind.Header.GetHeader()
+			columnTypes = messageKind.Header.GetDeclaredTypes()
+			truncated = messageKind.Header.GetTruncated()
+			totalRows = messageKind.Header.GetTotalRows()
 		case *dbrunnerv1.RetrieveQueryResponse_Row:
-			var row []*string
-			cell := messageKind.Row.GetCells()
-			for _, cell := range cell {
-				row = append(row, cell.Value)
-			}
-			rows = append(rows, row)
+			rows = append(rows, cellsFromProto(messageKind.Row.GetCells()))
+			cellTypes = append(cellTypes, cellTypesFromProto(messageKind.Row.GetCells()))
 		}
 	}
 	if response.Err() != nil {
-		if connect.CodeOf(response.Err()) == connect.CodeNotFound {
-			return openapi.GetChallengesId404JSONResponse{
-				NoSuchResourceErrorJSONResponse: openapi.NoSuchResourceErrorJSONResponse{
-					Message: "Challenge not found or is expired.",
-				},
-			}, nil
-		}
+		return s.challengeRetrieveError(ctx, request, response.Err()), nil
+	}
 
-		s.logger.ErrorContext(ctx, "Failed to fetch challenge", slog.Any("error", response.Err()), slog.Any("request", request))
-		return openapi.GetChallengesId500JSONResponse{
-			ErrorJSONResponse: openapi.ErrorJSONResponse{
-				Message: "Failed to fetch challenge.",
-			},
-		}, nil
+	// The results cached before the types are recorded have no declared types.
+	if len(columnTypes) != len(header) {
+		columnTypes = make([]string, len(header))
 	}
 
 	return openapi.GetChallengesId200JSONResponse{
-		Header: header,
-		Rows:   rows,
+		Header:      header,
+		ColumnTypes: columnTypes,
+		Rows:        rows,
+		CellTypes:   cellTypes,
+		Truncated:   truncated,
+		TotalRows:   totalRows,
 	}, nil
 }
 
+// exportChallenge streams the result of the challenge in the format
+// other than JSON. The header is received before responding, so the
+// expired challenges are still responded with 404; the errors after
+// that cut the response short.
+func (s *Server) exportChallenge(ctx context.Context, request openapi.GetChallengesIdRequestObject, response *connect.ServerStreamForClient[dbrunnerv1.RetrieveQueryResponse], format openapi.GetChallengesIdParamsFormat) openapi.GetChallengesIdResponseObject {
+	if !response.Receive() {
+		err := response.Err()
+		if err == nil {
+			err = errors.New("missing header")
+		}
+		return s.challengeRetrieveError(ctx, request, err)
+	}
+	header := response.Msg().GetHeader()
+
+	body, pw := io.Pipe()
+	writer, _ := newResultWriter(pw, format, request.Params.Null)
+	go func() {
+		defer response.Close()
+
+		err := exportResult(response, header, writer)
+		if err != nil && !errors.Is(err, io.ErrClosedPipe) {
+			s.logger.ErrorContext(ctx, "Failed to export challenge", slog.Any("error", err), slog.Any("request", request))
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	switch format {
+	case openapi.Csv:
+		return openapi.GetChallengesId200TextcsvResponse{Body: body}
+	case openapi.Tsv:
+		return openapi.GetChallengesId200TexttabSeparatedValuesResponse{Body: body}
+	case openapi.Ndjson:
+		return openapi.GetChallengesId200ApplicationxNdjsonResponse{Body: body}
+	default:
+		return openapi.GetChallengesId200TextmarkdownResponse{Body: body}
+	}
+}
+
+// challengeRetrieveError responds the error of retrieving the result of a challenge.
+func (s *Server) challengeRetrieveError(ctx context.Context, request openapi.GetChallengesIdRequestObject, err error) openapi.GetChallengesIdResponseObject {
+	if connect.CodeOf(err) == connect.CodeNotFound {
+		return openapi.GetChallengesId404JSONResponse{
+			NoSuchResourceErrorJSONResponse: openapi.NoSuchResourceErrorJSONResponse{
+				Message: "Challenge not found or is expired.",
+			},
+		}
+	}
+
+	s.logger.ErrorContext(ctx, "Failed to fetch challenge", slog.Any("error", err), slog.Any("request", request))
+	return openapi.GetChallengesId500JSONResponse{
+		ErrorJSONResponse: openapi.ErrorJSONResponse{
+			Message: "Failed to fetch challenge.",
+		},
+	}
+}
+
 // PostChallenge implements openapi.StrictServerInterface.
 func (s *Server) PostChallenges(ctx context.Context, request openapi.PostChallengesRequestObject) (openapi.PostChallengesResponseObject, error) {
 	questionID, err := converter.StringToID(request.Body.QuestionID)
@@ -216,8 +534,9 @@ func (s *Server) PostChallenges(ctx context.Context, request openapi.PostChallen
 		}, nil
 	}
 
-	questionResponse, err := s.questionManagerService.GetQuestion(ctx, &connect.Request[questionmanagerv1.GetQuestionRequest]{
-		Msg: &questionmanagerv1.GetQuestionRequest{
+	// The answer contains the schema and how the challenge is graded.
+	answerResponse, err := s.questionManagerService.GetQuestionAnswer(ctx, &connect.Request[questionmanagerv1.GetQuestionAnswerRequest]{
+		Msg: &questionmanagerv1.GetQuestionAnswerRequest{
 			Id: questionID,
 		},
 	})
@@ -237,27 +556,23 @@ func (s *Server) PostChallenges(ctx context.Context, request openapi.PostChallen
 		}, nil
 	}
 
-	schemaInitialSQLResponse, err := s.questionManagerService.GetSchemaInitialSQL(ctx, &connect.Request[questionmanagerv1.GetSchemaInitialSQLRequest]{
-		Msg: &questionmanagerv1.GetSchemaInitialSQLRequest{
-			Id: questionResponse.Msg.GetQuestion().GetSchemaId(),
-		},
-	})
-	if err != nil {
-		s.logger.ErrorContext(ctx, "Failed to fetch initial SQL", slog.Any("error", err), slog.Any("request", request))
-		return openapi.PostChallenges500JSONResponse{
-			ErrorJSONResponse: openapi.ErrorJSONResponse{
-				Message: "Failed to fetch initial SQL.",
-			},
-		}, nil
-	}
-
 	// execute question
 	queryResponse, err := s.dbrunnerService.RunQuery(ctx, &connect.Request[dbrunnerv1.RunQueryRequest]{
 		Msg: &dbrunnerv1.RunQueryRequest{
-			Schema: schemaInitialSQLResponse.Msg.GetSchemaInitialSql().GetInitialSql(),
-			Query:  request.Body.Query,
+			Schema:       answerResponse.Msg.GetQuestionAnswer().GetSchema(),
+			Query:        request.Body.Query,
+			CaptureState: stateCapture(answerResponse.Msg.GetQuestionAnswer()),
+			Limits:       answerResponse.Msg.GetQuestionAnswer().GetLimits(),
+			SandboxAllow: answerResponse.Msg.GetQuestionAnswer().GetSandboxAllow(),
+			// The query is run against the hidden datasets when comparing.
+			KeepQuery: true,
 		},
 	})
+	if busy, ok := asTooManyQueries(err); ok {
+		return openapi.PostChallenges429JSONResponse{
+			TooManyRequestsErrorJSONResponse: busy.response(),
+		}, nil
+	}
 	if err != nil {
 		s.logger.ErrorContext(ctx, "Failed to execute query", slog.Any("error", err), slog.Any("request", request))
 		return openapi.PostChallenges500JSONResponse{
@@ -266,10 +581,10 @@ func (s *Server) PostChallenges(ctx context.Context, request openapi.PostChallen
 			},
 		}, nil
 	}
-	if queryResponse.Msg.GetError() != "" {
+	if errMessage := queryError(queryResponse.Msg); errMessage != "" {
 		return openapi.PostChallenges422JSONResponse{
 			UnprocessableEntityErrorJSONResponse: openapi.UnprocessableEntityErrorJSONResponse{
-				Message: queryResponse.Msg.GetError(),
+				Message: errMessage,
 			},
 		}, nil
 	}
@@ -280,9 +595,14 @@ func (s *Server) PostChallenges(ctx context.Context, request openapi.PostChallen
 		ChallengeID: queryResponse.Msg.GetId(),
 	})
 
-	return openapi.PostChallenges200JSONResponse{
+	response := openapi.PostChallenges200JSONResponse{
 		ChallengeID: base64ChallengeID,
-	}, nil
+	}
+	if queryResponse.Msg.GetExpiresAt() != nil {
+		response.ExpiresAt = lo.ToPtr(queryResponse.Msg.GetExpiresAt().AsTime())
+	}
+
+	return response, nil
 }
 
 // GetChallengesIdCompare implements openapi.StrictServerInterface.
@@ -296,54 +616,32 @@ func (s *Server) GetChallengesIdCompare(ctx context.Context, request openapi.Get
 		}, nil
 	}
 
-	answer, err := s.questionManagerService.GetQuestionAnswer(ctx, &connect.Request[questionmanagerv1.GetQuestionAnswerRequest]{
-		Msg: &questionmanagerv1.GetQuestionAnswerRequest{
-			Id: tc.QuestionID,
-		},
-	})
-	if connect.CodeOf(err) == connect.CodeNotFound {
+	answer, err := s.runAnswer(ctx, tc.QuestionID)
+	if errors.Is(err, errAnswerNotFound) {
 		return openapi.GetChallengesIdCompare404JSONResponse{
 			NoSuchResourceErrorJSONResponse: openapi.NoSuchResourceErrorJSONResponse{
-				Message: "Answer not found.",
+				Message: answerErrorMessage(err),
 			},
 		}, nil
 	}
-	if err != nil {
-		s.logger.ErrorContext(ctx, "Failed to fetch answer", slog.Any("error", err), slog.Any("request", request))
-		return openapi.GetChallengesIdCompare500JSONResponse{
-			ErrorJSONResponse: openapi.ErrorJSONResponse{
-				Message: "Failed to fetch answer.",
-			},
+	if busy, ok := asTooManyQueries(err); ok {
+		return openapi.GetChallengesIdCompare429JSONResponse{
+			TooManyRequestsErrorJSONResponse: busy.response(),
 		}, nil
 	}
-
-	answerResponse, err := s.dbrunnerService.RunQuery(ctx, &connect.Request[dbrunnerv1.RunQueryRequest]{
-		Msg: &dbrunnerv1.RunQueryRequest{
-			Schema: answer.Msg.QuestionAnswer.GetSchema(),
-			Query:  answer.Msg.QuestionAnswer.GetAnswer(),
-		},
-	})
 	if err != nil {
-		s.logger.ErrorContext(ctx, "Failed to execute answer", slog.Any("error", err), slog.Any("request", request), slog.Any("answer", answer.Msg.GetQuestionAnswer()))
-		return openapi.GetChallengesIdCompare500JSONResponse{
-			ErrorJSONResponse: openapi.ErrorJSONResponse{
-				Message: "Failed to execute answer. The answer is incorrect.",
-			},
-		}, nil
-	}
-	if answerResponse.Msg.GetError() != "" {
-		s.logger.ErrorContext(ctx, "Failed to execute answer", slog.Any("error", answerResponse.Msg.GetError()), slog.Any("request", request), slog.Any("answer", answer.Msg.GetQuestionAnswer()))
 		return openapi.GetChallengesIdCompare500JSONResponse{
 			ErrorJSONResponse: openapi.ErrorJSONResponse{
-				Message: "Failed to execute answer. The answer is incorrect.",
+				Message: answerErrorMessage(err),
 			},
 		}, nil
 	}
 
 	sameResponse, err := s.dbrunnerService.AreQueriesOutputSame(ctx, &connect.Request[dbrunnerv1.AreQueriesOutputSameRequest]{
 		Msg: &dbrunnerv1.AreQueriesOutputSameRequest{
-			LeftId:  answerResponse.Msg.GetId(),
+			LeftId:  answer.ID,
 			RightId: tc.ChallengeID,
+			Options: answer.CompareOptions,
 		},
 	})
 	if err != nil {
@@ -355,18 +653,531 @@ func (s *Server) GetChallengesIdCompare(ctx context.Context, request openapi.Get
 		}, nil
 	}
 
-	return openapi.GetChallengesIdCompare200JSONResponse{
-		Same: sameResponse.Msg.GetSame(),
-	}, nil
-}
+	var passed, failed int64
+	if sameResponse.Msg.GetSame() {
+		passed++
+	} else {
+		failed++
+	}
 
-// #region Schema
+	if len(answer.Datasets) > 0 {
+		// The query of the challenge is kept by the runner, and must
+		// still be the query of the challenge on the schema of the question.
+		queryResponse, err := s.dbrunnerService.GetQuery(ctx, &connect.Request[dbrunnerv1.GetQueryRequest]{
+			Msg: &dbrunnerv1.GetQueryRequest{
+				Id: tc.ChallengeID,
+			},
+		})
+		if connect.CodeOf(err) == connect.CodeNotFound {
+			return openapi.GetChallengesIdCompare400JSONResponse{
+				BadRequestErrorJSONResponse: openapi.BadRequestErrorJSONResponse{
+					Message: "Invalid or outdated challenge ID. Please submit the challenge again.",
+				},
+			}, nil
+		}
+		if err != nil {
+			s.logger.ErrorContext(ctx, "Failed to get the query of the challenge", slog.Any("error", err), slog.Any("request", request))
+			return openapi.GetChallengesIdCompare500JSONResponse{
+				ErrorJSONResponse: openapi.ErrorJSONResponse{
+					Message: "Failed to compare answers.",
+				},
+			}, nil
+		}
+		query := queryResponse.Msg.GetQuery()
+
+		challengeResponse, err := s.dbrunnerService.RunQuery(ctx, &connect.Request[dbrunnerv1.RunQueryRequest]{
+			Msg: &dbrunnerv1.RunQueryRequest{
+				Schema:       answer.Schema,
+				Query:        query,
+				CaptureState: answer.StateCapture,
+				Limits:       answer.Limits,
+				SandboxAllow: answer.SandboxAllow,
+			},
+		})
+		if busy, ok := asTooManyQueries(err); ok {
+			return openapi.GetChallengesIdCompare429JSONResponse{
+				TooManyRequestsErrorJSONResponse: busy.response(),
+			}, nil
+		}
+		if err != nil || challengeResponse.Msg.GetId() != tc.ChallengeID {
+			return openapi.GetChallengesIdCompare400JSONResponse{
+				BadRequestErrorJSONResponse: openapi.BadRequestErrorJSONResponse{
+					Message: "Invalid or outdated challenge ID. Please submit the challenge again.",
+				},
+			}, nil
+		}
+
+		for _, dataset := range answer.Datasets {
+			same, err := s.compareOnDataset(ctx, answer, dataset, query)
+			if busy, ok := asTooManyQueries(err); ok {
+				return openapi.GetChallengesIdCompare429JSONResponse{
+					TooManyRequestsErrorJSONResponse: busy.response(),
+				}, nil
+			}
+			if err != nil {
+				return openapi.GetChallengesIdCompare500JSONResponse{
+					ErrorJSONResponse: openapi.ErrorJSONResponse{
+						Message: answerErrorMessage(err),
+					},
+				}, nil
+			}
+
+			if same {
+				passed++
+			} else {
+				failed++
+			}
+		}
+	}
+
+	return openapi.GetChallengesIdCompare200JSONResponse{
+		Same:   failed == 0,
+		Passed: passed,
+		Failed: failed,
+	}, nil
+}
+
+var errCompareFailed = errors.New("failed to compare answers")
+
+// compareOnDataset runs the answer and the query of the challenge
+// against the hidden dataset, and returns whether the results are the same.
+//
+// The query failed on the dataset is considered different. Errors are
+// logged; use [answerErrorMessage] to get the user-facing message.
+func (s *Server) compareOnDataset(ctx context.Context, answer *answerRun, dataset *questionmanagerv1.QuestionDataset, query string) (bool, error) {
+	answerResponse, err := s.dbrunnerService.RunQuery(ctx, &connect.Request[dbrunnerv1.RunQueryRequest]{
+		Msg: &dbrunnerv1.RunQueryRequest{
+			Schema:       dataset.GetSchema(),
+			Query:        answer.Query,
+			CaptureState: answer.StateCapture,
+			Limits:       answer.Limits,
+			SandboxAllow: answer.SandboxAllow,
+			Pin:          true,
+		},
+	})
+	if busy, ok := asTooManyQueries(err); ok {
+		return false, busy
+	}
+	if err == nil && queryError(answerResponse.Msg) != "" {
+		err = errors.New(queryError(answerResponse.Msg))
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to execute answer on dataset", slog.Any("error", err), slog.Int64("datasetID", dataset.GetId()))
+		return false, errAnswerFailed
+	}
+
+	challengeResponse, err := s.dbrunnerService.RunQuery(ctx, &connect.Request[dbrunnerv1.RunQueryRequest]{
+		Msg: &dbrunnerv1.RunQueryRequest{
+			Schema:       dataset.GetSchema(),
+			Query:        query,
+			CaptureState: answer.StateCapture,
+			Limits:       answer.Limits,
+			SandboxAllow: answer.SandboxAllow,
+		},
+	})
+	if busy, ok := asTooManyQueries(err); ok {
+		return false, busy
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to execute query on dataset", slog.Any("error", err), slog.Int64("datasetID", dataset.GetId()))
+		return false, errCompareFailed
+	}
+	if queryError(challengeResponse.Msg) != "" {
+		return false, nil
+	}
+
+	sameResponse, err := s.dbrunnerService.AreQueriesOutputSame(ctx, &connect.Request[dbrunnerv1.AreQueriesOutputSameRequest]{
+		Msg: &dbrunnerv1.AreQueriesOutputSameRequest{
+			LeftId:  answerResponse.Msg.GetId(),
+			RightId: challengeResponse.Msg.GetId(),
+			Options: answer.CompareOptions,
+		},
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to compare answers on dataset", slog.Any("error", err), slog.Int64("datasetID", dataset.GetId()))
+		return false, errCompareFailed
+	}
+
+	return sameResponse.Msg.GetSame(), nil
+}
+
+// diffSampleSize is the maximum number of missing and extra rows
+// revealed with the sample diff policy, respectively.
+const diffSampleSize = 5
+
+// GetChallengesIdDiff implements openapi.StrictServerInterface.
+//
+// The diff explains the result on the schema of the question only, and
+// does not reveal the hidden datasets.
+func (s *Server) GetChallengesIdDiff(ctx context.Context, request openapi.GetChallengesIdDiffRequestObject) (openapi.GetChallengesIdDiffResponseObject, error) {
+	tc, err := converter.DecodeChallengeID(request.Id)
+	if err != nil || tc == nil {
+		return openapi.GetChallengesIdDiff400JSONResponse{
+			BadRequestErrorJSONResponse: openapi.BadRequestErrorJSONResponse{
+				Message: "Invalid challenge ID.",
+			},
+		}, nil
+	}
+
+	answer, err := s.runAnswer(ctx, tc.QuestionID)
+	if errors.Is(err, errAnswerNotFound) {
+		return openapi.GetChallengesIdDiff404JSONResponse{
+			NoSuchResourceErrorJSONResponse: openapi.NoSuchResourceErrorJSONResponse{
+				Message: answerErrorMessage(err),
+			},
+		}, nil
+	}
+	if busy, ok := asTooManyQueries(err); ok {
+		return openapi.GetChallengesIdDiff429JSONResponse{
+			TooManyRequestsErrorJSONResponse: busy.response(),
+		}, nil
+	}
+	if err != nil {
+		return openapi.GetChallengesIdDiff500JSONResponse{
+			ErrorJSONResponse: openapi.ErrorJSONResponse{
+				Message: answerErrorMessage(err),
+			},
+		}, nil
+	}
+
+	// The summary policy is used if the teacher does not specify one.
+	policy := answer.DiffPolicy
+	if policy == questionmanagerv1.DiffPolicy_DIFF_POLICY_UNSPECIFIED {
+		policy = questionmanagerv1.DiffPolicy_DIFF_POLICY_SUMMARY
+	}
+
+	// The rows of the answer are only needed to be revealed as the samples.
+	var maxRows int64
+	if policy == questionmanagerv1.DiffPolicy_DIFF_POLICY_SAMPLE {
+		maxRows = diffSampleSize
+	}
+
+	response, err := s.dbrunnerService.DiffQuery(ctx, &connect.Request[dbrunnerv1.DiffQueryRequest]{
+		Msg: &dbrunnerv1.DiffQueryRequest{
+			LeftId:  answer.ID,
+			RightId: tc.ChallengeID,
+			Options: answer.CompareOptions,
+			MaxRows: &maxRows,
+		},
+	})
+	if connect.CodeOf(err) == connect.CodeNotFound {
+		return openapi.GetChallengesIdDiff404JSONResponse{
+			NoSuchResourceErrorJSONResponse: openapi.NoSuchResourceErrorJSONResponse{
+				Message: "Challenge not found or is expired.",
+			},
+		}, nil
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to diff answers", slog.Any("error", err), slog.Any("request", request))
+		return openapi.GetChallengesIdDiff500JSONResponse{
+			ErrorJSONResponse: openapi.ErrorJSONResponse{
+				Message: "Failed to diff answers.",
+			},
+		}, nil
+	}
+
+	var summary *dbrunnerv1.DiffSummary
+	var header *dbrunnerv1.HeaderDiff
+	var missingRows, extraRows [][]*string
+	changedColumns := map[int64]struct{}{}
+
+	for response.Receive() {
+		switch messageKind := response.Msg().Kind.(type) {
+		case *dbrunnerv1.DiffQueryResponse_Summary:
+			summary = messageKind.Summary
+		case *dbrunnerv1.DiffQueryResponse_Header:
+			header = messageKind.Header
+		case *dbrunnerv1.DiffQueryResponse_MissingRow:
+			if len(missingRows) < diffSampleSize {
+				missingRows = append(missingRows, cellsFromProto(messageKind.MissingRow.GetCells()))
+			}
+		case *dbrunnerv1.DiffQueryResponse_ExtraRow:
+			if len(extraRows) < diffSampleSize {
+				extraRows = append(extraRows, cellsFromProto(messageKind.ExtraRow.GetCells()))
+			}
+		case *dbrunnerv1.DiffQueryResponse_Cell:
+			changedColumns[messageKind.Cell.GetColumn()] = struct{}{}
+		}
+	}
+	if response.Err() != nil || summary == nil {
+		if connect.CodeOf(response.Err()) == connect.CodeNotFound {
+			return openapi.GetChallengesIdDiff404JSONResponse{
+				NoSuchResourceErrorJSONResponse: openapi.NoSuchResourceErrorJSONResponse{
+					Message: "Challenge not found or is expired.",
+				},
+			}, nil
+		}
+
+		s.logger.ErrorContext(ctx, "Failed to diff answers", slog.Any("error", response.Err()), slog.Any("request", request))
+		return openapi.GetChallengesIdDiff500JSONResponse{
+			ErrorJSONResponse: openapi.ErrorJSONResponse{
+				Message: "Failed to diff answers.",
+			},
+		}, nil
+	}
+
+	diffResponse := openapi.GetChallengesIdDiff200JSONResponse{
+		Same:   summary.GetSame(),
+		Policy: openapi.None,
+	}
+
+	if policy == questionmanagerv1.DiffPolicy_DIFF_POLICY_SUMMARY || policy == questionmanagerv1.DiffPolicy_DIFF_POLICY_SAMPLE {
+		missingColumns, extraColumns := lo.Difference(header.GetLeft(), header.GetRight())
+
+		// The changed columns are named by the header of the challenge, if available.
+		changedColumnNames := make([]string, 0, len(changedColumns))
+		for column := range changedColumns {
+			if column < int64(len(header.GetRight())) && header.GetRight()[column] != "" {
+				changedColumnNames = append(changedColumnNames, header.GetRight()[column])
+			} else {
+				changedColumnNames = append(changedColumnNames, "#"+strconv.FormatInt(column, 10))
+			}
+		}
+		slices.Sort(changedColumnNames)
+
+		diffResponse.Policy = openapi.Summary
+		diffResponse.ExpectedRowCount = lo.ToPtr(summary.GetLeftRowCount())
+		diffResponse.ActualRowCount = lo.ToPtr(summary.GetRightRowCount())
+		diffResponse.OrderMismatch = lo.ToPtr(summary.GetOrderMismatch())
+		if header.GetTable() != "" {
+			diffResponse.Table = lo.ToPtr(header.GetTable())
+		}
+		diffResponse.MissingColumns = &missingColumns
+		diffResponse.ExtraColumns = &extraColumns
+		diffResponse.ChangedColumns = &changedColumnNames
+	}
+
+	if policy == questionmanagerv1.DiffPolicy_DIFF_POLICY_SAMPLE {
+		diffResponse.Policy = openapi.Sample
+		diffResponse.MissingRows = &missingRows
+		diffResponse.ExtraRows = &extraRows
+	}
+
+	return diffResponse, nil
+}
+
+var (
+	errAnswerNotFound    = errors.New("answer not found")
+	errAnswerFetchFailed = errors.New("failed to fetch answer")
+	errAnswerFailed      = errors.New("failed to execute answer")
+)
+
+// answerErrorMessage returns the user-facing message of the error returned by [Server.runAnswer].
+func answerErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, errAnswerNotFound):
+		return "Answer not found."
+	case errors.Is(err, errAnswerFetchFailed):
+		return "Failed to fetch answer."
+	case errors.Is(err, errCompareFailed):
+		return "Failed to compare answers."
+	default:
+		return "Failed to execute answer. The answer is incorrect."
+	}
+}
+
+// tooManyQueriesError is returned when the dbrunner service
+// has too many queries to run the query.
+type tooManyQueriesError struct {
+	// RetryAfter is the seconds to wait before retrying.
+	RetryAfter int
+}
+
+func (e *tooManyQueriesError) Error() string {
+	return "too many queries are running"
+}
+
+func (e *tooManyQueriesError) response() openapi.TooManyRequestsErrorJSONResponse {
+	return openapi.TooManyRequestsErrorJSONResponse{
+		Body: openapi.Error{
+			Message: "Too many queries are running. Please retry later.",
+		},
+		Headers: openapi.TooManyRequestsErrorResponseHeaders{
+			RetryAfter: e.RetryAfter,
+		},
+	}
+}
+
+// asTooManyQueries returns the [tooManyQueriesError] in the error, or
+// converts the RESOURCE_EXHAUSTED error of the dbrunner service to one.
+func asTooManyQueries(err error) (*tooManyQueriesError, bool) {
+	var busy *tooManyQueriesError
+	if errors.As(err, &busy) {
+		return busy, true
+	}
+
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) || connectErr.Code() != connect.CodeResourceExhausted {
+		return nil, false
+	}
+
+	retryAfter, err := strconv.Atoi(connectErr.Meta().Get("Retry-After"))
+	if err != nil || retryAfter <= 0 {
+		retryAfter = 1
+	}
+
+	return &tooManyQueriesError{RetryAfter: retryAfter}, true
+}
+
+// answerRun is the result of running the answer of a question.
+type answerRun struct {
+	// ID is the query ID of the answer in the dbrunner service.
+	ID             string
+	DiffPolicy     questionmanagerv1.DiffPolicy
+	CompareOptions *commonv1.CompareOptions
+
+	// Schema and Query are the schema and the answer of the question.
+	Schema string
+	Query  string
+	// StateCapture is the state capturing option of the question,
+	// which the queries compared with the answer must be run with.
+	StateCapture *dbrunnerv1.StateCapture
+	// Datasets are the hidden datasets of the question.
+	Datasets []*questionmanagerv1.QuestionDataset
+	// Limits are the execution limits of the question.
+	Limits *commonv1.ExecutionLimits
+	// SandboxAllow lists the sandboxed operations the question allows.
+	SandboxAllow []commonv1.SandboxPermission
+}
+
+// runAnswer runs the answer of the question and returns its query ID.
+//
+// It returns errAnswerNotFound if the question does not exist. Other errors
+// are logged; use [answerErrorMessage] to get the user-facing message.
+func (s *Server) runAnswer(ctx context.Context, questionID int64) (*answerRun, error) {
+	answer, err := s.questionManagerService.GetQuestionAnswer(ctx, &connect.Request[questionmanagerv1.GetQuestionAnswerRequest]{
+		Msg: &questionmanagerv1.GetQuestionAnswerRequest{
+			Id: questionID,
+		},
+	})
+	if connect.CodeOf(err) == connect.CodeNotFound {
+		return nil, errAnswerNotFound
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to fetch answer", slog.Any("error", err), slog.Int64("questionID", questionID))
+		return nil, errAnswerFetchFailed
+	}
+
+	answerResponse, err := s.dbrunnerService.RunQuery(ctx, &connect.Request[dbrunnerv1.RunQueryRequest]{
+		Msg: &dbrunnerv1.RunQueryRequest{
+			Schema:       answer.Msg.QuestionAnswer.GetSchema(),
+			Query:        answer.Msg.QuestionAnswer.GetAnswer(),
+			CaptureState: stateCapture(answer.Msg.GetQuestionAnswer()),
+			Limits:       answer.Msg.GetQuestionAnswer().GetLimits(),
+			SandboxAllow: answer.Msg.GetQuestionAnswer().GetSandboxAllow(),
+			Pin:          true,
+		},
+	})
+	if busy, ok := asTooManyQueries(err); ok {
+		return nil, busy
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to execute answer", slog.Any("error", err), slog.Int64("questionID", questionID), slog.Any("answer", answer.Msg.GetQuestionAnswer()))
+		return nil, errAnswerFailed
+	}
+	if errMessage := queryError(answerResponse.Msg); errMessage != "" {
+		s.logger.ErrorContext(ctx, "Failed to execute answer", slog.Any("error", errMessage), slog.Int64("questionID", questionID), slog.Any("answer", answer.Msg.GetQuestionAnswer()))
+		return nil, errAnswerFailed
+	}
+
+	// The states are compared instead of the results if the question grades by state.
+	compareOptions := answer.Msg.GetQuestionAnswer().GetCompareOptions()
+	if answer.Msg.GetQuestionAnswer().GetGradeByState() {
+		if compareOptions != nil {
+			compareOptions = proto.Clone(compareOptions).(*commonv1.CompareOptions)
+		} else {
+			compareOptions = &commonv1.CompareOptions{}
+		}
+		compareOptions.CompareState = true
+	}
+
+	return &answerRun{
+		ID:             answerResponse.Msg.GetId(),
+		DiffPolicy:     answer.Msg.GetQuestionAnswer().GetDiffPolicy(),
+		CompareOptions: compareOptions,
+		Schema:         answer.Msg.GetQuestionAnswer().GetSchema(),
+		Query:          answer.Msg.GetQuestionAnswer().GetAnswer(),
+		StateCapture:   stateCapture(answer.Msg.GetQuestionAnswer()),
+		Datasets:       answer.Msg.GetQuestionAnswer().GetDatasets(),
+		Limits:         answer.Msg.GetQuestionAnswer().GetLimits(),
+		SandboxAllow:   answer.Msg.GetQuestionAnswer().GetSandboxAllow(),
+	}, nil
+}
+
+// queryError returns the error message of the query, including the
+// exceeded limit. It is empty if the query succeeded.
+func queryError(response *dbrunnerv1.RunQueryResponse) string {
+	if limitExceeded := response.GetLimitExceeded(); limitExceeded != nil {
+		return limitExceeded.GetMessage()
+	}
+
+	return response.GetError()
+}
+
+// stateCapture returns the tables to capture after running the queries
+// of the question. It is nil if the question does not grade by state.
+func stateCapture(answer *questionmanagerv1.QuestionAnswer) *dbrunnerv1.StateCapture {
+	if !answer.GetGradeByState() {
+		return nil
+	}
+
+	return &dbrunnerv1.StateCapture{
+		Tables: answer.GetStateTables(),
+	}
+}
+
+// cellsFromProto converts the protobuf cells to the nullable strings.
+func cellsFromProto(cells []*dbrunnerv1.Cell) []*string {
+	row := make([]*string, len(cells))
+	for i, cell := range cells {
+		row[i] = cell.Value
+	}
+	return row
+}
+
+// cellTypesFromProto converts the types of the protobuf cells.
+func cellTypesFromProto(cells []*dbrunnerv1.Cell) []openapi.CellType {
+	types := make([]openapi.CellType, len(cells))
+	for i, cell := range cells {
+		types[i] = cellTypeFromProto(cell.GetType())
+	}
+	return types
+}
+
+func cellTypeFromProto(cellType dbrunnerv1.CellType) openapi.CellType {
+	switch cellType {
+	case dbrunnerv1.CellType_CELL_TYPE_NULL:
+		return openapi.Null
+	case dbrunnerv1.CellType_CELL_TYPE_INTEGER:
+		return openapi.Integer
+	case dbrunnerv1.CellType_CELL_TYPE_REAL:
+		return openapi.Real
+	case dbrunnerv1.CellType_CELL_TYPE_TEXT:
+		return openapi.Text
+	case dbrunnerv1.CellType_CELL_TYPE_BLOB:
+		return openapi.Blob
+	default:
+		return openapi.Unknown
+	}
+}
+
+// #region Schema
 
 // GetSchemasId implements StrictServerInterface.
 func (s *Server) GetSchemasId(ctx context.Context, request openapi.GetSchemasIdRequestObject) (openapi.GetSchemasIdResponseObject, error) {
+	includeDeleted := lo.FromPtr(request.Params.IncludeDeleted)
+	if includeDeleted && !hasScope(ctx, "write:resource") {
+		return openapi.GetSchemasId401JSONResponse{
+			UnauthorizedErrorJSONResponse: openapi.UnauthorizedErrorJSONResponse{
+				Message: includeDeletedScopeMessage,
+			},
+		}, nil
+	}
+
 	response, err := s.questionManagerService.GetSchema(ctx, &connect.Request[questionmanagerv1.GetSchemaRequest]{
 		Msg: &questionmanagerv1.GetSchemaRequest{
-			Id: request.Id,
+			Id:             request.Id,
+			IncludeDeleted: includeDeleted,
 		},
 	})
 	if connect.CodeOf(err) == connect.CodeNotFound {
@@ -390,3 +1201,139 @@ func (s *Server) GetSchemasId(ctx context.Context, request openapi.GetSchemasIdR
 
 	return openapi.GetSchemasId200JSONResponse(schemaResponse), nil
 }
+
+// PostSchemas implements openapi.StrictServerInterface.
+func (s *Server) PostSchemas(ctx context.Context, request openapi.PostSchemasRequestObject) (openapi.PostSchemasResponseObject, error) {
+	response, err := s.questionManagerService.CreateSchema(ctx, &connect.Request[questionmanagerv1.CreateSchemaRequest]{
+		Msg: &questionmanagerv1.CreateSchemaRequest{
+			Id:     lo.FromPtr(request.Body.Id),
+			Schema: schemaInputToProto(request.Body),
+		},
+	})
+	switch connect.CodeOf(err) {
+	case connect.CodeInvalidArgument:
+		return openapi.PostSchemas400JSONResponse{
+			BadRequestErrorJSONResponse: openapi.BadRequestErrorJSONResponse{
+				Message: "Invalid schema: " + connectErrorMessage(err),
+			},
+		}, nil
+	case connect.CodeAlreadyExists:
+		return openapi.PostSchemas409JSONResponse{
+			ConflictErrorJSONResponse: openapi.ConflictErrorJSONResponse{
+				Message: "Schema already exists.",
+			},
+		}, nil
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to create schema", slog.Any("error", err), slog.Any("request", request))
+		return openapi.PostSchemas500JSONResponse{
+			ErrorJSONResponse: openapi.ErrorJSONResponse{
+				Message: "Failed to create schema.",
+			},
+		}, nil
+	}
+
+	schemaModel := s.pbConverter.SchemaFromProto(response.Msg.GetSchema())
+	schemaResponse := s.modelConverter.SchemaFromModel(schemaModel)
+
+	return openapi.PostSchemas201JSONResponse(schemaResponse), nil
+}
+
+// PutSchemasId implements openapi.StrictServerInterface.
+func (s *Server) PutSchemasId(ctx context.Context, request openapi.PutSchemasIdRequestObject) (openapi.PutSchemasIdResponseObject, error) {
+	response, err := s.questionManagerService.UpdateSchema(ctx, &connect.Request[questionmanagerv1.UpdateSchemaRequest]{
+		Msg: &questionmanagerv1.UpdateSchemaRequest{
+			Id:     request.Id,
+			Schema: schemaInputToProto(request.Body),
+		},
+	})
+	switch connect.CodeOf(err) {
+	case connect.CodeInvalidArgument:
+		return openapi.PutSchemasId400JSONResponse{
+			BadRequestErrorJSONResponse: openapi.BadRequestErrorJSONResponse{
+				Message: "Invalid schema: " + connectErrorMessage(err),
+			},
+		}, nil
+	case connect.CodeNotFound:
+		return openapi.PutSchemasId404JSONResponse{
+			NoSuchResourceErrorJSONResponse: openapi.NoSuchResourceErrorJSONResponse{
+				Message: "Schema not found.",
+			},
+		}, nil
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to update schema", slog.Any("error", err), slog.Any("request", request))
+		return openapi.PutSchemasId500JSONResponse{
+			ErrorJSONResponse: openapi.ErrorJSONResponse{
+				Message: "Failed to update schema.",
+			},
+		}, nil
+	}
+
+	schemaModel := s.pbConverter.SchemaFromProto(response.Msg.GetSchema())
+	schemaResponse := s.modelConverter.SchemaFromModel(schemaModel)
+
+	return openapi.PutSchemasId200JSONResponse(schemaResponse), nil
+}
+
+// DeleteSchemasId implements openapi.StrictServerInterface.
+func (s *Server) DeleteSchemasId(ctx context.Context, request openapi.DeleteSchemasIdRequestObject) (openapi.DeleteSchemasIdResponseObject, error) {
+	_, err := s.questionManagerService.DeleteSchema(ctx, &connect.Request[questionmanagerv1.DeleteSchemaRequest]{
+		Msg: &questionmanagerv1.DeleteSchemaRequest{
+			Id: request.Id,
+		},
+	})
+	switch connect.CodeOf(err) {
+	case connect.CodeNotFound:
+		return openapi.DeleteSchemasId404JSONResponse{
+			NoSuchResourceErrorJSONResponse: openapi.NoSuchResourceErrorJSONResponse{
+				Message: "Schema not found.",
+			},
+		}, nil
+	case connect.CodeFailedPrecondition:
+		return openapi.DeleteSchemasId409JSONResponse{
+			ConflictErrorJSONResponse: openapi.ConflictErrorJSONResponse{
+				Message: "Schema is used by some questions.",
+			},
+		}, nil
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to delete schema", slog.Any("error", err), slog.Any("request", request))
+		return openapi.DeleteSchemasId500JSONResponse{
+			ErrorJSONResponse: openapi.ErrorJSONResponse{
+				Message: "Failed to delete schema.",
+			},
+		}, nil
+	}
+
+	return openapi.DeleteSchemasId204Response{}, nil
+}
+
+// PostSchemasIdRestore implements openapi.StrictServerInterface.
+func (s *Server) PostSchemasIdRestore(ctx context.Context, request openapi.PostSchemasIdRestoreRequestObject) (openapi.PostSchemasIdRestoreResponseObject, error) {
+	response, err := s.questionManagerService.RestoreSchema(ctx, &connect.Request[questionmanagerv1.RestoreSchemaRequest]{
+		Msg: &questionmanagerv1.RestoreSchemaRequest{
+			Id: request.Id,
+		},
+	})
+	if connect.CodeOf(err) == connect.CodeNotFound {
+		return openapi.PostSchemasIdRestore404JSONResponse{
+			NoSuchResourceErrorJSONResponse: openapi.NoSuchResourceErrorJSONResponse{
+				Message: "Deleted schema not found.",
+			},
+		}, nil
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to restore schema", slog.Any("error", err), slog.Any("request", request))
+		return openapi.PostSchemasIdRestore500JSONResponse{
+			ErrorJSONResponse: openapi.ErrorJSONResponse{
+				Message: "Failed to restore schema.",
+			},
+		}, nil
+	}
+
+	schemaModel := s.pbConverter.SchemaFromProto(response.Msg.GetSchema())
+	schemaResponse := s.modelConverter.SchemaFromModel(schemaModel)
+
+	return openapi.PostSchemasIdRestore200JSONResponse(schemaResponse), nil
+}
@@ -0,0 +1,622 @@
+package gatewayservice
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"connectrpc.com/connect"
+	dbrunnerv1 "github.com/database-playground/backend/gen/dbrunner/v1"
+	"github.com/database-playground/backend/gen/dbrunner/v1/dbrunnerv1connect"
+	questionmanagerv1 "github.com/database-playground/backend/gen/questionmanager/v1"
+	"github.com/database-playground/backend/gen/questionmanager/v1/questionmanagerv1connect"
+	"github.com/database-playground/backend/internal/services/gateway/converter"
+	"github.com/database-playground/backend/internal/services/gateway/openapi"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeQuestionManager is a question manager service whose RPCs are
+// the functions. The RPCs without a function are unimplemented.
+type fakeQuestionManager struct {
+	questionmanagerv1connect.UnimplementedQuestionManagerServiceHandler
+
+	getQuestionAnswer func(*questionmanagerv1.GetQuestionAnswerRequest) (*questionmanagerv1.GetQuestionAnswerResponse, error)
+}
+
+func (f *fakeQuestionManager) GetQuestionAnswer(ctx context.Context, request *connect.Request[questionmanagerv1.GetQuestionAnswerRequest]) (*connect.Response[questionmanagerv1.GetQuestionAnswerResponse], error) {
+	if f.getQuestionAnswer == nil {
+		return f.UnimplementedQuestionManagerServiceHandler.GetQuestionAnswer(ctx, request)
+	}
+	return respond(f.getQuestionAnswer(request.Msg))
+}
+
+// fakeDBRunner is a dbrunner service whose RPCs are the functions.
+// The RPCs without a function are unimplemented.
+type fakeDBRunner struct {
+	dbrunnerv1connect.UnimplementedDbRunnerServiceHandler
+
+	runQuery             func(*dbrunnerv1.RunQueryRequest) (*dbrunnerv1.RunQueryResponse, error)
+	getQuery             func(*dbrunnerv1.GetQueryRequest) (*dbrunnerv1.GetQueryResponse, error)
+	areQueriesOutputSame func(*dbrunnerv1.AreQueriesOutputSameRequest) (*dbrunnerv1.AreQueriesOutputSameResponse, error)
+	diffQuery            func(*dbrunnerv1.DiffQueryRequest) ([]*dbrunnerv1.DiffQueryResponse, error)
+}
+
+func (f *fakeDBRunner) RunQuery(ctx context.Context, request *connect.Request[dbrunnerv1.RunQueryRequest]) (*connect.Response[dbrunnerv1.RunQueryResponse], error) {
+	if f.runQuery == nil {
+		return f.UnimplementedDbRunnerServiceHandler.RunQuery(ctx, request)
+	}
+	return respond(f.runQuery(request.Msg))
+}
+
+func (f *fakeDBRunner) GetQuery(ctx context.Context, request *connect.Request[dbrunnerv1.GetQueryRequest]) (*connect.Response[dbrunnerv1.GetQueryResponse], error) {
+	if f.getQuery == nil {
+		return f.UnimplementedDbRunnerServiceHandler.GetQuery(ctx, request)
+	}
+	return respond(f.getQuery(request.Msg))
+}
+
+func (f *fakeDBRunner) AreQueriesOutputSame(ctx context.Context, request *connect.Request[dbrunnerv1.AreQueriesOutputSameRequest]) (*connect.Response[dbrunnerv1.AreQueriesOutputSameResponse], error) {
+	if f.areQueriesOutputSame == nil {
+		return f.UnimplementedDbRunnerServiceHandler.AreQueriesOutputSame(ctx, request)
+	}
+	return respond(f.areQueriesOutputSame(request.Msg))
+}
+
+func (f *fakeDBRunner) DiffQuery(ctx context.Context, request *connect.Request[dbrunnerv1.DiffQueryRequest], stream *connect.ServerStream[dbrunnerv1.DiffQueryResponse]) error {
+	if f.diffQuery == nil {
+		return f.UnimplementedDbRunnerServiceHandler.DiffQuery(ctx, request, stream)
+	}
+
+	responses, err := f.diffQuery(request.Msg)
+	if err != nil {
+		return err
+	}
+	for _, response := range responses {
+		if err := stream.Send(response); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func respond[T any](msg *T, err error) (*connect.Response[T], error) {
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(msg), nil
+}
+
+// failingQuestionManager is a question manager service whose mutations
+// of the questions and the schemas fail with err.
+type failingQuestionManager struct {
+	questionmanagerv1connect.UnimplementedQuestionManagerServiceHandler
+
+	err error
+}
+
+func (f *failingQuestionManager) CreateQuestion(context.Context, *connect.Request[questionmanagerv1.CreateQuestionRequest]) (*connect.Response[questionmanagerv1.CreateQuestionResponse], error) {
+	return nil, f.err
+}
+
+func (f *failingQuestionManager) UpdateQuestion(context.Context, *connect.Request[questionmanagerv1.UpdateQuestionRequest]) (*connect.Response[questionmanagerv1.UpdateQuestionResponse], error) {
+	return nil, f.err
+}
+
+func (f *failingQuestionManager) DeleteQuestion(context.Context, *connect.Request[questionmanagerv1.DeleteQuestionRequest]) (*connect.Response[questionmanagerv1.DeleteQuestionResponse], error) {
+	return nil, f.err
+}
+
+func (f *failingQuestionManager) RestoreQuestion(context.Context, *connect.Request[questionmanagerv1.RestoreQuestionRequest]) (*connect.Response[questionmanagerv1.RestoreQuestionResponse], error) {
+	return nil, f.err
+}
+
+func (f *failingQuestionManager) CreateSchema(context.Context, *connect.Request[questionmanagerv1.CreateSchemaRequest]) (*connect.Response[questionmanagerv1.CreateSchemaResponse], error) {
+	return nil, f.err
+}
+
+func (f *failingQuestionManager) UpdateSchema(context.Context, *connect.Request[questionmanagerv1.UpdateSchemaRequest]) (*connect.Response[questionmanagerv1.UpdateSchemaResponse], error) {
+	return nil, f.err
+}
+
+func (f *failingQuestionManager) DeleteSchema(context.Context, *connect.Request[questionmanagerv1.DeleteSchemaRequest]) (*connect.Response[questionmanagerv1.DeleteSchemaResponse], error) {
+	return nil, f.err
+}
+
+func (f *failingQuestionManager) RestoreSchema(context.Context, *connect.Request[questionmanagerv1.RestoreSchemaRequest]) (*connect.Response[questionmanagerv1.RestoreSchemaResponse], error) {
+	return nil, f.err
+}
+
+// newTestServer returns a [Server] calling the fake services over HTTP.
+func newTestServer(t *testing.T, questionManager questionmanagerv1connect.QuestionManagerServiceHandler, dbrunner dbrunnerv1connect.DbRunnerServiceHandler) *Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.Handle(questionmanagerv1connect.NewQuestionManagerServiceHandler(questionManager))
+	mux.Handle(dbrunnerv1connect.NewDbRunnerServiceHandler(dbrunner))
+
+	httpServer := httptest.NewServer(mux)
+	t.Cleanup(httpServer.Close)
+
+	return NewServer(ServerParam{
+		Logger:                slog.New(slog.NewTextHandler(io.Discard, nil)),
+		QuestionManagerClient: questionmanagerv1connect.NewQuestionManagerServiceClient(httpServer.Client(), httpServer.URL),
+		DBRunnerClient:        dbrunnerv1connect.NewDbRunnerServiceClient(httpServer.Client(), httpServer.URL),
+	}).(*Server)
+}
+
+// answerOf returns a GetQuestionAnswer function returning the answer.
+func answerOf(answer *questionmanagerv1.QuestionAnswer) func(*questionmanagerv1.GetQuestionAnswerRequest) (*questionmanagerv1.GetQuestionAnswerResponse, error) {
+	return func(*questionmanagerv1.GetQuestionAnswerRequest) (*questionmanagerv1.GetQuestionAnswerResponse, error) {
+		return &questionmanagerv1.GetQuestionAnswerResponse{QuestionAnswer: answer}, nil
+	}
+}
+
+// queryID returns the response of a query succeeded with the ID.
+func queryID(id string) *dbrunnerv1.RunQueryResponse {
+	return &dbrunnerv1.RunQueryResponse{
+		ResponseType: &dbrunnerv1.RunQueryResponse_Id{Id: id},
+	}
+}
+
+// recordBody returns the body written by the response of a handler.
+func recordBody(t *testing.T, visit func(w http.ResponseWriter) error) string {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	require.NoError(t, visit(recorder))
+
+	return recorder.Body.String()
+}
+
+func TestGetChallengesIdDiff(t *testing.T) {
+	t.Parallel()
+
+	secret := func(value string) *dbrunnerv1.Cell {
+		return &dbrunnerv1.Cell{Value: lo.ToPtr(value)}
+	}
+
+	diffResponses := []*dbrunnerv1.DiffQueryResponse{
+		{Kind: &dbrunnerv1.DiffQueryResponse_Summary{Summary: &dbrunnerv1.DiffSummary{
+			LeftRowCount:  2,
+			RightRowCount: 2,
+		}}},
+		{Kind: &dbrunnerv1.DiffQueryResponse_Header{Header: &dbrunnerv1.HeaderDiff{
+			Left:  []string{"id", "name"},
+			Right: []string{"id", "name"},
+		}}},
+		{Kind: &dbrunnerv1.DiffQueryResponse_MissingRow{MissingRow: &dbrunnerv1.RowDiff{
+			Index: 1,
+			Cells: []*dbrunnerv1.Cell{secret("1"), secret("secret-missing")},
+		}}},
+		{Kind: &dbrunnerv1.DiffQueryResponse_ExtraRow{ExtraRow: &dbrunnerv1.RowDiff{
+			Index: 1,
+			Cells: []*dbrunnerv1.Cell{secret("2"), secret("secret-extra")},
+		}}},
+		{Kind: &dbrunnerv1.DiffQueryResponse_Cell{Cell: &dbrunnerv1.CellDiff{
+			Column: 1,
+			Left:   secret("secret-left"),
+			Right:  secret("secret-right"),
+		}}},
+	}
+
+	challengeID := converter.EncodeChallengeID(converter.TransferableChallengeID{
+		QuestionID:  1,
+		ChallengeID: "challenge",
+	})
+
+	cases := []struct {
+		name            string
+		policy          questionmanagerv1.DiffPolicy
+		expectedPolicy  openapi.ChallengeDiffPolicy
+		expectedMaxRows int64
+	}{
+		{"the none policy reveals no row", questionmanagerv1.DiffPolicy_DIFF_POLICY_NONE, openapi.None, 0},
+		{"the summary policy reveals no row", questionmanagerv1.DiffPolicy_DIFF_POLICY_SUMMARY, openapi.Summary, 0},
+		{"the unspecified policy is the summary policy", questionmanagerv1.DiffPolicy_DIFF_POLICY_UNSPECIFIED, openapi.Summary, 0},
+		{"the sample policy reveals the sampled rows", questionmanagerv1.DiffPolicy_DIFF_POLICY_SAMPLE, openapi.Sample, diffSampleSize},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			var diffRequest *dbrunnerv1.DiffQueryRequest
+
+			server := newTestServer(t, &fakeQuestionManager{
+				getQuestionAnswer: answerOf(&questionmanagerv1.QuestionAnswer{
+					Schema:     "schema",
+					Answer:     "SELECT 1",
+					DiffPolicy: c.policy,
+				}),
+			}, &fakeDBRunner{
+				runQuery: func(*dbrunnerv1.RunQueryRequest) (*dbrunnerv1.RunQueryResponse, error) {
+					return queryID("answer"), nil
+				},
+				diffQuery: func(request *dbrunnerv1.DiffQueryRequest) ([]*dbrunnerv1.DiffQueryResponse, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					diffRequest = request
+					return diffResponses, nil
+				},
+			})
+
+			response, err := server.GetChallengesIdDiff(context.Background(), openapi.GetChallengesIdDiffRequestObject{Id: challengeID})
+			require.NoError(t, err)
+			require.IsType(t, openapi.GetChallengesIdDiff200JSONResponse{}, response)
+
+			mu.Lock()
+			defer mu.Unlock()
+			require.NotNil(t, diffRequest)
+			assert.Equal(t, "answer", diffRequest.GetLeftId())
+			assert.Equal(t, "challenge", diffRequest.GetRightId())
+			require.NotNil(t, diffRequest.MaxRows)
+			assert.Equal(t, c.expectedMaxRows, diffRequest.GetMaxRows())
+
+			diff := response.(openapi.GetChallengesIdDiff200JSONResponse)
+			assert.Equal(t, c.expectedPolicy, diff.Policy)
+
+			body := recordBody(t, response.VisitGetChallengesIdDiffResponse)
+			assert.NotContains(t, body, "secret-left")
+			assert.NotContains(t, body, "secret-right")
+
+			switch c.expectedPolicy {
+			case openapi.None:
+				assert.Nil(t, diff.ExpectedRowCount)
+				assert.Nil(t, diff.ChangedColumns)
+				assert.Nil(t, diff.MissingRows)
+				assert.Nil(t, diff.ExtraRows)
+				assert.NotContains(t, body, "secret")
+			case openapi.Summary:
+				assert.Equal(t, lo.ToPtr(int64(2)), diff.ExpectedRowCount)
+				assert.Equal(t, &[]string{"name"}, diff.ChangedColumns)
+				assert.Nil(t, diff.MissingRows)
+				assert.Nil(t, diff.ExtraRows)
+				assert.NotContains(t, body, "secret")
+			case openapi.Sample:
+				assert.Equal(t, &[][]*string{{lo.ToPtr("1"), lo.ToPtr("secret-missing")}}, diff.MissingRows)
+				assert.Equal(t, &[][]*string{{lo.ToPtr("2"), lo.ToPtr("secret-extra")}}, diff.ExtraRows)
+			}
+		})
+	}
+}
+
+func TestGetChallengesIdCompare(t *testing.T) {
+	t.Parallel()
+
+	answer := &questionmanagerv1.QuestionAnswer{
+		Schema: "schema",
+		Answer: "SELECT answer;",
+		Datasets: []*questionmanagerv1.QuestionDataset{
+			{Id: 1, Schema: "dataset-1"},
+			{Id: 2, Schema: "dataset-2"},
+		},
+	}
+
+	challengeID := converter.EncodeChallengeID(converter.TransferableChallengeID{
+		QuestionID:  1,
+		ChallengeID: "schema/SELECT challenge;",
+	})
+
+	// runQuery runs the queries successfully with the ID of the schema
+	// and the query, except the queries of failingQueries.
+	runQuery := func(failingQueries ...string) func(*dbrunnerv1.RunQueryRequest) (*dbrunnerv1.RunQueryResponse, error) {
+		return func(request *dbrunnerv1.RunQueryRequest) (*dbrunnerv1.RunQueryResponse, error) {
+			id := request.GetSchema() + "/" + request.GetQuery()
+			if lo.Contains(failingQueries, id) {
+				return &dbrunnerv1.RunQueryResponse{
+					ResponseType: &dbrunnerv1.RunQueryResponse_Error{Error: "no such table: a"},
+				}, nil
+			}
+			return queryID(id), nil
+		}
+	}
+
+	// sameOn compares the outputs as the same on the schemas.
+	sameOn := func(schemas ...string) func(*dbrunnerv1.AreQueriesOutputSameRequest) (*dbrunnerv1.AreQueriesOutputSameResponse, error) {
+		return func(request *dbrunnerv1.AreQueriesOutputSameRequest) (*dbrunnerv1.AreQueriesOutputSameResponse, error) {
+			schema, _, _ := strings.Cut(request.GetRightId(), "/")
+			return &dbrunnerv1.AreQueriesOutputSameResponse{Same: lo.Contains(schemas, schema)}, nil
+		}
+	}
+
+	challengeQuery := func(*dbrunnerv1.GetQueryRequest) (*dbrunnerv1.GetQueryResponse, error) {
+		return &dbrunnerv1.GetQueryResponse{Query: "SELECT challenge;"}, nil
+	}
+
+	t.Run("the challenge is graded on the schema and the datasets", func(t *testing.T) {
+		t.Parallel()
+
+		cases := []struct {
+			name     string
+			sameOn   []string
+			expected openapi.GetChallengesIdCompare200JSONResponse
+		}{
+			{"all passed", []string{"schema", "dataset-1", "dataset-2"}, openapi.GetChallengesIdCompare200JSONResponse{Same: true, Passed: 3}},
+			{"failed on a dataset", []string{"schema", "dataset-1"}, openapi.GetChallengesIdCompare200JSONResponse{Passed: 2, Failed: 1}},
+			{"passed on the schema only", []string{"schema"}, openapi.GetChallengesIdCompare200JSONResponse{Passed: 1, Failed: 2}},
+			{"failed on the schema only", []string{"dataset-1", "dataset-2"}, openapi.GetChallengesIdCompare200JSONResponse{Passed: 2, Failed: 1}},
+		}
+
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				t.Parallel()
+
+				server := newTestServer(t, &fakeQuestionManager{getQuestionAnswer: answerOf(answer)}, &fakeDBRunner{
+					runQuery:             runQuery(),
+					getQuery:             challengeQuery,
+					areQueriesOutputSame: sameOn(c.sameOn...),
+				})
+
+				response, err := server.GetChallengesIdCompare(context.Background(), openapi.GetChallengesIdCompareRequestObject{Id: challengeID})
+				require.NoError(t, err)
+				assert.Equal(t, c.expected, response)
+			})
+		}
+	})
+
+	t.Run("the query failing on a dataset fails the dataset", func(t *testing.T) {
+		t.Parallel()
+
+		server := newTestServer(t, &fakeQuestionManager{getQuestionAnswer: answerOf(answer)}, &fakeDBRunner{
+			runQuery:             runQuery("dataset-2/SELECT challenge;"),
+			getQuery:             challengeQuery,
+			areQueriesOutputSame: sameOn("schema", "dataset-1", "dataset-2"),
+		})
+
+		response, err := server.GetChallengesIdCompare(context.Background(), openapi.GetChallengesIdCompareRequestObject{Id: challengeID})
+		require.NoError(t, err)
+		assert.Equal(t, openapi.GetChallengesIdCompare200JSONResponse{Passed: 2, Failed: 1}, response)
+	})
+
+	t.Run("the expired query is rejected", func(t *testing.T) {
+		t.Parallel()
+
+		server := newTestServer(t, &fakeQuestionManager{getQuestionAnswer: answerOf(answer)}, &fakeDBRunner{
+			runQuery: runQuery(),
+			getQuery: func(*dbrunnerv1.GetQueryRequest) (*dbrunnerv1.GetQueryResponse, error) {
+				return nil, connect.NewError(connect.CodeNotFound, errors.New("query not found"))
+			},
+			areQueriesOutputSame: sameOn("schema", "dataset-1", "dataset-2"),
+		})
+
+		response, err := server.GetChallengesIdCompare(context.Background(), openapi.GetChallengesIdCompareRequestObject{Id: challengeID})
+		require.NoError(t, err)
+		require.IsType(t, openapi.GetChallengesIdCompare400JSONResponse{}, response)
+		assert.Contains(t, response.(openapi.GetChallengesIdCompare400JSONResponse).Message, "submit the challenge again")
+	})
+
+	t.Run("the query of another challenge is rejected", func(t *testing.T) {
+		t.Parallel()
+
+		// The challenge ID is of another query than the one kept by
+		// the runner, so the query is not run on the datasets.
+		server := newTestServer(t, &fakeQuestionManager{getQuestionAnswer: answerOf(answer)}, &fakeDBRunner{
+			runQuery: runQuery(),
+			getQuery: func(*dbrunnerv1.GetQueryRequest) (*dbrunnerv1.GetQueryResponse, error) {
+				return &dbrunnerv1.GetQueryResponse{Query: "SELECT other;"}, nil
+			},
+			areQueriesOutputSame: sameOn("schema", "dataset-1", "dataset-2"),
+		})
+
+		response, err := server.GetChallengesIdCompare(context.Background(), openapi.GetChallengesIdCompareRequestObject{Id: challengeID})
+		require.NoError(t, err)
+		require.IsType(t, openapi.GetChallengesIdCompare400JSONResponse{}, response)
+		assert.Contains(t, response.(openapi.GetChallengesIdCompare400JSONResponse).Message, "submit the challenge again")
+	})
+
+	t.Run("the question without datasets is graded on the schema", func(t *testing.T) {
+		t.Parallel()
+
+		server := newTestServer(t, &fakeQuestionManager{getQuestionAnswer: answerOf(&questionmanagerv1.QuestionAnswer{
+			Schema: "schema",
+			Answer: "SELECT answer;",
+		})}, &fakeDBRunner{
+			runQuery:             runQuery(),
+			areQueriesOutputSame: sameOn(),
+		})
+
+		response, err := server.GetChallengesIdCompare(context.Background(), openapi.GetChallengesIdCompareRequestObject{Id: challengeID})
+		require.NoError(t, err)
+		assert.Equal(t, openapi.GetChallengesIdCompare200JSONResponse{Failed: 1}, response)
+	})
+}
+
+func TestPostChallenges_TooManyQueries(t *testing.T) {
+	t.Parallel()
+
+	// The dbrunner service reports the full queue and the busy workers alike.
+	server := newTestServer(t, &fakeQuestionManager{
+		getQuestionAnswer: answerOf(&questionmanagerv1.QuestionAnswer{Schema: "schema", Answer: "SELECT 1"}),
+	}, &fakeDBRunner{
+		runQuery: func(*dbrunnerv1.RunQueryRequest) (*dbrunnerv1.RunQueryResponse, error) {
+			connectErr := connect.NewError(connect.CodeResourceExhausted, errors.New("all the workers are busy"))
+			connectErr.Meta().Set("Retry-After", "7")
+			return nil, connectErr
+		},
+	})
+
+	response, err := server.PostChallenges(context.Background(), openapi.PostChallengesRequestObject{
+		Body: &openapi.PostChallengesJSONRequestBody{QuestionID: "1", Query: "SELECT 1"},
+	})
+	require.NoError(t, err)
+	require.IsType(t, openapi.PostChallenges429JSONResponse{}, response)
+
+	recorder := httptest.NewRecorder()
+	require.NoError(t, response.VisitPostChallengesResponse(recorder))
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, "7", recorder.Header().Get("Retry-After"))
+}
+
+func TestMutations(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	question := &openapi.QuestionInput{
+		SchemaId:   "bookstore",
+		Type:       "select",
+		Difficulty: openapi.Easy,
+		Title:      "List the books",
+		Answer:     "SELECT * FROM books;",
+	}
+	schema := &openapi.SchemaInput{
+		Id:         lo.ToPtr("bookstore"),
+		InitialSql: "CREATE TABLE books (id INTEGER PRIMARY KEY);",
+	}
+
+	// visit writes the response of the handler.
+	type visit func(w http.ResponseWriter) error
+
+	endpoints := []struct {
+		name string
+		call func(s *Server, id string) (visit, error)
+		// statuses are the HTTP statuses of the codes of the errors
+		// returned by the question manager service.
+		statuses map[connect.Code]int
+	}{
+		{
+			name: "PostQuestions",
+			call: func(s *Server, _ string) (visit, error) {
+				response, err := s.PostQuestions(ctx, openapi.PostQuestionsRequestObject{Body: question})
+				return response.VisitPostQuestionsResponse, err
+			},
+			statuses: map[connect.Code]int{
+				connect.CodeInvalidArgument:    http.StatusBadRequest,
+				connect.CodeFailedPrecondition: http.StatusUnprocessableEntity,
+				connect.CodeInternal:           http.StatusInternalServerError,
+			},
+		},
+		{
+			name: "PutQuestionsId",
+			call: func(s *Server, id string) (visit, error) {
+				response, err := s.PutQuestionsId(ctx, openapi.PutQuestionsIdRequestObject{Id: id, Body: question})
+				return response.VisitPutQuestionsIdResponse, err
+			},
+			statuses: map[connect.Code]int{
+				connect.CodeInvalidArgument:    http.StatusBadRequest,
+				connect.CodeNotFound:           http.StatusNotFound,
+				connect.CodeFailedPrecondition: http.StatusUnprocessableEntity,
+				connect.CodeInternal:           http.StatusInternalServerError,
+			},
+		},
+		{
+			name: "DeleteQuestionsId",
+			call: func(s *Server, id string) (visit, error) {
+				response, err := s.DeleteQuestionsId(ctx, openapi.DeleteQuestionsIdRequestObject{Id: id})
+				return response.VisitDeleteQuestionsIdResponse, err
+			},
+			statuses: map[connect.Code]int{
+				connect.CodeNotFound: http.StatusNotFound,
+				connect.CodeInternal: http.StatusInternalServerError,
+			},
+		},
+		{
+			name: "PostQuestionsIdRestore",
+			call: func(s *Server, id string) (visit, error) {
+				response, err := s.PostQuestionsIdRestore(ctx, openapi.PostQuestionsIdRestoreRequestObject{Id: id})
+				return response.VisitPostQuestionsIdRestoreResponse, err
+			},
+			statuses: map[connect.Code]int{
+				connect.CodeNotFound:           http.StatusNotFound,
+				connect.CodeFailedPrecondition: http.StatusUnprocessableEntity,
+				connect.CodeInternal:           http.StatusInternalServerError,
+			},
+		},
+		{
+			name: "PostSchemas",
+			call: func(s *Server, _ string) (visit, error) {
+				response, err := s.PostSchemas(ctx, openapi.PostSchemasRequestObject{Body: schema})
+				return response.VisitPostSchemasResponse, err
+			},
+			statuses: map[connect.Code]int{
+				connect.CodeInvalidArgument: http.StatusBadRequest,
+				connect.CodeAlreadyExists:   http.StatusConflict,
+				connect.CodeInternal:        http.StatusInternalServerError,
+			},
+		},
+		{
+			name: "PutSchemasId",
+			call: func(s *Server, _ string) (visit, error) {
+				response, err := s.PutSchemasId(ctx, openapi.PutSchemasIdRequestObject{Id: "bookstore", Body: schema})
+				return response.VisitPutSchemasIdResponse, err
+			},
+			statuses: map[connect.Code]int{
+				connect.CodeInvalidArgument: http.StatusBadRequest,
+				connect.CodeNotFound:        http.StatusNotFound,
+				connect.CodeInternal:        http.StatusInternalServerError,
+			},
+		},
+		{
+			name: "DeleteSchemasId",
+			call: func(s *Server, _ string) (visit, error) {
+				response, err := s.DeleteSchemasId(ctx, openapi.DeleteSchemasIdRequestObject{Id: "bookstore"})
+				return response.VisitDeleteSchemasIdResponse, err
+			},
+			statuses: map[connect.Code]int{
+				connect.CodeNotFound:           http.StatusNotFound,
+				connect.CodeFailedPrecondition: http.StatusConflict,
+				connect.CodeInternal:           http.StatusInternalServerError,
+			},
+		},
+		{
+			name: "PostSchemasIdRestore",
+			call: func(s *Server, _ string) (visit, error) {
+				response, err := s.PostSchemasIdRestore(ctx, openapi.PostSchemasIdRestoreRequestObject{Id: "bookstore"})
+				return response.VisitPostSchemasIdRestoreResponse, err
+			},
+			statuses: map[connect.Code]int{
+				connect.CodeNotFound: http.StatusNotFound,
+				connect.CodeInternal: http.StatusInternalServerError,
+			},
+		},
+	}
+
+	for _, endpoint := range endpoints {
+		for code, status := range endpoint.statuses {
+			t.Run(endpoint.name+" maps "+code.String(), func(t *testing.T) {
+				t.Parallel()
+
+				server := newTestServer(t, &failingQuestionManager{
+					err: connect.NewError(code, errors.New("failed")),
+				}, &fakeDBRunner{})
+
+				visit, err := endpoint.call(server, "1")
+				require.NoError(t, err)
+
+				recorder := httptest.NewRecorder()
+				require.NoError(t, visit(recorder))
+				assert.Equal(t, status, recorder.Code)
+			})
+		}
+	}
+
+	t.Run("the invalid IDs are rejected before calling the service", func(t *testing.T) {
+		t.Parallel()
+
+		server := newTestServer(t, &failingQuestionManager{
+			err: connect.NewError(connect.CodeInternal, errors.New("called")),
+		}, &fakeDBRunner{})
+
+		for _, endpoint := range endpoints {
+			if !strings.Contains(endpoint.name, "Questions") || endpoint.name == "PostQuestions" {
+				continue
+			}
+
+			visit, err := endpoint.call(server, "not-an-id")
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			require.NoError(t, visit(recorder))
+			assert.Equal(t, http.StatusBadRequest, recorder.Code, endpoint.name)
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package gatewayservice
+
+import (
+	"errors"
+	"fmt"
+
+	"connectrpc.com/connect"
+	commonv1 "github.com/database-playground/backend/gen/common/v1"
+	questionmanagerv1 "github.com/database-playground/backend/gen/questionmanager/v1"
+	"github.com/database-playground/backend/internal/services/gateway/openapi"
+	"github.com/samber/lo"
+)
+
+var difficultyToProto = map[openapi.QuestionDifficulty]questionmanagerv1.Difficulty{
+	openapi.Easy:   questionmanagerv1.Difficulty_DIFFICULTY_EASY,
+	openapi.Medium: questionmanagerv1.Difficulty_DIFFICULTY_MEDIUM,
+	openapi.Hard:   questionmanagerv1.Difficulty_DIFFICULTY_HARD,
+}
+
+var diffPolicyToProto = map[openapi.ChallengeDiffPolicy]questionmanagerv1.DiffPolicy{
+	openapi.None:    questionmanagerv1.DiffPolicy_DIFF_POLICY_NONE,
+	openapi.Summary: questionmanagerv1.DiffPolicy_DIFF_POLICY_SUMMARY,
+	openapi.Sample:  questionmanagerv1.DiffPolicy_DIFF_POLICY_SAMPLE,
+}
+
+var sandboxPermissionToProto = map[openapi.SandboxPermission]commonv1.SandboxPermission{
+	openapi.Attach:        commonv1.SandboxPermission_SANDBOX_PERMISSION_ATTACH,
+	openapi.Pragma:        commonv1.SandboxPermission_SANDBOX_PERMISSION_PRAGMA,
+	openapi.VacuumInto:    commonv1.SandboxPermission_SANDBOX_PERMISSION_VACUUM_INTO,
+	openapi.LoadExtension: commonv1.SandboxPermission_SANDBOX_PERMISSION_LOAD_EXTENSION,
+}
+
+var questionSortToProto = map[openapi.GetQuestionsParamsSort]questionmanagerv1.QuestionSort{
+	openapi.Id:         questionmanagerv1.QuestionSort_QUESTION_SORT_UNSPECIFIED,
+	openapi.CreatedAt:  questionmanagerv1.QuestionSort_QUESTION_SORT_CREATED_AT,
+	openapi.Difficulty: questionmanagerv1.QuestionSort_QUESTION_SORT_DIFFICULTY,
+	openapi.Title:      questionmanagerv1.QuestionSort_QUESTION_SORT_TITLE,
+}
+
+// listQuestionsParamsToProto converts the filters and the sort of the
+// question list. It returns an error if any enum value is unknown.
+func listQuestionsParamsToProto(params *openapi.GetQuestionsParams) (*questionmanagerv1.ListQuestionsRequest, error) {
+	difficulty := questionmanagerv1.Difficulty_DIFFICULTY_UNSPECIFIED
+	if params.Difficulty != nil {
+		var ok bool
+		difficulty, ok = difficultyToProto[*params.Difficulty]
+		if !ok {
+			return nil, fmt.Errorf("unknown difficulty %q", *params.Difficulty)
+		}
+	}
+
+	sort := questionmanagerv1.QuestionSort_QUESTION_SORT_UNSPECIFIED
+	if params.Sort != nil {
+		var ok bool
+		sort, ok = questionSortToProto[*params.Sort]
+		if !ok {
+			return nil, fmt.Errorf("unknown sort %q", *params.Sort)
+		}
+	}
+
+	order := lo.FromPtrOr(params.Order, openapi.Asc)
+	if order != openapi.Asc && order != openapi.Desc {
+		return nil, fmt.Errorf("unknown order %q", order)
+	}
+
+	return &questionmanagerv1.ListQuestionsRequest{
+		Difficulty: difficulty,
+		Type:       params.Type,
+		SchemaId:   params.SchemaId,
+		Tags:       lo.FromPtr(params.Tags),
+		Search:     params.Search,
+		Sort:       sort,
+		Descending: order == openapi.Desc,
+	}, nil
+}
+
+// schemaInputToProto converts the schema in the request body.
+func schemaInputToProto(input *openapi.SchemaInput) *questionmanagerv1.SchemaInput {
+	return &questionmanagerv1.SchemaInput{
+		Picture:     input.Picture,
+		Description: input.Description,
+		InitialSql:  input.InitialSql,
+		Limits:      executionLimitsToProto(input.Limits),
+	}
+}
+
+// questionInputToProto converts the question in the request body.
+// It returns an error if any enum value is unknown.
+func questionInputToProto(input *openapi.QuestionInput) (*questionmanagerv1.QuestionInput, error) {
+	difficulty, ok := difficultyToProto[input.Difficulty]
+	if !ok {
+		return nil, fmt.Errorf("unknown difficulty %q", input.Difficulty)
+	}
+
+	diffPolicy := questionmanagerv1.DiffPolicy_DIFF_POLICY_UNSPECIFIED
+	if input.DiffPolicy != nil {
+		diffPolicy, ok = diffPolicyToProto[*input.DiffPolicy]
+		if !ok {
+			return nil, fmt.Errorf("unknown diff policy %q", *input.DiffPolicy)
+		}
+	}
+
+	var sandboxAllow []commonv1.SandboxPermission
+	for _, permission := range lo.FromPtr(input.SandboxAllow) {
+		permissionPb, ok := sandboxPermissionToProto[permission]
+		if !ok {
+			return nil, fmt.Errorf("unknown sandbox permission %q", permission)
+		}
+		sandboxAllow = append(sandboxAllow, permissionPb)
+	}
+
+	var compareOptions *commonv1.CompareOptions
+	if input.CompareOptions != nil {
+		compareOptions = &commonv1.CompareOptions{
+			IgnoreRowOrder:      lo.FromPtr(input.CompareOptions.IgnoreRowOrder),
+			IgnoreColumnOrder:   lo.FromPtr(input.CompareOptions.IgnoreColumnOrder),
+			IgnoreColumnNames:   lo.FromPtr(input.CompareOptions.IgnoreColumnNames),
+			IgnoreDuplicateRows: lo.FromPtr(input.CompareOptions.IgnoreDuplicateRows),
+			FloatEpsilon:        lo.FromPtr(input.CompareOptions.FloatEpsilon),
+			DecimalPlaces:       input.CompareOptions.DecimalPlaces,
+		}
+	}
+
+	return &questionmanagerv1.QuestionInput{
+		SchemaId:       input.SchemaId,
+		Type:           input.Type,
+		Difficulty:     difficulty,
+		Title:          input.Title,
+		Description:    input.Description,
+		Answer:         input.Answer,
+		SolutionVideo:  input.SolutionVideo,
+		DiffPolicy:     diffPolicy,
+		CompareOptions: compareOptions,
+		GradeByState:   lo.FromPtr(input.GradeByState),
+		StateTables:    lo.FromPtr(input.StateTables),
+		Limits:         executionLimitsToProto(input.Limits),
+		SandboxAllow:   sandboxAllow,
+		Tags:           lo.FromPtr(input.Tags),
+	}, nil
+}
+
+func executionLimitsToProto(limits *openapi.ExecutionLimits) *commonv1.ExecutionLimits {
+	if limits == nil {
+		return nil
+	}
+
+	return &commonv1.ExecutionLimits{
+		TimeoutMs:        lo.FromPtr(limits.TimeoutMs),
+		MaxRows:          lo.FromPtr(limits.MaxRows),
+		MaxCellBytes:     lo.FromPtr(limits.MaxCellBytes),
+		MaxOutputBytes:   lo.FromPtr(limits.MaxOutputBytes),
+		MaxDatabaseBytes: lo.FromPtr(limits.MaxDatabaseBytes),
+		MaxVmSteps:       lo.FromPtr(limits.MaxVmSteps),
+		MaxHeapBytes:     lo.FromPtr(limits.MaxHeapBytes),
+	}
+}
+
+// connectErrorMessage returns the message of the error returned by the
+// services, without the code prefix.
+func connectErrorMessage(err error) string {
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		return connectErr.Message()
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,74 @@
+package gatewayservice
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/database-playground/backend/internal/services/gateway/openapi"
+)
+
+// questionsPageResponse is a page of the questions. Unlike
+// [openapi.GetQuestions200JSONResponse], it omits the Link and the
+// X-Total-Count headers on the last page and when the questions
+// are not counted.
+type questionsPageResponse struct {
+	Body openapi.Questions
+	// NextLink is the link to the next page, which is empty on the last page.
+	NextLink   string
+	TotalCount *int64
+}
+
+func (response questionsPageResponse) VisitGetQuestionsResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	if response.NextLink != "" {
+		w.Header().Set("Link", "<"+response.NextLink+`>; rel="next"`)
+	}
+	if response.TotalCount != nil {
+		w.Header().Set("X-Total-Count", strconv.FormatInt(*response.TotalCount, 10))
+	}
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response.Body)
+}
+
+// nextQuestionsLink returns the link to the page of the cursor, which
+// keeps the parameters of the current page except the offset.
+func nextQuestionsLink(params *openapi.GetQuestionsParams, cursor string) string {
+	query := url.Values{}
+	query.Set("cursor", cursor)
+
+	if params.Limit != nil {
+		query.Set("limit", strconv.FormatInt(*params.Limit, 10))
+	}
+	if params.IncludeTotalCount != nil {
+		query.Set("include_total_count", strconv.FormatBool(*params.IncludeTotalCount))
+	}
+	if params.IncludeDeleted != nil {
+		query.Set("include_deleted", strconv.FormatBool(*params.IncludeDeleted))
+	}
+	if params.Difficulty != nil {
+		query.Set("difficulty", string(*params.Difficulty))
+	}
+	if params.Type != nil {
+		query.Set("type", *params.Type)
+	}
+	if params.SchemaId != nil {
+		query.Set("schema_id", *params.SchemaId)
+	}
+	if params.Tags != nil {
+		query["tags"] = *params.Tags
+	}
+	if params.Search != nil {
+		query.Set("search", *params.Search)
+	}
+	if params.Sort != nil {
+		query.Set("sort", string(*params.Sort))
+	}
+	if params.Order != nil {
+		query.Set("order", string(*params.Order))
+	}
+
+	return "/questions?" + query.Encode()
+}
@@ -0,0 +1,71 @@
+package questionmanagerservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"connectrpc.com/connect"
+	commonv1 "github.com/database-playground/backend/gen/common/v1"
+	dbrunnerv1 "github.com/database-playground/backend/gen/dbrunner/v1"
+	"github.com/database-playground/backend/internal/database"
+	"github.com/database-playground/backend/internal/models"
+	"github.com/samber/lo"
+)
+
+// validateAnswer runs the answer of the written question on its schema
+// with the limits and the sandbox of the question, and records the
+// output hash and the row count of the result in params.
+//
+// It returns a connect error: INVALID_ARGUMENT if the answer fails or
+// exceeds the limits, FAILED_PRECONDITION if the schema does not exist,
+// and UNAVAILABLE if the dbrunner service cannot run the answer.
+//
+// The result is pinned in the cache like the answers run by the
+// challenges, so the first challenges do not have to run it again.
+func (s *Service) validateAnswer(ctx context.Context, params *database.QuestionParams) error {
+	runtime, err := s.db.GetSchemaRuntime(ctx, params.SchemaID)
+	if errors.Is(err, database.ErrNotFound) {
+		return connect.NewError(connect.CodeFailedPrecondition, database.ErrSchemaNotFound)
+	}
+	if err != nil {
+		return connect.NewError(connect.CodeInternal, err)
+	}
+
+	var captureState *dbrunnerv1.StateCapture
+	if params.GradeByState {
+		captureState = &dbrunnerv1.StateCapture{
+			Tables: params.StateTables,
+		}
+	}
+
+	response, err := s.dbrunner.RunQuery(ctx, &connect.Request[dbrunnerv1.RunQueryRequest]{
+		Msg: &dbrunnerv1.RunQueryRequest{
+			Schema:       runtime.InitialSQL,
+			Query:        params.Answer,
+			CaptureState: captureState,
+			Limits:       s.converter.ExecutionLimitsToProto(runtime.Limits.Override(params.Limits)),
+			SandboxAllow: lo.Map(params.SandboxAllow, func(permission models.SandboxPermission, _ int) commonv1.SandboxPermission {
+				return s.converter.SandboxPermissionToProto(permission)
+			}),
+			Pin: true,
+		},
+	})
+	if connect.CodeOf(err) == connect.CodeInvalidArgument {
+		return connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid answer: %w", err))
+	}
+	if err != nil {
+		return connect.NewError(connect.CodeUnavailable, fmt.Errorf("run answer: %w", err))
+	}
+	if limitExceeded := response.Msg.GetLimitExceeded(); limitExceeded != nil {
+		return connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("answer exceeds the limits: %s", limitExceeded.GetMessage()))
+	}
+	if response.Msg.GetError() != "" {
+		return connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("answer fails on the schema: %s", response.Msg.GetError()))
+	}
+
+	params.AnswerOutputHash = response.Msg.OutputHash
+	params.AnswerRowCount = response.Msg.RowCount
+
+	return nil
+}
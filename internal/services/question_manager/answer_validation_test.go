@@ -0,0 +1,151 @@
+package questionmanagerservice_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"connectrpc.com/connect"
+	commonv1 "github.com/database-playground/backend/gen/common/v1"
+	dbrunnerv1 "github.com/database-playground/backend/gen/dbrunner/v1"
+	questionmanagerv1 "github.com/database-playground/backend/gen/questionmanager/v1"
+	questionmanagerservice "github.com/database-playground/backend/internal/services/question_manager"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAnswer(t *testing.T) {
+	t.Parallel()
+
+	db := createSeededDatabase(t)
+	ctx := context.Background()
+
+	runtime, err := db.GetSchemaRuntime(ctx, "shop")
+	require.NoError(t, err)
+
+	cases := []struct {
+		name         string
+		response     *dbrunnerv1.RunQueryResponse
+		err          error
+		expectedCode connect.Code
+	}{
+		{
+			name:         "the answer is rejected by the dbrunner service",
+			err:          connect.NewError(connect.CodeInvalidArgument, errors.New("syntax error")),
+			expectedCode: connect.CodeInvalidArgument,
+		},
+		{
+			name: "the answer fails on the schema",
+			response: &dbrunnerv1.RunQueryResponse{
+				ResponseType: &dbrunnerv1.RunQueryResponse_Error{Error: "no such table: product"},
+			},
+			expectedCode: connect.CodeInvalidArgument,
+		},
+		{
+			name: "the answer exceeds the limits",
+			response: &dbrunnerv1.RunQueryResponse{
+				ResponseType: &dbrunnerv1.RunQueryResponse_LimitExceeded{
+					LimitExceeded: &dbrunnerv1.LimitExceeded{
+						Limit:   dbrunnerv1.Limit_LIMIT_TIMEOUT,
+						Message: "the query timed out",
+					},
+				},
+			},
+			expectedCode: connect.CodeInvalidArgument,
+		},
+		{
+			name:         "the dbrunner service is unavailable",
+			err:          connect.NewError(connect.CodeResourceExhausted, errors.New("too many queries")),
+			expectedCode: connect.CodeUnavailable,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := questionmanagerservice.New(db, &fakeDBRunner{
+				runQuery: func(*dbrunnerv1.RunQueryRequest) (*dbrunnerv1.RunQueryResponse, error) {
+					return c.response, c.err
+				},
+			})
+
+			_, err := s.CreateQuestion(ctx, connect.NewRequest(&questionmanagerv1.CreateQuestionRequest{
+				Question: validQuestion(),
+			}))
+			require.Error(t, err)
+			assert.Equal(t, c.expectedCode, connect.CodeOf(err))
+		})
+	}
+
+	t.Run("the schema is missing", func(t *testing.T) {
+		s := questionmanagerservice.New(db, &fakeDBRunner{
+			runQuery: func(*dbrunnerv1.RunQueryRequest) (*dbrunnerv1.RunQueryResponse, error) {
+				t.Error("the answer is run without the schema")
+				return nil, errors.New("unexpected query")
+			},
+		})
+
+		question := validQuestion()
+		question.SchemaId = "unknown"
+
+		_, err := s.CreateQuestion(ctx, connect.NewRequest(&questionmanagerv1.CreateQuestionRequest{
+			Question: question,
+		}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+	})
+
+	t.Run("the question to update is missing", func(t *testing.T) {
+		s := questionmanagerservice.New(db, &fakeDBRunner{
+			runQuery: func(*dbrunnerv1.RunQueryRequest) (*dbrunnerv1.RunQueryResponse, error) {
+				t.Error("the answer is run without the question")
+				return nil, errors.New("unexpected query")
+			},
+		})
+
+		_, err := s.UpdateQuestion(ctx, connect.NewRequest(&questionmanagerv1.UpdateQuestionRequest{
+			Id:       999,
+			Question: validQuestion(),
+		}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("the result of the answer is recorded", func(t *testing.T) {
+		var request *dbrunnerv1.RunQueryRequest
+		s := questionmanagerservice.New(db, &fakeDBRunner{
+			runQuery: func(r *dbrunnerv1.RunQueryRequest) (*dbrunnerv1.RunQueryResponse, error) {
+				request = r
+				return &dbrunnerv1.RunQueryResponse{
+					ResponseType: &dbrunnerv1.RunQueryResponse_Id{Id: "id"},
+					OutputHash:   lo.ToPtr("output-hash"),
+					RowCount:     lo.ToPtr[int64](2),
+				}, nil
+			},
+		})
+
+		question := validQuestion()
+		question.Limits = &commonv1.ExecutionLimits{MaxRows: 10}
+		question.SandboxAllow = []commonv1.SandboxPermission{commonv1.SandboxPermission_SANDBOX_PERMISSION_PRAGMA}
+
+		response, err := s.CreateQuestion(ctx, connect.NewRequest(&questionmanagerv1.CreateQuestionRequest{
+			Question: question,
+		}))
+		require.NoError(t, err)
+
+		// The answer is run on the schema with the limits of the
+		// question overriding the limits of the schema.
+		require.NotNil(t, request)
+		assert.Equal(t, runtime.InitialSQL, request.GetSchema())
+		assert.Equal(t, question.GetAnswer(), request.GetQuery())
+		assert.Equal(t, runtime.Limits.TimeoutMS, request.GetLimits().GetTimeoutMs())
+		assert.Equal(t, int64(10), request.GetLimits().GetMaxRows())
+		assert.Equal(t, question.GetSandboxAllow(), request.GetSandboxAllow())
+		assert.True(t, request.GetPin())
+
+		answer, err := db.GetQuestionAnswer(ctx, response.Msg.GetQuestion().GetId())
+		require.NoError(t, err)
+		assert.Equal(t, lo.ToPtr("output-hash"), answer.AnswerOutputHash)
+		assert.Equal(t, lo.ToPtr[int64](2), answer.AnswerRowCount)
+	})
+}
@@ -0,0 +1,108 @@
+package questionmanagerservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/database-playground/backend/internal/database"
+	"go.uber.org/fx"
+)
+
+const (
+	defaultPurgeRetention = 30 * 24 * time.Hour
+	defaultPurgeInterval  = time.Hour
+)
+
+// runPurger purges the questions and the schemas soft-deleted longer
+// than QUESTION_MANAGER_PURGE_RETENTION ago, every
+// QUESTION_MANAGER_PURGE_INTERVAL. The purge is disabled if the
+// interval is 0.
+//
+// The purge is idempotent, so it is fine for the replicas to run it
+// at the same time.
+func runPurger(lc fx.Lifecycle, db *database.Database, logger *slog.Logger) error {
+	retention := defaultPurgeRetention
+	if retentionStr := os.Getenv("QUESTION_MANAGER_PURGE_RETENTION"); retentionStr != "" {
+		var err error
+		retention, err = time.ParseDuration(retentionStr)
+		if err != nil {
+			return fmt.Errorf("invalid QUESTION_MANAGER_PURGE_RETENTION: %w", err)
+		}
+		if retention < 0 {
+			return errors.New("invalid QUESTION_MANAGER_PURGE_RETENTION: must not be negative")
+		}
+	}
+
+	interval := defaultPurgeInterval
+	if intervalStr := os.Getenv("QUESTION_MANAGER_PURGE_INTERVAL"); intervalStr != "" {
+		var err error
+		interval, err = time.ParseDuration(intervalStr)
+		if err != nil {
+			return fmt.Errorf("invalid QUESTION_MANAGER_PURGE_INTERVAL: %w", err)
+		}
+		if interval < 0 {
+			return errors.New("invalid QUESTION_MANAGER_PURGE_INTERVAL: must not be negative")
+		}
+	}
+
+	if interval == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+
+				ticker := time.NewTicker(interval)
+				defer ticker.Stop()
+
+				for {
+					purge(ctx, db, logger, retention)
+
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+					}
+				}
+			}()
+
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		},
+	})
+
+	return nil
+}
+
+func purge(ctx context.Context, db *database.Database, logger *slog.Logger, retention time.Duration) {
+	result, err := db.PurgeDeleted(ctx, retention)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.ErrorContext(ctx, "failed to purge the deleted questions and schemas", slog.Any("error", err))
+		}
+		return
+	}
+
+	if result.Questions > 0 || result.Schemas > 0 {
+		logger.InfoContext(ctx, "purged the deleted questions and schemas",
+			slog.Int64("questions", result.Questions), slog.Int64("schemas", result.Schemas))
+	}
+}
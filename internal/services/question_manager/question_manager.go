@@ -1,6 +1,7 @@
 package questionmanagerservice
 
 import (
+	"github.com/database-playground/backend/gen/dbrunner/v1/dbrunnerv1connect"
 	"github.com/database-playground/backend/gen/questionmanager/v1/questionmanagerv1connect"
 	"github.com/database-playground/backend/internal/database"
 	"github.com/database-playground/backend/internal/models"
@@ -8,18 +9,22 @@ import (
 	"go.uber.org/fx"
 )
 
-var FxModule = fx.Module("question-manager-service", fx.Provide(New))
+var FxModule = fx.Module("question-manager-service", fx.Provide(New), fx.Invoke(runPurger))
 
 type Service struct {
 	questionmanagerv1connect.UnimplementedQuestionManagerServiceHandler
 
 	db        *database.Database
+	dbrunner  dbrunnerv1connect.DbRunnerServiceClient
 	converter models.Converter
 }
 
-func New(database *database.Database) *Service {
+// New creates the service. The dbrunner client is used to validate
+// the answers of the written questions.
+func New(database *database.Database, dbrunner dbrunnerv1connect.DbRunnerServiceClient) *Service {
 	return &Service{
 		db:        database,
+		dbrunner:  dbrunner,
 		converter: &generated.ConverterImpl{},
 	}
 }
@@ -0,0 +1,95 @@
+package questionmanagerservice_test
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"testing"
+
+	"connectrpc.com/connect"
+	dbrunnerv1 "github.com/database-playground/backend/gen/dbrunner/v1"
+	"github.com/database-playground/backend/gen/dbrunner/v1/dbrunnerv1connect"
+	"github.com/database-playground/backend/internal/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDBRunner is a dbrunner client running the queries with runQuery.
+// The other RPCs are not used by the service.
+type fakeDBRunner struct {
+	dbrunnerv1connect.DbRunnerServiceClient
+
+	runQuery func(*dbrunnerv1.RunQueryRequest) (*dbrunnerv1.RunQueryResponse, error)
+}
+
+func (f *fakeDBRunner) RunQuery(_ context.Context, request *connect.Request[dbrunnerv1.RunQueryRequest]) (*connect.Response[dbrunnerv1.RunQueryResponse], error) {
+	response, err := f.runQuery(request.Msg)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(response), nil
+}
+
+// passingDBRunner returns a dbrunner client which runs all the queries
+// successfully.
+func passingDBRunner() *fakeDBRunner {
+	return &fakeDBRunner{
+		runQuery: func(*dbrunnerv1.RunQueryRequest) (*dbrunnerv1.RunQueryResponse, error) {
+			outputHash := "hash"
+			rowCount := int64(1)
+
+			return &dbrunnerv1.RunQueryResponse{
+				ResponseType: &dbrunnerv1.RunQueryResponse_Id{Id: "id"},
+				OutputHash:   &outputHash,
+				RowCount:     &rowCount,
+			}, nil
+		},
+	}
+}
+
+// createSeededDatabase creates a migrated and seeded database for the
+// test, which is dropped when the test finishes.
+func createSeededDatabase(t *testing.T) *database.Database {
+	t.Helper()
+
+	uri := os.Getenv("TEST_POSTGRES_URI")
+	if uri == "" {
+		t.Skip("skipping test; no database connection")
+	}
+
+	rootConnection, err := pgx.Connect(context.Background(), uri)
+	if err != nil {
+		t.Skip("skipping test; unconnectable database")
+	}
+
+	newDatabase := "dp_backend_test_" + strconv.FormatInt(rand.Int64(), 36)
+	_, err = rootConnection.Exec(context.Background(), "CREATE DATABASE "+newDatabase)
+	require.NoError(t, err)
+
+	newConnConfig, err := pgxpool.ParseConfig(uri)
+	require.NoError(t, err)
+	newConnConfig.ConnConfig.Database = newDatabase
+
+	newPool, err := pgxpool.NewWithConfig(context.Background(), newConnConfig)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		newPool.Close()
+
+		if _, err := rootConnection.Exec(context.Background(), "DROP DATABASE "+newDatabase); err != nil {
+			t.Errorf("failed to drop database: %v", err)
+		}
+		if err := rootConnection.Close(context.Background()); err != nil {
+			t.Errorf("failed to close connection: %v", err)
+		}
+	})
+
+	db := database.NewWithPool(newPool, slog.Default())
+	require.NoError(t, db.Migrate(context.Background()))
+	require.NoError(t, db.SeedTestOnly(context.Background()))
+
+	return db
+}
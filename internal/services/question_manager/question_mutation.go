@@ -0,0 +1,162 @@
+package questionmanagerservice
+
+import (
+	"context"
+	"errors"
+
+	"connectrpc.com/connect"
+	commonv1 "github.com/database-playground/backend/gen/common/v1"
+	questionmanagerv1 "github.com/database-playground/backend/gen/questionmanager/v1"
+	"github.com/database-playground/backend/internal/database"
+	"github.com/database-playground/backend/internal/models"
+	"github.com/samber/lo"
+)
+
+func (s *Service) CreateQuestion(ctx context.Context, request *connect.Request[questionmanagerv1.CreateQuestionRequest]) (*connect.Response[questionmanagerv1.CreateQuestionResponse], error) {
+	params, err := s.questionParams(request.Msg.GetQuestion())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	if err := s.validateAnswer(ctx, &params); err != nil {
+		return nil, err
+	}
+
+	question, err := s.db.CreateQuestion(ctx, params)
+	if errors.Is(err, database.ErrSchemaNotFound) {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, err)
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	questionPb := s.converter.QuestionToProto(question)
+
+	return &connect.Response[questionmanagerv1.CreateQuestionResponse]{
+		Msg: &questionmanagerv1.CreateQuestionResponse{
+			Question: questionPb,
+		},
+	}, nil
+}
+
+func (s *Service) UpdateQuestion(ctx context.Context, request *connect.Request[questionmanagerv1.UpdateQuestionRequest]) (*connect.Response[questionmanagerv1.UpdateQuestionResponse], error) {
+	params, err := s.questionParams(request.Msg.GetQuestion())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	// Check the question before running its answer on the schema.
+	_, err = s.db.GetQuestion(ctx, request.Msg.GetId(), false)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	if err := s.validateAnswer(ctx, &params); err != nil {
+		return nil, err
+	}
+
+	question, err := s.db.UpdateQuestion(ctx, request.Msg.GetId(), params)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	}
+	if errors.Is(err, database.ErrSchemaNotFound) {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, err)
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	questionPb := s.converter.QuestionToProto(question)
+
+	return &connect.Response[questionmanagerv1.UpdateQuestionResponse]{
+		Msg: &questionmanagerv1.UpdateQuestionResponse{
+			Question: questionPb,
+		},
+	}, nil
+}
+
+func (s *Service) DeleteQuestion(ctx context.Context, request *connect.Request[questionmanagerv1.DeleteQuestionRequest]) (*connect.Response[questionmanagerv1.DeleteQuestionResponse], error) {
+	err := s.db.DeleteQuestion(ctx, request.Msg.GetId())
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	return &connect.Response[questionmanagerv1.DeleteQuestionResponse]{
+		Msg: &questionmanagerv1.DeleteQuestionResponse{},
+	}, nil
+}
+
+func (s *Service) RestoreQuestion(ctx context.Context, request *connect.Request[questionmanagerv1.RestoreQuestionRequest]) (*connect.Response[questionmanagerv1.RestoreQuestionResponse], error) {
+	question, err := s.db.RestoreQuestion(ctx, request.Msg.GetId())
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("deleted question not found"))
+	}
+	if errors.Is(err, database.ErrSchemaNotFound) {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, err)
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	questionPb := s.converter.QuestionToProto(question)
+
+	return &connect.Response[questionmanagerv1.RestoreQuestionResponse]{
+		Msg: &questionmanagerv1.RestoreQuestionResponse{
+			Question: questionPb,
+		},
+	}, nil
+}
+
+// questionParams validates and converts the question in the request.
+func (s *Service) questionParams(input *questionmanagerv1.QuestionInput) (database.QuestionParams, error) {
+	switch {
+	case input.GetSchemaId() == "":
+		return database.QuestionParams{}, errors.New("schema_id is required")
+	case input.GetType() == "":
+		return database.QuestionParams{}, errors.New("type is required")
+	case input.GetDifficulty() == questionmanagerv1.Difficulty_DIFFICULTY_UNSPECIFIED:
+		return database.QuestionParams{}, errors.New("difficulty is required")
+	case input.GetTitle() == "":
+		return database.QuestionParams{}, errors.New("title is required")
+	case input.GetAnswer() == "":
+		return database.QuestionParams{}, errors.New("answer is required")
+	}
+
+	diffPolicy := s.converter.DiffPolicyFromProto(input.GetDiffPolicy())
+	if diffPolicy == models.DiffPolicyUnspecified {
+		diffPolicy = models.DiffPolicySummary
+	}
+
+	sandboxAllow := lo.Map(input.GetSandboxAllow(), func(permission commonv1.SandboxPermission, _ int) models.SandboxPermission {
+		return s.converter.SandboxPermissionFromProto(permission)
+	})
+	if lo.Contains(sandboxAllow, models.SandboxPermissionUnspecified) {
+		return database.QuestionParams{}, errors.New("sandbox_allow contains an unspecified permission")
+	}
+
+	if lo.Contains(input.GetTags(), "") {
+		return database.QuestionParams{}, errors.New("tags contains an empty tag")
+	}
+
+	return database.QuestionParams{
+		SchemaID:       input.GetSchemaId(),
+		Type:           input.GetType(),
+		Difficulty:     s.converter.DifficultyFromProto(input.GetDifficulty()),
+		Title:          input.GetTitle(),
+		Description:    input.GetDescription(),
+		Tags:           lo.Uniq(input.GetTags()),
+		Answer:         input.GetAnswer(),
+		SolutionVideo:  input.SolutionVideo,
+		DiffPolicy:     diffPolicy,
+		CompareOptions: s.converter.CompareOptionsFromProto(input.GetCompareOptions()),
+		GradeByState:   input.GetGradeByState(),
+		StateTables:    input.GetStateTables(),
+		Limits:         s.converter.ExecutionLimitsFromProto(input.GetLimits()),
+		SandboxAllow:   sandboxAllow,
+	}, nil
+}
@@ -0,0 +1,131 @@
+package questionmanagerservice_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	commonv1 "github.com/database-playground/backend/gen/common/v1"
+	questionmanagerv1 "github.com/database-playground/backend/gen/questionmanager/v1"
+	questionmanagerservice "github.com/database-playground/backend/internal/services/question_manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// validQuestion returns a question input which passes the validation.
+func validQuestion() *questionmanagerv1.QuestionInput {
+	return &questionmanagerv1.QuestionInput{
+		SchemaId:   "shop",
+		Type:       "select",
+		Difficulty: questionmanagerv1.Difficulty_DIFFICULTY_EASY,
+		Title:      "List the products",
+		Answer:     "SELECT * FROM products;",
+		Tags:       []string{"select"},
+	}
+}
+
+func TestQuestionParams(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name          string
+		modify        func(*questionmanagerv1.QuestionInput)
+		expectedError string
+	}{
+		{"schema_id is required", func(q *questionmanagerv1.QuestionInput) { q.SchemaId = "" }, "schema_id is required"},
+		{"type is required", func(q *questionmanagerv1.QuestionInput) { q.Type = "" }, "type is required"},
+		{"difficulty is required", func(q *questionmanagerv1.QuestionInput) {
+			q.Difficulty = questionmanagerv1.Difficulty_DIFFICULTY_UNSPECIFIED
+		}, "difficulty is required"},
+		{"title is required", func(q *questionmanagerv1.QuestionInput) { q.Title = "" }, "title is required"},
+		{"answer is required", func(q *questionmanagerv1.QuestionInput) { q.Answer = "" }, "answer is required"},
+		{"the unspecified permissions are rejected", func(q *questionmanagerv1.QuestionInput) {
+			q.SandboxAllow = []commonv1.SandboxPermission{
+				commonv1.SandboxPermission_SANDBOX_PERMISSION_PRAGMA,
+				commonv1.SandboxPermission_SANDBOX_PERMISSION_UNSPECIFIED,
+			}
+		}, "sandbox_allow contains an unspecified permission"},
+		{"the empty tags are rejected", func(q *questionmanagerv1.QuestionInput) {
+			q.Tags = []string{"select", ""}
+		}, "tags contains an empty tag"},
+	}
+
+	// The questions are validated before the database and the dbrunner
+	// service are used.
+	s := questionmanagerservice.New(nil, nil)
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			question := validQuestion()
+			c.modify(question)
+
+			_, err := s.CreateQuestion(context.Background(), connect.NewRequest(&questionmanagerv1.CreateQuestionRequest{
+				Question: question,
+			}))
+			require.Error(t, err)
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			assert.ErrorContains(t, err, c.expectedError)
+
+			_, err = s.UpdateQuestion(context.Background(), connect.NewRequest(&questionmanagerv1.UpdateQuestionRequest{
+				Id:       1,
+				Question: question,
+			}))
+			require.Error(t, err)
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			assert.ErrorContains(t, err, c.expectedError)
+		})
+	}
+}
+
+func TestQuestionMutation_Errors(t *testing.T) {
+	t.Parallel()
+
+	db := createSeededDatabase(t)
+	s := questionmanagerservice.New(db, passingDBRunner())
+	ctx := context.Background()
+
+	t.Run("create with a missing schema", func(t *testing.T) {
+		question := validQuestion()
+		question.SchemaId = "unknown"
+
+		_, err := s.CreateQuestion(ctx, connect.NewRequest(&questionmanagerv1.CreateQuestionRequest{
+			Question: question,
+		}))
+		assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+	})
+
+	t.Run("update a missing question", func(t *testing.T) {
+		_, err := s.UpdateQuestion(ctx, connect.NewRequest(&questionmanagerv1.UpdateQuestionRequest{
+			Id:       999,
+			Question: validQuestion(),
+		}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("update with a missing schema", func(t *testing.T) {
+		question := validQuestion()
+		question.SchemaId = "unknown"
+
+		_, err := s.UpdateQuestion(ctx, connect.NewRequest(&questionmanagerv1.UpdateQuestionRequest{
+			Id:       1,
+			Question: question,
+		}))
+		assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+	})
+
+	t.Run("delete a missing question", func(t *testing.T) {
+		_, err := s.DeleteQuestion(ctx, connect.NewRequest(&questionmanagerv1.DeleteQuestionRequest{
+			Id: 999,
+		}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("restore a question which is not deleted", func(t *testing.T) {
+		_, err := s.RestoreQuestion(ctx, connect.NewRequest(&questionmanagerv1.RestoreQuestionRequest{
+			Id: 1,
+		}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+}
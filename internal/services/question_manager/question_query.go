@@ -3,31 +3,75 @@ package questionmanagerservice
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"connectrpc.com/connect"
 	questionmanagerv1 "github.com/database-playground/backend/gen/questionmanager/v1"
 	"github.com/database-playground/backend/internal/database"
+	"github.com/database-playground/backend/internal/models"
+	"github.com/samber/lo"
 )
 
+var questionSortFromProto = map[questionmanagerv1.QuestionSort]database.QuestionSort{
+	questionmanagerv1.QuestionSort_QUESTION_SORT_UNSPECIFIED: database.QuestionSortID,
+	questionmanagerv1.QuestionSort_QUESTION_SORT_CREATED_AT:  database.QuestionSortCreatedAt,
+	questionmanagerv1.QuestionSort_QUESTION_SORT_DIFFICULTY:  database.QuestionSortDifficulty,
+	questionmanagerv1.QuestionSort_QUESTION_SORT_TITLE:       database.QuestionSortTitle,
+}
+
 func (s *Service) ListQuestions(ctx context.Context, request *connect.Request[questionmanagerv1.ListQuestionsRequest]) (*connect.Response[questionmanagerv1.ListQuestionsResponse], error) {
-	questions, err := s.db.ListQuestions(ctx, database.ListQuestionsParams{
-		Cursor: database.CursorFromProto(request.Msg.Cursor),
-	})
+	sort, ok := questionSortFromProto[request.Msg.GetSort()]
+	if !ok {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("unknown sort %v", request.Msg.GetSort()))
+	}
+
+	difficulty := s.converter.DifficultyFromProto(request.Msg.GetDifficulty())
+	if difficulty == models.DifficultyUnspecified && request.Msg.GetDifficulty() != questionmanagerv1.Difficulty_DIFFICULTY_UNSPECIFIED {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("unknown difficulty %v", request.Msg.GetDifficulty()))
+	}
+
+	params := database.ListQuestionsParams{
+		Cursor:         database.CursorFromProto(request.Msg.Cursor),
+		IncludeDeleted: request.Msg.GetIncludeDeleted(),
+		Difficulty:     difficulty,
+		Type:           request.Msg.GetType(),
+		SchemaID:       request.Msg.GetSchemaId(),
+		Tags:           request.Msg.GetTags(),
+		Search:         request.Msg.GetSearch(),
+		Sort:           sort,
+		Descending:     request.Msg.GetDescending(),
+	}
+
+	questions, nextCursor, err := s.db.ListQuestions(ctx, params)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
 	if err != nil {
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
+	var totalCount *int64
+	if request.Msg.GetIncludeTotalCount() {
+		count, err := s.db.CountQuestions(ctx, params)
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+		totalCount = &count
+	}
+
 	questionsPb := s.converter.QuestionsToProto(questions)
 
 	return &connect.Response[questionmanagerv1.ListQuestionsResponse]{
 		Msg: &questionmanagerv1.ListQuestionsResponse{
-			Questions: questionsPb,
+			Questions:  questionsPb,
+			NextCursor: lo.EmptyableToPtr(nextCursor),
+			TotalCount: totalCount,
 		},
 	}, nil
 }
 
 func (s *Service) GetQuestion(ctx context.Context, request *connect.Request[questionmanagerv1.GetQuestionRequest]) (*connect.Response[questionmanagerv1.GetQuestionResponse], error) {
-	question, err := s.db.GetQuestion(ctx, request.Msg.GetId())
+	question, err := s.db.GetQuestion(ctx, request.Msg.GetId(), request.Msg.GetIncludeDeleted())
 	if errors.Is(err, database.ErrNotFound) {
 		return nil, connect.NewError(connect.CodeNotFound, err)
 	}
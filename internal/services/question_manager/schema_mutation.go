@@ -0,0 +1,106 @@
+package questionmanagerservice
+
+import (
+	"context"
+	"errors"
+
+	"connectrpc.com/connect"
+	questionmanagerv1 "github.com/database-playground/backend/gen/questionmanager/v1"
+	"github.com/database-playground/backend/internal/database"
+)
+
+func (s *Service) CreateSchema(ctx context.Context, request *connect.Request[questionmanagerv1.CreateSchemaRequest]) (*connect.Response[questionmanagerv1.CreateSchemaResponse], error) {
+	params, err := s.schemaParams(request.Msg.GetId(), request.Msg.GetSchema())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	schema, err := s.db.CreateSchema(ctx, params)
+	if errors.Is(err, database.ErrAlreadyExists) {
+		return nil, connect.NewError(connect.CodeAlreadyExists, err)
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	schemaPb := s.converter.SchemaToProto(schema)
+	return &connect.Response[questionmanagerv1.CreateSchemaResponse]{
+		Msg: &questionmanagerv1.CreateSchemaResponse{
+			Schema: schemaPb,
+		},
+	}, nil
+}
+
+func (s *Service) UpdateSchema(ctx context.Context, request *connect.Request[questionmanagerv1.UpdateSchemaRequest]) (*connect.Response[questionmanagerv1.UpdateSchemaResponse], error) {
+	params, err := s.schemaParams(request.Msg.GetId(), request.Msg.GetSchema())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	schema, err := s.db.UpdateSchema(ctx, params)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	schemaPb := s.converter.SchemaToProto(schema)
+	return &connect.Response[questionmanagerv1.UpdateSchemaResponse]{
+		Msg: &questionmanagerv1.UpdateSchemaResponse{
+			Schema: schemaPb,
+		},
+	}, nil
+}
+
+func (s *Service) DeleteSchema(ctx context.Context, request *connect.Request[questionmanagerv1.DeleteSchemaRequest]) (*connect.Response[questionmanagerv1.DeleteSchemaResponse], error) {
+	err := s.db.DeleteSchema(ctx, request.Msg.GetId())
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	}
+	if errors.Is(err, database.ErrSchemaInUse) {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, err)
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	return &connect.Response[questionmanagerv1.DeleteSchemaResponse]{
+		Msg: &questionmanagerv1.DeleteSchemaResponse{},
+	}, nil
+}
+
+func (s *Service) RestoreSchema(ctx context.Context, request *connect.Request[questionmanagerv1.RestoreSchemaRequest]) (*connect.Response[questionmanagerv1.RestoreSchemaResponse], error) {
+	schema, err := s.db.RestoreSchema(ctx, request.Msg.GetId())
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("deleted schema not found"))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	schemaPb := s.converter.SchemaToProto(schema)
+	return &connect.Response[questionmanagerv1.RestoreSchemaResponse]{
+		Msg: &questionmanagerv1.RestoreSchemaResponse{
+			Schema: schemaPb,
+		},
+	}, nil
+}
+
+// schemaParams validates and converts the schema in the request.
+func (s *Service) schemaParams(id string, input *questionmanagerv1.SchemaInput) (database.SchemaParams, error) {
+	if id == "" {
+		return database.SchemaParams{}, errors.New("id is required")
+	}
+	if input.GetInitialSql() == "" {
+		return database.SchemaParams{}, errors.New("initial_sql is required")
+	}
+
+	return database.SchemaParams{
+		ID:          id,
+		Picture:     input.Picture,
+		Description: input.GetDescription(),
+		InitialSQL:  input.GetInitialSql(),
+		Limits:      s.converter.ExecutionLimitsFromProto(input.GetLimits()),
+	}, nil
+}
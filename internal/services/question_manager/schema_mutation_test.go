@@ -0,0 +1,97 @@
+package questionmanagerservice_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	questionmanagerv1 "github.com/database-playground/backend/gen/questionmanager/v1"
+	questionmanagerservice "github.com/database-playground/backend/internal/services/question_manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaParams(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name          string
+		id            string
+		schema        *questionmanagerv1.SchemaInput
+		expectedError string
+	}{
+		{"id is required", "", &questionmanagerv1.SchemaInput{InitialSql: "CREATE TABLE a (id INT);"}, "id is required"},
+		{"initial_sql is required", "bookstore", &questionmanagerv1.SchemaInput{Description: "A bookstore"}, "initial_sql is required"},
+		{"the schema is required", "bookstore", nil, "initial_sql is required"},
+	}
+
+	// The schemas are validated before the database is used.
+	s := questionmanagerservice.New(nil, nil)
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := s.CreateSchema(context.Background(), connect.NewRequest(&questionmanagerv1.CreateSchemaRequest{
+				Id:     c.id,
+				Schema: c.schema,
+			}))
+			require.Error(t, err)
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			assert.ErrorContains(t, err, c.expectedError)
+
+			_, err = s.UpdateSchema(context.Background(), connect.NewRequest(&questionmanagerv1.UpdateSchemaRequest{
+				Id:     c.id,
+				Schema: c.schema,
+			}))
+			require.Error(t, err)
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			assert.ErrorContains(t, err, c.expectedError)
+		})
+	}
+}
+
+func TestSchemaMutation_Errors(t *testing.T) {
+	t.Parallel()
+
+	db := createSeededDatabase(t)
+	s := questionmanagerservice.New(db, passingDBRunner())
+	ctx := context.Background()
+
+	t.Run("create an existing schema", func(t *testing.T) {
+		_, err := s.CreateSchema(ctx, connect.NewRequest(&questionmanagerv1.CreateSchemaRequest{
+			Id:     "shop",
+			Schema: &questionmanagerv1.SchemaInput{InitialSql: "CREATE TABLE a (id INT);"},
+		}))
+		assert.Equal(t, connect.CodeAlreadyExists, connect.CodeOf(err))
+	})
+
+	t.Run("update a missing schema", func(t *testing.T) {
+		_, err := s.UpdateSchema(ctx, connect.NewRequest(&questionmanagerv1.UpdateSchemaRequest{
+			Id:     "unknown",
+			Schema: &questionmanagerv1.SchemaInput{InitialSql: "CREATE TABLE a (id INT);"},
+		}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("delete a missing schema", func(t *testing.T) {
+		_, err := s.DeleteSchema(ctx, connect.NewRequest(&questionmanagerv1.DeleteSchemaRequest{
+			Id: "unknown",
+		}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("delete a schema in use", func(t *testing.T) {
+		_, err := s.DeleteSchema(ctx, connect.NewRequest(&questionmanagerv1.DeleteSchemaRequest{
+			Id: "shop",
+		}))
+		assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+	})
+
+	t.Run("restore a schema which is not deleted", func(t *testing.T) {
+		_, err := s.RestoreSchema(ctx, connect.NewRequest(&questionmanagerv1.RestoreSchemaRequest{
+			Id: "shop",
+		}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+}
@@ -10,7 +10,7 @@ import (
 )
 
 func (s *Service) GetSchema(ctx context.Context, request *connect.Request[questionmanagerv1.GetSchemaRequest]) (*connect.Response[questionmanagerv1.GetSchemaResponse], error) {
-	schema, err := s.db.GetSchema(ctx, request.Msg.GetId())
+	schema, err := s.db.GetSchema(ctx, request.Msg.GetId(), request.Msg.GetIncludeDeleted())
 	if errors.Is(err, database.ErrNotFound) {
 		return nil, connect.NewError(connect.CodeNotFound, err)
 	}